@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"log/slog"
+	nethttp "net/http"
 
 	//"time"
 
@@ -9,8 +11,13 @@ import (
 	"github.com/huangrao121/CommunicationApp/BackendService/config"
 	"github.com/huangrao121/CommunicationApp/BackendService/config/database"
 	"github.com/huangrao121/CommunicationApp/BackendService/config/logger"
-
-	//"github.com/huangrao121/CommunicationApp/BackendService/internal/user"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/lifecycle"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/middleware"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/ratelimit"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/redisclient"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/tracing"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/user"
+	"github.com/redis/go-redis/v9"
 
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/http"
 )
@@ -25,8 +32,21 @@ func main() {
 	// 这个的配置来自env
 	logger.InitLogger()
 
+	lc := lifecycle.New("api", cfg.Server.ShutdownTimeout)
+
+	// 链路追踪最先注册，最后关闭
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing, "api")
+	if err != nil {
+		slog.Error("failed to init tracing", "error", err)
+		return
+	}
+	lc.OnShutdown("tracing", shutdownTracing)
+
 	// 初始化数据库
 	database.InitDB(cfg)
+	db := database.GetDB(cfg)
+	lc.AddCheck("postgres", lifecycle.PostgresCheck(db))
+	lc.OnShutdown("postgres", lifecycle.PostgresCloser(db))
 
 	slog.Info("BackendService started")
 
@@ -50,6 +70,28 @@ func main() {
 	// }
 	// slog.Info("jwt claims", "claims", claims)
 
-	router := http.InitRouter()
-	router.Run(":8080")
+	// 登录限流、失败锁定和令牌撤销检查依赖Redis，没有Redis时不限制也不检查
+	var limiter *ratelimit.Limiter
+	var redisClient redis.Cmdable
+	if len(cfg.Redis.ClusterAddrs) > 0 {
+		client := redisclient.New(cfg.Redis)
+		redisClient = client
+		lc.AddCheck("redis", lifecycle.RedisCheck(redisClient))
+		lc.OnShutdown("redis", func(ctx context.Context) error {
+			return client.Close()
+		})
+		if !cfg.RateLimit.Disabled {
+			limiter = ratelimit.New(redisClient)
+		}
+	}
+	userHandler := user.NewUserHandler(user.NewUserStore(db), ratelimit.NewLoginGuard(limiter, cfg.RateLimit))
+
+	// 按IP限流
+	auth := middleware.AuthMiddleware(redisClient)
+	router := http.InitRouter(userHandler, limiter.Routes(cfg.RateLimit, ratelimit.ByIP), auth, middleware.RequireAdmin())
+	lc.RegisterRoutes(router)
+	srv := &nethttp.Server{Addr: ":8080", Handler: router}
+	if err := lc.Run(srv); err != nil {
+		slog.Error("api service stopped", "error", err)
+	}
 }
@@ -2,16 +2,35 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/huangrao121/CommunicationApp/BackendService/config"
+	"github.com/huangrao121/CommunicationApp/BackendService/config/logger"
+	"github.com/prometheus/client_golang/prometheus"
 
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/metrics"
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/middleware"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/ratelimit"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/redisclient"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/tracing"
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/gateway/handler"
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/gateway/service"
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/gateway/websocket"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+const (
+	// 收到SIGTERM后等待连接排空的默认时间，Kubernetes默认的terminationGracePeriodSeconds是30秒
+	defaultShutdownTimeout = 25 * time.Second
+	defaultConsumerGroup   = "gateway-message-updates"
 )
 
 func main() {
@@ -20,42 +39,140 @@ func main() {
 	if err != nil {
 		log.Fatal("Failed to load config:", err)
 	}
+	logger.InitLogger()
 
-	// Gateway服务（与Message Service通信）
-	gatewayService := service.NewGatewayService("http://message-service:8002")
+	// 链路追踪，关闭时导出剩余的span
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing, "gateway")
+	if err != nil {
+		logger.Fatal("failed to init tracing", "error", err)
+	}
+
+	// Message Service的gRPC客户端，使用redis:///发现实例时需要Redis
+	discoveryRedis := redisclient.New(cfg.Redis)
+	defer discoveryRedis.Close()
+	messageClient, err := service.NewMessageGRPCClient(cfg.GRPC, discoveryRedis)
+	if err != nil {
+		logger.Fatal("failed to create message service client", "error", err)
+	}
+	defer messageClient.Close()
 
 	// WebSocket Hub
-	hub := websocket.NewHub(gatewayService, cfg)
+	hub := websocket.NewHub(messageClient, cfg)
+	if err := hub.RegisterMetrics(prometheus.DefaultRegisterer); err != nil {
+		logger.Fatal("failed to register hub metrics", "error", err)
+	}
 
-	// 启动Hub
-	ctx := context.Background()
-	go hub.Run(ctx)
+	// 启动Hub，先加入集群再接受连接
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	if err := hub.JoinCluster(hubCtx); err != nil {
+		logger.Fatal("failed to join gateway cluster", "error", err)
+	}
+	hubDone := make(chan struct{})
+	go func() {
+		hub.Run(hubCtx)
+		close(hubDone)
+	}()
+
+	// 消费编辑/撤回事件，跟随Hub一起停止
+	groupID := cfg.Gateway.ConsumerGroup
+	if groupID == "" {
+		groupID = defaultConsumerGroup
+	}
+	var topics []string
+	for _, name := range []string{"p2p_message", "group_message"} {
+		if topic, ok := cfg.Kafka.Topic[name]; ok {
+			topics = append(topics, topic)
+		}
+	}
+	go hub.ConsumeMessageUpdates(hubCtx, cfg.Kafka.Brokers, topics, groupID)
 
 	// 处理器
-	gatewayHandler := handler.NewGatewayHandler(hub)
+	gatewayHandler := handler.NewGatewayHandler(hub, messageClient)
 
 	// 设置路由
-	r := gin.Default()
+	r := gin.New()
 
 	// 中间件
 	r.Use(middleware.CORS())
-	r.Use(gin.Logger())
 	r.Use(gin.Recovery())
+	r.Use(metrics.GinMiddleware("gateway"))
+	r.Use(otelgin.Middleware("gateway"))
+	r.Use(logger.GinMiddleware())
+
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	// 认证时检查token是否已撤销
+	auth := middleware.AuthMiddleware(discoveryRedis)
+	requireAdmin := middleware.RequireAdmin()
+	logger.RegisterRoutes(r, auth, requireAdmin)
+
+	// 按路由限流，放在认证之后按用户计数，未认证的请求按IP计数
+	var limiter *ratelimit.Limiter
+	if !cfg.RateLimit.Disabled {
+		limiter = ratelimit.New(discoveryRedis)
+	}
+	apiLimit := limiter.Routes(cfg.RateLimit, ratelimit.ByUser)
 
-	// WebSocket路由
-	r.GET("/ws", middleware.AuthMiddleware(), gatewayHandler.HandleWebSocket)
+	// WebSocket路由，握手在认证之前按IP限流，防止暴力尝试ticket
+	r.GET("/ws", apiLimit, middleware.WebSocketAuth(hub, discoveryRedis), gatewayHandler.HandleWebSocket)
 
 	// API路由
 	api := r.Group("/api/v1")
 	{
-		api.GET("/online-users", gatewayHandler.GetOnlineUsers)
+		// 在线用户、节点统计和集群拓扑只对管理员开放
+		api.GET("/online-users", auth, requireAdmin, gatewayHandler.GetOnlineUsers)
+		api.GET("/stats", auth, requireAdmin, gatewayHandler.GetStats)
+		api.GET("/cluster/nodes", auth, requireAdmin, gatewayHandler.GetClusterNodes)
+		api.GET("/protocol", gatewayHandler.GetProtocolSchema)
+		api.PATCH("/conversations/:conversation_id/settings", auth, apiLimit, gatewayHandler.UpdateConversationSettings)
+		api.PUT("/conversations/pins", auth, apiLimit, gatewayHandler.ReorderPinnedConversations)
+		api.POST("/ws-ticket", auth, apiLimit, gatewayHandler.CreateTicket)
+		api.POST("/logout", auth, apiLimit, gatewayHandler.Logout)
+		api.GET("/sessions", auth, apiLimit, gatewayHandler.ListSessions)
+		api.DELETE("/sessions/:device_id", auth, apiLimit, gatewayHandler.DisconnectSession)
 		api.GET("/health", func(c *gin.Context) {
 			c.JSON(200, gin.H{"status": "ok"})
 		})
 	}
 
-	log.Printf("Gateway service starting on port %d", cfg.Server.Port)
-	if err := r.Run(fmt.Sprintf(":%d", cfg.Server.Port)); err != nil {
-		log.Fatal("Failed to start server:", err)
+	srv := &http.Server{
+		Addr:    fmt.Sprintf(":%d", cfg.Server.Port),
+		Handler: r,
+	}
+	go func() {
+		slog.Info("gateway service starting", "port", cfg.Server.Port)
+		if err := srv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger.Fatal("failed to start server", "error", err)
+		}
+	}()
+
+	// 等待SIGINT/SIGTERM
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	<-ctx.Done()
+	stop()
+
+	shutdownTimeout := cfg.Gateway.ShutdownTimeout
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = defaultShutdownTimeout
+	}
+	slog.Info("gateway service shutting down", "timeout", shutdownTimeout)
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
+
+	// 1. 拒绝新的WebSocket连接，通知客户端重连并等待连接排空。
+	// WebSocket连接已经被劫持，http.Server.Shutdown不会等待它们
+	if err := hub.Drain(shutdownCtx); err != nil {
+		slog.Error("failed to drain connections", "error", err)
+	}
+	// 2. 关闭监听并等待进行中的HTTP请求
+	if err := srv.Shutdown(shutdownCtx); err != nil {
+		slog.Error("failed to shut down server", "error", err)
+	}
+	// 3. 停止心跳和订阅，删除本节点剩余的会话记录并退出集群
+	stopHub()
+	<-hubDone
+	// 4. 导出剩余的span
+	if err := shutdownTracing(shutdownCtx); err != nil {
+		slog.Error("failed to shut down tracing", "error", err)
 	}
+	slog.Info("gateway service stopped")
 }
@@ -0,0 +1,262 @@
+// hubbench 对运行中的Gateway做WebSocket压测：建立大量连接，每个连接按固定间隔发送消息，
+// 统计吞吐和发送确认(message_sent)的往返延迟。这是可选的外部压测工具，需要真实的Gateway、
+// Redis和Message Service；进程内的扇出和编解码开销用benchmark测量：
+//
+//	go test -run xxx -bench . ./internal/gateway/websocket
+//
+// 单机压测5万连接需要调大文件描述符和本地端口范围，例如
+//
+//	ulimit -n 200000
+//	sysctl -w net.ipv4.ip_local_port_range="1024 65535"
+//
+// 用法：
+//
+//	go run ./cmd/hubbench -url ws://localhost:8080/ws -tokens tokens.txt -conns 50000 \
+//		-mode p2p -receiver <user_id> -interval 5s -duration 2m
+//
+// tokens文件每行一个JWT，连接按顺序轮流使用，同一token的多个连接会作为同一用户的不同设备。
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type stats struct {
+	connected   atomic.Int64
+	connectFail atomic.Int64
+	sent        atomic.Int64
+	acked       atomic.Int64
+	failed      atomic.Int64
+	timeouts    atomic.Int64
+
+	mu        sync.Mutex
+	latencies []time.Duration
+}
+
+func (s *stats) recordLatency(d time.Duration) {
+	s.mu.Lock()
+	s.latencies = append(s.latencies, d)
+	s.mu.Unlock()
+}
+
+// percentiles 返回并清空当前统计周期的延迟
+func (s *stats) percentiles() (p50, p99, max time.Duration, n int) {
+	s.mu.Lock()
+	latencies := s.latencies
+	s.latencies = nil
+	s.mu.Unlock()
+
+	if len(latencies) == 0 {
+		return 0, 0, 0, 0
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	return latencies[len(latencies)/2], latencies[len(latencies)*99/100], latencies[len(latencies)-1], len(latencies)
+}
+
+func main() {
+	url := flag.String("url", "ws://localhost:8080/ws", "gateway websocket url")
+	tokensPath := flag.String("tokens", "tokens.txt", "file with one JWT per line")
+	conns := flag.Int("conns", 50000, "number of connections")
+	connectRate := flag.Int("connect-rate", 1000, "new connections per second")
+	mode := flag.String("mode", "p2p", "p2p: send_p2p_message and wait for message_sent; typing: fire-and-forget typing events")
+	receiver := flag.String("receiver", "", "receiver user id")
+	interval := flag.Duration("interval", 5*time.Second, "per-connection send interval")
+	ackTimeout := flag.Duration("ack-timeout", 10*time.Second, "max wait for message_sent")
+	duration := flag.Duration("duration", time.Minute, "test duration after all connections are up")
+	flag.Parse()
+
+	if *receiver == "" {
+		log.Fatal("-receiver is required")
+	}
+	tokens, err := readTokens(*tokensPath)
+	if err != nil {
+		log.Fatal("failed to read tokens: ", err)
+	}
+
+	payload, err := buildPayload(*mode, *receiver)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	st := &stats{}
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+
+	// 1. 按connect-rate逐步建立连接
+	start := time.Now()
+	ticker := time.NewTicker(time.Second / time.Duration(*connectRate))
+	for i := 0; i < *conns; i++ {
+		<-ticker.C
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			runConnection(i, *url, tokens[i%len(tokens)], *mode, payload, *interval, *ackTimeout, st, stop)
+		}(i)
+	}
+	ticker.Stop()
+	log.Printf("ramp-up finished in %s: %d connected, %d failed", time.Since(start).Round(time.Millisecond), st.connected.Load(), st.connectFail.Load())
+
+	// 2. 稳定运行，周期性输出统计
+	report := time.NewTicker(5 * time.Second)
+	deadline := time.After(*duration)
+	var lastSent, lastAcked int64
+	runStart := time.Now()
+	for running := true; running; {
+		select {
+		case <-report.C:
+			sent, acked := st.sent.Load(), st.acked.Load()
+			p50, p99, max, _ := st.percentiles()
+			log.Printf("conns=%d sent=%d/s acked=%d/s failed=%d timeouts=%d p50=%s p99=%s max=%s",
+				st.connected.Load(), (sent-lastSent)/5, (acked-lastAcked)/5, st.failed.Load(), st.timeouts.Load(),
+				p50.Round(time.Microsecond), p99.Round(time.Microsecond), max.Round(time.Microsecond))
+			lastSent, lastAcked = sent, acked
+		case <-deadline:
+			running = false
+		}
+	}
+	report.Stop()
+	close(stop)
+	wg.Wait()
+
+	elapsed := time.Since(runStart).Seconds()
+	fmt.Printf("connections: %d ok, %d failed\n", st.connected.Load(), st.connectFail.Load())
+	fmt.Printf("messages: %d sent (%.0f/s), %d acked (%.0f/s), %d errors, %d timeouts\n",
+		st.sent.Load(), float64(st.sent.Load())/elapsed, st.acked.Load(), float64(st.acked.Load())/elapsed,
+		st.failed.Load(), st.timeouts.Load())
+}
+
+func readTokens(path string) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var tokens []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		if line := strings.TrimSpace(scanner.Text()); line != "" {
+			tokens = append(tokens, line)
+		}
+	}
+	if len(tokens) == 0 {
+		return nil, fmt.Errorf("no tokens in %s", path)
+	}
+	return tokens, scanner.Err()
+}
+
+func buildPayload(mode, receiver string) ([]byte, error) {
+	switch mode {
+	case "p2p":
+		return json.Marshal(map[string]interface{}{
+			"type": "send_p2p_message",
+			"data": map[string]interface{}{
+				"receiver_id":  receiver,
+				"content":      "hubbench",
+				"content_type": 0,
+			},
+		})
+	case "typing":
+		return json.Marshal(map[string]interface{}{
+			"type": "typing",
+			"data": map[string]interface{}{
+				"receiver_id": receiver,
+				"is_typing":   true,
+			},
+		})
+	default:
+		return nil, fmt.Errorf("unknown mode %q", mode)
+	}
+}
+
+// runConnection 建立一个连接并按间隔发送消息。p2p模式下每条消息等待确认后再发下一条(闭环)，
+// 往返时间即发送确认延迟
+func runConnection(i int, url, token, mode string, payload []byte, interval, ackTimeout time.Duration, st *stats, stop <-chan struct{}) {
+	header := http.Header{}
+	header.Set("Authorization", "Bearer "+token)
+	conn, _, err := websocket.DefaultDialer.Dial(fmt.Sprintf("%s?device_id=hubbench-%d", url, i), header)
+	if err != nil {
+		st.connectFail.Add(1)
+		return
+	}
+	defer conn.Close()
+	st.connected.Add(1)
+	defer st.connected.Add(-1)
+
+	// 读循环只关心发送确认和错误，其余推送直接丢弃
+	acks := make(chan bool, 1)
+	go func() {
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				close(acks)
+				return
+			}
+			var msg struct {
+				Type string `json:"type"`
+			}
+			if json.Unmarshal(data, &msg) != nil {
+				continue
+			}
+			if msg.Type != "message_sent" && msg.Type != "error" {
+				continue
+			}
+			// typing模式没有人读确认，不能阻塞读循环
+			select {
+			case acks <- msg.Type == "message_sent":
+			default:
+			}
+		}
+	}()
+
+	// 按连接序号错开第一次发送，避免所有连接同时发送
+	time.Sleep(time.Duration(i%1000) * interval / 1000)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		sentAt := time.Now()
+		if err := conn.WriteMessage(websocket.TextMessage, payload); err != nil {
+			return
+		}
+		st.sent.Add(1)
+		if mode != "p2p" {
+			continue
+		}
+
+		select {
+		case ok, open := <-acks:
+			if !open {
+				return
+			}
+			if ok {
+				st.acked.Add(1)
+				st.recordLatency(time.Since(sentAt))
+			} else {
+				st.failed.Add(1)
+			}
+		case <-time.After(ackTimeout):
+			st.timeouts.Add(1)
+		case <-stop:
+			return
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/metrics"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/pb/messagepb"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/health"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/keepalive"
+)
+
+const defaultGRPCListenAddr = ":9081"
+
+// InitializeGRPCServer 返回的health server用于关闭时把实例标记为NOT_SERVING
+func InitializeGRPCServer(handlerInit *HandlerInit) (*grpc.Server, *health.Server) {
+	s := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(metrics.UnaryServerInterceptor()),
+		grpc.StatsHandler(otelgrpc.NewServerHandler(otelgrpc.WithFilter(filters.Not(filters.HealthCheck())))),
+		// 允许Gateway在空闲连接上发送keepalive ping，默认策略会把30s一次的ping当作滥用并断开连接
+		grpc.KeepaliveEnforcementPolicy(keepalive.EnforcementPolicy{
+			MinTime:             20 * time.Second,
+			PermitWithoutStream: true,
+		}),
+	)
+	messagepb.RegisterMessageServiceServer(s, handlerInit.messageGRPCServer)
+
+	// Gateway定期检查实例健康状态，NOT_SERVING的实例不再分配请求
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus(messagepb.MessageService_ServiceDesc.ServiceName, grpc_health_v1.HealthCheckResponse_SERVING)
+	grpc_health_v1.RegisterHealthServer(s, healthServer)
+	return s, healthServer
+}
+
+// stopGRPCServer 先标记为NOT_SERVING让Gateway不再分配请求，再等待进行中的调用完成，ctx到期时强制关闭
+func stopGRPCServer(ctx context.Context, s *grpc.Server, healthServer *health.Server) error {
+	healthServer.Shutdown()
+	done := make(chan struct{})
+	go func() {
+		s.GracefulStop()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		s.Stop()
+		return ctx.Err()
+	}
+}
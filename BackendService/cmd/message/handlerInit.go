@@ -3,11 +3,15 @@ package main
 import "github.com/huangrao121/CommunicationApp/BackendService/internal/message/handler"
 
 type HandlerInit struct {
-	messageHandler *handler.MessageHandler
+	messageHandler    *handler.MessageHandler
+	adminHandler      *handler.AdminHandler
+	messageGRPCServer *handler.MessageGRPCServer
 }
 
-func NewHandlerInit(messageHandler *handler.MessageHandler) *HandlerInit {
+func NewHandlerInit(messageHandler *handler.MessageHandler, adminHandler *handler.AdminHandler, messageGRPCServer *handler.MessageGRPCServer) *HandlerInit {
 	return &HandlerInit{
-		messageHandler: messageHandler,
+		messageHandler:    messageHandler,
+		adminHandler:      adminHandler,
+		messageGRPCServer: messageGRPCServer,
 	}
 }
@@ -1,37 +1,137 @@
 package main
 
 import (
+	"context"
 	"log"
+	"net"
+	"net/http"
 
 	"github.com/huangrao121/CommunicationApp/BackendService/config"
 	"github.com/huangrao121/CommunicationApp/BackendService/config/database"
 	"github.com/huangrao121/CommunicationApp/BackendService/config/logger"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/discovery"
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/kafka"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/lifecycle"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/middleware"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/ratelimit"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/redisclient"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/tracing"
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/message/handler"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/message/moderation"
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/message/service"
+	"github.com/redis/go-redis/v9"
 )
 
 func main() {
 	cfg, err := config.LoadConfig("../../")
 	if err != nil {
-		log.Fatal("failed to load config", "error", err)
+		log.Fatal("failed to load config: ", err)
 		return
 	}
 	logger.InitLogger()
 
+	// 就绪检查和关闭顺序，依赖按创建顺序注册，关闭时倒序执行
+	lc := lifecycle.New("message", cfg.Server.ShutdownTimeout)
+
+	// 链路追踪最先注册，最后关闭，其它依赖关闭时产生的span也能导出
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing, "message")
+	if err != nil {
+		logger.Fatal("failed to init tracing", "error", err)
+	}
+	lc.OnShutdown("tracing", shutdownTracing)
+
 	// 初始化db
 	database.InitDB(cfg)
+	db := database.GetDB(cfg)
+	lc.AddCheck("postgres", lifecycle.PostgresCheck(db))
+	lc.OnShutdown("postgres", lifecycle.PostgresCloser(db))
 
-	// 初始化Kafka producer
+	// 初始化Kafka producer，关闭时写完缓冲区里的消息
 	kafkaProducer := kafka.NewProducer(cfg.Kafka.Brokers, cfg.Kafka.Topic)
-	// message service
-	db := database.GetDB(cfg)
-	messageService := service.NewMessageService(db, kafkaProducer)
+	lc.AddCheck("kafka", lifecycle.KafkaCheck(cfg.Kafka.Brokers))
+	lc.OnShutdown("kafka", func(ctx context.Context) error {
+		return kafkaProducer.Close()
+	})
+
+	// Redis用于实例注册、REST API限流和审核的发送频率统计，没有Redis时REST API不限流，发送频率只在本实例内统计
+	var redisClient redis.Cmdable
+	if len(cfg.Redis.ClusterAddrs) > 0 {
+		client := redisclient.New(cfg.Redis)
+		redisClient = client
+		lc.AddCheck("redis", lifecycle.RedisCheck(redisClient))
+		lc.OnShutdown("redis", func(ctx context.Context) error {
+			return client.Close()
+		})
+	}
+
+	// message service，消息落库前经过内容审核
+	moderator := moderation.NewPipeline(cfg.Moderation, redisClient)
+	messageService := service.NewMessageService(db, kafkaProducer, cfg.Message, moderator)
 	messageHandler := handler.NewMessageHandler(messageService)
-	handlerInit := NewHandlerInit(messageHandler)
+	messageGRPCServer := handler.NewMessageGRPCServer(messageService)
+	// 封禁用户时通过Redis通知所有网关断开连接
+	adminService := service.NewAdminService(messageService, redisClient)
+	adminHandler := handler.NewAdminHandler(adminService)
+	handlerInit := NewHandlerInit(messageHandler, adminHandler, messageGRPCServer)
 
-	// 初始化gin http
-	router := InitializeRouter(handlerInit)
-	router.Run(":8081")
+	// 初始化gRPC server，Gateway通过gRPC调用，REST API保留给其他客户端
+	grpcAddr := cfg.GRPC.MessageListenAddr
+	if grpcAddr == "" {
+		grpcAddr = defaultGRPCListenAddr
+	}
+	lis, err := net.Listen("tcp", grpcAddr)
+	if err != nil {
+		logger.Fatal("failed to listen", "addr", grpcAddr, "error", err)
+	}
+	grpcServer, healthServer := InitializeGRPCServer(handlerInit)
+	go func() {
+		if err := grpcServer.Serve(lis); err != nil {
+			logger.Fatal("grpc server stopped", "error", err)
+		}
+	}()
+	lc.OnShutdown("grpc", func(ctx context.Context) error {
+		return stopGRPCServer(ctx, grpcServer, healthServer)
+	})
+
+	// 注册到Redis，Gateway使用redis:///发现实例时才会用到
+	var limiter *ratelimit.Limiter
+	if redisClient != nil {
+		advertiseAddr := cfg.GRPC.MessageAdvertiseAddr
+		if advertiseAddr == "" {
+			if advertiseAddr, err = discovery.AdvertiseAddr(lis.Addr().String()); err != nil {
+				logger.Fatal("failed to determine advertise address", "error", err)
+			}
+		}
+		if !cfg.RateLimit.Disabled {
+			limiter = ratelimit.New(redisClient)
+		}
 
+		// 关闭时最先注销，Gateway下次解析时不再连接这个实例
+		registerCtx, unregister := context.WithCancel(context.Background())
+		unregistered := make(chan struct{})
+		go func() {
+			discovery.Register(registerCtx, redisClient, "message", advertiseAddr, discovery.DefaultHeartbeatTTL)
+			close(unregistered)
+		}()
+		lc.OnShutdown("discovery", func(ctx context.Context) error {
+			unregister()
+			select {
+			case <-unregistered:
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		})
+	}
+
+	// 初始化gin http
+	// REST API按IP限流；管理员接口还要求token未被撤销，并且数据库中的角色仍是管理员
+	auth := middleware.AuthMiddleware(redisClient)
+	requireAdmin := middleware.RequireAdmin(adminService.VerifyAdmin)
+	router := InitializeRouter(handlerInit, limiter.Routes(cfg.RateLimit, ratelimit.ByIP), auth, requireAdmin)
+	lc.RegisterRoutes(router)
+	srv := &http.Server{Addr: ":8081", Handler: router}
+	if err := lc.Run(srv); err != nil {
+		logger.Fatal("message service stopped", "error", err)
+	}
 }
@@ -4,11 +4,14 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/huangrao121/CommunicationApp/BackendService/config/logger"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/metrics"
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/middleware"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
-func InitializeRouter(handlerInit *HandlerInit) *gin.Engine {
-	r := gin.Default()
+func InitializeRouter(handlerInit *HandlerInit, rateLimit gin.HandlerFunc, auth gin.HandlerFunc, requireAdmin gin.HandlerFunc) *gin.Engine {
+	r := gin.New()
 
 	r.Use(middleware.SecureHeaders())
 
@@ -16,6 +19,9 @@ func InitializeRouter(handlerInit *HandlerInit) *gin.Engine {
 		r.Use(middleware.CORS())
 	}
 	r.Use(gin.Recovery())
+	r.Use(metrics.GinMiddleware("message"))
+	r.Use(otelgin.Middleware("message"))
+	r.Use(logger.GinMiddleware())
 
 	// 设置信任代理，如果在生产环境中，使用负载均衡和反向代理
 	// if gin.Mode() == gin.ReleaseMode {
@@ -29,6 +35,53 @@ func InitializeRouter(handlerInit *HandlerInit) *gin.Engine {
 		})
 	})
 
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	logger.RegisterRoutes(r, auth, requireAdmin)
+
+	// 所有接口都需要登录，发送者和查看者取自token，不信任body、路径和查询参数里的用户ID
+	h := handlerInit.messageHandler
+	api := r.Group("/api/v1", rateLimit, auth)
+	{
+		api.POST("/messages/p2p", h.SendP2PMessage)
+		api.POST("/messages/group", h.SendGroupMessage)
+		api.PUT("/messages/:chat_type/:message_id", h.EditMessage)
+		api.POST("/messages/:chat_type/:message_id/recall", h.RecallMessage)
+		api.DELETE("/messages/:chat_type/:message_id", h.DeleteMessage)
+		api.GET("/messages/:chat_type/:message_id/history", h.GetMessageHistory)
+		api.GET("/messages/search", h.SearchMessages)
+		api.POST("/messages/:chat_type/:message_id/reactions", h.UpdateReaction)
+		api.DELETE("/messages/:chat_type/:message_id/reactions", h.UpdateReaction)
+
+		api.GET("/p2p/:peer_id/messages", h.GetP2PMessages)
+		api.GET("/groups/:group_id/messages", h.GetGroupMessages)
+		api.GET("/groups/:group_id/messages/:message_id/thread", h.GetGroupThread)
+		api.GET("/groups/:group_id/members", h.GetGroupMembers)
+		api.GET("/conversations", h.GetUserConversations)
+		api.POST("/conversations/:conversation_id/read", h.MarkAsRead)
+		api.PATCH("/conversations/:conversation_id/settings", h.UpdateConversationSettings)
+		api.PUT("/conversations/pins", h.ReorderPinnedConversations)
+
+		// 举报者取自token
+		reports := api.Group("/reports")
+		reports.POST("/users/:user_id", h.ReportUser)
+		reports.POST("/messages/:chat_type/:message_id", h.ReportMessage)
+	}
+
+	// 管理员接口，所有操作写入审计日志
+	ah := handlerInit.adminHandler
+	admin := r.Group("/api/v1/admin", rateLimit, auth, requireAdmin)
+	{
+		admin.GET("/reports", ah.ListReports)
+		admin.POST("/reports/:report_id/resolve", ah.ResolveReport)
+		admin.POST("/users/:user_id/suspend", ah.SuspendUser)
+		admin.POST("/users/:user_id/ban", ah.BanUser)
+		admin.POST("/users/:user_id/reinstate", ah.ReinstateUser)
+		admin.DELETE("/messages/:chat_type/:message_id", ah.DeleteMessage)
+		admin.GET("/audit-logs", ah.ListAuditLogs)
+		admin.GET("/moderation/records", ah.ListModerationRecords)
+		admin.POST("/moderation/records/:record_id/review", ah.ReviewModeration)
+	}
+
 	return r
 
 }
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"log"
+	"log/slog"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huangrao121/CommunicationApp/BackendService/config"
+	"github.com/huangrao121/CommunicationApp/BackendService/config/database"
+	"github.com/huangrao121/CommunicationApp/BackendService/config/logger"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/metrics"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/middleware"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/ratelimit"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/redisclient"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/tracing"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/notification"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
+
+const defaultConsumerGroup = "notification-worker"
+
+func main() {
+	cfg, err := config.LoadConfig("../../")
+	if err != nil {
+		log.Fatal("failed to load config: ", err)
+		return
+	}
+	logger.InitLogger()
+
+	// 推送worker的span接在消息发送方的trace后面
+	shutdownTracing, err := tracing.Init(context.Background(), cfg.Tracing, "notification")
+	if err != nil {
+		logger.Fatal("failed to init tracing", "error", err)
+	}
+	defer shutdownTracing(context.Background())
+
+	// 初始化db
+	database.InitDB(cfg)
+	db := database.GetDB(cfg)
+
+	// 在线状态和频率限制都依赖Redis
+	redisClient := redisclient.New(cfg.Redis)
+	defer redisClient.Close()
+	limiter := notification.NewRedisRateLimiter(redisClient, cfg.Notification.RateLimit, cfg.Notification.RateWindow)
+	deviceStore := notification.NewDeviceStore(db)
+
+	worker := notification.NewWorker(db, notification.NewRedisPresence(redisClient), limiter, deviceStore, initProviders(cfg.Notification)...)
+
+	groupID := cfg.Notification.ConsumerGroup
+	if groupID == "" {
+		groupID = defaultConsumerGroup
+	}
+	var topics []string
+	for _, name := range []string{"p2p_message", "group_message"} {
+		if topic, ok := cfg.Kafka.Topic[name]; ok {
+			topics = append(topics, topic)
+		}
+	}
+	go worker.Run(context.Background(), cfg.Kafka.Brokers, topics, groupID)
+
+	// 设备注册接口
+	deviceHandler := notification.NewDeviceHandler(deviceStore)
+	r := gin.New()
+	r.Use(middleware.SecureHeaders())
+	r.Use(gin.Recovery())
+	r.Use(metrics.GinMiddleware("notification"))
+	r.Use(otelgin.Middleware("notification"))
+	r.Use(logger.GinMiddleware())
+
+	r.GET("/metrics", gin.WrapH(metrics.Handler()))
+	auth := middleware.AuthMiddleware(redisClient)
+	logger.RegisterRoutes(r, auth, middleware.RequireAdmin())
+
+	var apiLimiter *ratelimit.Limiter
+	if !cfg.RateLimit.Disabled {
+		apiLimiter = ratelimit.New(redisClient)
+	}
+	api := r.Group("/api/v1", auth, apiLimiter.Routes(cfg.RateLimit, ratelimit.ByUser))
+	{
+		api.GET("/devices", deviceHandler.ListDevices)
+		api.POST("/devices", deviceHandler.RegisterDevice)
+		api.DELETE("/devices/:device_id", deviceHandler.UnregisterDevice)
+	}
+	r.Run(":8083")
+}
+
+// initProviders 只启用配置了凭证的推送平台
+func initProviders(cfg config.NotificationConfig) []notification.Provider {
+	var providers []notification.Provider
+
+	if cfg.APNs.KeyPath != "" {
+		p, err := notification.NewAPNsProvider(cfg.APNs)
+		if err != nil {
+			slog.Error("failed to init apns provider", "error", err)
+		} else {
+			providers = append(providers, p)
+		}
+	}
+	if cfg.FCM.CredentialsPath != "" {
+		p, err := notification.NewFCMProvider(cfg.FCM)
+		if err != nil {
+			slog.Error("failed to init fcm provider", "error", err)
+		} else {
+			providers = append(providers, p)
+		}
+	}
+	if cfg.WebPush.VAPIDPrivateKey != "" {
+		p, err := notification.NewWebPushProvider(cfg.WebPush)
+		if err != nil {
+			slog.Error("failed to init web push provider", "error", err)
+		} else {
+			providers = append(providers, p)
+		}
+	}
+
+	if len(providers) == 0 {
+		slog.Warn("no push provider configured, notifications will be dropped")
+	}
+	return providers
+}
@@ -11,10 +11,17 @@ import (
 )
 
 type Config struct {
-	Server   ServerConfig   `yaml:"server"`
-	Database DatabaseConfig `yaml:"database"`
-	Redis    RedisConfig    `yaml:"redis"`
-	Kafka    KafkaConfig    `yaml:"kafka"`
+	Server       ServerConfig       `yaml:"server"`
+	Database     DatabaseConfig     `yaml:"database"`
+	Redis        RedisConfig        `yaml:"redis"`
+	Kafka        KafkaConfig        `yaml:"kafka"`
+	Message      MessageConfig      `yaml:"message"`
+	Notification NotificationConfig `yaml:"notification"`
+	Gateway      GatewayConfig      `yaml:"gateway"`
+	GRPC         GRPCConfig         `yaml:"grpc"`
+	Tracing      TracingConfig      `yaml:"tracing"`
+	RateLimit    RateLimitConfig    `yaml:"rateLimit"`
+	Moderation   ModerationConfig   `yaml:"moderation"`
 }
 
 type ServerConfig struct {
@@ -22,6 +29,8 @@ type ServerConfig struct {
 	Port        int           `yaml:"port"`
 	ReadTimeout time.Duration `yaml:"readTimeout"`
 	Environment string        `yaml:"environment"`
+	// 收到SIGTERM后等待进行中的请求完成和依赖关闭的最长时间，<=0时使用默认值
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
 }
 
 type DatabaseConfig struct {
@@ -48,6 +57,200 @@ type KafkaConfig struct {
 	Topic   map[string]string `yaml:"topic"`
 }
 
+// MessageConfig 消息相关的业务配置
+type MessageConfig struct {
+	// 发送后允许编辑的时间窗口，<=0时使用默认值
+	EditWindow time.Duration `yaml:"editWindow"`
+	// 发送后允许撤回的时间窗口，<=0时使用默认值
+	RecallWindow time.Duration `yaml:"recallWindow"`
+}
+
+// GatewayConfig WebSocket网关配置
+type GatewayConfig struct {
+	// 节点ID，用于跨节点路由，集群内必须唯一。为空时使用主机名
+	NodeID string `yaml:"nodeID"`
+	// 超过这个时间没有心跳的节点视为下线，其上的会话记录会被其它节点清理，<=0时使用默认值
+	NodeTTL time.Duration `yaml:"nodeTTL"`
+	// 群成员列表在Redis中的缓存时间，<=0时使用默认值
+	GroupMemberCacheTTL time.Duration `yaml:"groupMemberCacheTTL"`
+	// 消费编辑/撤回事件的Kafka消费者组，所有网关节点共享，为空时使用默认值
+	ConsumerGroup string `yaml:"consumerGroup"`
+	// 收到SIGTERM后等待连接排空和HTTP请求完成的最长时间，<=0时使用默认值
+	ShutdownTimeout time.Duration `yaml:"shutdownTimeout"`
+	// 关闭前通知客户端重连，每个客户端在0到该时间之间随机等待后重连，避免同时涌向其它节点，<=0时使用默认值
+	ReconnectJitter time.Duration `yaml:"reconnectJitter"`
+
+	// 单个上行请求的处理时限(含Message Service调用)，<=0时使用默认值。
+	// 同一用户的请求在同一个worker上按顺序处理，慢调用会阻塞分到这个worker的其他用户，时限决定了最长阻塞时间
+	RequestTimeout time.Duration `yaml:"requestTimeout"`
+
+	// 每个连接发送缓冲区能容纳的消息条数，<=0时使用默认值
+	SendBufferSize int `yaml:"sendBufferSize"`
+	// 发送缓冲区满时的处理策略：disconnect(默认)断开连接，drop_oldest丢弃最旧的一条消息
+	SlowConsumerPolicy string `yaml:"slowConsumerPolicy"`
+
+	// 允许建立WebSocket连接的Origin，如https://app.example.com，"*"表示不限制。
+	// 为空时只允许与Host同源的浏览器请求；没有Origin头的原生客户端不受影响
+	AllowedOrigins []string `yaml:"allowedOrigins"`
+	// 浏览器连接票据的有效期，票据只能使用一次
+	TicketTTL time.Duration `yaml:"ticketTTL"`
+
+	// 单条上行消息的最大字节数，超过时回复错误帧并丢弃该消息，连接保持
+	MaxMessageSize int64 `yaml:"maxMessageSize"`
+	// 单条上行消息的硬上限，超过时以1009关闭连接，防止客户端持续发送超大帧
+	MaxFrameSize int64 `yaml:"maxFrameSize"`
+	// 按消息类型的大小限制，如typing: 256，未配置的类型只受MaxMessageSize限制
+	MessageTypeLimits map[string]int64 `yaml:"messageTypeLimits"`
+
+	// 等待客户端pong的超时时间
+	PongWait time.Duration `yaml:"pongWait"`
+	// ping间隔，必须小于PongWait，默认为PongWait的9/10
+	PingPeriod time.Duration `yaml:"pingPeriod"`
+	// 单次写入的超时时间
+	WriteWait        time.Duration `yaml:"writeWait"`
+	HandshakeTimeout time.Duration `yaml:"handshakeTimeout"`
+
+	// 是否协商permessage-deflate压缩
+	EnableCompression bool `yaml:"enableCompression"`
+	// 压缩级别(1-9)，0时使用1
+	CompressionLevel int `yaml:"compressionLevel"`
+	// 小于该字节数的下行消息不压缩
+	CompressionThreshold int `yaml:"compressionThreshold"`
+}
+
+// GRPCConfig 服务间gRPC通信配置
+type GRPCConfig struct {
+	// Message Service的gRPC监听地址，为空时使用:9081
+	MessageListenAddr string `yaml:"messageListenAddr"`
+	// 注册到Redis的地址，为空时由监听地址和主机名推导；没有配置Redis时不注册
+	MessageAdvertiseAddr string `yaml:"messageAdvertiseAddr"`
+	// Gateway发现Message Service实例的方式，为空时使用dns:///message-service:9081：
+	// static:///host1:9081,host2:9081、dns:///host:port、srv:///_grpc._tcp.message-service、
+	// redis:///message(Message Service启动时注册到Redis)
+	MessageServiceAddr string `yaml:"messageServiceAddr"`
+	// 单次调用(含重试)的超时时间，<=0时使用默认值；调用方的ctx有更早的deadline时以ctx为准
+	Timeout time.Duration `yaml:"timeout"`
+	// 幂等调用遇到UNAVAILABLE时的最大尝试次数(含第一次)，<=0时使用默认值，1表示不重试
+	MaxAttempts int `yaml:"maxAttempts"`
+	// 到每个Message Service实例的连接数，<=0时使用默认值
+	PoolSize int `yaml:"poolSize"`
+
+	// 重新解析实例列表和健康检查的间隔
+	ResolveInterval     time.Duration `yaml:"resolveInterval"`
+	HealthCheckInterval time.Duration `yaml:"healthCheckInterval"`
+	// 实例连续失败多少次后被摘除，摘除时间为BaseEjectionTime乘以摘除次数
+	EjectionThreshold int           `yaml:"ejectionThreshold"`
+	BaseEjectionTime  time.Duration `yaml:"baseEjectionTime"`
+	// 同时被摘除的实例占比上限(1-100)，至少允许摘除一个实例
+	MaxEjectionPercent int `yaml:"maxEjectionPercent"`
+	// 每个实例同时进行的调用上限，所有实例都达到上限时调用直接失败，0表示不限制
+	MaxConcurrentRequests int `yaml:"maxConcurrentRequests"`
+}
+
+// TracingConfig OpenTelemetry链路追踪配置。未启用时仍然在服务之间传递trace上下文，只是不导出span
+type TracingConfig struct {
+	Enabled bool `yaml:"enabled"`
+	// OTLP gRPC接收地址，如otel-collector:4317
+	Endpoint string `yaml:"endpoint"`
+	// 不使用TLS连接Endpoint
+	Insecure bool `yaml:"insecure"`
+	// 根span的采样比例(0-1]，<=0时全部采样；上游已经采样的请求总是继续采样
+	SampleRatio float64 `yaml:"sampleRatio"`
+}
+
+// RateLimitConfig 基于Redis的限流配置，计数在所有实例之间共享。未配置的规则使用默认值
+type RateLimitConfig struct {
+	// 关闭所有限流，Redis不可用时限流本身也会放行请求
+	Disabled bool `yaml:"disabled"`
+	// 每个用户通过WebSocket发送、编辑、撤回消息和表情回应的速率
+	Messages RateLimitRule `yaml:"messages"`
+	// 每个用户typing、已读回执等轻量请求的速率
+	Events RateLimitRule `yaml:"events"`
+
+	// 每个IP的登录尝试速率
+	LoginIP RateLimitRule `yaml:"loginIP"`
+	// 同一IP对同一账号在LoginFailureWindow内失败LoginMaxFailures次后锁定该IP上的登录LoginLockout，<=0时使用默认值
+	LoginMaxFailures   int           `yaml:"loginMaxFailures"`
+	LoginFailureWindow time.Duration `yaml:"loginFailureWindow"`
+	LoginLockout       time.Duration `yaml:"loginLockout"`
+
+	// HTTP API每个路由的默认速率，已登录时按用户计算，否则按IP
+	API RateLimitRule `yaml:"api"`
+	// 按路由覆盖API，key为"方法 路由模板"，如"POST /api/v1/ws-ticket"
+	Routes map[string]RateLimitRule `yaml:"routes"`
+}
+
+// RateLimitRule 令牌桶：每Period补充Rate个令牌，桶容量为Burst(<=0时等于Rate)。Rate<=0时使用默认值
+type RateLimitRule struct {
+	Rate   int           `yaml:"rate"`
+	Period time.Duration `yaml:"period"`
+	Burst  int           `yaml:"burst"`
+}
+
+// ModerationConfig 消息落库前的内容审核。命中规则的消息被拦截(block)或放行后进入审核队列(flag)
+type ModerationConfig struct {
+	// 关闭所有审核
+	Disabled bool `yaml:"disabled"`
+	// 敏感词，不区分大小写，按子串匹配。BlockedWords拦截，FlaggedWords标记
+	BlockedWords []string `yaml:"blockedWords"`
+	FlaggedWords []string `yaml:"flaggedWords"`
+
+	// 消息中包含链接时的处理：allow(默认)、flag、block
+	LinkPolicy string `yaml:"linkPolicy"`
+	// 这些域名及其子域名的链接总是允许
+	AllowedDomains []string `yaml:"allowedDomains"`
+	// 这些域名及其子域名的链接总是拦截
+	BlockedDomains []string `yaml:"blockedDomains"`
+
+	// 同一用户在RepeatWindow内发送相同内容超过RepeatLimit次时标记，<=0时使用默认值
+	RepeatLimit  int           `yaml:"repeatLimit"`
+	RepeatWindow time.Duration `yaml:"repeatWindow"`
+	// 同一用户在BurstWindow内发送超过BurstLimit条消息时标记，<=0时使用默认值
+	BurstLimit  int           `yaml:"burstLimit"`
+	BurstWindow time.Duration `yaml:"burstWindow"`
+	// 垃圾消息的处理：flag(默认)或block
+	SpamAction string `yaml:"spamAction"`
+
+	// 外部分类服务，为空时不启用。POST {"content", "sender_id", "chat_type"}，返回{"action", "reason"}
+	ClassifierURL     string        `yaml:"classifierURL"`
+	ClassifierTimeout time.Duration `yaml:"classifierTimeout"`
+	// 审核规则出错(外部分类服务、Redis不可用)时拦截消息，默认跳过出错的规则
+	FailClosed bool `yaml:"failClosed"`
+}
+
+// NotificationConfig 离线推送配置，未配置的推送平台不会启用
+type NotificationConfig struct {
+	// Kafka消费者组，多个推送worker共享同一个组来分摊分区
+	ConsumerGroup string `yaml:"consumerGroup"`
+	// 每个用户在RateWindow内最多收到的推送数，<=0时使用默认值
+	RateLimit  int           `yaml:"rateLimit"`
+	RateWindow time.Duration `yaml:"rateWindow"`
+	APNs       APNsConfig    `yaml:"apns"`
+	FCM        FCMConfig     `yaml:"fcm"`
+	WebPush    WebPushConfig `yaml:"webPush"`
+}
+
+type APNsConfig struct {
+	// .p8格式的token认证私钥
+	KeyPath    string `yaml:"keyPath"`
+	KeyID      string `yaml:"keyID"`
+	TeamID     string `yaml:"teamID"`
+	Topic      string `yaml:"topic"` // 应用的bundle id
+	Production bool   `yaml:"production"`
+}
+
+type FCMConfig struct {
+	// Firebase服务账号的json凭证
+	CredentialsPath string `yaml:"credentialsPath"`
+}
+
+type WebPushConfig struct {
+	VAPIDPublicKey  string `yaml:"vapidPublicKey"`
+	VAPIDPrivateKey string `yaml:"vapidPrivateKey"`
+	// VAPID的sub，mailto:或https:开头的联系方式
+	Subject string `yaml:"subject"`
+}
+
 func LoadConfig(path string) (*Config, error) {
 	viper.AddConfigPath(path)
 	viper.SetConfigName("config")
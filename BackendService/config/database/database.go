@@ -6,6 +6,8 @@ import (
 	"sync"
 
 	"github.com/huangrao121/CommunicationApp/BackendService/config"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/metrics"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/tracing"
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -20,9 +22,19 @@ func InitDB(cfg *config.Config) {
 	once.Do(func() {
 		dsn := fmt.Sprintf("host=%s port=%d user=%s password=%s dbname=%s sslmode=disable",
 			cfg.Database.Host, cfg.Database.Port, cfg.Database.User, cfg.Database.Password, cfg.Database.DBName)
-		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		var err error
+		db, err = gorm.Open(postgres.Open(dsn), &gorm.Config{})
 		if err != nil {
 			slog.Error("failed to connect database", "error", err)
+			return
+		}
+		// SQL耗时指标
+		if err := db.Use(metrics.GormPlugin{}); err != nil {
+			slog.Error("failed to register gorm metrics plugin", "error", err)
+		}
+		// SQL span，挂在请求的span下面
+		if err := db.Use(tracing.GormPlugin{}); err != nil {
+			slog.Error("failed to register gorm tracing plugin", "error", err)
 		}
 		result := db.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp";`)
 		if result.Error != nil {
@@ -39,10 +51,23 @@ func InitDB(cfg *config.Config) {
 			&types.ConversationParticipants{},
 			&types.Friends{},
 			&types.GroupMembers{},
+			&types.MessageEdits{},
+			&types.MessageDeletions{},
+			&types.MessageReactions{},
+			&types.DeviceTokens{},
+			&types.ModerationRecords{},
+			&types.Reports{},
+			&types.AdminAuditLogs{},
 		)
 		if migrateErr != nil {
 			slog.Error("failed to migrate database", "error", migrateErr)
 		}
+		if err := migrateSearchIndexes(db); err != nil {
+			slog.Error("failed to create message search indexes", "error", err)
+		}
+		if err := migrateAuditLogTrigger(db); err != nil {
+			slog.Error("failed to create admin audit log trigger", "error", err)
+		}
 		slog.Info("database migrate successfully")
 	})
 }
@@ -53,3 +78,63 @@ func GetDB(cfg *config.Config) *gorm.DB {
 	}
 	return db
 }
+
+// migrateSearchIndexes 为消息内容创建全文检索所需的列和索引。
+// search_vector使用simple配置(不做词干处理，适用于多语言)，
+// 中日韩文本没有空格分词，依赖pg_trgm的三元组索引做子串匹配
+func migrateSearchIndexes(db *gorm.DB) error {
+	if err := db.Exec(`CREATE EXTENSION IF NOT EXISTS pg_trgm;`).Error; err != nil {
+		return err
+	}
+
+	for _, model := range []interface{}{&types.P2PMessages{}, &types.GroupMessages{}} {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(model); err != nil {
+			return err
+		}
+		table := stmt.Schema.Table
+
+		statements := []string{
+			fmt.Sprintf(`ALTER TABLE %s ADD COLUMN IF NOT EXISTS search_vector tsvector
+				GENERATED ALWAYS AS (to_tsvector('simple', coalesce(content, ''))) STORED;`, table),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_%s_search_vector ON %s USING GIN (search_vector);`, table, table),
+			fmt.Sprintf(`CREATE INDEX IF NOT EXISTS idx_%s_content_trgm ON %s USING GIN (content gin_trgm_ops);`, table, table),
+		}
+		for _, sql := range statements {
+			if err := db.Exec(sql).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// migrateAuditLogTrigger 管理员操作记录只能追加，拒绝UPDATE、DELETE和TRUNCATE，
+// 即使有数据库写权限的程序出错也不会改掉已有的记录
+func migrateAuditLogTrigger(db *gorm.DB) error {
+	stmt := &gorm.Statement{DB: db}
+	if err := stmt.Parse(&types.AdminAuditLogs{}); err != nil {
+		return err
+	}
+	table := stmt.Schema.Table
+
+	statements := []string{
+		fmt.Sprintf(`CREATE OR REPLACE FUNCTION %s_append_only() RETURNS trigger AS $$
+			BEGIN
+				RAISE EXCEPTION '%s is append-only';
+			END;
+			$$ LANGUAGE plpgsql;`, table, table),
+		fmt.Sprintf(`DROP TRIGGER IF EXISTS %s_no_modify ON %s;`, table, table),
+		fmt.Sprintf(`CREATE TRIGGER %s_no_modify BEFORE UPDATE OR DELETE ON %s
+			FOR EACH ROW EXECUTE FUNCTION %s_append_only();`, table, table, table),
+		fmt.Sprintf(`DROP TRIGGER IF EXISTS %s_no_truncate ON %s;`, table, table),
+		fmt.Sprintf(`CREATE TRIGGER %s_no_truncate BEFORE TRUNCATE ON %s
+			FOR EACH STATEMENT EXECUTE FUNCTION %s_append_only();`, table, table, table),
+	}
+	for _, sql := range statements {
+		if err := db.Exec(sql).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
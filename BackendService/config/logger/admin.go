@@ -0,0 +1,45 @@
+package logger
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RegisterRoutes 注册GET/PUT /admin/log-level，修改立即对整个进程生效，重启后恢复LOG_LEVEL。
+// auth在处理函数之前执行，调用方传入AuthMiddleware和RequireAdmin(logger包不能依赖middleware包)
+func RegisterRoutes(r gin.IRoutes, auth ...gin.HandlerFunc) {
+	r.GET("/admin/log-level", withHandlers(auth, GetLevel)...)
+	r.PUT("/admin/log-level", withHandlers(auth, UpdateLevel)...)
+}
+
+func withHandlers(auth []gin.HandlerFunc, handler gin.HandlerFunc) []gin.HandlerFunc {
+	handlers := make([]gin.HandlerFunc, 0, len(auth)+1)
+	handlers = append(handlers, auth...)
+	return append(handlers, handler)
+}
+
+func GetLevel(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"level": strings.ToLower(LevelVar.Level().String())})
+}
+
+// UpdateLevel 请求体为{"level": "debug"}
+func UpdateLevel(c *gin.Context) {
+	var req struct {
+		Level string `json:"level" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	level, err := ParseLevel(req.Level)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	previous := LevelVar.Level()
+	SetLevel(level)
+	WithCtx(c.Request.Context()).Warn("log level changed", "from", previous.String(), "to", level.String())
+	c.JSON(http.StatusOK, gin.H{"level": strings.ToLower(level.String())})
+}
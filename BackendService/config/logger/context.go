@@ -0,0 +1,67 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+// 常用的ctx字段名，同一个ID在所有服务的日志里使用相同的key
+const (
+	RequestIDKey = "request_id"
+	ConnIDKey    = "conn_id"
+	UserIDKey    = "user_id"
+	DeviceIDKey  = "device_id"
+)
+
+type ctxKey struct{}
+
+// With 把字段加入ctx，之后用这个ctx记录的日志都会带上这些字段。
+// args的格式和slog.Info相同：key/value对或slog.Attr
+func With(ctx context.Context, args ...any) context.Context {
+	added := slog.Group("", args...).Value.Group()
+	if len(added) == 0 {
+		return ctx
+	}
+	existing := Attrs(ctx)
+	attrs := make([]slog.Attr, 0, len(existing)+len(added))
+	attrs = append(attrs, existing...)
+	attrs = append(attrs, added...)
+	return context.WithValue(ctx, ctxKey{}, attrs)
+}
+
+// Attrs 返回通过With加入ctx的字段
+func Attrs(ctx context.Context) []slog.Attr {
+	if ctx == nil {
+		return nil
+	}
+	attrs, _ := ctx.Value(ctxKey{}).([]slog.Attr)
+	return attrs
+}
+
+// contextHandler 在每条日志中补充ctx里的字段和trace ID
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx != nil {
+		r.AddAttrs(Attrs(ctx)...)
+		if spanCtx := trace.SpanContextFromContext(ctx); spanCtx.IsValid() {
+			r.AddAttrs(
+				slog.String("trace_id", spanCtx.TraceID().String()),
+				slog.String("span_id", spanCtx.SpanID().String()),
+			)
+		}
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,48 @@
+package logger
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// GinMiddleware 为每个请求分配request_id并放入ctx，处理器用slog.XxxContext(c.Request.Context(), ...)
+// 记录的日志都会带上它。放在otelgin之后，没有X-Request-ID时使用trace ID，日志和trace可以互相查找
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		span := trace.SpanFromContext(c.Request.Context())
+		rid := c.GetHeader("X-Request-ID")
+		if rid == "" && span.SpanContext().HasTraceID() {
+			rid = span.SpanContext().TraceID().String()
+		}
+		if rid == "" {
+			rid = uuid.New().String()
+		}
+		c.Writer.Header().Set("X-Request-ID", rid)
+		span.SetAttributes(attribute.String("request.id", rid))
+
+		ctx := With(c.Request.Context(), RequestIDKey, rid)
+		c.Request = c.Request.WithContext(ctx)
+		c.Next()
+
+		// 认证中间件可能在处理过程中把user_id加入了ctx
+		ctx = c.Request.Context()
+		level := slog.LevelInfo
+		if c.Writer.Status() >= 500 {
+			level = slog.LevelError
+		}
+		slog.Log(ctx, level, "request completed",
+			"method", c.Request.Method,
+			"path", c.FullPath(),
+			"status", c.Writer.Status(),
+			"latency_ms", time.Since(start).Milliseconds(),
+			"bytes", c.Writer.Size(),
+			"remote_ip", c.ClientIP(),
+		)
+	}
+}
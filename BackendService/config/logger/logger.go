@@ -2,7 +2,9 @@ package logger
 
 import (
 	"context"
+	"errors"
 	"io"
+	"io/fs"
 	"log"
 	"log/slog"
 	"os"
@@ -13,29 +15,29 @@ import (
 )
 
 var (
+	// LevelVar 所有服务共用的日志级别，运行时通过/admin/log-level修改
 	LevelVar = new(slog.LevelVar)
 )
 
+// InitLogger 按环境变量初始化默认logger：
+// LOG_LEVEL(debug/info/warn/error)、LOG_FORMAT(json/text)、LOG_PATH(为空时只输出到stdout)。
+// 标准库log的输出也会经过这个handler，以INFO级别记录
 func InitLogger() {
-	if err := godotenv.Load(".env"); err != nil {
-		log.Fatal("Error loading .env file", "error", err)
+	// 容器里的配置直接来自环境变量，没有.env文件
+	if err := godotenv.Load(".env"); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		log.Fatal("Error loading .env file: ", err)
 	}
 
 	logLevel := os.Getenv("LOG_LEVEL")
 	logFormat := os.Getenv("LOG_FORMAT")
 	logPath := os.Getenv("LOG_PATH")
 
-	var level slog.Level
-	switch strings.ToLower(logLevel) {
-	case "debug":
-		level = slog.LevelDebug
-	case "warn":
-		level = slog.LevelWarn
-	case "error":
-		level = slog.LevelError
-	default:
+	level, err := ParseLevel(logLevel)
+	if err != nil {
 		level = slog.LevelInfo
 	}
+	LevelVar.Set(level)
+
 	var writer io.Writer
 	if logPath != "" {
 		logRotation := &lumberjack.Logger{
@@ -51,20 +53,22 @@ func InitLogger() {
 	}
 
 	opts := &slog.HandlerOptions{
-		Level: level,
+		Level: LevelVar,
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
-			// 如果日志级别是 SourceKey，则不输出，隐藏源码位置
+			// 隐藏源码位置
 			if a.Key == slog.SourceKey {
 				return slog.Attr{}
 			}
-			return a
+			return redact(a)
 		},
 	}
 
 	// 在开发环境（text格式）中，我们通常希望看到源码位置
 	if strings.ToLower(logFormat) == "text" {
 		opts.AddSource = true
-		opts.ReplaceAttr = nil // 使用默认行为
+		opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+			return redact(a)
+		}
 	}
 
 	var handler slog.Handler
@@ -73,12 +77,57 @@ func InitLogger() {
 	} else {
 		handler = slog.NewTextHandler(writer, opts)
 	}
-	// 设置默认的 slog 处理器
-	slog.SetDefault(slog.New(handler))
+	// 设置默认的 slog 处理器，请求/连接/用户ID从ctx中补充到每条日志
+	slog.SetDefault(slog.New(contextHandler{handler}))
+}
+
+// ParseLevel 解析debug/info/warn/error，不区分大小写，空字符串为info
+func ParseLevel(s string) (slog.Level, error) {
+	var level slog.Level
+	if s == "" {
+		return slog.LevelInfo, nil
+	}
+	err := level.UnmarshalText([]byte(s))
+	return level, err
 }
 
 func SetLevel(level slog.Level) {
 	LevelVar.Set(level)
 }
 
-func WithCtx(ctx context.Context) *slog.Logger { return slog.Default().With(slog.Group("ctx")) }
+// WithCtx 返回绑定了ctx的logger，适合在一个函数里多次记录日志；
+// 单条日志直接用slog.InfoContext(ctx, ...)即可。ctx里的字段只由contextHandler添加，
+// 用WithCtx(ctx).InfoContext(ctx, ...)记录时字段不会重复
+func WithCtx(ctx context.Context) *slog.Logger {
+	if ctx == nil {
+		return slog.Default()
+	}
+	return slog.New(boundHandler{Handler: slog.Default().Handler(), ctx: ctx})
+}
+
+// boundHandler 不带ctx的调用(Info/Warn等)使用绑定的ctx，带ctx的调用使用调用方的ctx
+type boundHandler struct {
+	slog.Handler
+	ctx context.Context
+}
+
+func (h boundHandler) Handle(ctx context.Context, r slog.Record) error {
+	if ctx == nil || ctx == context.Background() {
+		ctx = h.ctx
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h boundHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return boundHandler{Handler: h.Handler.WithAttrs(attrs), ctx: h.ctx}
+}
+
+func (h boundHandler) WithGroup(name string) slog.Handler {
+	return boundHandler{Handler: h.Handler.WithGroup(name), ctx: h.ctx}
+}
+
+// Fatal 记录错误后退出进程，只在启动阶段使用
+func Fatal(msg string, args ...any) {
+	slog.Error(msg, args...)
+	os.Exit(1)
+}
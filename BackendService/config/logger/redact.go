@@ -0,0 +1,68 @@
+package logger
+
+import (
+	"fmt"
+	"log/slog"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+// 值整个隐藏的字段：凭证类
+var secretKeys = map[string]bool{
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"id_token":      true,
+	"device_token":  true,
+	"ticket":        true,
+	"password":      true,
+	"secret":        true,
+	"authorization": true,
+	"cookie":        true,
+	"private_key":   true,
+}
+
+// 只保留长度的字段：消息正文
+var bodyKeys = map[string]bool{
+	"content":      true,
+	"body":         true,
+	"message_body": true,
+	"payload":      true,
+}
+
+// redact 按字段名隐藏敏感内容，key不区分大小写，以_token/_password/_secret结尾的字段也会隐藏。
+// 只看字段名，不检查值的内容，记录错误时不要把凭证拼进错误信息
+func redact(a slog.Attr) slog.Attr {
+	key := strings.ToLower(a.Key)
+	switch {
+	case secretKeys[key] || strings.HasSuffix(key, "_token") || strings.HasSuffix(key, "_password") || strings.HasSuffix(key, "_secret"):
+		a.Value = slog.StringValue(redacted)
+	case key == "email" || strings.HasSuffix(key, "_email"):
+		a.Value = slog.StringValue(MaskEmail(a.Value.String()))
+	case bodyKeys[key]:
+		a.Value = slog.StringValue(fmt.Sprintf("[REDACTED len=%d]", bodyLen(a.Value)))
+	}
+	return a
+}
+
+// MaskEmail 只保留用户名的首字母和域名，如a***@example.com
+func MaskEmail(email string) string {
+	at := strings.LastIndex(email, "@")
+	if at <= 0 {
+		return redacted
+	}
+	return email[:1] + "***" + email[at:]
+}
+
+func bodyLen(v slog.Value) int {
+	switch v.Kind() {
+	case slog.KindString:
+		return len(v.String())
+	case slog.KindAny:
+		if b, ok := v.Any().([]byte); ok {
+			return len(b)
+		}
+	}
+	return len(v.String())
+}
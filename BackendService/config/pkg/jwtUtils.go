@@ -17,9 +17,29 @@ type AppClaims struct {
 	ID       uuid.UUID `json:"id"`
 	Username string    `json:"username"`
 	Email    string    `json:"email"`
+	// 签发时的用户角色，角色变化后需要重新登录才会生效
+	Role string `json:"role,omitempty"`
+	// 签发时间(unix毫秒)。iat只精确到秒，撤销检查用它区分同一秒内撤销前后签发的token
+	IssuedAtMs int64 `json:"iat_ms,omitempty"`
 	jwt.RegisteredClaims
 }
 
+// IsAdmin token是否属于管理员
+func (c *AppClaims) IsAdmin() bool {
+	return c.Role == types.UserRoleAdmin
+}
+
+// IssuedAtMillis 签发时间(unix毫秒)，没有iat_ms的旧token按iat计算，都没有时返回0
+func (c *AppClaims) IssuedAtMillis() int64 {
+	if c.IssuedAtMs > 0 {
+		return c.IssuedAtMs
+	}
+	if c.IssuedAt != nil {
+		return c.IssuedAt.UnixMilli()
+	}
+	return 0
+}
+
 type MQTTClaims struct {
 	ID       string      `json:"id"`
 	Username string      `json:"username"`
@@ -32,14 +52,17 @@ type MQTTClaims struct {
 func GenerateJWKToken(user *types.Users, acl *[]types.ACL, path string, ttl time.Duration) (string, error) {
 	var customClaims jwt.Claims
 	if acl == nil {
+		now := time.Now()
 		customClaims = &AppClaims{
-			ID:       user.ID,
-			Username: user.Username,
-			Email:    user.Email,
+			ID:         user.ID,
+			Username:   user.Username,
+			Email:      user.Email,
+			Role:       user.Role,
+			IssuedAtMs: now.UnixMilli(),
 			RegisteredClaims: jwt.RegisteredClaims{
 				Subject:   user.Username,
-				IssuedAt:  jwt.NewNumericDate(time.Now()),
-				ExpiresAt: jwt.NewNumericDate(time.Now().Add(ttl)),
+				IssuedAt:  jwt.NewNumericDate(now),
+				ExpiresAt: jwt.NewNumericDate(now.Add(ttl)),
 				Issuer:    "app",
 				Audience:  jwt.ClaimStrings{"app"},
 			},
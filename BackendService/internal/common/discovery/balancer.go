@@ -0,0 +1,463 @@
+package discovery
+
+import (
+	"context"
+	"log/slog"
+	"math/rand/v2"
+	"sync"
+	"time"
+
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	defaultTimeout             = 5 * time.Second
+	defaultMaxAttempts         = 3
+	defaultConnsPerEndpoint    = 2
+	defaultResolveInterval     = 10 * time.Second
+	defaultHealthCheckInterval = 5 * time.Second
+	defaultEjectionThreshold   = 5
+	defaultBaseEjectionTime    = 30 * time.Second
+	defaultMaxEjectionPercent  = 50
+
+	maxEjectionTime = 5 * time.Minute
+	// 实例从解析结果中消失后，等正在进行的调用结束再关闭连接
+	drainTimeout = 10 * time.Second
+)
+
+// errNoEndpoint 所有实例都不健康、被摘除或已达到并发上限，调用直接失败而不是排队等待
+var errNoEndpoint = status.Error(codes.Unavailable, "no available service instance")
+
+// Options Balancer的配置，零值字段使用默认值
+type Options struct {
+	// 健康检查使用的gRPC服务名，服务端未实现健康检查接口时视为健康
+	HealthService string
+	// 可以安全重试的完整方法名，如/message.v1.MessageService/GetGroupMembers
+	IdempotentMethods []string
+
+	// 单次调用(含重试)的超时时间，调用方的ctx有更早的deadline时以ctx为准
+	Timeout time.Duration
+	// 幂等调用遇到UNAVAILABLE时的最大尝试次数(含第一次)，重试优先选择其他实例
+	MaxAttempts int
+	// 每个实例的连接数
+	ConnsPerEndpoint int
+
+	ResolveInterval     time.Duration
+	HealthCheckInterval time.Duration
+
+	// 连续失败多少次后摘除实例(熔断)，摘除时间为BaseEjectionTime乘以摘除次数，最长5分钟。
+	// 到期后放行一个探测请求，成功则恢复，失败则再次摘除
+	EjectionThreshold int
+	BaseEjectionTime  time.Duration
+	// 同时被摘除的实例占比上限，至少允许摘除一个实例
+	MaxEjectionPercent int
+	// 每个实例同时进行的调用上限，0表示不限制
+	MaxConcurrentRequests int
+
+	DialOptions []grpc.DialOption
+}
+
+func (o *Options) setDefaults() {
+	if o.Timeout <= 0 {
+		o.Timeout = defaultTimeout
+	}
+	if o.MaxAttempts <= 0 {
+		o.MaxAttempts = defaultMaxAttempts
+	}
+	if o.ConnsPerEndpoint <= 0 {
+		o.ConnsPerEndpoint = defaultConnsPerEndpoint
+	}
+	if o.ResolveInterval <= 0 {
+		o.ResolveInterval = defaultResolveInterval
+	}
+	if o.HealthCheckInterval <= 0 {
+		o.HealthCheckInterval = defaultHealthCheckInterval
+	}
+	if o.EjectionThreshold <= 0 {
+		o.EjectionThreshold = defaultEjectionThreshold
+	}
+	if o.BaseEjectionTime <= 0 {
+		o.BaseEjectionTime = defaultBaseEjectionTime
+	}
+	if o.MaxEjectionPercent <= 0 || o.MaxEjectionPercent > 100 {
+		o.MaxEjectionPercent = defaultMaxEjectionPercent
+	}
+}
+
+// endpoint 一个服务实例，除addr和conns外的字段都由Balancer.mu保护
+type endpoint struct {
+	addr  string
+	conns []*grpc.ClientConn
+	next  int
+
+	healthy             bool
+	inflight            int
+	consecutiveFailures int
+	// ejections>0表示处于摘除状态：ejectedUntil之前不可用，之后只放行一个探测请求
+	ejections    int
+	ejectedUntil time.Time
+	probing      bool
+}
+
+// Balancer 客户端负载均衡，实现grpc.ClientConnInterface，可以直接传给生成的gRPC客户端。
+// 定期通过Resolver更新实例列表，按轮询选择健康且未被摘除的实例
+type Balancer struct {
+	name       string
+	resolver   Resolver
+	opts       Options
+	idempotent map[string]bool
+	cancel     context.CancelFunc
+
+	mu        sync.Mutex
+	endpoints []*endpoint
+	next      int
+}
+
+// NewBalancer 创建Balancer并立即解析一次，解析失败不会返回错误，后台会继续重试
+func NewBalancer(name string, resolver Resolver, opts Options) *Balancer {
+	opts.setDefaults()
+	ctx, cancel := context.WithCancel(context.Background())
+	b := &Balancer{
+		name:       name,
+		resolver:   resolver,
+		opts:       opts,
+		idempotent: make(map[string]bool, len(opts.IdempotentMethods)),
+		cancel:     cancel,
+	}
+	for _, method := range opts.IdempotentMethods {
+		b.idempotent[method] = true
+	}
+
+	b.resolve(ctx)
+	go b.run(ctx)
+	return b
+}
+
+// Close 停止解析和健康检查并关闭所有连接
+func (b *Balancer) Close() error {
+	b.cancel()
+	b.mu.Lock()
+	endpoints := b.endpoints
+	b.endpoints = nil
+	b.mu.Unlock()
+	for _, ep := range endpoints {
+		closeConns(ep)
+	}
+	return nil
+}
+
+func (b *Balancer) run(ctx context.Context) {
+	resolveTicker := time.NewTicker(b.opts.ResolveInterval)
+	defer resolveTicker.Stop()
+	healthTicker := time.NewTicker(b.opts.HealthCheckInterval)
+	defer healthTicker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-resolveTicker.C:
+			b.resolve(ctx)
+		case <-healthTicker.C:
+			b.checkHealth(ctx)
+		}
+	}
+}
+
+// resolve 按解析结果增删实例。解析失败或结果为空时保留现有实例，避免DNS或Redis抖动时断开所有连接
+func (b *Balancer) resolve(ctx context.Context) {
+	resolveCtx, cancel := context.WithTimeout(ctx, b.opts.ResolveInterval)
+	addrs, err := b.resolver.Resolve(resolveCtx)
+	cancel()
+	if err != nil {
+		slog.Error("Failed to resolve instances", "service", b.name, "error", err)
+		return
+	}
+	if len(addrs) == 0 {
+		slog.Warn("No instances resolved, keeping existing", "service", b.name, "instances", b.count())
+		return
+	}
+
+	wanted := make(map[string]bool, len(addrs))
+	for _, addr := range addrs {
+		wanted[addr] = true
+	}
+
+	b.mu.Lock()
+	current := make(map[string]bool, len(b.endpoints))
+	kept := b.endpoints[:0]
+	var removed []*endpoint
+	for _, ep := range b.endpoints {
+		current[ep.addr] = true
+		if wanted[ep.addr] {
+			kept = append(kept, ep)
+		} else {
+			removed = append(removed, ep)
+		}
+	}
+	b.endpoints = kept
+	b.mu.Unlock()
+
+	for _, ep := range removed {
+		slog.Info("Instance removed", "service", b.name, "addr", ep.addr)
+		go func(ep *endpoint) {
+			time.Sleep(drainTimeout)
+			closeConns(ep)
+		}(ep)
+	}
+
+	for addr := range wanted {
+		if current[addr] {
+			continue
+		}
+		ep, err := b.dial(addr)
+		if err != nil {
+			slog.Error("Failed to connect to instance", "service", b.name, "addr", addr, "error", err)
+			continue
+		}
+		slog.Info("Instance added", "service", b.name, "addr", addr)
+		b.mu.Lock()
+		b.endpoints = append(b.endpoints, ep)
+		b.mu.Unlock()
+	}
+}
+
+// dial 创建到实例的连接。grpc.NewClient不会立即建立连接，新实例在健康检查通过前也参与负载均衡
+func (b *Balancer) dial(addr string) (*endpoint, error) {
+	ep := &endpoint{addr: addr, healthy: true}
+	for i := 0; i < b.opts.ConnsPerEndpoint; i++ {
+		conn, err := grpc.NewClient("passthrough:///"+addr, b.opts.DialOptions...)
+		if err != nil {
+			closeConns(ep)
+			return nil, err
+		}
+		ep.conns = append(ep.conns, conn)
+	}
+	return ep, nil
+}
+
+func closeConns(ep *endpoint) {
+	for _, conn := range ep.conns {
+		conn.Close()
+	}
+}
+
+func (b *Balancer) count() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return len(b.endpoints)
+}
+
+// checkHealth 并发检查所有实例，NOT_SERVING或检查失败的实例不再被选中，直到下一次检查通过
+func (b *Balancer) checkHealth(ctx context.Context) {
+	b.mu.Lock()
+	endpoints := append([]*endpoint(nil), b.endpoints...)
+	b.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, ep := range endpoints {
+		wg.Add(1)
+		go func(ep *endpoint) {
+			defer wg.Done()
+			checkCtx, cancel := context.WithTimeout(ctx, b.opts.HealthCheckInterval)
+			defer cancel()
+			resp, err := grpc_health_v1.NewHealthClient(ep.conns[0]).Check(checkCtx, &grpc_health_v1.HealthCheckRequest{Service: b.opts.HealthService})
+			healthy := err == nil && resp.Status == grpc_health_v1.HealthCheckResponse_SERVING
+			if status.Code(err) == codes.Unimplemented {
+				healthy = true
+			}
+
+			b.mu.Lock()
+			changed := ep.healthy != healthy
+			ep.healthy = healthy
+			b.mu.Unlock()
+			if changed && healthy {
+				slog.Info("Instance is healthy", "service", b.name, "addr", ep.addr)
+			} else if changed {
+				slog.Warn("Instance is unhealthy", "service", b.name, "addr", ep.addr, "error", err)
+			}
+		}(ep)
+	}
+	wg.Wait()
+}
+
+// pick 轮询选择一个可用实例，优先选择tried之外的实例，都试过时可以再次选择
+func (b *Balancer) pick(tried map[*endpoint]bool) (*endpoint, *grpc.ClientConn, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	n := len(b.endpoints)
+	var chosen, fallback *endpoint
+	for i := 0; i < n; i++ {
+		idx := (b.next + i) % n
+		ep := b.endpoints[idx]
+		if !b.available(ep, now) {
+			continue
+		}
+		if tried[ep] {
+			if fallback == nil {
+				fallback = ep
+			}
+			continue
+		}
+		chosen = ep
+		b.next = idx + 1
+		break
+	}
+	if chosen == nil {
+		chosen = fallback
+	}
+	if chosen == nil {
+		return nil, nil, errNoEndpoint
+	}
+
+	if chosen.ejections > 0 {
+		chosen.probing = true
+	}
+	chosen.inflight++
+	conn := chosen.conns[chosen.next%len(chosen.conns)]
+	chosen.next++
+	return chosen, conn, nil
+}
+
+func (b *Balancer) available(ep *endpoint, now time.Time) bool {
+	if !ep.healthy {
+		return false
+	}
+	if b.opts.MaxConcurrentRequests > 0 && ep.inflight >= b.opts.MaxConcurrentRequests {
+		return false
+	}
+	if ep.ejections > 0 && (now.Before(ep.ejectedUntil) || ep.probing) {
+		return false
+	}
+	return true
+}
+
+// done 记录调用结果，连续失败达到阈值或探测请求失败时摘除实例
+func (b *Balancer) done(ep *endpoint, err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	ep.inflight--
+	probe := ep.probing
+	ep.probing = false
+	if !isEndpointFailure(err) {
+		ep.consecutiveFailures = 0
+		if probe {
+			ep.ejections = 0
+			slog.Info("Instance recovered", "service", b.name, "addr", ep.addr)
+		}
+		return
+	}
+
+	ep.consecutiveFailures++
+	if !probe && (ep.consecutiveFailures < b.opts.EjectionThreshold || !b.canEject()) {
+		return
+	}
+	ep.ejections++
+	ejection := b.opts.BaseEjectionTime * time.Duration(ep.ejections)
+	if ejection > maxEjectionTime {
+		ejection = maxEjectionTime
+	}
+	ep.ejectedUntil = time.Now().Add(ejection)
+	ep.consecutiveFailures = 0
+	slog.Warn("Instance ejected", "service", b.name, "addr", ep.addr, "ejection", ejection, "error", err)
+}
+
+// canEject 被摘除的实例数是否还没有达到MaxEjectionPercent
+func (b *Balancer) canEject() bool {
+	ejected := 0
+	for _, ep := range b.endpoints {
+		if ep.ejections > 0 {
+			ejected++
+		}
+	}
+	limit := len(b.endpoints) * b.opts.MaxEjectionPercent / 100
+	if limit < 1 {
+		limit = 1
+	}
+	return ejected < limit
+}
+
+// isEndpointFailure 只有实例本身的问题计入失败，NotFound等业务错误不影响实例状态
+func isEndpointFailure(err error) bool {
+	switch status.Code(err) {
+	case codes.Unavailable, codes.DeadlineExceeded, codes.Internal, codes.Unknown:
+		return true
+	default:
+		return false
+	}
+}
+
+// Invoke 实现grpc.ClientConnInterface并记录耗时。幂等方法遇到UNAVAILABLE时退避后换一个实例重试
+func (b *Balancer) Invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	start := time.Now()
+	err := b.invoke(ctx, method, args, reply, opts...)
+	metrics.ObserveGRPCClient(b.name, method, err, time.Since(start))
+	return err
+}
+
+func (b *Balancer) invoke(ctx context.Context, method string, args, reply any, opts ...grpc.CallOption) error {
+	ctx, cancel := context.WithTimeout(ctx, b.opts.Timeout)
+	defer cancel()
+
+	attempts := 1
+	if b.idempotent[method] {
+		attempts = b.opts.MaxAttempts
+	}
+	tried := make(map[*endpoint]bool, attempts)
+	var err error
+	for attempt := 0; attempt < attempts; attempt++ {
+		if attempt > 0 && !sleep(ctx, backoff(attempt)) {
+			return err
+		}
+
+		var ep *endpoint
+		var conn *grpc.ClientConn
+		ep, conn, err = b.pick(tried)
+		if err == nil {
+			tried[ep] = true
+			err = conn.Invoke(ctx, method, args, reply, opts...)
+			b.done(ep, err)
+		}
+		if status.Code(err) != codes.Unavailable {
+			return err
+		}
+	}
+	return err
+}
+
+// NewStream 实现grpc.ClientConnInterface，流式调用不重试，只在建立时计入实例状态
+func (b *Balancer) NewStream(ctx context.Context, desc *grpc.StreamDesc, method string, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	ep, conn, err := b.pick(nil)
+	if err != nil {
+		return nil, err
+	}
+	stream, err := conn.NewStream(ctx, desc, method, opts...)
+	b.done(ep, err)
+	return stream, err
+}
+
+// backoff 100ms起指数退避，最长1s，带50%抖动
+func backoff(attempt int) time.Duration {
+	d := 100 * time.Millisecond << (attempt - 1)
+	if d > time.Second {
+		d = time.Second
+	}
+	return d/2 + rand.N(d/2)
+}
+
+func sleep(ctx context.Context, d time.Duration) bool {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,86 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// DefaultHeartbeatTTL 超过这个时间没有心跳的实例视为已下线
+const DefaultHeartbeatTTL = 15 * time.Second
+
+// 实例记录在有序集合里，成员是地址，分数是最后一次心跳的毫秒时间戳
+func instancesKey(service string) string {
+	return fmt.Sprintf("service_instances:%s", service)
+}
+
+// Register 把实例注册到Redis并每ttl/3续期一次，ctx取消后注销。
+// 进程异常退出时不会注销，实例在ttl后自然过期
+func Register(ctx context.Context, client redis.Cmdable, service, addr string, ttl time.Duration) {
+	key := instancesKey(service)
+	heartbeat := func() {
+		if err := client.ZAdd(ctx, key, redis.Z{Score: float64(time.Now().UnixMilli()), Member: addr}).Err(); err != nil {
+			slog.Error("Failed to register instance", "service", service, "addr", addr, "error", err)
+		}
+	}
+
+	heartbeat()
+	ticker := time.NewTicker(ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			// ctx已经取消，用新的ctx注销
+			unregisterCtx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			if err := client.ZRem(unregisterCtx, key, addr).Err(); err != nil {
+				slog.Error("Failed to unregister instance", "service", service, "addr", addr, "error", err)
+			}
+			cancel()
+			return
+		case <-ticker.C:
+			heartbeat()
+		}
+	}
+}
+
+// RedisResolver 返回ttl内有心跳的实例
+type RedisResolver struct {
+	client  redis.Cmdable
+	service string
+	ttl     time.Duration
+}
+
+func NewRedisResolver(client redis.Cmdable, service string, ttl time.Duration) *RedisResolver {
+	return &RedisResolver{client: client, service: service, ttl: ttl}
+}
+
+func (r *RedisResolver) Resolve(ctx context.Context) ([]string, error) {
+	key := instancesKey(r.service)
+	cutoff := strconv.FormatInt(time.Now().Add(-r.ttl).UnixMilli(), 10)
+	// 顺便清理过期的实例，多个gateway同时清理也没有问题
+	if err := r.client.ZRemRangeByScore(ctx, key, "-inf", "("+cutoff).Err(); err != nil {
+		return nil, err
+	}
+	return r.client.ZRangeByScore(ctx, key, &redis.ZRangeBy{Min: cutoff, Max: "+inf"}).Result()
+}
+
+// AdvertiseAddr 由监听地址推导注册到Redis的地址，listenAddr没有指定host(如:9081)时使用主机名。
+// 主机名不能被gateway解析时(如Kubernetes的pod)，应直接配置pod IP
+func AdvertiseAddr(listenAddr string) (string, error) {
+	host, port, err := net.SplitHostPort(listenAddr)
+	if err != nil {
+		return "", err
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		if host, err = os.Hostname(); err != nil {
+			return "", err
+		}
+	}
+	return net.JoinHostPort(host, port), nil
+}
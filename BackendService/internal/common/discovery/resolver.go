@@ -0,0 +1,101 @@
+package discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Resolver 返回服务当前所有实例的地址(host:port)，Balancer定期调用并按结果增删连接
+type Resolver interface {
+	Resolve(ctx context.Context) ([]string, error)
+}
+
+// StaticResolver 固定的实例列表
+type StaticResolver []string
+
+func (r StaticResolver) Resolve(ctx context.Context) ([]string, error) {
+	return r, nil
+}
+
+// DNSResolver 解析域名的A/AAAA记录，每个IP是一个实例，适合Kubernetes的headless service
+type DNSResolver struct {
+	Host string
+	Port string
+}
+
+func (r DNSResolver) Resolve(ctx context.Context) ([]string, error) {
+	hosts, err := net.DefaultResolver.LookupHost(ctx, r.Host)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(hosts))
+	for _, host := range hosts {
+		addrs = append(addrs, net.JoinHostPort(host, r.Port))
+	}
+	return addrs, nil
+}
+
+// SRVResolver 解析SRV记录，如_grpc._tcp.message-service.default.svc.cluster.local，端口来自记录
+type SRVResolver struct {
+	Name string
+}
+
+func (r SRVResolver) Resolve(ctx context.Context) ([]string, error) {
+	_, records, err := net.DefaultResolver.LookupSRV(ctx, "", "", r.Name)
+	if err != nil {
+		return nil, err
+	}
+	addrs := make([]string, 0, len(records))
+	for _, record := range records {
+		addrs = append(addrs, net.JoinHostPort(strings.TrimSuffix(record.Target, "."), strconv.Itoa(int(record.Port))))
+	}
+	return addrs, nil
+}
+
+// NewResolver 按target的scheme创建Resolver：
+//
+//	static:///host1:9081,host2:9081                 固定列表
+//	dns:///message-service:9081                      A/AAAA记录，没有scheme时的默认方式
+//	srv:///_grpc._tcp.message-service                SRV记录
+//	redis:///message                                 通过Register注册到Redis的实例，client不能为nil
+func NewResolver(target string, client redis.Cmdable) (Resolver, error) {
+	scheme, endpoint, ok := strings.Cut(target, ":///")
+	if !ok {
+		scheme, endpoint = "dns", target
+	}
+	if endpoint == "" {
+		return nil, fmt.Errorf("empty target %q", target)
+	}
+
+	switch scheme {
+	case "static":
+		var addrs StaticResolver
+		for _, addr := range strings.Split(endpoint, ",") {
+			if addr = strings.TrimSpace(addr); addr != "" {
+				addrs = append(addrs, addr)
+			}
+		}
+		return addrs, nil
+	case "dns":
+		host, port, err := net.SplitHostPort(endpoint)
+		if err != nil {
+			return nil, fmt.Errorf("invalid dns target %q: %w", target, err)
+		}
+		return DNSResolver{Host: host, Port: port}, nil
+	case "srv":
+		return SRVResolver{Name: endpoint}, nil
+	case "redis":
+		if client == nil {
+			return nil, errors.New("redis resolver requires a redis client")
+		}
+		return NewRedisResolver(client, endpoint, DefaultHeartbeatTTL), nil
+	default:
+		return nil, fmt.Errorf("unknown resolver scheme %q", scheme)
+	}
+}
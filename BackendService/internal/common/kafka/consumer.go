@@ -3,13 +3,17 @@ package kafka
 import (
 	"context"
 	"encoding/json"
-	"log"
+	"log/slog"
+	"strconv"
 
+	"github.com/huangrao121/CommunicationApp/BackendService/config/logger"
 	"github.com/segmentio/kafka-go"
 )
 
 type Consumer struct {
-	reader *kafka.Reader
+	reader  *kafka.Reader
+	topic   string
+	groupID string
 }
 
 type MessageHandler func(context.Context, MessagePayload) error
@@ -23,7 +27,7 @@ func NewConsumer(brokers []string, topic, groupID string) *Consumer {
 		MaxBytes: 10e6, // 10MB
 	})
 
-	return &Consumer{reader: r}
+	return &Consumer{reader: r, topic: topic, groupID: groupID}
 }
 
 func (c *Consumer) Start(ctx context.Context, handler MessageHandler) error {
@@ -34,20 +38,36 @@ func (c *Consumer) Start(ctx context.Context, handler MessageHandler) error {
 		default:
 			msg, err := c.reader.ReadMessage(ctx)
 			if err != nil {
-				log.Printf("Error reading message: %v", err)
+				consumeErrors.WithLabelValues(c.topic, c.groupID, "read").Inc()
+				slog.ErrorContext(ctx, "Failed to read message", "topic", c.topic, "group", c.groupID, "error", err)
 				continue
 			}
+			consumedMessages.WithLabelValues(c.topic, c.groupID).Inc()
+			// HighWaterMark是分区下一条消息的offset
+			consumerLag.WithLabelValues(c.topic, c.groupID, strconv.Itoa(msg.Partition)).Set(float64(msg.HighWaterMark - msg.Offset - 1))
+			c.process(ctx, msg, handler)
+		}
+	}
+}
 
-			var payload MessagePayload
-			if err := json.Unmarshal(msg.Value, &payload); err != nil {
-				log.Printf("Error unmarshaling message: %v", err)
-				continue
-			}
+// process 在生产者传来的trace下处理一条消息
+func (c *Consumer) process(ctx context.Context, msg kafka.Message, handler MessageHandler) {
+	ctx, span := startConsumerSpan(ctx, &msg)
+	defer span.End()
+	ctx = logger.With(ctx, "topic", msg.Topic, "partition", msg.Partition, "offset", msg.Offset)
 
-			if err := handler(ctx, payload); err != nil {
-				log.Printf("Error handling message: %v", err)
-			}
-		}
+	var payload MessagePayload
+	if err := json.Unmarshal(msg.Value, &payload); err != nil {
+		consumeErrors.WithLabelValues(c.topic, c.groupID, "decode").Inc()
+		recordSpanError(span, err)
+		slog.ErrorContext(ctx, "Failed to unmarshal message", "error", err)
+		return
+	}
+
+	if err := handler(ctx, payload); err != nil {
+		consumeErrors.WithLabelValues(c.topic, c.groupID, "handle").Inc()
+		recordSpanError(span, err)
+		slog.ErrorContext(ctx, "Failed to handle message", "error", err)
 	}
 }
 
@@ -0,0 +1,42 @@
+package kafka
+
+import (
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	producedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "kafka",
+		Name:      "produced_messages_total",
+		Help:      "Messages written to Kafka by topic and result.",
+	}, []string{"topic", "result"})
+	produceDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "kafka",
+		Name:      "produce_duration_seconds",
+		Help:      "Latency of synchronous Kafka writes by topic.",
+		Buckets:   metrics.LatencyBuckets,
+	}, []string{"topic"})
+
+	consumedMessages = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "kafka",
+		Name:      "consumed_messages_total",
+		Help:      "Messages read from Kafka by topic and consumer group.",
+	}, []string{"topic", "group"})
+	consumeErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "kafka",
+		Name:      "consume_errors_total",
+		Help:      "Kafka consumer errors by stage: read, decode or handle.",
+	}, []string{"topic", "group", "stage"})
+	consumerLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "kafka",
+		Name:      "consumer_lag",
+		Help:      "Messages between the last consumed offset and the partition high watermark.",
+	}, []string{"topic", "group", "partition"})
+)
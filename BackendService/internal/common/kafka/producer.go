@@ -4,6 +4,7 @@ import (
 	"context"
 	"encoding/json"
 	"log/slog"
+	"time"
 
 	"github.com/segmentio/kafka-go"
 )
@@ -36,20 +37,33 @@ func NewProducer(brokers []string, topics map[string]string) *Producer {
 func (p *Producer) SendMessage(ctx context.Context, writerName string, key string, payload MessagePayload) error {
 	writer, exists := p.writers[writerName]
 	if !exists {
-		slog.Error("writer not found", "writerName", writerName)
+		slog.ErrorContext(ctx, "writer not found", "writerName", writerName)
 		return nil
 	}
 
 	jsonPayload, err := json.Marshal(payload)
 	if err != nil {
-		slog.Error("failed to marshal payload", "error", err)
+		slog.ErrorContext(ctx, "failed to marshal payload", "error", err)
 		return nil
 	}
 
-	return writer.WriteMessages(ctx, kafka.Message{
+	msg := kafka.Message{
 		Key:   []byte(key),
 		Value: jsonPayload,
-	})
+	}
+	ctx, span := startProducerSpan(ctx, writer.Topic, &msg)
+	defer span.End()
+
+	start := time.Now()
+	err = writer.WriteMessages(ctx, msg)
+	recordSpanError(span, err)
+	produceDuration.WithLabelValues(writer.Topic).Observe(time.Since(start).Seconds())
+	result := "success"
+	if err != nil {
+		result = "error"
+	}
+	producedMessages.WithLabelValues(writer.Topic, result).Inc()
+	return err
 }
 
 func (p *Producer) Close() error {
@@ -0,0 +1,71 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/segmentio/kafka-go"
+	"gorm.io/gorm"
+)
+
+// PostgresCheck 检查数据库连接池能否连通，db为nil说明启动时就没有连上
+func PostgresCheck(db *gorm.DB) Check {
+	return func(ctx context.Context) error {
+		if db == nil {
+			return errors.New("database not connected")
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.PingContext(ctx)
+	}
+}
+
+// PostgresCloser 关闭数据库连接池
+func PostgresCloser(db *gorm.DB) Closer {
+	return func(ctx context.Context) error {
+		if db == nil {
+			return nil
+		}
+		sqlDB, err := db.DB()
+		if err != nil {
+			return err
+		}
+		return sqlDB.Close()
+	}
+}
+
+func RedisCheck(client redis.Cmdable) Check {
+	return func(ctx context.Context) error {
+		return client.Ping(ctx).Err()
+	}
+}
+
+// KafkaCheck 任一broker能连通并返回元数据即认为可用，写入时由kafka-go路由到分区leader
+func KafkaCheck(brokers []string) Check {
+	return func(ctx context.Context) error {
+		if len(brokers) == 0 {
+			return errors.New("no kafka brokers configured")
+		}
+		var errs []error
+		for _, broker := range brokers {
+			conn, err := kafka.DialContext(ctx, "tcp", broker)
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			if deadline, ok := ctx.Deadline(); ok {
+				conn.SetDeadline(deadline)
+			}
+			_, err = conn.Brokers()
+			conn.Close()
+			if err == nil {
+				return nil
+			}
+			errs = append(errs, err)
+		}
+		return errors.Join(errs...)
+	}
+}
@@ -0,0 +1,180 @@
+package lifecycle
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	DefaultShutdownTimeout = 25 * time.Second
+	// 单个就绪检查的超时时间，探针的超时一般是1秒
+	defaultCheckTimeout = 800 * time.Millisecond
+)
+
+// Check 检查一个依赖是否可用，返回nil表示可用
+type Check func(ctx context.Context) error
+
+// Closer 关闭一个依赖，ctx到期时应尽快返回
+type Closer func(ctx context.Context) error
+
+type namedCheck struct {
+	name  string
+	check Check
+}
+
+type namedCloser struct {
+	name  string
+	close Closer
+}
+
+// Lifecycle 管理服务的就绪检查和关闭顺序：
+// 收到SIGINT/SIGTERM后就绪检查立即失败，然后关闭HTTP服务器，最后按注册的相反顺序关闭依赖
+type Lifecycle struct {
+	name            string
+	shutdownTimeout time.Duration
+
+	mu      sync.Mutex
+	checks  []namedCheck
+	closers []namedCloser
+
+	shuttingDown atomic.Bool
+}
+
+// New shutdownTimeout<=0时使用默认值
+func New(name string, shutdownTimeout time.Duration) *Lifecycle {
+	if shutdownTimeout <= 0 {
+		shutdownTimeout = DefaultShutdownTimeout
+	}
+	return &Lifecycle{name: name, shutdownTimeout: shutdownTimeout}
+}
+
+// AddCheck 添加就绪检查，所有检查都通过时服务才就绪
+func (l *Lifecycle) AddCheck(name string, check Check) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.checks = append(l.checks, namedCheck{name: name, check: check})
+}
+
+// OnShutdown 添加关闭时执行的操作，按注册的相反顺序执行，先创建的依赖最后关闭
+func (l *Lifecycle) OnShutdown(name string, closer Closer) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.closers = append(l.closers, namedCloser{name: name, close: closer})
+}
+
+// ShuttingDown 是否已经收到关闭信号
+func (l *Lifecycle) ShuttingDown() bool {
+	return l.shuttingDown.Load()
+}
+
+// RegisterRoutes 注册/healthz(存活)和/readyz(就绪)。
+// 存活检查只说明进程能处理请求，依赖故障时不应该让kubelet重启服务，所以只在就绪检查里探测依赖
+func (l *Lifecycle) RegisterRoutes(r gin.IRoutes) {
+	r.GET("/healthz", l.Liveness)
+	r.GET("/readyz", l.Readiness)
+}
+
+func (l *Lifecycle) Liveness(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Readiness 并发执行所有就绪检查，任一失败或正在关闭时返回503
+func (l *Lifecycle) Readiness(c *gin.Context) {
+	if l.ShuttingDown() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting_down"})
+		return
+	}
+
+	l.mu.Lock()
+	checks := append([]namedCheck(nil), l.checks...)
+	l.mu.Unlock()
+
+	results := make([]error, len(checks))
+	var wg sync.WaitGroup
+	for i, check := range checks {
+		wg.Add(1)
+		go func(i int, check namedCheck) {
+			defer wg.Done()
+			ctx, cancel := context.WithTimeout(c.Request.Context(), defaultCheckTimeout)
+			defer cancel()
+			results[i] = check.check(ctx)
+		}(i, check)
+	}
+	wg.Wait()
+
+	status := http.StatusOK
+	details := make(map[string]string, len(checks))
+	for i, check := range checks {
+		if results[i] != nil {
+			status = http.StatusServiceUnavailable
+			details[check.name] = results[i].Error()
+			slog.Warn("readiness check failed", "service", l.name, "check", check.name, "error", results[i])
+			continue
+		}
+		details[check.name] = "ok"
+	}
+	if status != http.StatusOK {
+		c.JSON(status, gin.H{"status": "not_ready", "checks": details})
+		return
+	}
+	c.JSON(status, gin.H{"status": "ready", "checks": details})
+}
+
+// Run 启动HTTP服务器并阻塞到收到SIGINT/SIGTERM，然后按顺序关闭：
+// 1. 就绪检查失败，负载均衡停止分配新请求
+// 2. 关闭HTTP服务器，等待进行中的请求完成
+// 3. 按注册的相反顺序关闭依赖
+// 整个过程不超过shutdownTimeout。服务器启动失败时直接关闭依赖并返回错误
+func (l *Lifecycle) Run(srv *http.Server) error {
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	serveErr := make(chan error, 1)
+	go func() {
+		slog.Info("server starting", "service", l.name, "addr", srv.Addr)
+		serveErr <- srv.ListenAndServe()
+	}()
+
+	var err error
+	select {
+	case <-ctx.Done():
+		slog.Info("shutdown signal received", "service", l.name, "timeout", l.shutdownTimeout)
+	case err = <-serveErr:
+		slog.Error("server stopped unexpectedly", "service", l.name, "error", err)
+	}
+	l.shuttingDown.Store(true)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), l.shutdownTimeout)
+	defer cancel()
+	if shutdownErr := srv.Shutdown(shutdownCtx); shutdownErr != nil && !errors.Is(shutdownErr, http.ErrServerClosed) {
+		slog.Error("failed to shut down server", "service", l.name, "error", shutdownErr)
+	}
+	l.closeAll(shutdownCtx)
+	slog.Info("service stopped", "service", l.name)
+	return err
+}
+
+func (l *Lifecycle) closeAll(ctx context.Context) {
+	l.mu.Lock()
+	closers := append([]namedCloser(nil), l.closers...)
+	l.mu.Unlock()
+
+	for i := len(closers) - 1; i >= 0; i-- {
+		closer := closers[i]
+		start := time.Now()
+		if err := closer.close(ctx); err != nil {
+			slog.Error("failed to close dependency", "service", l.name, "dependency", closer.name, "error", err)
+			continue
+		}
+		slog.Info("dependency closed", "service", l.name, "dependency", closer.name, "elapsed_ms", time.Since(start).Milliseconds())
+	}
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/status"
+)
+
+// UnaryServerInterceptor 记录服务端每个方法的处理耗时
+func UnaryServerInterceptor() grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		grpcServerDuration.WithLabelValues(info.FullMethod, status.Code(err).String()).Observe(time.Since(start).Seconds())
+		return resp, err
+	}
+}
+
+// ObserveGRPCClient 记录一次客户端调用的耗时，包含重试和退避
+func ObserveGRPCClient(service, method string, err error, d time.Duration) {
+	grpcClientDuration.WithLabelValues(service, method, status.Code(err).String()).Observe(d.Seconds())
+}
@@ -0,0 +1,24 @@
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GinMiddleware 记录请求数和耗时。route使用路由模板(如/api/v1/groups/:group_id/messages)，
+// 没有匹配到路由的请求记为unmatched，避免路径参数导致标签基数爆炸
+func GinMiddleware(service string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		httpRequests.WithLabelValues(service, c.Request.Method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		httpDuration.WithLabelValues(service, c.Request.Method, route).Observe(time.Since(start).Seconds())
+	}
+}
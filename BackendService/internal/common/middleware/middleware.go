@@ -1,15 +1,23 @@
 package middleware
 
 import (
+	"context"
+	"log/slog"
 	"net/http"
 	"os"
 	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/config/logger"
 	"github.com/huangrao121/CommunicationApp/BackendService/config/pkg"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/revocation"
+	"github.com/redis/go-redis/v9"
 )
 
-func AuthMiddleware() gin.HandlerFunc {
+// AuthMiddleware 校验Authorization头里的token。revocations不为nil时还会拒绝
+// 登出、封禁等撤销之前签发的token，为nil时不检查撤销
+func AuthMiddleware(revocations redis.Cmdable) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		token := c.GetHeader("Authorization")
 		if token == "" {
@@ -26,20 +34,127 @@ func AuthMiddleware() gin.HandlerFunc {
 			c.Abort()
 			return
 		}
+		if revocations != nil {
+			revoked, err := revocation.IsRevoked(c.Request.Context(), revocations, claims.ID, claims.IssuedAtMillis())
+			if err != nil {
+				slog.ErrorContext(c.Request.Context(), "Failed to check token revocation", "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify token"})
+				c.Abort()
+				return
+			}
+			if revoked {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Token revoked"})
+				c.Abort()
+				return
+			}
+		}
+
+		c.Set("userID", claims.ID)
+		c.Set("username", claims.Username)
+		c.Set("email", claims.Email)
+		c.Set("claims", claims)
+		// 之后的日志都带上user_id
+		c.Request = c.Request.WithContext(logger.With(c.Request.Context(), logger.UserIDKey, claims.ID.String()))
+		c.Next()
+	}
+}
+
+// TicketRedeemer 校验WebSocket连接票据，由gateway的Hub实现
+type TicketRedeemer interface {
+	RedeemTicket(ctx context.Context, ticket string) (*pkg.AppClaims, error)
+}
+
+// WebSocketAuth /ws的认证。浏览器无法在握手时设置Authorization头，使用ticket查询参数
+// 携带一次性票据；原生客户端仍然可以直接使用Authorization头，按AuthMiddleware检查撤销
+func WebSocketAuth(redeemer TicketRedeemer, revocations redis.Cmdable) gin.HandlerFunc {
+	auth := AuthMiddleware(revocations)
+	return func(c *gin.Context) {
+		ticket := c.Query("ticket")
+		if ticket == "" {
+			auth(c)
+			return
+		}
+
+		claims, err := redeemer.RedeemTicket(c.Request.Context(), ticket)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid ticket"})
+			c.Abort()
+			return
+		}
 
 		c.Set("userID", claims.ID)
 		c.Set("username", claims.Username)
 		c.Set("email", claims.Email)
+		c.Set("claims", claims)
+		// 之后的日志都带上user_id
+		c.Request = c.Request.WithContext(logger.With(c.Request.Context(), logger.UserIDKey, claims.ID.String()))
 		c.Next()
 	}
 }
 
+// AdminVerifier 确认token里的管理员身份仍然有效，返回false时拒绝访问
+type AdminVerifier func(ctx context.Context, claims *pkg.AppClaims) (bool, error)
+
+// RequireAdmin 只允许管理员访问，必须放在AuthMiddleware之后。token里的角色只是签发时的状态，
+// 通过后再依次执行verifiers，如查询数据库中当前的角色；校验出错时拒绝访问。
+// token是否已撤销由AuthMiddleware检查
+func RequireAdmin(verifiers ...AdminVerifier) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, ok := CurrentClaims(c)
+		if !ok {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+			c.Abort()
+			return
+		}
+		if !claims.IsAdmin() {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Admin role required"})
+			c.Abort()
+			return
+		}
+		for _, verify := range verifiers {
+			ok, err := verify(c.Request.Context(), claims)
+			if err != nil {
+				slog.ErrorContext(c.Request.Context(), "Failed to verify admin", "error", err)
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify admin"})
+				c.Abort()
+				return
+			}
+			if !ok {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Admin role required"})
+				c.Abort()
+				return
+			}
+		}
+		c.Next()
+	}
+}
+
+// CurrentUserID 读取AuthMiddleware写入的用户ID，这里存的是uuid.UUID而不是字符串
+func CurrentUserID(c *gin.Context) (uuid.UUID, bool) {
+	value, ok := c.Get("userID")
+	if !ok {
+		return uuid.Nil, false
+	}
+	userID, ok := value.(uuid.UUID)
+	return userID, ok && userID != uuid.Nil
+}
+
+// CurrentClaims 读取认证时解析出的token claims，用于获取签发和过期时间
+func CurrentClaims(c *gin.Context) (*pkg.AppClaims, bool) {
+	value, ok := c.Get("claims")
+	if !ok {
+		return nil, false
+	}
+	claims, ok := value.(*pkg.AppClaims)
+	return claims, ok
+}
+
 func CORS() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
 		c.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
 		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, accept, origin, Cache-Control, X-Requested-With")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, DELETE")
+		c.Writer.Header().Set("Access-Control-Allow-Methods", "POST, OPTIONS, GET, PUT, PATCH, DELETE")
 		c.Next()
 	}
 }
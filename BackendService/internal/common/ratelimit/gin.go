@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/huangrao121/CommunicationApp/BackendService/config"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/middleware"
+)
+
+// KeyFunc 返回请求的限流对象
+type KeyFunc func(c *gin.Context) string
+
+// ByIP 按客户端IP限流，部署在代理后面时需要配置gin的TrustedProxies
+func ByIP(c *gin.Context) string {
+	return "ip:" + c.ClientIP()
+}
+
+// ByUser 已登录时按用户限流，否则按IP。必须放在AuthMiddleware之后
+func ByUser(c *gin.Context) string {
+	if userID, ok := middleware.CurrentUserID(c); ok {
+		return "user:" + userID.String()
+	}
+	return ByIP(c)
+}
+
+// Middleware 一组路由共用同一个桶
+func (l *Limiter) Middleware(scope string, limit Limit, key KeyFunc) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if l.check(c, scope, key(c), limit) {
+			c.Next()
+		}
+	}
+}
+
+// Routes 每个路由单独计数，cfg.Routes里配置了的路由使用自己的规则，其它路由使用cfg.API
+func (l *Limiter) Routes(cfg config.RateLimitConfig, key KeyFunc) gin.HandlerFunc {
+	def := LimitFromConfig(cfg.API, DefaultAPI)
+	routes := make(map[string]Limit, len(cfg.Routes))
+	for route, rule := range cfg.Routes {
+		routes[route] = LimitFromConfig(rule, def)
+	}
+	return func(c *gin.Context) {
+		// 没有匹配到路由的请求由gin返回404
+		if c.FullPath() == "" {
+			c.Next()
+			return
+		}
+		route := c.Request.Method + " " + c.FullPath()
+		limit, ok := routes[route]
+		if !ok {
+			limit = def
+		}
+		if l.check(c, "api", route+":"+key(c), limit) {
+			c.Next()
+		}
+	}
+}
+
+// check 超过限制时回复429并中止请求，返回是否放行
+func (l *Limiter) check(c *gin.Context, scope, key string, limit Limit) bool {
+	result, err := l.Allow(c.Request.Context(), scope, key, limit)
+	if err != nil {
+		slog.WarnContext(c.Request.Context(), "rate limit check failed, allowing request", "scope", scope, "error", err)
+	}
+	if l == nil {
+		return true
+	}
+	c.Header("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+	c.Header("X-RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	if !result.Allowed {
+		Abort(c, result.RetryAfter)
+		return false
+	}
+	return true
+}
+
+// Abort 回复429，Retry-After按秒向上取整
+func Abort(c *gin.Context, retryAfter time.Duration) {
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	c.Header("Retry-After", strconv.Itoa(seconds))
+	c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+		"error":       "rate limit exceeded",
+		"retry_after": seconds,
+	})
+}
@@ -0,0 +1,122 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/huangrao121/CommunicationApp/BackendService/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// 默认规则，配置里Rate<=0时使用
+var (
+	DefaultMessages = Limit{Rate: 10, Period: time.Second, Burst: 20}
+	DefaultEvents   = Limit{Rate: 20, Period: time.Second, Burst: 40}
+	DefaultLoginIP  = Limit{Rate: 10, Period: time.Minute, Burst: 10}
+	DefaultAPI      = Limit{Rate: 60, Period: time.Minute, Burst: 60}
+)
+
+// Limit 令牌桶：每Period补充Rate个令牌，桶容量为Burst
+type Limit struct {
+	Rate   int
+	Period time.Duration
+	Burst  int
+}
+
+// LimitFromConfig rule.Rate<=0时使用def，Period<=0时使用def的Period，Burst<=0时等于Rate
+func LimitFromConfig(rule config.RateLimitRule, def Limit) Limit {
+	if rule.Rate <= 0 {
+		return def
+	}
+	limit := Limit{Rate: rule.Rate, Period: rule.Period, Burst: rule.Burst}
+	if limit.Period <= 0 {
+		limit.Period = def.Period
+	}
+	if limit.Burst <= 0 {
+		limit.Burst = limit.Rate
+	}
+	return limit
+}
+
+// Result 一次限流检查的结果，Allowed为false时RetryAfter之后才会有可用的令牌
+type Result struct {
+	Allowed    bool
+	Limit      int
+	Remaining  int
+	RetryAfter time.Duration
+}
+
+// tokenBucketScript 按Redis的时间补充令牌并尝试取出cost个，各实例的时钟偏差不影响结果。
+// 桶装满所需的时间之后key过期，和满桶等价
+var tokenBucketScript = redis.NewScript(`
+if redis.replicate_commands then redis.replicate_commands() end
+local rate = tonumber(ARGV[1])
+local period = tonumber(ARGV[2])
+local burst = tonumber(ARGV[3])
+local cost = tonumber(ARGV[4])
+local t = redis.call('TIME')
+local now = tonumber(t[1]) * 1000 + math.floor(tonumber(t[2]) / 1000)
+
+local state = redis.call('HMGET', KEYS[1], 'tokens', 'ts')
+local tokens = tonumber(state[1])
+local ts = tonumber(state[2])
+if tokens == nil or ts == nil then
+  tokens = burst
+  ts = now
+end
+if now > ts then
+  tokens = math.min(burst, tokens + (now - ts) * rate / period)
+end
+
+local allowed = 0
+local retry = 0
+if tokens >= cost then
+  tokens = tokens - cost
+  allowed = 1
+else
+  retry = math.ceil((cost - tokens) * period / rate)
+end
+redis.call('HSET', KEYS[1], 'tokens', tostring(tokens), 'ts', now)
+redis.call('PEXPIRE', KEYS[1], math.ceil(burst * period / rate))
+return {allowed, math.floor(tokens), retry}
+`)
+
+// Limiter 基于Redis的分布式限流，所有实例共享同一个计数。nil的Limiter放行所有请求
+type Limiter struct {
+	client redis.Cmdable
+}
+
+// New client为nil时返回nil，即不限流
+func New(client redis.Cmdable) *Limiter {
+	if client == nil {
+		return nil
+	}
+	return &Limiter{client: client}
+}
+
+// Allow 从scope:key对应的桶里取一个令牌。Redis出错时放行并返回错误，限流不应该让服务不可用
+func (l *Limiter) Allow(ctx context.Context, scope, key string, limit Limit) (Result, error) {
+	if l == nil || limit.Rate <= 0 {
+		return Result{Allowed: true, Limit: limit.Burst, Remaining: limit.Burst}, nil
+	}
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = limit.Rate
+	}
+	values, err := tokenBucketScript.Run(ctx, l.client, []string{fmt.Sprintf("ratelimit:%s:%s", scope, key)},
+		limit.Rate, limit.Period.Milliseconds(), burst, 1).Int64Slice()
+	if err != nil {
+		return Result{Allowed: true, Limit: burst, Remaining: burst}, err
+	}
+	result := Result{
+		Allowed:    values[0] == 1,
+		Limit:      burst,
+		Remaining:  int(values[1]),
+		RetryAfter: time.Duration(values[2]) * time.Millisecond,
+	}
+	if !result.Allowed {
+		rejected.WithLabelValues(scope).Inc()
+	}
+	return result, nil
+}
@@ -0,0 +1,14 @@
+package ratelimit
+
+import (
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var rejected = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: metrics.Namespace,
+	Subsystem: "ratelimit",
+	Name:      "rejected_total",
+	Help:      "Requests rejected by rate limiting, by scope.",
+}, []string{"scope"})
@@ -0,0 +1,23 @@
+package redisclient
+
+import (
+	"github.com/huangrao121/CommunicationApp/BackendService/config"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/tracing"
+	"github.com/redis/go-redis/v9"
+)
+
+// New 按配置创建Redis集群客户端，所有服务共用同样的连接池设置。调用方负责Close
+func New(cfg config.RedisConfig) *redis.ClusterClient {
+	client := redis.NewClusterClient(&redis.ClusterOptions{
+		Addrs:          cfg.ClusterAddrs,
+		Password:       cfg.Password,
+		PoolSize:       cfg.PoolSize,
+		MinIdleConns:   cfg.MinIdleConns,
+		MaxIdleConns:   cfg.MaxIdleConns,
+		MaxActiveConns: cfg.MaxActiveConns,
+		PoolTimeout:    cfg.IdleTimeout,
+	})
+	// 只在已有span的调用里记录Redis命令，心跳等后台操作不产生孤立的trace
+	client.AddHook(tracing.RedisHook{})
+	return client
+}
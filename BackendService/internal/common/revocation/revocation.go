@@ -0,0 +1,73 @@
+package revocation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+// Channel 令牌撤销事件的Redis频道，所有gateway节点都订阅
+const Channel = "auth_revocations"
+
+// MaxTokenTTL 撤销记录的保留时间，与user服务签发的app token有效期一致，
+// 超过这个时间撤销前签发的token已经自然过期
+const MaxTokenTTL = 24 * time.Hour
+
+const (
+	ReasonLogout  = "logout"
+	ReasonBan     = "ban"
+	ReasonSuspend = "suspend"
+)
+
+// Event 撤销用户在IssuedBefore(unix毫秒)之前签发的所有token，DeviceID不为空时只断开该设备
+type Event struct {
+	UserID       uuid.UUID `json:"user_id"`
+	DeviceID     string    `json:"device_id,omitempty"`
+	Reason       string    `json:"reason"`
+	IssuedBefore int64     `json:"issued_before"`
+}
+
+func revokedKey(userID uuid.UUID) string {
+	return fmt.Sprintf("token_revoked_before:%s", userID)
+}
+
+// Publish 记录撤销时间并通知所有gateway节点。记录用于拒绝之后用旧token建立的连接，
+// 事件用于断开已经建立的连接
+func Publish(ctx context.Context, client redis.Cmdable, event Event) error {
+	if event.IssuedBefore == 0 {
+		event.IssuedBefore = time.Now().UnixMilli()
+	}
+	// 只撤销单个设备时旧token在其他设备上仍然有效
+	if event.DeviceID == "" {
+		if err := client.Set(ctx, revokedKey(event.UserID), event.IssuedBefore, MaxTokenTTL).Err(); err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return client.Publish(ctx, Channel, data).Err()
+}
+
+// IsRevoked 签发时间为issuedAtMs(unix毫秒)的token是否在撤销时间之前签发
+func IsRevoked(ctx context.Context, client redis.Cmdable, userID uuid.UUID, issuedAtMs int64) (bool, error) {
+	value, err := client.Get(ctx, revokedKey(userID)).Result()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	revokedBefore, err := strconv.ParseInt(value, 10, 64)
+	if err != nil {
+		return false, err
+	}
+	return issuedAtMs < revokedBefore, nil
+}
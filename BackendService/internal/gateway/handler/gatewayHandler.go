@@ -1,30 +1,134 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/middleware"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/revocation"
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/gateway/websocket"
 )
 
 type GatewayHandler struct {
-	hub *websocket.Hub
+	hub            *websocket.Hub
+	messageService websocket.MessageServiceClient
 }
 
-func NewGatewayHandler(hub *websocket.Hub) *GatewayHandler {
+func NewGatewayHandler(hub *websocket.Hub, messageService websocket.MessageServiceClient) *GatewayHandler {
 	return &GatewayHandler{
-		hub: hub,
+		hub:            hub,
+		messageService: messageService,
 	}
 }
 
 func (h *GatewayHandler) HandleWebSocket(c *gin.Context) {
 	// 从查询参数或头部获取token
-	userName := c.GetString("username")
-	userID := c.GetString("userID")
+	claims, ok := middleware.CurrentClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	// 升级前拒绝已撤销的token，连接建立后由Hub跟踪过期和撤销
+	if err := h.hub.CheckToken(c.Request.Context(), claims); err != nil {
+		c.JSON(tokenErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	// device_id由客户端生成并持久保存，同一用户可以同时在多个设备上连接
+	deviceID := c.Query("device_id")
+	deviceName := c.Query("device_name")
 
 	// 处理WebSocket连接
-	h.hub.HandleWebSocket(c.Writer, c.Request, uuid.MustParse(userID), userName)
+	h.hub.HandleWebSocket(c.Writer, c.Request, claims, deviceID, deviceName)
+}
+
+// CreateTicket 签发一次性的WebSocket连接票据，浏览器用它代替Authorization头建立连接
+func (h *GatewayHandler) CreateTicket(c *gin.Context) {
+	claims, ok := middleware.CurrentClaims(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	if err := h.hub.CheckToken(c.Request.Context(), claims); err != nil {
+		c.JSON(tokenErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	ticket, ttl, err := h.hub.IssueTicket(c.Request.Context(), claims)
+	if err != nil {
+		c.JSON(tokenErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"ticket":     ticket,
+		"expires_in": int(ttl.Seconds()),
+	})
+}
+
+// Logout 撤销当前用户已签发的所有token，所有节点上的连接都会被断开
+func (h *GatewayHandler) Logout(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	if err := h.hub.RevokeUserTokens(c.Request.Context(), userID, revocation.ReasonLogout); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+func tokenErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, websocket.ErrTokenExpired), errors.Is(err, websocket.ErrTokenRevoked):
+		return http.StatusUnauthorized
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// ListSessions 返回当前用户所有在线设备
+func (h *GatewayHandler) ListSessions(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	sessions, err := h.hub.GetUserSessions(c.Request.Context(), userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"sessions": sessions})
+}
+
+// DisconnectSession 远程下线当前用户的某个设备
+func (h *GatewayHandler) DisconnectSession(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	err := h.hub.DisconnectDevice(c.Request.Context(), userID, c.Param("device_id"))
+	if err != nil {
+		status := http.StatusInternalServerError
+		if errors.Is(err, websocket.ErrSessionNotFound) {
+			status = http.StatusNotFound
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
 func (h *GatewayHandler) GetOnlineUsers(c *gin.Context) {
@@ -34,3 +138,80 @@ func (h *GatewayHandler) GetOnlineUsers(c *gin.Context) {
 		"online_users": onlineUsers,
 	})
 }
+
+// GetStats 返回本节点的连接数和慢消费者统计
+func (h *GatewayHandler) GetStats(c *gin.Context) {
+	c.JSON(http.StatusOK, h.hub.Stats())
+}
+
+// GetClusterNodes 返回集群中所有网关节点及其心跳状态
+func (h *GatewayHandler) GetClusterNodes(c *gin.Context) {
+	nodes, err := h.hub.ClusterNodes(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"nodes": nodes})
+}
+
+// GetProtocolSchema 返回WebSocket协议的请求、事件和错误码描述
+func (h *GatewayHandler) GetProtocolSchema(c *gin.Context) {
+	c.JSON(http.StatusOK, h.hub.ProtocolSchema())
+}
+
+// UpdateConversationSettings 修改会话设置，并同步给该用户的所有在线连接
+func (h *GatewayHandler) UpdateConversationSettings(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	conversationID, err := uuid.Parse(c.Param("conversation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req websocket.ConversationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = userID
+	req.ConversationID = conversationID
+
+	settings, err := h.messageService.UpdateConversationSettings(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.hub.NotifyUser(c.Request.Context(), userID, "conversation_settings_updated", settings)
+	c.JSON(http.StatusOK, settings)
+}
+
+// ReorderPinnedConversations 调整置顶会话的顺序，并同步给该用户的所有在线连接
+func (h *GatewayHandler) ReorderPinnedConversations(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	var req struct {
+		ConversationIDs []uuid.UUID `json:"conversation_ids" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	pinned, err := h.messageService.ReorderPinnedConversations(c.Request.Context(), userID, req.ConversationIDs)
+	if err != nil {
+		c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()})
+		return
+	}
+
+	h.hub.NotifyUser(c.Request.Context(), userID, "conversation_pins_updated", gin.H{"pinned": pinned})
+	c.JSON(http.StatusOK, gin.H{"pinned": pinned})
+}
@@ -0,0 +1,428 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/config"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/discovery"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/gateway/websocket"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/pb/messagepb"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc/filters"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const defaultMessageServiceAddr = "dns:///message-service:9081"
+
+// 重复执行结果相同的调用，遇到UNAVAILABLE时可以安全重试。
+// 发送、编辑、撤回不在其中，重试可能产生重复消息或多余的版本
+var idempotentMethods = []string{
+	messagepb.MessageService_DeleteMessageForUser_FullMethodName,
+	messagepb.MessageService_AddReaction_FullMethodName,
+	messagepb.MessageService_RemoveReaction_FullMethodName,
+	messagepb.MessageService_GetP2PMessages_FullMethodName,
+	messagepb.MessageService_GetGroupMessages_FullMethodName,
+	messagepb.MessageService_GetGroupMembers_FullMethodName,
+	messagepb.MessageService_UpdateConversationSettings_FullMethodName,
+	messagepb.MessageService_ReorderPinnedConversations_FullMethodName,
+}
+
+// MessageGRPCClient 通过gRPC调用Message Service，实现MessageServiceClient接口。
+// 实例通过cfg.MessageServiceAddr发现，调用在健康的实例间负载均衡
+type MessageGRPCClient struct {
+	balancer *discovery.Balancer
+	client   messagepb.MessageServiceClient
+}
+
+// NewMessageGRPCClient redisClient只在使用redis:///发现实例时需要，可以为nil
+func NewMessageGRPCClient(cfg config.GRPCConfig, redisClient redis.Cmdable) (*MessageGRPCClient, error) {
+	return newMessageGRPCClient(cfg, redisClient)
+}
+
+// newMessageGRPCClient dialOptions追加在默认选项之后，测试时用来替换连接方式
+func newMessageGRPCClient(cfg config.GRPCConfig, redisClient redis.Cmdable, dialOptions ...grpc.DialOption) (*MessageGRPCClient, error) {
+	target := cfg.MessageServiceAddr
+	if target == "" {
+		target = defaultMessageServiceAddr
+	}
+	resolver, err := discovery.NewResolver(target, redisClient)
+	if err != nil {
+		return nil, err
+	}
+
+	balancer := discovery.NewBalancer("message-service", resolver, discovery.Options{
+		HealthService:         messagepb.MessageService_ServiceDesc.ServiceName,
+		IdempotentMethods:     idempotentMethods,
+		Timeout:               cfg.Timeout,
+		MaxAttempts:           cfg.MaxAttempts,
+		ConnsPerEndpoint:      cfg.PoolSize,
+		ResolveInterval:       cfg.ResolveInterval,
+		HealthCheckInterval:   cfg.HealthCheckInterval,
+		EjectionThreshold:     cfg.EjectionThreshold,
+		BaseEjectionTime:      cfg.BaseEjectionTime,
+		MaxEjectionPercent:    cfg.MaxEjectionPercent,
+		MaxConcurrentRequests: cfg.MaxConcurrentRequests,
+		DialOptions: append([]grpc.DialOption{
+			grpc.WithTransportCredentials(insecure.NewCredentials()),
+			// 把trace上下文传给Message Service，健康检查不产生span
+			grpc.WithStatsHandler(otelgrpc.NewClientHandler(otelgrpc.WithFilter(filters.Not(filters.HealthCheck())))),
+			grpc.WithKeepaliveParams(keepalive.ClientParameters{
+				Time:                30 * time.Second,
+				Timeout:             10 * time.Second,
+				PermitWithoutStream: true,
+			}),
+		}, dialOptions...),
+	})
+	return &MessageGRPCClient{
+		balancer: balancer,
+		client:   messagepb.NewMessageServiceClient(balancer),
+	}, nil
+}
+
+// Close 关闭所有连接
+func (c *MessageGRPCClient) Close() error {
+	return c.balancer.Close()
+}
+
+func (c *MessageGRPCClient) SendP2PMessage(ctx context.Context, req *websocket.SendP2PRequest) (*websocket.MessageResponse, error) {
+	resp, err := c.client.SendP2PMessage(ctx, &messagepb.SendP2PMessageRequest{
+		SenderId:    req.SenderID.String(),
+		ReceiverId:  req.ReceiverID.String(),
+		Content:     req.Content,
+		ContentType: int32(req.ContentType),
+		ReplyToId:   optionalString(req.ReplyToID),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return fromPBMessageResponse(resp)
+}
+
+func (c *MessageGRPCClient) SendGroupMessage(ctx context.Context, req *websocket.SendGroupRequest) (*websocket.MessageResponse, error) {
+	resp, err := c.client.SendGroupMessage(ctx, &messagepb.SendGroupMessageRequest{
+		SenderId:    req.SenderID.String(),
+		GroupId:     req.GroupID.String(),
+		Content:     req.Content,
+		ContentType: int32(req.ContentType),
+		ReplyToId:   optionalString(req.ReplyToID),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return fromPBMessageResponse(resp)
+}
+
+func (c *MessageGRPCClient) EditMessage(ctx context.Context, req *websocket.EditMessageRequest) (*websocket.MessageUpdateResponse, error) {
+	resp, err := c.client.EditMessage(ctx, &messagepb.EditMessageRequest{
+		MessageId:   req.MessageID.String(),
+		ChatType:    req.ChatType,
+		EditorId:    req.EditorID.String(),
+		Content:     req.Content,
+		ContentType: int32(req.ContentType),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return fromPBMessageUpdate(resp)
+}
+
+func (c *MessageGRPCClient) RecallMessage(ctx context.Context, req *websocket.RecallMessageRequest) (*websocket.MessageUpdateResponse, error) {
+	resp, err := c.client.RecallMessage(ctx, &messagepb.RecallMessageRequest{
+		MessageId:  req.MessageID.String(),
+		ChatType:   req.ChatType,
+		OperatorId: req.OperatorID.String(),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return fromPBMessageUpdate(resp)
+}
+
+func (c *MessageGRPCClient) DeleteMessageForUser(ctx context.Context, req *websocket.DeleteMessageRequest) error {
+	_, err := c.client.DeleteMessageForUser(ctx, &messagepb.DeleteMessageRequest{
+		MessageId: req.MessageID.String(),
+		ChatType:  req.ChatType,
+		UserId:    req.UserID.String(),
+	})
+	return grpcError(err)
+}
+
+func (c *MessageGRPCClient) AddReaction(ctx context.Context, req *websocket.ReactionRequest) (*websocket.ReactionUpdateResponse, error) {
+	resp, err := c.client.AddReaction(ctx, toPBReactionRequest(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return fromPBReactionUpdate(resp)
+}
+
+func (c *MessageGRPCClient) RemoveReaction(ctx context.Context, req *websocket.ReactionRequest) (*websocket.ReactionUpdateResponse, error) {
+	resp, err := c.client.RemoveReaction(ctx, toPBReactionRequest(req))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return fromPBReactionUpdate(resp)
+}
+
+func (c *MessageGRPCClient) GetGroupMembers(ctx context.Context, groupID uuid.UUID) ([]uuid.UUID, error) {
+	resp, err := c.client.GetGroupMembers(ctx, &messagepb.GetGroupMembersRequest{GroupId: groupID.String()})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return parseUUIDs(resp.Members)
+}
+
+// GetP2PMessages 返回userID与peerID之间的历史消息，按发送时间倒序
+func (c *MessageGRPCClient) GetP2PMessages(ctx context.Context, userID, peerID uuid.UUID, offset, limit int) ([]*messagepb.HistoryMessage, error) {
+	resp, err := c.client.GetP2PMessages(ctx, &messagepb.GetP2PMessagesRequest{
+		UserId: userID.String(),
+		PeerId: peerID.String(),
+		Offset: int32(offset),
+		Limit:  int32(limit),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.Messages, nil
+}
+
+// GetGroupMessages 返回群历史消息，userID必须是群成员
+func (c *MessageGRPCClient) GetGroupMessages(ctx context.Context, userID, groupID uuid.UUID, offset, limit int) ([]*messagepb.HistoryMessage, error) {
+	resp, err := c.client.GetGroupMessages(ctx, &messagepb.GetGroupMessagesRequest{
+		UserId:  userID.String(),
+		GroupId: groupID.String(),
+		Offset:  int32(offset),
+		Limit:   int32(limit),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return resp.Messages, nil
+}
+
+func (c *MessageGRPCClient) UpdateConversationSettings(ctx context.Context, req *websocket.ConversationSettingsRequest) (*websocket.ConversationSettings, error) {
+	pbReq := &messagepb.ConversationSettingsRequest{
+		UserId:         req.UserID.String(),
+		ConversationId: req.ConversationID.String(),
+		Muted:          req.Muted,
+		Pinned:         req.Pinned,
+		Archived:       req.Archived,
+		MarkedUnread:   req.MarkedUnread,
+	}
+	if req.MutedUntil != nil {
+		pbReq.MutedUntil = timestamppb.New(*req.MutedUntil)
+	}
+
+	resp, err := c.client.UpdateConversationSettings(ctx, pbReq)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	settings, err := fromPBConversationSettings(resp)
+	if err != nil {
+		return nil, err
+	}
+	return &settings, nil
+}
+
+func (c *MessageGRPCClient) ReorderPinnedConversations(ctx context.Context, userID uuid.UUID, conversationIDs []uuid.UUID) ([]websocket.ConversationSettings, error) {
+	ids := make([]string, 0, len(conversationIDs))
+	for _, id := range conversationIDs {
+		ids = append(ids, id.String())
+	}
+
+	resp, err := c.client.ReorderPinnedConversations(ctx, &messagepb.ReorderPinnedConversationsRequest{
+		UserId:          userID.String(),
+		ConversationIds: ids,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	pinned := make([]websocket.ConversationSettings, 0, len(resp.Pinned))
+	for _, p := range resp.Pinned {
+		settings, err := fromPBConversationSettings(p)
+		if err != nil {
+			return nil, err
+		}
+		pinned = append(pinned, settings)
+	}
+	return pinned, nil
+}
+
+// grpcError 转换为与HTTP客户端一致的错误信息，客户端看到的是Message Service给出的原因
+func grpcError(err error) error {
+	if err == nil {
+		return nil
+	}
+	st, ok := status.FromError(err)
+	if !ok {
+		return err
+	}
+	return fmt.Errorf("message service returned %s: %s", st.Code(), st.Message())
+}
+
+func toPBReactionRequest(req *websocket.ReactionRequest) *messagepb.ReactionRequest {
+	return &messagepb.ReactionRequest{
+		MessageId: req.MessageID.String(),
+		ChatType:  req.ChatType,
+		UserId:    req.UserID.String(),
+		Emoji:     req.Emoji,
+	}
+}
+
+func fromPBMessageResponse(resp *messagepb.MessageResponse) (*websocket.MessageResponse, error) {
+	id, err := uuid.Parse(resp.Id)
+	if err != nil {
+		return nil, err
+	}
+	threadRootID, err := parseOptionalUUID(resp.ThreadRootId)
+	if err != nil {
+		return nil, err
+	}
+	mentions, err := fromPBMentions(resp.Mentions)
+	if err != nil {
+		return nil, err
+	}
+	mutedUserIDs, err := parseUUIDs(resp.MutedUserIds)
+	if err != nil {
+		return nil, err
+	}
+	return &websocket.MessageResponse{
+		ID:           id,
+		Success:      true,
+		ThreadRootID: threadRootID,
+		Mentions:     mentions,
+		MentionAll:   resp.MentionAll,
+		MutedUserIDs: mutedUserIDs,
+		Timestamp:    resp.Timestamp,
+	}, nil
+}
+
+func fromPBMessageUpdate(resp *messagepb.MessageUpdate) (*websocket.MessageUpdateResponse, error) {
+	ids, err := parseUUIDs([]string{resp.Id, resp.SenderId, resp.ReceiverId, resp.GroupId})
+	if err != nil {
+		return nil, err
+	}
+	return &websocket.MessageUpdateResponse{
+		ID:          ids[0],
+		ChatType:    resp.ChatType,
+		SenderID:    ids[1],
+		ReceiverID:  ids[2],
+		GroupID:     ids[3],
+		Content:     resp.Content,
+		ContentType: int(resp.ContentType),
+		Edited:      resp.Edited,
+		Recalled:    resp.Recalled,
+		Version:     int(resp.Version),
+		Timestamp:   resp.Timestamp,
+	}, nil
+}
+
+func fromPBReactionUpdate(resp *messagepb.ReactionUpdate) (*websocket.ReactionUpdateResponse, error) {
+	ids, err := parseUUIDs([]string{resp.MessageId, resp.SenderId, resp.ReceiverId, resp.GroupId, resp.UserId})
+	if err != nil {
+		return nil, err
+	}
+	reactions := make([]websocket.ReactionSummary, 0, len(resp.Reactions))
+	for _, r := range resp.Reactions {
+		userIDs, err := parseUUIDs(r.UserIds)
+		if err != nil {
+			return nil, err
+		}
+		reactions = append(reactions, websocket.ReactionSummary{Emoji: r.Emoji, Count: int(r.Count), UserIDs: userIDs})
+	}
+	return &websocket.ReactionUpdateResponse{
+		MessageID:  ids[0],
+		ChatType:   resp.ChatType,
+		SenderID:   ids[1],
+		ReceiverID: ids[2],
+		GroupID:    ids[3],
+		UserID:     ids[4],
+		Emoji:      resp.Emoji,
+		Added:      resp.Added,
+		Reactions:  reactions,
+		Timestamp:  resp.Timestamp,
+	}, nil
+}
+
+func fromPBConversationSettings(resp *messagepb.ConversationSettings) (websocket.ConversationSettings, error) {
+	conversationID, err := uuid.Parse(resp.ConversationId)
+	if err != nil {
+		return websocket.ConversationSettings{}, err
+	}
+	settings := websocket.ConversationSettings{
+		ConversationID: conversationID,
+		Muted:          resp.Muted,
+		Pinned:         resp.Pinned,
+		PinOrder:       int(resp.PinOrder),
+		Archived:       resp.Archived,
+		MarkedUnread:   resp.MarkedUnread,
+		UpdatedAt:      resp.UpdatedAt.AsTime(),
+	}
+	if resp.MutedUntil != nil {
+		mutedUntil := resp.MutedUntil.AsTime()
+		settings.MutedUntil = &mutedUntil
+	}
+	return settings, nil
+}
+
+func fromPBMentions(mentions []*messagepb.Mention) ([]types.MentionEntity, error) {
+	if len(mentions) == 0 {
+		return nil, nil
+	}
+	result := make([]types.MentionEntity, 0, len(mentions))
+	for _, m := range mentions {
+		userID, err := uuid.Parse(m.UserId)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, types.MentionEntity{
+			UserID:   userID,
+			Username: m.Username,
+			Offset:   int(m.Offset),
+			Length:   int(m.Length),
+		})
+	}
+	return result, nil
+}
+
+func parseUUIDs(values []string) ([]uuid.UUID, error) {
+	if len(values) == 0 {
+		return nil, nil
+	}
+	ids := make([]uuid.UUID, 0, len(values))
+	for _, v := range values {
+		id, err := uuid.Parse(v)
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func parseOptionalUUID(value *string) (*uuid.UUID, error) {
+	if value == nil {
+		return nil, nil
+	}
+	id, err := uuid.Parse(*value)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func optionalString(id *uuid.UUID) *string {
+	if id == nil {
+		return nil
+	}
+	s := id.String()
+	return &s
+}
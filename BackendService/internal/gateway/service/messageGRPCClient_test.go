@@ -0,0 +1,301 @@
+package service
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/config"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/gateway/websocket"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/message/handler"
+	messageservice "github.com/huangrao121/CommunicationApp/BackendService/internal/message/service"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/pb/messagepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// 测试用的群：memberGroup有两个成员，brokenGroup的查询返回数据库错误
+var (
+	memberGroup = uuid.MustParse("6f9619ff-8b86-4011-b42d-00c04fc964ff")
+	brokenGroup = uuid.MustParse("00000000-0000-4000-8000-000000000bad")
+	members     = []string{"0b5d7c1e-1f6a-4d1e-9c37-5f0f3c0e1a01", "0b5d7c1e-1f6a-4d1e-9c37-5f0f3c0e1a02"}
+)
+
+// fakeDriver Message Service只需要查询群成员和按ID加载消息，消息总是不存在
+type fakeDriver struct{}
+
+func (fakeDriver) Open(string) (driver.Conn, error) { return fakeConn{}, nil }
+
+type fakeConn struct{}
+
+func (fakeConn) Prepare(string) (driver.Stmt, error) { return nil, errors.New("prepare not supported") }
+func (fakeConn) Close() error                        { return nil }
+func (fakeConn) Begin() (driver.Tx, error)           { return fakeTx{}, nil }
+
+func (fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	if !strings.Contains(query, "group_members") {
+		return &fakeRows{columns: []string{"id"}}, nil
+	}
+	if len(args) > 0 && args[0].Value == brokenGroup.String() {
+		return nil, errors.New("connection reset by peer")
+	}
+	rows := &fakeRows{columns: []string{"user_id"}}
+	if len(args) > 0 && args[0].Value == memberGroup.String() {
+		rows.values = members
+	}
+	return rows, nil
+}
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+type fakeRows struct {
+	columns []string
+	values  []string
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	dest[0], r.values = r.values[0], r.values[1:]
+	return nil
+}
+
+func init() {
+	sql.Register("grpcclienttest", fakeDriver{})
+}
+
+// faults 服务端拦截器，按方法注入UNAVAILABLE或阻塞到调用方超时，并记录每个方法收到的调用次数
+type faults struct {
+	mu          sync.Mutex
+	calls       map[string]int
+	unavailable map[string]int // 方法的前n次调用返回UNAVAILABLE，-1表示一直返回
+	block       map[string]bool
+}
+
+func newFaults() *faults {
+	return &faults{calls: make(map[string]int), unavailable: make(map[string]int), block: make(map[string]bool)}
+}
+
+func (f *faults) intercept(ctx context.Context, req any, info *grpc.UnaryServerInfo, next grpc.UnaryHandler) (any, error) {
+	f.mu.Lock()
+	f.calls[info.FullMethod]++
+	call := f.calls[info.FullMethod]
+	n := f.unavailable[info.FullMethod]
+	block := f.block[info.FullMethod]
+	f.mu.Unlock()
+
+	if n < 0 || call <= n {
+		return nil, status.Error(codes.Unavailable, "injected unavailable")
+	}
+	if block {
+		<-ctx.Done()
+		return nil, status.FromContextError(ctx.Err()).Err()
+	}
+	return next(ctx, req)
+}
+
+func (f *faults) count(method string) int {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.calls[method]
+}
+
+// startMessageService 在bufconn上运行MessageGRPCServer，返回连接到它的MessageGRPCClient
+func startMessageService(t *testing.T, cfg config.GRPCConfig, f *faults) *MessageGRPCClient {
+	t.Helper()
+	db, err := gorm.Open(postgres.New(postgres.Config{DriverName: "grpcclienttest"}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	messageService := messageservice.NewMessageService(db, nil, config.MessageConfig{}, nil)
+
+	lis := bufconn.Listen(1 << 20)
+	server := grpc.NewServer(grpc.UnaryInterceptor(f.intercept))
+	messagepb.RegisterMessageServiceServer(server, handler.NewMessageGRPCServer(messageService))
+	go server.Serve(lis)
+	t.Cleanup(server.Stop)
+
+	cfg.MessageServiceAddr = "static:///bufnet"
+	client, err := newMessageGRPCClient(cfg, nil, grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+		return lis.DialContext(ctx)
+	}))
+	if err != nil {
+		t.Fatalf("create client: %v", err)
+	}
+	t.Cleanup(func() { client.Close() })
+	return client
+}
+
+func TestGetGroupMembers(t *testing.T) {
+	client := startMessageService(t, config.GRPCConfig{}, newFaults())
+
+	got, err := client.GetGroupMembers(context.Background(), memberGroup)
+	if err != nil {
+		t.Fatalf("GetGroupMembers: %v", err)
+	}
+	if len(got) != len(members) || got[0].String() != members[0] || got[1].String() != members[1] {
+		t.Errorf("members = %v, want %v", got, members)
+	}
+}
+
+func TestIdempotentCallRetriesUnavailable(t *testing.T) {
+	f := newFaults()
+	f.unavailable[messagepb.MessageService_GetGroupMembers_FullMethodName] = 2
+	client := startMessageService(t, config.GRPCConfig{MaxAttempts: 3, Timeout: 5 * time.Second}, f)
+
+	if _, err := client.GetGroupMembers(context.Background(), memberGroup); err != nil {
+		t.Fatalf("GetGroupMembers: %v, want success on the third attempt", err)
+	}
+	if got := f.count(messagepb.MessageService_GetGroupMembers_FullMethodName); got != 3 {
+		t.Errorf("server received %d calls, want 3", got)
+	}
+}
+
+func TestIdempotentCallStopsAtMaxAttempts(t *testing.T) {
+	f := newFaults()
+	f.unavailable[messagepb.MessageService_GetGroupMembers_FullMethodName] = -1
+	client := startMessageService(t, config.GRPCConfig{MaxAttempts: 3, Timeout: 5 * time.Second, EjectionThreshold: 10}, f)
+
+	_, err := client.GetGroupMembers(context.Background(), memberGroup)
+	if err == nil || !strings.Contains(err.Error(), codes.Unavailable.String()) {
+		t.Fatalf("err = %v, want Unavailable", err)
+	}
+	if got := f.count(messagepb.MessageService_GetGroupMembers_FullMethodName); got != 3 {
+		t.Errorf("server received %d calls, want 3", got)
+	}
+}
+
+// TestNonIdempotentCallNotRetried 编辑重试可能产生多余的版本，UNAVAILABLE时直接返回
+func TestNonIdempotentCallNotRetried(t *testing.T) {
+	f := newFaults()
+	f.unavailable[messagepb.MessageService_EditMessage_FullMethodName] = -1
+	client := startMessageService(t, config.GRPCConfig{MaxAttempts: 3, Timeout: 5 * time.Second}, f)
+
+	_, err := client.EditMessage(context.Background(), &websocket.EditMessageRequest{
+		MessageID: uuid.New(),
+		ChatType:  "p2p",
+		EditorID:  uuid.New(),
+		Content:   "edited",
+	})
+	if err == nil || !strings.Contains(err.Error(), codes.Unavailable.String()) {
+		t.Fatalf("err = %v, want Unavailable", err)
+	}
+	if got := f.count(messagepb.MessageService_EditMessage_FullMethodName); got != 1 {
+		t.Errorf("server received %d calls, want 1", got)
+	}
+}
+
+func TestCallDeadline(t *testing.T) {
+	f := newFaults()
+	f.block[messagepb.MessageService_SendP2PMessage_FullMethodName] = true
+	client := startMessageService(t, config.GRPCConfig{Timeout: 200 * time.Millisecond}, f)
+	req := &websocket.SendP2PRequest{SenderID: uuid.New(), ReceiverID: uuid.New(), Content: "hi"}
+
+	t.Run("client timeout", func(t *testing.T) {
+		start := time.Now()
+		_, err := client.SendP2PMessage(context.Background(), req)
+		if err == nil || !strings.Contains(err.Error(), codes.DeadlineExceeded.String()) {
+			t.Fatalf("err = %v, want DeadlineExceeded", err)
+		}
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("call took %v, want about 200ms", elapsed)
+		}
+	})
+
+	t.Run("caller deadline is earlier", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		defer cancel()
+		start := time.Now()
+		_, err := client.SendP2PMessage(ctx, req)
+		if err == nil || !strings.Contains(err.Error(), codes.DeadlineExceeded.String()) {
+			t.Fatalf("err = %v, want DeadlineExceeded", err)
+		}
+		if elapsed := time.Since(start); elapsed >= 200*time.Millisecond {
+			t.Errorf("call took %v, want the caller's 50ms deadline", elapsed)
+		}
+	})
+}
+
+// TestStatusMapping Message Service的错误经gRPC状态码传到网关，网关的错误里带有状态码和原因
+func TestStatusMapping(t *testing.T) {
+	client := startMessageService(t, config.GRPCConfig{}, newFaults())
+	ctx := context.Background()
+
+	tests := []struct {
+		name    string
+		call    func() error
+		code    codes.Code
+		message string
+	}{
+		{
+			name: "empty content",
+			call: func() error {
+				_, err := client.EditMessage(ctx, &websocket.EditMessageRequest{MessageID: uuid.New(), ChatType: "p2p", EditorID: uuid.New()})
+				return err
+			},
+			code:    codes.InvalidArgument,
+			message: messageservice.ErrEmptyMessageContent.Error(),
+		},
+		{
+			name: "invalid chat type",
+			call: func() error {
+				_, err := client.RecallMessage(ctx, &websocket.RecallMessageRequest{MessageID: uuid.New(), ChatType: "channel", OperatorID: uuid.New()})
+				return err
+			},
+			code:    codes.InvalidArgument,
+			message: messageservice.ErrInvalidChatType.Error(),
+		},
+		{
+			name: "message not found",
+			call: func() error {
+				_, err := client.RecallMessage(ctx, &websocket.RecallMessageRequest{MessageID: uuid.New(), ChatType: "p2p", OperatorID: uuid.New()})
+				return err
+			},
+			code:    codes.NotFound,
+			message: messageservice.ErrMessageNotFound.Error(),
+		},
+		{
+			name: "database error",
+			call: func() error {
+				_, err := client.GetGroupMembers(ctx, brokenGroup)
+				return err
+			},
+			code:    codes.Internal,
+			message: "connection reset by peer",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.call()
+			if err == nil {
+				t.Fatal("err = nil")
+			}
+			want := "message service returned " + tt.code.String() + ": "
+			if !strings.HasPrefix(err.Error(), want) || !strings.Contains(err.Error(), tt.message) {
+				t.Errorf("err = %q, want prefix %q containing %q", err, want, tt.message)
+			}
+		})
+	}
+}
@@ -0,0 +1,76 @@
+package websocket
+
+import (
+	"log/slog"
+
+	"github.com/gorilla/websocket"
+)
+
+const defaultSendBufferSize = 256
+
+// 发送缓冲区满时的处理策略
+const (
+	// SlowConsumerDisconnect 断开连接，客户端重连后通过拉取历史消息补齐
+	SlowConsumerDisconnect = "disconnect"
+	// SlowConsumerDropOldest 丢弃缓冲区里最旧的一条消息，连接保持
+	SlowConsumerDropOldest = "drop_oldest"
+)
+
+// sendResult trySend的结果
+type sendResult int
+
+const (
+	sendNotRegistered sendResult = iota // 连接已经被移除或替换
+	sendDelivered
+	sendDropped    // drop_oldest策略下丢弃了旧消息后写入
+	sendBufferFull // 缓冲区满，需要按disconnect策略断开
+)
+
+// closeReason 服务端主动关闭连接的原因，writePump在关闭连接前通过关闭帧发给客户端
+type closeReason struct {
+	code int
+	text string
+}
+
+var (
+	closeNormal       = closeReason{websocket.CloseNormalClosure, ""}
+	closeReplaced     = closeReason{websocket.CloseNormalClosure, "replaced by new connection"}
+	closeRevoked      = closeReason{websocket.ClosePolicyViolation, "session revoked"}
+	closeSlowConsumer = closeReason{websocket.CloseTryAgainLater, "slow consumer"}
+)
+
+// HubStats 本节点的连接和背压统计
+type HubStats struct {
+	NodeID                  string `json:"node_id"`
+	Connections             int    `json:"connections"`
+	OnlineUsers             int    `json:"online_users"`
+	DroppedMessages         int64  `json:"dropped_messages"`
+	SlowConsumerDisconnects int64  `json:"slow_consumer_disconnects"`
+}
+
+// Stats 返回本节点当前的连接数和累计的丢弃、断开次数
+func (h *Hub) Stats() HubStats {
+	connections, users := h.clients.count()
+	return HubStats{
+		NodeID:                  h.RedisManager.GetNodeID(),
+		Connections:             connections,
+		OnlineUsers:             users,
+		DroppedMessages:         h.droppedMessages.Load(),
+		SlowConsumerDisconnects: h.slowConsumerDisconnects.Load(),
+	}
+}
+
+// deliver 把消息写入连接的发送缓冲区，缓冲区满时按慢消费者策略处理
+func (h *Hub) deliver(client *Client, data []byte) {
+	result, dropped := h.clients.trySend(client, data, h.dropOldest)
+	switch result {
+	case sendDropped:
+		h.droppedMessages.Add(int64(dropped))
+	case sendBufferFull:
+		h.droppedMessages.Add(1)
+		if h.clients.remove(client, closeSlowConsumer) {
+			h.slowConsumerDisconnects.Add(1)
+			slog.WarnContext(client.logCtx, "Client disconnected", "reason", "send_buffer_full")
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package websocket
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func newTestHub(dropOldest bool) *Hub {
+	return &Hub{
+		clients:      newClientRegistry(4),
+		RedisManager: &RedisManager{nodeID: "test-node"},
+		dropOldest:   dropOldest,
+	}
+}
+
+// fillBuffer 写满缓冲区，每条消息都应该直接投递
+func fillBuffer(t *testing.T, h *Hub, client *Client, messages ...string) {
+	t.Helper()
+	for _, m := range messages {
+		h.deliver(client, []byte(m))
+	}
+	if got := h.droppedMessages.Load(); got != 0 {
+		t.Fatalf("dropped %d messages while filling the buffer", got)
+	}
+}
+
+func drain(ch chan []byte) []string {
+	var messages []string
+	for {
+		select {
+		case data, ok := <-ch:
+			if !ok {
+				return messages
+			}
+			messages = append(messages, string(data))
+		default:
+			return messages
+		}
+	}
+}
+
+func TestDeliverDropOldest(t *testing.T) {
+	h := newTestHub(true)
+	client := newTestClient(uuid.New(), "phone", 2)
+	h.clients.add(client)
+
+	fillBuffer(t, h, client, "1", "2")
+	h.deliver(client, []byte("3"))
+	h.deliver(client, []byte("4"))
+
+	if got := drain(client.send); len(got) != 2 || got[0] != "3" || got[1] != "4" {
+		t.Errorf("buffer = %v, want [3 4]", got)
+	}
+	stats := h.Stats()
+	if stats.DroppedMessages != 2 {
+		t.Errorf("DroppedMessages = %d, want 2", stats.DroppedMessages)
+	}
+	if stats.SlowConsumerDisconnects != 0 {
+		t.Errorf("SlowConsumerDisconnects = %d, want 0", stats.SlowConsumerDisconnects)
+	}
+	if stats.Connections != 1 {
+		t.Errorf("Connections = %d, want 1, drop_oldest must keep the connection", stats.Connections)
+	}
+}
+
+func TestDeliverDisconnect(t *testing.T) {
+	h := newTestHub(false)
+	client := newTestClient(uuid.New(), "phone", 2)
+	h.clients.add(client)
+
+	fillBuffer(t, h, client, "1", "2")
+	h.deliver(client, []byte("3"))
+
+	// 已经写入的消息保留，通道关闭后writePump发完再断开
+	if got := drain(client.send); len(got) != 2 || got[0] != "1" || got[1] != "2" {
+		t.Errorf("buffer = %v, want [1 2]", got)
+	}
+	if !sendClosed(client.send) {
+		t.Error("send channel is not closed after disconnect")
+	}
+	if client.closeReason != closeSlowConsumer {
+		t.Errorf("closeReason = %v, want %v", client.closeReason, closeSlowConsumer)
+	}
+
+	// 断开后的投递直接忽略，不再计数
+	h.deliver(client, []byte("4"))
+
+	stats := h.Stats()
+	if stats.DroppedMessages != 1 {
+		t.Errorf("DroppedMessages = %d, want 1", stats.DroppedMessages)
+	}
+	if stats.SlowConsumerDisconnects != 1 {
+		t.Errorf("SlowConsumerDisconnects = %d, want 1", stats.SlowConsumerDisconnects)
+	}
+	if stats.Connections != 0 {
+		t.Errorf("Connections = %d, want 0", stats.Connections)
+	}
+}
+
+// TestDeliverDisconnectConcurrent 多个goroutine同时向缓冲区已满的连接投递，
+// 连接只能被断开一次
+func TestDeliverDisconnectConcurrent(t *testing.T) {
+	h := newTestHub(false)
+	client := newTestClient(uuid.New(), "phone", 1)
+	h.clients.add(client)
+	fillBuffer(t, h, client, "1")
+
+	const senders = 16
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			h.deliver(client, []byte("x"))
+		}()
+	}
+	wg.Wait()
+
+	if got := h.slowConsumerDisconnects.Load(); got != 1 {
+		t.Errorf("slowConsumerDisconnects = %d, want 1", got)
+	}
+	// 第一个发现缓冲区满的投递计为丢弃，其余的投递时连接已经不在表中
+	if got := h.droppedMessages.Load(); got < 1 || got > senders {
+		t.Errorf("droppedMessages = %d, want between 1 and %d", got, senders)
+	}
+}
+
+func TestDeliverDropOldestConcurrent(t *testing.T) {
+	h := newTestHub(true)
+	client := newTestClient(uuid.New(), "phone", 4)
+	h.clients.add(client)
+
+	const senders, perSender = 8, 50
+	var wg sync.WaitGroup
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < perSender; j++ {
+				h.deliver(client, []byte("x"))
+			}
+		}()
+	}
+	wg.Wait()
+
+	buffered := int64(len(client.send))
+	if got := h.droppedMessages.Load() + buffered; got != senders*perSender {
+		t.Errorf("dropped + buffered = %d, want %d", got, senders*perSender)
+	}
+	if got := h.slowConsumerDisconnects.Load(); got != 0 {
+		t.Errorf("slowConsumerDisconnects = %d, want 0", got)
+	}
+}
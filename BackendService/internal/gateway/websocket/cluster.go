@@ -0,0 +1,189 @@
+package websocket
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/config"
+)
+
+const defaultNodeTTL = 15 * time.Second
+
+// NodeInfo 集群中的一个网关节点
+type NodeInfo struct {
+	NodeID        string `json:"node_id"`
+	LastHeartbeat int64  `json:"last_heartbeat"`
+	Alive         bool   `json:"alive"`
+	Self          bool   `json:"self"`
+}
+
+// clusterView 本节点看到的集群成员，每次心跳时从Redis刷新
+type clusterView struct {
+	mu    sync.RWMutex
+	nodes map[string]time.Time // nodeID -> 最后一次心跳时间
+	ttl   time.Duration
+}
+
+func newClusterView(ttl time.Duration) *clusterView {
+	return &clusterView{nodes: make(map[string]time.Time), ttl: ttl}
+}
+
+func (v *clusterView) update(nodes map[string]time.Time) {
+	v.mu.Lock()
+	v.nodes = nodes
+	v.mu.Unlock()
+}
+
+// alive 节点是否仍有心跳。视图里还没有的节点可能是刚刚加入的，按在线处理
+func (v *clusterView) alive(nodeID string) bool {
+	v.mu.RLock()
+	lastHeartbeat, ok := v.nodes[nodeID]
+	v.mu.RUnlock()
+	return !ok || time.Since(lastHeartbeat) < v.ttl
+}
+
+// resolveNodeID 节点ID优先使用配置，其次使用主机名(Kubernetes中即pod名)，都拿不到时随机生成
+func resolveNodeID(cfg config.GatewayConfig) string {
+	if cfg.NodeID != "" {
+		return cfg.NodeID
+	}
+	hostname, err := os.Hostname()
+	if err == nil && hostname != "" {
+		return hostname
+	}
+	nodeID := uuid.NewString()
+	slog.Warn("Failed to get hostname, using random node ID", "node_id", nodeID, "error", err)
+	return nodeID
+}
+
+// JoinCluster 注册节点并清理同一nodeID上次运行遗留的会话记录，必须在接受连接之前调用
+func (h *Hub) JoinCluster(ctx context.Context) error {
+	nodeID := h.RedisManager.GetNodeID()
+	nodes, err := h.RedisManager.GetNodes(ctx)
+	if err != nil {
+		return err
+	}
+	if lastHeartbeat, ok := nodes[nodeID]; ok && time.Since(lastHeartbeat) < h.cluster.ttl {
+		// 也可能是刚刚重启，两个进程使用同一个nodeID时会互相清理对方的会话
+		slog.Warn("Node still has a recent heartbeat, make sure no other gateway uses the same node ID", "node_id", nodeID)
+	}
+	removed, err := h.RedisManager.RemoveNodeSessions(ctx, nodeID)
+	if err != nil {
+		return err
+	}
+	if removed > 0 {
+		slog.Info("Removed sessions left by the previous run of node", "node_id", nodeID, "sessions", removed)
+	}
+	if err := h.RedisManager.HeartbeatNode(ctx, nodeID); err != nil {
+		return err
+	}
+	slog.Info("Gateway node joined the cluster", "node_id", nodeID)
+	return nil
+}
+
+// runMembership 定期发送心跳、刷新集群视图并清理下线节点的会话。ctx取消后退出集群
+func (h *Hub) runMembership(ctx context.Context) {
+	ticker := time.NewTicker(h.cluster.ttl / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			h.leaveCluster()
+			return
+		case <-ticker.C:
+			if err := h.RedisManager.HeartbeatNode(ctx, h.RedisManager.GetNodeID()); err != nil {
+				slog.Error("Failed to send node heartbeat", "error", err)
+			}
+			h.refreshCluster(ctx)
+		}
+	}
+}
+
+// refreshCluster 刷新集群视图，并清理超过ttl没有心跳的节点
+func (h *Hub) refreshCluster(ctx context.Context) {
+	nodes, err := h.RedisManager.GetNodes(ctx)
+	if err != nil {
+		slog.Error("Failed to get cluster nodes", "error", err)
+		return
+	}
+	h.cluster.update(nodes)
+
+	for nodeID, lastHeartbeat := range nodes {
+		if nodeID == h.RedisManager.GetNodeID() || time.Since(lastHeartbeat) < h.cluster.ttl {
+			continue
+		}
+		locked, err := h.RedisManager.LockNodeCleanup(ctx, nodeID, h.cluster.ttl)
+		if err != nil {
+			slog.Error("Failed to lock node cleanup", "node_id", nodeID, "error", err)
+			continue
+		}
+		if !locked {
+			continue
+		}
+		removed, err := h.RedisManager.RemoveNodeSessions(ctx, nodeID)
+		if err != nil {
+			slog.Error("Failed to remove node sessions", "node_id", nodeID, "error", err)
+			continue
+		}
+		if err := h.RedisManager.RemoveNode(ctx, nodeID); err != nil {
+			slog.Error("Failed to remove node", "node_id", nodeID, "error", err)
+		}
+		slog.Warn("Node stopped heartbeating, removed its sessions", "node_id", nodeID, "sessions", removed)
+	}
+}
+
+// leaveCluster 主动退出集群，其它节点不需要等到ttl过期
+func (h *Hub) leaveCluster() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	nodeID := h.RedisManager.GetNodeID()
+	if _, err := h.RedisManager.RemoveNodeSessions(ctx, nodeID); err != nil {
+		slog.Error("Failed to remove node sessions", "node_id", nodeID, "error", err)
+	}
+	if err := h.RedisManager.RemoveNode(ctx, nodeID); err != nil {
+		slog.Error("Failed to remove node", "node_id", nodeID, "error", err)
+	}
+	slog.Info("Gateway node left the cluster", "node_id", nodeID)
+}
+
+// ClusterNodes 返回Redis中记录的所有网关节点，按nodeID排序
+func (h *Hub) ClusterNodes(ctx context.Context) ([]NodeInfo, error) {
+	nodes, err := h.RedisManager.GetNodes(ctx)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]NodeInfo, 0, len(nodes))
+	for nodeID, lastHeartbeat := range nodes {
+		infos = append(infos, NodeInfo{
+			NodeID:        nodeID,
+			LastHeartbeat: lastHeartbeat.Unix(),
+			Alive:         time.Since(lastHeartbeat) < h.cluster.ttl,
+			Self:          nodeID == h.RedisManager.GetNodeID(),
+		})
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].NodeID < infos[j].NodeID })
+	return infos, nil
+}
+
+// removeNodeUser 用户在本节点已经没有连接时从节点的用户集合中移除。
+// 移除后再检查一次，期间有新连接注册时重新加入，避免新连接的会话在节点下线后清理不到
+func (h *Hub) removeNodeUser(ctx context.Context, userID uuid.UUID) {
+	if len(h.clients.get(userID, "", "")) > 0 {
+		return
+	}
+	nodeID := h.RedisManager.GetNodeID()
+	if err := h.RedisManager.RemoveNodeUser(ctx, nodeID, userID.String()); err != nil {
+		slog.ErrorContext(ctx, "Failed to remove user from node", "error", err)
+		return
+	}
+	if len(h.clients.get(userID, "", "")) > 0 {
+		if err := h.RedisManager.AddNodeUser(ctx, nodeID, userID.String()); err != nil {
+			slog.ErrorContext(ctx, "Failed to add user to node", "error", err)
+		}
+	}
+}
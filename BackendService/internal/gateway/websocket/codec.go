@@ -0,0 +1,218 @@
+package websocket
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/pb/gatewaypb"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/proto"
+	"google.golang.org/protobuf/reflect/protoreflect"
+)
+
+// wireFormat 连接的帧格式，由协议版本和编码决定
+type wireFormat int
+
+const (
+	formatJSONv1 wireFormat = iota
+	formatJSONv2
+	formatProtobuf
+	formatCount
+)
+
+const (
+	EncodingJSON     = "json"
+	EncodingProtobuf = "protobuf"
+)
+
+// SubprotocolSchema 一个子协议对应的协议版本和编码
+type SubprotocolSchema struct {
+	Version  int    `json:"version"`
+	Encoding string `json:"encoding"`
+}
+
+// 握手时通过Sec-WebSocket-Protocol协商版本和编码，按服务端的偏好排序；
+// 客户端没有声明子协议时按v1 JSON处理，兼容老客户端
+var subprotocols = []string{"chat.v2+proto", "chat.v2", "chat.v1"}
+
+var subprotocolFormats = map[string]SubprotocolSchema{
+	"chat.v1":       {Version: ProtocolV1, Encoding: EncodingJSON},
+	"chat.v2":       {Version: ProtocolV2, Encoding: EncodingJSON},
+	"chat.v2+proto": {Version: ProtocolV2, Encoding: EncodingProtobuf},
+}
+
+func (c *Client) format() wireFormat {
+	switch {
+	case c.protobuf:
+		return formatProtobuf
+	case c.protocolVersion >= ProtocolV2:
+		return formatJSONv2
+	default:
+		return formatJSONv1
+	}
+}
+
+// outgoingFrame 一条待发送的消息，按连接的帧格式延迟序列化并缓存。
+// 扇出给多个连接时每种格式只序列化一次；不是并发安全的，只在一个goroutine里使用
+type outgoingFrame struct {
+	message OutgoingMessage
+	frames  [formatCount][]byte
+	errs    [formatCount]error
+	encoded [formatCount]bool
+}
+
+func newOutgoingFrame(message OutgoingMessage) *outgoingFrame {
+	return &outgoingFrame{message: message}
+}
+
+func (f *outgoingFrame) bytes(format wireFormat) ([]byte, error) {
+	if !f.encoded[format] {
+		f.frames[format], f.errs[format] = encodeFrame(format, f.message)
+		f.encoded[format] = true
+	}
+	return f.frames[format], f.errs[format]
+}
+
+func encodeFrame(format wireFormat, message OutgoingMessage) ([]byte, error) {
+	switch format {
+	case formatProtobuf:
+		return encodeProtobuf(message)
+	case formatJSONv2:
+		return json.Marshal(envelope{
+			Version:   ProtocolV2,
+			RequestID: message.RequestID,
+			Type:      message.Type,
+			Payload:   message.Data,
+			Timestamp: message.Timestamp,
+			Silent:    message.Silent,
+			TraceID:   message.TraceID,
+		})
+	default:
+		return json.Marshal(message)
+	}
+}
+
+// decodeIncoming 按连接的编码解析上行帧
+func decodeIncoming(userMsg UserMessage) (IncomingMessage, error) {
+	if userMsg.Protobuf {
+		return decodeProtobuf(userMsg.Payload)
+	}
+	var incoming IncomingMessage
+	err := json.Unmarshal(userMsg.Payload, &incoming)
+	return incoming, err
+}
+
+// protobuf帧的payload是oneof，按事件类型选择字段。
+// payload先按JSON序列化再用protojson转换，跨节点转发后Data变成map也能正确编码
+var eventPayloadFields = map[string]protoreflect.Name{
+	"connection_established":        "connection_established",
+	"nack":                          "error",
+	"error":                         "error",
+	"message_sent":                  "message_response",
+	"message_edited":                "message_update",
+	"message_recalled":              "message_update",
+	"reaction_updated":              "reaction_update",
+	"token_refreshed":               "refresh_token_result",
+	"new_p2p_message":               "new_p2p_message",
+	"new_group_message":             "new_group_message",
+	"mention":                       "mention",
+	"typing_indicator":              "typing_indicator",
+	"message_deleted":               "message_deleted",
+	"conversation_settings_updated": "conversation_settings",
+	"conversation_pins_updated":     "conversation_pins",
+	"session_revoked":               "session_revoked",
+	"token_expiring":                "token_expiry",
+	"token_expired":                 "token_expiry",
+	"reconnect":                     "reconnect",
+}
+
+// ack的payload是请求的处理结果，按结果类型选择字段
+var resultPayloadFields = map[reflect.Type]protoreflect.Name{
+	reflect.TypeOf(MessageResponse{}):        "message_response",
+	reflect.TypeOf(MessageUpdateResponse{}):  "message_update",
+	reflect.TypeOf(ReactionUpdateResponse{}): "reaction_update",
+	reflect.TypeOf(RefreshTokenResult{}):     "refresh_token_result",
+}
+
+var (
+	serverFrameFields  = (&gatewaypb.ServerFrame{}).ProtoReflect().Descriptor().Fields()
+	clientPayloadOneof = (&gatewaypb.ClientFrame{}).ProtoReflect().Descriptor().Oneofs().ByName("payload")
+
+	protoJSONIn  = protojson.UnmarshalOptions{DiscardUnknown: true}
+	protoJSONOut = protojson.MarshalOptions{UseProtoNames: true}
+)
+
+func encodeProtobuf(message OutgoingMessage) ([]byte, error) {
+	frame := &gatewaypb.ServerFrame{
+		V:         ProtocolV2,
+		Id:        message.RequestID,
+		Type:      message.Type,
+		Timestamp: message.Timestamp,
+		Silent:    message.Silent,
+		TraceId:   message.TraceID,
+	}
+	if message.Data != nil {
+		if err := setProtobufPayload(frame.ProtoReflect(), message); err != nil {
+			return nil, err
+		}
+	}
+	return proto.Marshal(frame)
+}
+
+func setProtobufPayload(frame protoreflect.Message, message OutgoingMessage) error {
+	name, ok := eventPayloadFields[message.Type]
+	if message.Type == "ack" {
+		t := reflect.TypeOf(message.Data)
+		if t.Kind() == reflect.Ptr {
+			t = t.Elem()
+		}
+		name, ok = resultPayloadFields[t]
+	}
+	if !ok {
+		return fmt.Errorf("no protobuf payload for %s", message.Type)
+	}
+
+	raw, err := json.Marshal(message.Data)
+	if err != nil {
+		return err
+	}
+	fd := serverFrameFields.ByName(name)
+	payload := frame.NewField(fd)
+	if err := protoJSONIn.Unmarshal(raw, payload.Message().Interface()); err != nil {
+		return fmt.Errorf("encode %s payload: %w", message.Type, err)
+	}
+	frame.Set(fd, payload)
+	return nil
+}
+
+// decodeProtobuf 把ClientFrame转换成IncomingMessage，payload转成JSON交给各请求的处理函数，
+// 处理函数不需要区分编码
+func decodeProtobuf(data []byte) (IncomingMessage, error) {
+	var frame gatewaypb.ClientFrame
+	if err := proto.Unmarshal(data, &frame); err != nil {
+		return IncomingMessage{}, err
+	}
+	incoming := IncomingMessage{
+		Version:     int(frame.V),
+		RequestID:   frame.Id,
+		Type:        frame.Type,
+		Traceparent: frame.Traceparent,
+		Tracestate:  frame.Tracestate,
+	}
+
+	m := frame.ProtoReflect()
+	fd := m.WhichOneof(clientPayloadOneof)
+	if fd == nil {
+		return incoming, nil
+	}
+	if incoming.Type == "" {
+		incoming.Type = string(fd.Name())
+	}
+	payload, err := protoJSONOut.Marshal(m.Get(fd).Message().Interface())
+	if err != nil {
+		return IncomingMessage{}, err
+	}
+	incoming.Payload = payload
+	return incoming, nil
+}
@@ -0,0 +1,146 @@
+package websocket
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/pb/gatewaypb"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"google.golang.org/protobuf/proto"
+)
+
+// 各子协议对应的帧格式，benchmark按子协议名输出
+var benchFormats = []struct {
+	subprotocol string
+	format      wireFormat
+}{
+	{"chat.v1", formatJSONv1},
+	{"chat.v2", formatJSONv2},
+	{"chat.v2+proto", formatProtobuf},
+}
+
+// sampleMessages 最常见的三种下行消息：群消息推送、发送确认和输入状态
+func sampleMessages() []OutgoingMessage {
+	now := time.Now()
+	senderID, mentioned := uuid.New(), uuid.New()
+	return []OutgoingMessage{
+		{
+			Type: "new_group_message",
+			Data: GroupMessageEvent{
+				ID:          uuid.New(),
+				SenderID:    senderID,
+				GroupID:     uuid.New(),
+				Content:     "@alice the release notes are ready for review, see the doc pinned in this group",
+				ContentType: 0,
+				Mentions:    []types.MentionEntity{{UserID: mentioned, Username: "alice", Offset: 0, Length: 6}},
+				Timestamp:   now.UnixMilli(),
+			},
+			Timestamp: now.Unix(),
+		},
+		{
+			Type:      "ack",
+			RequestID: "c5a1f0e2-7",
+			Data: MessageResponse{
+				ID:        uuid.New(),
+				Success:   true,
+				Timestamp: now.UnixMilli(),
+			},
+			Timestamp: now.Unix(),
+		},
+		{
+			Type: "typing_indicator",
+			Data: map[string]interface{}{
+				"user_id":   senderID,
+				"is_typing": true,
+			},
+			Timestamp: now.Unix(),
+		},
+	}
+}
+
+// sampleClientFrames 同一条send_group_message请求在各帧格式下的上行帧
+func sampleClientFrames(tb testing.TB) map[wireFormat][]byte {
+	tb.Helper()
+	groupID := uuid.New().String()
+	content := "the release notes are ready for review"
+
+	v1 := fmt.Sprintf(`{"type":"send_group_message","data":{"group_id":%q,"content":%q,"content_type":0}}`, groupID, content)
+	v2 := fmt.Sprintf(`{"v":2,"id":"c5a1f0e2-7","type":"send_group_message","payload":{"group_id":%q,"content":%q,"content_type":0}}`, groupID, content)
+	pb, err := proto.Marshal(&gatewaypb.ClientFrame{
+		V:  ProtocolV2,
+		Id: "c5a1f0e2-7",
+		Payload: &gatewaypb.ClientFrame_SendGroupMessage{SendGroupMessage: &gatewaypb.SendGroupRequest{
+			GroupId: groupID,
+			Content: content,
+		}},
+	})
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return map[wireFormat][]byte{formatJSONv1: []byte(v1), formatJSONv2: []byte(v2), formatProtobuf: pb}
+}
+
+// BenchmarkEncodeFrame 下行事件在每种帧格式下的编码开销，frame_bytes是帧的大小
+func BenchmarkEncodeFrame(b *testing.B) {
+	for _, m := range sampleMessages() {
+		for _, f := range benchFormats {
+			b.Run(m.Type+"/"+f.subprotocol, func(b *testing.B) {
+				data, err := encodeFrame(f.format, m)
+				if err != nil {
+					b.Fatalf("encode %s as %s: %v", m.Type, f.subprotocol, err)
+				}
+				b.ReportAllocs()
+				b.ReportMetric(float64(len(data)), "frame_bytes")
+				for i := 0; i < b.N; i++ {
+					encodeFrame(f.format, m)
+				}
+			})
+		}
+	}
+}
+
+// BenchmarkGroupFanoutEncode 群消息扇出给fanout个连接：per-client每个连接序列化一次，
+// encode-once通过outgoingFrame每种格式只序列化一次
+func BenchmarkGroupFanoutEncode(b *testing.B) {
+	const fanout = 500
+	group := sampleMessages()[0]
+	for _, f := range benchFormats {
+		b.Run("per-client/"+f.subprotocol, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				for j := 0; j < fanout; j++ {
+					encodeFrame(f.format, group)
+				}
+			}
+		})
+		b.Run("encode-once/"+f.subprotocol, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				frame := newOutgoingFrame(group)
+				for j := 0; j < fanout; j++ {
+					frame.bytes(f.format)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkDecodeIncoming 同一条上行请求在每种帧格式下的解码开销
+func BenchmarkDecodeIncoming(b *testing.B) {
+	frames := sampleClientFrames(b)
+	for _, f := range benchFormats {
+		b.Run("send_group_message/"+f.subprotocol, func(b *testing.B) {
+			userMsg := UserMessage{Payload: frames[f.format], Protobuf: f.format == formatProtobuf}
+			if _, err := decodeIncoming(userMsg); err != nil {
+				b.Fatalf("decode %s: %v", f.subprotocol, err)
+			}
+			b.ReportAllocs()
+			b.ReportMetric(float64(len(userMsg.Payload)), "frame_bytes")
+			for i := 0; i < b.N; i++ {
+				decodeIncoming(userMsg)
+			}
+		})
+	}
+}
@@ -3,286 +3,669 @@ package websocket
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
 	"github.com/huangrao121/CommunicationApp/BackendService/config"
+	"github.com/huangrao121/CommunicationApp/BackendService/config/logger"
+	"github.com/huangrao121/CommunicationApp/BackendService/config/pkg"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/ratelimit"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"github.com/redis/go-redis/v9"
 )
 
 type Hub struct {
-	clients        map[uuid.UUID]*Client
-	register       chan *Client
-	unregister     chan *Client
+	clients        *clientRegistry
+	workers        *workerPool // 处理客户端上行消息，避免慢调用阻塞其他连接
 	broadcast      chan []byte
-	userMessage    chan UserMessage
 	RedisManager   *RedisManager
 	messageService MessageServiceClient // gRPC客户端接口
-	mutex          sync.RWMutex
+	cluster        *clusterView         // 集群成员，见cluster.go
+
+	upgrader websocket.Upgrader
+	opts     connOptions // 连接的超时和大小限制，见limits.go
+
+	ticketTTL time.Duration
+
+	// 优雅关闭，见shutdown.go
+	drainMu         sync.RWMutex
+	draining        bool
+	conns           sync.WaitGroup // 未结束的连接，readPump退出并删除会话记录后Done
+	reconnectJitter time.Duration
+
+	requests map[string]requestType // 客户端请求类型，见requests.go
+
+	// 按用户限流，见ratelimit.go
+	limiter    *ratelimit.Limiter
+	rateLimits map[string]ratelimit.Limit
+
+	// 慢消费者策略，见backpressure.go
+	sendBufferSize          int
+	dropOldest              bool
+	droppedMessages         atomic.Int64
+	slowConsumerDisconnects atomic.Int64
 }
 
 type Client struct {
-	hub      *Hub
-	conn     *websocket.Conn
-	send     chan []byte
-	userID   uuid.UUID
-	username string
+	hub *Hub
+	// connID 每个连接唯一，同一设备重连后是新的connID
+	connID string
+	// logCtx 带有conn_id/user_id/device_id，连接相关的日志都用它记录
+	logCtx      context.Context
+	conn        *websocket.Conn
+	send        chan []byte
+	userID      uuid.UUID
+	username    string
+	deviceID    string
+	deviceName  string
+	connectedAt time.Time
+	closeReason closeReason // 由registry在关闭send通道前写入
+	// 握手时协商的协议版本和编码，连接建立后不再变化
+	protocolVersion int
+	protobuf        bool
+
+	// 当前token的签发时间(unix毫秒)和过期时间(unix秒)，连接内刷新token时由worker更新
+	tokenIssuedAt  atomic.Int64
+	tokenExpiresAt atomic.Int64
+	expiryWarned   atomic.Bool
 }
 
 // data里的内容是IncomingMessage，IncomingMessage里的data是SendP2PRequest
 type UserMessage struct {
-	UserID  uuid.UUID `json:"user_id"`
-	Type    string    `json:"type"`
-	Payload []byte    `json:"payload"`
+	ConnID   string    `json:"conn_id,omitempty"`
+	UserID   uuid.UUID `json:"user_id"`
+	DeviceID string    `json:"device_id"`
+	Version  int       `json:"version"` // 连接协商的协议版本
+	Protobuf bool      `json:"protobuf,omitempty"`
+	Type     string    `json:"type"`
+	Payload  []byte    `json:"payload"`
 }
 
+// IncomingMessage 兼容两种上行格式：v1的{type, data}和v2的{v, id, type, payload}
 type IncomingMessage struct {
-	Type string          `json:"type"`
-	Data json.RawMessage `json:"data"`
+	Version   int             `json:"v,omitempty"`
+	RequestID string          `json:"id,omitempty"`
+	Type      string          `json:"type"`
+	Payload   json.RawMessage `json:"payload,omitempty"`
+	Data      json.RawMessage `json:"data,omitempty"`
+	// W3C trace context，客户端有自己的trace时传入
+	Traceparent string `json:"traceparent,omitempty"`
+	Tracestate  string `json:"tracestate,omitempty"`
 }
 
+func (m *IncomingMessage) body() json.RawMessage {
+	if len(m.Payload) > 0 {
+		return m.Payload
+	}
+	return m.Data
+}
+
+// OutgoingMessage 下行消息，发送时按连接的协议版本编码，见Client.encode
 type OutgoingMessage struct {
 	Type      string      `json:"type"`
 	Data      interface{} `json:"data"`
 	Timestamp int64       `json:"timestamp"`
+	// Silent 接收者对该会话开启了免打扰，客户端只更新界面不提醒
+	Silent bool `json:"silent,omitempty"`
+	// RequestID ack/nack对应的请求ID
+	RequestID string `json:"request_id,omitempty"`
+	// TraceID 处理请求的trace ID，只在ack/nack里出现
+	TraceID string `json:"trace_id,omitempty"`
+}
+
+// CrossNodeMessage 通过Redis转发给用户所在节点的消息。DeviceID为空时投递给用户在该节点上的所有设备，
+// ExcludeDeviceID用于把发送者自己的消息同步到其它设备，Disconnect为true时投递后关闭DeviceID的连接
+type CrossNodeMessage struct {
+	UserID          uuid.UUID       `json:"user_id"`
+	DeviceID        string          `json:"device_id,omitempty"`
+	ExcludeDeviceID string          `json:"exclude_device_id,omitempty"`
+	Disconnect      bool            `json:"disconnect,omitempty"`
+	Message         OutgoingMessage `json:"message"`
+}
+
+// GroupBroadcastMessage 通过group_broadcast频道发给所有节点，每个节点只投递给本地在线的群成员
+type GroupBroadcastMessage struct {
+	GroupID       uuid.UUID       `json:"group_id"`
+	Members       []uuid.UUID     `json:"members"`
+	SilentMembers []uuid.UUID     `json:"silent_members,omitempty"`
+	Message       OutgoingMessage `json:"message"`
+}
+
+// P2PMessageEvent 推送给接收者的单聊消息
+type P2PMessageEvent struct {
+	ID          uuid.UUID  `json:"id"`
+	SenderID    uuid.UUID  `json:"sender_id"`
+	ReceiverID  uuid.UUID  `json:"receiver_id"`
+	Content     string     `json:"content"`
+	ContentType int        `json:"content_type"`
+	ReplyToID   *uuid.UUID `json:"reply_to_id,omitempty"`
+	Timestamp   int64      `json:"timestamp"`
+}
+
+// GroupMessageEvent 推送给群成员的群消息，ThreadRootID不为空时表示是某个话题下的回复
+type GroupMessageEvent struct {
+	ID           uuid.UUID             `json:"id"`
+	SenderID     uuid.UUID             `json:"sender_id"`
+	GroupID      uuid.UUID             `json:"group_id"`
+	Content      string                `json:"content"`
+	ContentType  int                   `json:"content_type"`
+	ReplyToID    *uuid.UUID            `json:"reply_to_id,omitempty"`
+	ThreadRootID *uuid.UUID            `json:"thread_root_id,omitempty"`
+	Mentions     []types.MentionEntity `json:"mentions,omitempty"`
+	MentionAll   bool                  `json:"mention_all,omitempty"`
+	Timestamp    int64                 `json:"timestamp"`
+}
+
+// MentionEvent 单独推送给被@的成员，不受群免打扰设置影响
+type MentionEvent struct {
+	MessageID  uuid.UUID `json:"message_id"`
+	GroupID    uuid.UUID `json:"group_id"`
+	SenderID   uuid.UUID `json:"sender_id"`
+	Content    string    `json:"content"`
+	MentionAll bool      `json:"mention_all"`
+	Timestamp  int64     `json:"timestamp"`
 }
 
 // MessageServiceClient 接口，用于与Message Service通信
 type MessageServiceClient interface {
 	SendP2PMessage(ctx context.Context, req *SendP2PRequest) (*MessageResponse, error)
 	SendGroupMessage(ctx context.Context, req *SendGroupRequest) (*MessageResponse, error)
+	EditMessage(ctx context.Context, req *EditMessageRequest) (*MessageUpdateResponse, error)
+	RecallMessage(ctx context.Context, req *RecallMessageRequest) (*MessageUpdateResponse, error)
+	DeleteMessageForUser(ctx context.Context, req *DeleteMessageRequest) error
+	GetGroupMembers(ctx context.Context, groupID uuid.UUID) ([]uuid.UUID, error)
+	AddReaction(ctx context.Context, req *ReactionRequest) (*ReactionUpdateResponse, error)
+	RemoveReaction(ctx context.Context, req *ReactionRequest) (*ReactionUpdateResponse, error)
+	UpdateConversationSettings(ctx context.Context, req *ConversationSettingsRequest) (*ConversationSettings, error)
+	ReorderPinnedConversations(ctx context.Context, userID uuid.UUID, conversationIDs []uuid.UUID) ([]ConversationSettings, error)
+}
+
+type TypingRequest struct {
+	ReceiverID uuid.UUID `json:"receiver_id"`
+	IsTyping   bool      `json:"is_typing"`
 }
 
 type SendP2PRequest struct {
-	SenderID    uuid.UUID `json:"sender_id"`
-	ReceiverID  uuid.UUID `json:"receiver_id"`
+	SenderID    uuid.UUID  `json:"sender_id"`
+	ReceiverID  uuid.UUID  `json:"receiver_id"`
+	Content     string     `json:"content"`
+	ContentType int        `json:"content_type"`
+	ReplyToID   *uuid.UUID `json:"reply_to_id,omitempty"`
+}
+
+type SendGroupRequest struct {
+	SenderID    uuid.UUID  `json:"sender_id"`
+	GroupID     uuid.UUID  `json:"group_id"`
+	Content     string     `json:"content"`
+	ContentType int        `json:"content_type"`
+	ReplyToID   *uuid.UUID `json:"reply_to_id,omitempty"`
+}
+
+type MessageResponse struct {
+	ID           uuid.UUID             `json:"id"`
+	Success      bool                  `json:"success"`
+	Error        string                `json:"error,omitempty"`
+	ThreadRootID *uuid.UUID            `json:"thread_root_id,omitempty"`
+	Mentions     []types.MentionEntity `json:"mentions,omitempty"`
+	MentionAll   bool                  `json:"mention_all,omitempty"`
+	MutedUserIDs []uuid.UUID           `json:"muted_user_ids,omitempty"`
+	Timestamp    int64                 `json:"timestamp"`
+}
+
+type EditMessageRequest struct {
+	MessageID   uuid.UUID `json:"message_id"`
+	ChatType    string    `json:"chat_type"`
+	EditorID    uuid.UUID `json:"editor_id"`
 	Content     string    `json:"content"`
 	ContentType int       `json:"content_type"`
 }
 
-type SendGroupRequest struct {
+type RecallMessageRequest struct {
+	MessageID  uuid.UUID `json:"message_id"`
+	ChatType   string    `json:"chat_type"`
+	OperatorID uuid.UUID `json:"operator_id"`
+}
+
+type DeleteMessageRequest struct {
+	MessageID uuid.UUID `json:"message_id"`
+	ChatType  string    `json:"chat_type"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+
+// MessageUpdateResponse 消息被编辑或撤回后的最新状态，Gateway据此通知会话的所有参与者
+type MessageUpdateResponse struct {
+	ID          uuid.UUID `json:"id"`
+	ChatType    string    `json:"chat_type"`
 	SenderID    uuid.UUID `json:"sender_id"`
+	ReceiverID  uuid.UUID `json:"receiver_id"`
 	GroupID     uuid.UUID `json:"group_id"`
 	Content     string    `json:"content"`
 	ContentType int       `json:"content_type"`
+	Edited      bool      `json:"edited"`
+	Recalled    bool      `json:"recalled"`
+	Version     int       `json:"version"`
+	Timestamp   int64     `json:"timestamp"`
 }
 
-type MessageResponse struct {
-	ID        uuid.UUID `json:"id"`
-	Success   bool      `json:"success"`
-	Error     string    `json:"error,omitempty"`
-	Timestamp int64     `json:"timestamp"`
+type ReactionRequest struct {
+	MessageID uuid.UUID `json:"message_id"`
+	ChatType  string    `json:"chat_type"`
+	UserID    uuid.UUID `json:"user_id"`
+	Emoji     string    `json:"emoji"`
+}
+
+// ReactionSummary 某个表情的聚合结果
+type ReactionSummary struct {
+	Emoji   string      `json:"emoji"`
+	Count   int         `json:"count"`
+	UserIDs []uuid.UUID `json:"user_ids"`
+}
+
+// ReactionUpdateResponse 表情回应变化后消息的最新聚合结果
+type ReactionUpdateResponse struct {
+	MessageID  uuid.UUID         `json:"message_id"`
+	ChatType   string            `json:"chat_type"`
+	SenderID   uuid.UUID         `json:"sender_id"`
+	ReceiverID uuid.UUID         `json:"receiver_id"`
+	GroupID    uuid.UUID         `json:"group_id"`
+	UserID     uuid.UUID         `json:"user_id"`
+	Emoji      string            `json:"emoji"`
+	Added      bool              `json:"added"`
+	Reactions  []ReactionSummary `json:"reactions"`
+	Timestamp  int64             `json:"timestamp"`
+}
+
+// ConversationSettingsRequest 只更新非空字段
+type ConversationSettingsRequest struct {
+	UserID         uuid.UUID  `json:"user_id"`
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	Muted          *bool      `json:"muted,omitempty"`
+	MutedUntil     *time.Time `json:"muted_until,omitempty"`
+	Pinned         *bool      `json:"pinned,omitempty"`
+	Archived       *bool      `json:"archived,omitempty"`
+	MarkedUnread   *bool      `json:"marked_unread,omitempty"`
 }
 
-var upgrader = websocket.Upgrader{
-	CheckOrigin: func(r *http.Request) bool {
-		return true // 生产环境需要更严格的检查
-	},
-	HandshakeTimeout: 45 * time.Second,
+// ConversationSettings 用户对某个会话的个人设置
+type ConversationSettings struct {
+	ConversationID uuid.UUID  `json:"conversation_id"`
+	Muted          bool       `json:"muted"`
+	MutedUntil     *time.Time `json:"muted_until,omitempty"`
+	Pinned         bool       `json:"pinned"`
+	PinOrder       int        `json:"pin_order"`
+	Archived       bool       `json:"archived"`
+	MarkedUnread   bool       `json:"marked_unread"`
+	UpdatedAt      time.Time  `json:"updated_at"`
 }
 
 func NewHub(messageService MessageServiceClient, cfg *config.Config) *Hub {
-	return &Hub{
-		clients:     make(map[uuid.UUID]*Client),
-		register:    make(chan *Client),
-		unregister:  make(chan *Client),
-		broadcast:   make(chan []byte),
-		userMessage: make(chan UserMessage),
+	h := &Hub{
+		clients:   newClientRegistry(defaultShardCount),
+		broadcast: make(chan []byte),
 		// 这个redisManager是用来管理用户位置的，每个节点都有一个redisManager，用来管理用户位置。后面那个是nodeID
-		RedisManager:   NewRedisManager(cfg, "1"),
+		RedisManager:   NewRedisManager(cfg, resolveNodeID(cfg.Gateway)),
 		messageService: messageService,
+		sendBufferSize: cfg.Gateway.SendBufferSize,
+		opts:           newConnOptions(cfg.Gateway),
+		ticketTTL:      cfg.Gateway.TicketTTL,
 	}
+	if h.ticketTTL <= 0 {
+		h.ticketTTL = defaultTicketTTL
+	}
+	nodeTTL := cfg.Gateway.NodeTTL
+	if nodeTTL <= 0 {
+		nodeTTL = defaultNodeTTL
+	}
+	h.cluster = newClusterView(nodeTTL)
+	h.reconnectJitter = cfg.Gateway.ReconnectJitter
+	if h.reconnectJitter <= 0 {
+		h.reconnectJitter = defaultReconnectJitter
+	}
+	h.upgrader = websocket.Upgrader{
+		CheckOrigin:       newOriginChecker(cfg.Gateway.AllowedOrigins),
+		Subprotocols:      subprotocols,
+		HandshakeTimeout:  h.opts.handshakeTimeout,
+		EnableCompression: h.opts.enableCompression,
+	}
+	if h.sendBufferSize <= 0 {
+		h.sendBufferSize = defaultSendBufferSize
+	}
+	switch cfg.Gateway.SlowConsumerPolicy {
+	case "", SlowConsumerDisconnect:
+	case SlowConsumerDropOldest:
+		h.dropOldest = true
+	default:
+		slog.Warn("Unknown slow consumer policy", "policy", cfg.Gateway.SlowConsumerPolicy, "using", SlowConsumerDisconnect)
+	}
+	h.requests = h.requestTypes()
+	h.initRateLimits(cfg.RateLimit)
+	h.workers = newWorkerPool(0, defaultQueueSize, h.dispatch)
+	return h
 }
 
+// Run 启动worker池、集群心跳和跨节点订阅。连接的注册和注销在各自的goroutine里完成，
+// 这里只处理全局广播。调用前需要先JoinCluster，ctx取消后等退出集群完成再返回
 func (h *Hub) Run(ctx context.Context) {
-
+	h.workers.start(ctx)
+	membershipDone := make(chan struct{})
+	go func() {
+		h.runMembership(ctx)
+		close(membershipDone)
+	}()
 	go h.listenCrossServerMessage(ctx)
 	go h.listenGroupBoardcast(ctx)
+	go h.listenRevocations(ctx)
+	go h.watchTokenExpiry(ctx)
 	for {
 		select {
 		case <-ctx.Done():
+			<-membershipDone
 			return
-		case client := <-h.register:
-			h.mutex.Lock()
-			h.clients[client.userID] = client
-			h.mutex.Unlock()
-			log.Printf("Client %s (%s) connected", client.username, client.userID)
-
-			// 发送连接成功消息
-			client.sendMessage(OutgoingMessage{
-				Type:      "connection_established",
-				Data:      map[string]interface{}{"user_id": client.userID},
-				Timestamp: time.Now().Unix(),
-			})
-
-		case client := <-h.unregister:
-			h.mutex.Lock()
-			if _, ok := h.clients[client.userID]; ok {
-				delete(h.clients, client.userID)
-				close(client.send)
-			}
-			h.mutex.Unlock()
-			log.Printf("Client %s (%s) disconnected", client.username, client.userID)
-
 		case message := <-h.broadcast:
 			h.broadcastToAll(message)
-
-		case userMsg := <-h.userMessage:
-			h.handleUserMessage(ctx, userMsg)
 		}
 	}
 }
 
-func (h *Hub) handleUserMessage(ctx context.Context, userMsg UserMessage) {
-	var incoming IncomingMessage
-	if err := json.Unmarshal(userMsg.Payload, &incoming); err != nil {
-		log.Printf("Error unmarshaling user message: %v", err)
-		return
+// register 注册连接并记录会话，必须在启动readPump之前完成，保证连接的第一条消息能收到回复
+func (h *Hub) register(ctx context.Context, client *Client) {
+	// 同一设备重连时替换旧连接，其它设备的连接不受影响
+	if previous := h.clients.add(client); previous != nil {
+		slog.InfoContext(client.logCtx, "Client replaced by a new connection", "previous_conn_id", previous.connID)
+	}
+	if err := h.RedisManager.AddUserSession(ctx, client.userID.String(), SessionInfo{
+		DeviceID:    client.deviceID,
+		DeviceName:  client.deviceName,
+		NodeID:      h.RedisManager.GetNodeID(),
+		ConnectedAt: client.connectedAt.Unix(),
+	}); err != nil {
+		slog.ErrorContext(client.logCtx, "Failed to set user session", "error", err)
 	}
+	slog.InfoContext(client.logCtx, "Client connected", "protocol_version", client.protocolVersion, "protobuf", client.protobuf)
 
-	switch incoming.Type {
-	case "send_p2p_message":
-		h.handleP2PMessage(ctx, userMsg.UserID, incoming.Data)
-	case "send_group_message":
-		h.handleGroupMessage(ctx, userMsg.UserID, incoming.Data)
-	case "typing":
-		h.handleTyping(userMsg.UserID, incoming.Data)
-	case "read_receipt":
-		h.handleReadReceipt(userMsg.UserID, incoming.Data)
-	default:
-		log.Printf("Unknown message type: %s", incoming.Type)
+	// 发送连接成功消息
+	client.sendMessage(OutgoingMessage{
+		Type: "connection_established",
+		Data: map[string]interface{}{
+			"user_id":            client.userID,
+			"device_id":          client.deviceID,
+			"protocol_version":   client.protocolVersion,
+			"supported_versions": []int{ProtocolV1, ProtocolV2},
+		},
+		Timestamp: time.Now().Unix(),
+	})
+
+	// 与Drain并发时，Drain的快照可能没有包含这个连接
+	if h.Draining() {
+		h.disconnectForShutdown(client)
+	}
+}
+
+func (h *Hub) unregister(ctx context.Context, client *Client) {
+	h.clients.remove(client, closeNormal)
+	// 设备已经重连到本节点时保留会话记录
+	if !h.clients.replaced(client) {
+		if err := h.RedisManager.RemoveUserSession(ctx, client.userID.String(), client.deviceID); err != nil {
+			slog.ErrorContext(client.logCtx, "Failed to remove user session", "error", err)
+		}
+		h.removeNodeUser(ctx, client.userID)
 	}
+	slog.InfoContext(client.logCtx, "Client disconnected", "duration_ms", time.Since(client.connectedAt).Milliseconds())
 }
 
-func (h *Hub) handleP2PMessage(ctx context.Context, senderID uuid.UUID, data json.RawMessage) {
+func (h *Hub) handleP2PMessage(ctx context.Context, r *clientRequest) (interface{}, error) {
 	var req SendP2PRequest
-	if err := json.Unmarshal(data, &req); err != nil {
-		log.Printf("Error unmarshaling P2P message: %v", err)
-		return
+	if err := json.Unmarshal(r.Data, &req); err != nil {
+		return nil, invalidPayload(err)
 	}
+	senderID, deviceID := r.UserID, r.DeviceID
 	req.SenderID = senderID
 
-	// 如果接收者在本地，sendToLocalUser
-	location, err := h.RedisManager.GetUserLocation(ctx, req.ReceiverID.String())
+	// 调用Message Service，持久化成功后再投递
+	resp, err := h.messageService.SendP2PMessage(ctx, &req)
 	if err != nil {
-		log.Printf("Error getting user location: %v", err)
-		return
+		return nil, serviceError("Failed to send message", err)
 	}
-	if location == h.RedisManager.nodeID {
-		h.SendToUser(req.ReceiverID, OutgoingMessage{
-			Type:      "new_p2p_message",
-			Data:      data,
-			Timestamp: time.Now().Unix(),
-		})
-		return
-	} else {
-		// sendToCrossServer
-		h.PublishToTargetNode(ctx, req.ReceiverID, data)
+
+	event := OutgoingMessage{
+		Type: "new_p2p_message",
+		Data: P2PMessageEvent{
+			ID:          resp.ID,
+			SenderID:    senderID,
+			ReceiverID:  req.ReceiverID,
+			Content:     req.Content,
+			ContentType: req.ContentType,
+			ReplyToID:   req.ReplyToID,
+			Timestamp:   resp.Timestamp,
+		},
+		Timestamp: time.Now().Unix(),
 	}
+	// 发送给接收者的所有设备，接收者在其他节点时通过Redis转发
+	receiverEvent := event
+	receiverEvent.Silent = containsUser(resp.MutedUserIDs, req.ReceiverID)
+	h.routeToUser(ctx, req.ReceiverID, receiverEvent)
+	// 同步给发送者的其它设备
+	h.routeToUserExcept(ctx, senderID, deviceID, event)
+
+	// 确认由dispatch只回复给发送消息的设备
+	return resp, nil
+}
+
+func (h *Hub) handleGroupMessage(ctx context.Context, r *clientRequest) (interface{}, error) {
+	var req SendGroupRequest
+	if err := json.Unmarshal(r.Data, &req); err != nil {
+		return nil, invalidPayload(err)
+	}
+	senderID := r.UserID
+	req.SenderID = senderID
 
 	// 调用Message Service
-	resp, err := h.messageService.SendP2PMessage(ctx, &req)
+	resp, err := h.messageService.SendGroupMessage(ctx, &req)
 	if err != nil {
-		log.Printf("Error sending P2P message: %v", err)
-		h.sendErrorToUser(senderID, "Failed to send message", err)
-		return
+		return nil, serviceError("Failed to send group message", err)
 	}
 
-	// 发送给接收者
-	h.SendToUser(req.ReceiverID, OutgoingMessage{
-		Type:      "new_p2p_message",
-		Data:      resp,
+	// 通过group_broadcast推送给所有节点上的群成员(包括发送者的其它设备)，开启免打扰的成员收到静默消息
+	h.PublishToGroup(ctx, req.GroupID, resp.MutedUserIDs, OutgoingMessage{
+		Type: "new_group_message",
+		Data: GroupMessageEvent{
+			ID:           resp.ID,
+			SenderID:     senderID,
+			GroupID:      req.GroupID,
+			Content:      req.Content,
+			ContentType:  req.ContentType,
+			ReplyToID:    req.ReplyToID,
+			ThreadRootID: resp.ThreadRootID,
+			Mentions:     resp.Mentions,
+			MentionAll:   resp.MentionAll,
+			Timestamp:    resp.Timestamp,
+		},
 		Timestamp: time.Now().Unix(),
 	})
+	h.notifyMentions(ctx, senderID, &req, resp)
 
-	// 发送确认给发送者
-	h.SendToUser(senderID, OutgoingMessage{
-		Type:      "message_sent",
-		Data:      resp,
-		Timestamp: time.Now().Unix(),
-	})
+	return resp, nil
 }
 
-func (h *Hub) handleGroupMessage(ctx context.Context, senderID uuid.UUID, data json.RawMessage) {
-	var req SendGroupRequest
-	if err := json.Unmarshal(data, &req); err != nil {
-		log.Printf("Error unmarshaling group message: %v", err)
+// notifyMentions 给被@的成员单独推送mention事件，@all时推送给除发送者外的所有成员
+func (h *Hub) notifyMentions(ctx context.Context, senderID uuid.UUID, req *SendGroupRequest, resp *MessageResponse) {
+	if len(resp.Mentions) == 0 {
 		return
 	}
-	// publish to group broadcast
 
-	// 调用Message Service
-	//resp, err := h.messageService.SendGroupMessage(ctx, &req)
-	//if err != nil {
-	//	log.Printf("Error sending group message: %v", err)
-	//	h.sendErrorToUser(senderID, "Failed to send group message", err)
-	//	return
-	//}
-
-	// 这里可以从Message Service获取群组成员列表，然后推送给所有成员
-	// 或者通过Kafka事件来处理群组消息分发
-}
+	var targets []uuid.UUID
+	if resp.MentionAll {
+		members, err := h.getGroupMembers(ctx, req.GroupID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to get group members for mention", "group_id", req.GroupID, "error", err)
+			return
+		}
+		targets = members
+	} else {
+		for _, mention := range resp.Mentions {
+			targets = append(targets, mention.UserID)
+		}
+	}
 
-func (h *Hub) handleTyping(senderID uuid.UUID, data json.RawMessage) {
-	var typingData struct {
-		ReceiverID uuid.UUID `json:"receiver_id"`
-		IsTyping   bool      `json:"is_typing"`
+	message := OutgoingMessage{
+		Type: "mention",
+		Data: MentionEvent{
+			MessageID:  resp.ID,
+			GroupID:    req.GroupID,
+			SenderID:   senderID,
+			Content:    req.Content,
+			MentionAll: resp.MentionAll,
+			Timestamp:  resp.Timestamp,
+		},
+		Timestamp: time.Now().Unix(),
 	}
+	notified := make(map[uuid.UUID]bool)
+	for _, userID := range targets {
+		if userID == senderID || userID == uuid.Nil || notified[userID] {
+			continue
+		}
+		notified[userID] = true
+		h.routeToUser(ctx, userID, message)
+	}
+}
 
-	if err := json.Unmarshal(data, &typingData); err != nil {
-		return
+func (h *Hub) handleTyping(ctx context.Context, r *clientRequest) (interface{}, error) {
+	var typingData TypingRequest
+	if err := json.Unmarshal(r.Data, &typingData); err != nil {
+		return nil, invalidPayload(err)
 	}
 
-	h.SendToUser(typingData.ReceiverID, OutgoingMessage{
+	h.routeToUser(ctx, typingData.ReceiverID, OutgoingMessage{
 		Type: "typing_indicator",
 		Data: map[string]interface{}{
-			"user_id":   senderID,
+			"user_id":   r.UserID,
 			"is_typing": typingData.IsTyping,
 		},
 		Timestamp: time.Now().Unix(),
 	})
+	return nil, nil
 }
 
-func (h *Hub) handleReadReceipt(senderID uuid.UUID, data json.RawMessage) {
+func (h *Hub) handleReadReceipt(ctx context.Context, r *clientRequest) (interface{}, error) {
 	// 处理已读回执逻辑
+	return nil, nil
 }
 
+// SendToUser 发送给用户在本节点上的所有设备
 func (h *Hub) SendToUser(userID uuid.UUID, message OutgoingMessage) {
-	h.mutex.RLock()
-	client, ok := h.clients[userID]
-	h.mutex.RUnlock()
+	h.sendFrameToUser(userID, newOutgoingFrame(message))
+}
 
-	if !ok {
+func (h *Hub) sendFrameToUser(userID uuid.UUID, frame *outgoingFrame) {
+	for _, client := range h.clients.get(userID, "", "") {
+		client.sendFrame(frame)
+	}
+}
+
+// sendToDevice 只发送给本节点上的某个设备，用于消息确认和错误回复
+func (h *Hub) sendToDevice(userID uuid.UUID, deviceID string, message OutgoingMessage) {
+	for _, client := range h.clients.get(userID, deviceID, "") {
+		client.sendMessage(message)
+	}
+}
+
+// routeToUser 发送给用户的所有在线设备，其他节点上的设备通过Redis转发，离线用户直接忽略
+func (h *Hub) routeToUser(ctx context.Context, userID uuid.UUID, message OutgoingMessage) {
+	h.routeToUserExcept(ctx, userID, "", message)
+}
+
+// routeToUserExcept 同routeToUser，但跳过excludeDeviceID，用于把发送者自己的操作同步到其它设备
+func (h *Hub) routeToUserExcept(ctx context.Context, userID uuid.UUID, excludeDeviceID string, message OutgoingMessage) {
+	frame := newOutgoingFrame(message)
+	for _, client := range h.clients.get(userID, "", excludeDeviceID) {
+		client.sendFrame(frame)
+	}
+
+	nodes, err := h.RedisManager.GetUserNodes(ctx, userID.String())
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to get user sessions", "target_user_id", userID, "error", err)
 		return
 	}
+	for _, nodeID := range nodes {
+		// 已经停止心跳的节点上的会话等待清理，不再转发
+		if nodeID == h.RedisManager.GetNodeID() || !h.cluster.alive(nodeID) {
+			continue
+		}
+		h.PublishToTargetNode(ctx, nodeID, CrossNodeMessage{
+			UserID:          userID,
+			ExcludeDeviceID: excludeDeviceID,
+			Message:         message,
+		})
+	}
+}
 
-	client.sendMessage(message)
+func (h *Hub) PublishToTargetNode(ctx context.Context, nodeID string, message CrossNodeMessage) {
+	data, err := json.Marshal(message)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to marshal cross node message", "error", err)
+		return
+	}
+	channel := fmt.Sprintf("gateway_node:%s", nodeID)
+	if err := h.RedisManager.redisClusterClient.Publish(ctx, channel, data).Err(); err != nil {
+		slog.ErrorContext(ctx, "Failed to publish to node", "node_id", nodeID, "error", err)
+	}
 }
 
-func (h *Hub) PublishToTargetNode(ctx context.Context, userID uuid.UUID, data []byte) {
-	location, err := h.RedisManager.GetUserLocation(ctx, userID.String())
+// PublishToGroup 将消息发布到group_broadcast频道，由各节点投递给本地在线的群成员，
+// silentMembers收到的消息带silent标记
+func (h *Hub) PublishToGroup(ctx context.Context, groupID uuid.UUID, silentMembers []uuid.UUID, message OutgoingMessage) {
+	members, err := h.getGroupMembers(ctx, groupID)
 	if err != nil {
-		log.Printf("Error getting user location: %v", err)
+		slog.ErrorContext(ctx, "Failed to get group members", "group_id", groupID, "error", err)
 		return
 	}
-	h.RedisManager.redisClusterClient.Publish(ctx, location, data)
+	data, err := json.Marshal(GroupBroadcastMessage{GroupID: groupID, Members: members, SilentMembers: silentMembers, Message: message})
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to marshal group broadcast", "error", err)
+		return
+	}
+	if err := h.RedisManager.redisClusterClient.Publish(ctx, "group_broadcast", data).Err(); err != nil {
+		slog.ErrorContext(ctx, "Failed to publish group broadcast", "group_id", groupID, "error", err)
+	}
+}
+
+// getGroupMembers 优先读取Redis缓存，未命中时从Message Service获取并回写缓存
+func (h *Hub) getGroupMembers(ctx context.Context, groupID uuid.UUID) ([]uuid.UUID, error) {
+	members, err := h.RedisManager.GetGroupMemberByID(ctx, groupID.String())
+	if err == nil {
+		return members, nil
+	}
+	if !errors.Is(err, redis.Nil) {
+		slog.ErrorContext(ctx, "Failed to read cached group members", "group_id", groupID, "error", err)
+	}
+	members, err = h.messageService.GetGroupMembers(ctx, groupID)
+	if err != nil {
+		return nil, err
+	}
+	if err := h.RedisManager.SetGroupMemberByID(ctx, groupID.String(), members); err != nil {
+		slog.ErrorContext(ctx, "Failed to cache group members", "group_id", groupID, "error", err)
+	}
+	return members, nil
+}
+
+// InvalidateGroupMembers 群成员加入或退出后调用，删除缓存的成员列表
+func (h *Hub) InvalidateGroupMembers(ctx context.Context, groupID uuid.UUID) {
+	if err := h.RedisManager.DeleteGroupMemberByID(ctx, groupID.String()); err != nil {
+		slog.ErrorContext(ctx, "Failed to invalidate cached group members", "group_id", groupID, "error", err)
+	}
 }
 
 func (h *Hub) broadcastToAll(message []byte) {
-	h.mutex.RLock()
-	for _, client := range h.clients {
-		select {
-		case client.send <- message:
-		default:
-			close(client.send)
-			delete(h.clients, client.userID)
-		}
+	for _, client := range h.clients.snapshot() {
+		h.deliver(client, message)
 	}
-	h.mutex.RUnlock()
 }
 
 func (h *Hub) listenCrossServerMessage(ctx context.Context) {
@@ -296,16 +679,18 @@ func (h *Hub) listenCrossServerMessage(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case msg := <-ch.Channel():
-			var p2pMsg SendP2PRequest
-			if err := json.Unmarshal([]byte(msg.Payload), &p2pMsg); err != nil {
-				log.Printf("Error unmarshaling incoming message: %v", err)
+			var crossMsg CrossNodeMessage
+			if err := json.Unmarshal([]byte(msg.Payload), &crossMsg); err != nil {
+				slog.ErrorContext(ctx, "Failed to unmarshal incoming message", "error", err)
 				continue
 			}
-			h.SendToUser(p2pMsg.SenderID, OutgoingMessage{
-				Type:      "new_p2p_message",
-				Data:      p2pMsg,
-				Timestamp: time.Now().Unix(),
-			})
+			frame := newOutgoingFrame(crossMsg.Message)
+			for _, client := range h.clients.get(crossMsg.UserID, crossMsg.DeviceID, crossMsg.ExcludeDeviceID) {
+				client.sendFrame(frame)
+			}
+			if crossMsg.Disconnect {
+				h.disconnectLocal(crossMsg.UserID, crossMsg.DeviceID)
+			}
 		}
 	}
 
@@ -319,9 +704,9 @@ func (h *Hub) listenGroupBoardcast(ctx context.Context) {
 		case <-ctx.Done():
 			return
 		case msg := <-ch.Channel():
-			var groupMsg SendGroupRequest
+			var groupMsg GroupBroadcastMessage
 			if err := json.Unmarshal([]byte(msg.Payload), &groupMsg); err != nil {
-				log.Printf("Error unmarshaling incoming message: %v", err)
+				slog.ErrorContext(ctx, "Failed to unmarshal incoming message", "error", err)
 				continue
 			}
 			h.handleGroupBroadcast(groupMsg)
@@ -329,89 +714,158 @@ func (h *Hub) listenGroupBoardcast(ctx context.Context) {
 	}
 }
 
-func (h *Hub) handleGroupBroadcast(groupMsg SendGroupRequest) {
-
+func (h *Hub) handleGroupBroadcast(groupMsg GroupBroadcastMessage) {
+	// 所有成员共用两份frame，群消息扇出时每种格式只序列化一次
+	silentMessage := groupMsg.Message
+	silentMessage.Silent = true
+	frame, silentFrame := newOutgoingFrame(groupMsg.Message), newOutgoingFrame(silentMessage)
+	for _, memberID := range groupMsg.Members {
+		if containsUser(groupMsg.SilentMembers, memberID) {
+			h.sendFrameToUser(memberID, silentFrame)
+			continue
+		}
+		h.sendFrameToUser(memberID, frame)
+	}
 }
 
-func (h *Hub) sendErrorToUser(userID uuid.UUID, message string, err error) {
-	errorMsg := OutgoingMessage{
-		Type: "error",
-		Data: map[string]interface{}{
-			"message": message,
-			"error":   err.Error(),
-		},
+// NotifyUser 推送事件给用户，用户在其他节点时通过Redis转发
+func (h *Hub) NotifyUser(ctx context.Context, userID uuid.UUID, eventType string, data interface{}) {
+	h.routeToUser(ctx, userID, OutgoingMessage{
+		Type:      eventType,
+		Data:      data,
 		Timestamp: time.Now().Unix(),
+	})
+}
+
+func containsUser(userIDs []uuid.UUID, userID uuid.UUID) bool {
+	for _, id := range userIDs {
+		if id == userID {
+			return true
+		}
 	}
-	h.SendToUser(userID, errorMsg)
+	return false
 }
 
-func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request, userID uuid.UUID, username string) {
-	conn, err := upgrader.Upgrade(w, r, nil)
+// HandleWebSocket 建立连接。deviceID由客户端持久保存，同一设备重连时替换旧连接；
+// 为空时按新会话处理，生成一个随机ID
+func (h *Hub) HandleWebSocket(w http.ResponseWriter, r *http.Request, claims *pkg.AppClaims, deviceID, deviceName string) {
+	// 节点正在关闭，让客户端连接其它节点
+	if !h.acquireConn() {
+		w.Header().Set("Retry-After", "1")
+		http.Error(w, "server shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	conn, err := h.upgrader.Upgrade(w, r, nil)
 	if err != nil {
-		log.Printf("WebSocket upgrade error: %v", err)
+		h.conns.Done()
+		slog.WarnContext(r.Context(), "WebSocket upgrade failed", "error", err)
 		return
 	}
+	// 未协商permessage-deflate时设置压缩级别不生效
+	if h.opts.enableCompression {
+		conn.SetCompressionLevel(h.opts.compressionLevel)
+	}
+	format, ok := subprotocolFormats[conn.Subprotocol()]
+	if !ok {
+		format = SubprotocolSchema{Version: ProtocolV1, Encoding: EncodingJSON}
+	}
 
+	if deviceID == "" {
+		deviceID = uuid.NewString()
+	}
+	connID := uuid.NewString()
 	client := &Client{
-		hub:      h,
-		conn:     conn,
-		send:     make(chan []byte, 256),
-		userID:   userID,
-		username: username,
+		hub:    h,
+		connID: connID,
+		logCtx: logger.With(context.Background(),
+			logger.ConnIDKey, connID,
+			logger.UserIDKey, claims.ID.String(),
+			logger.DeviceIDKey, deviceID,
+		),
+		conn:        conn,
+		send:        make(chan []byte, h.sendBufferSize),
+		userID:      claims.ID,
+		username:    claims.Username,
+		deviceID:    deviceID,
+		deviceName:  deviceName,
+		connectedAt: time.Now(),
+
+		protocolVersion: format.Version,
+		protobuf:        format.Encoding == EncodingProtobuf,
 	}
+	client.setToken(claims)
 
-	client.hub.register <- client
+	h.register(r.Context(), client)
 
 	go client.writePump()
 	go client.readPump()
 }
 
 func (h *Hub) GetOnlineUsers() []uuid.UUID {
-	h.mutex.RLock()
-	defer h.mutex.RUnlock()
-
-	onlineUsers := make([]uuid.UUID, 0, len(h.clients))
-
-	for _, client := range h.clients {
-		onlineUsers = append(onlineUsers, client.userID)
-	}
-
-	return onlineUsers
+	return h.clients.userIDs()
 }
 
 func (c *Client) readPump() {
 	defer func() {
-		c.hub.unregister <- c
+		c.hub.unregister(context.Background(), c)
 		c.conn.Close()
+		c.hub.conns.Done()
 	}()
 
-	c.conn.SetReadLimit(512)
-	c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+	opts := c.hub.opts
+	// 超过maxFrameSize时gorilla直接以1009关闭连接；maxMessageSize和maxFrameSize之间的消息
+	// 被丢弃并回复错误帧，连接保持
+	c.conn.SetReadLimit(opts.maxFrameSize)
+	c.conn.SetReadDeadline(time.Now().Add(opts.pongWait))
 	c.conn.SetPongHandler(func(string) error {
-		c.conn.SetReadDeadline(time.Now().Add(60 * time.Second))
+		c.conn.SetReadDeadline(time.Now().Add(opts.pongWait))
 		return nil
 	})
 
 	for {
-		_, message, err := c.conn.ReadMessage()
+		message, err := c.readMessage()
+		if errors.Is(err, errMessageTooLarge) {
+			c.hub.respond(&clientRequest{UserID: c.userID, DeviceID: c.deviceID, Version: c.protocolVersion}, nil, messageTooLarge(opts.maxMessageSize))
+			continue
+		}
 		if err != nil {
 			if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
-				log.Printf("WebSocket error: %v", err)
+				slog.WarnContext(c.logCtx, "WebSocket closed unexpectedly", "error", err)
 			}
 			break
 		}
 
-		// 发送消息到处理器
-		c.hub.userMessage <- UserMessage{
-			UserID:  c.userID,
-			Type:    "user_message",
-			Payload: message,
+		// 交给worker池处理，同一用户的消息按顺序处理
+		if !c.hub.workers.submit(UserMessage{
+			ConnID:   c.connID,
+			UserID:   c.userID,
+			DeviceID: c.deviceID,
+			Version:  c.protocolVersion,
+			Protobuf: c.protobuf,
+			Type:     "user_message",
+			Payload:  message,
+		}) {
+			break
 		}
 	}
 }
 
+// readMessage 读取下一条消息，超过maxMessageSize时返回errMessageTooLarge
+func (c *Client) readMessage() ([]byte, error) {
+	_, reader, err := c.conn.NextReader()
+	if err != nil {
+		return nil, err
+	}
+	return readLimited(reader, c.hub.opts.maxMessageSize)
+}
+
 func (c *Client) writePump() {
-	ticker := time.NewTicker(54 * time.Second)
+	opts := c.hub.opts
+	messageType := websocket.TextMessage
+	if c.protobuf {
+		messageType = websocket.BinaryMessage
+	}
+	ticker := time.NewTicker(opts.pingPeriod)
 	defer func() {
 		ticker.Stop()
 		c.conn.Close()
@@ -420,15 +874,19 @@ func (c *Client) writePump() {
 	for {
 		select {
 		case message, ok := <-c.send:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.conn.SetWriteDeadline(time.Now().Add(opts.writeWait))
 			if !ok {
-				c.conn.WriteMessage(websocket.CloseMessage, []byte{})
+				c.conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(c.closeReason.code, c.closeReason.text))
 				return
 			}
-			c.conn.WriteMessage(websocket.TextMessage, message)
+			// 小消息压缩收益不大，只压缩超过阈值的消息
+			if opts.enableCompression {
+				c.conn.EnableWriteCompression(len(message) >= opts.compressionThreshold)
+			}
+			c.conn.WriteMessage(messageType, message)
 
 		case <-ticker.C:
-			c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+			c.conn.SetWriteDeadline(time.Now().Add(opts.writeWait))
 			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
 				return
 			}
@@ -437,18 +895,17 @@ func (c *Client) writePump() {
 }
 
 func (c *Client) sendMessage(message OutgoingMessage) {
-	data, err := json.Marshal(message)
+	c.sendFrame(newOutgoingFrame(message))
+}
+
+// sendFrame 发送一条已经包装好的消息，扇出时多个连接共用同一个frame，每种格式只序列化一次
+func (c *Client) sendFrame(frame *outgoingFrame) {
+	data, err := frame.bytes(c.format())
 	if err != nil {
-		log.Printf("Error marshaling message: %v", err)
+		slog.ErrorContext(c.logCtx, "Failed to encode message", "type", frame.message.Type, "error", err)
 		return
 	}
 
-	select {
-	case c.send <- data:
-	default:
-		c.hub.mutex.Lock()
-		close(c.send)
-		delete(c.hub.clients, c.userID)
-		c.hub.mutex.Unlock()
-	}
+	messagesSent.WithLabelValues(frame.message.Type).Inc()
+	c.hub.deliver(c, data)
 }
@@ -0,0 +1,226 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/config"
+)
+
+// connectBenchClients 在hub上注册users个用户，每个用户devices个连接，帧格式轮流使用v1、v2和protobuf。
+// 每个连接有一个goroutine代替writePump读取发送缓冲区，返回的函数断开所有连接并等待读取结束
+func connectBenchClients(h *Hub, users, devices int) ([]uuid.UUID, func()) {
+	var wg sync.WaitGroup
+	userIDs := make([]uuid.UUID, users)
+	var clients []*Client
+	for i := range userIDs {
+		userIDs[i] = uuid.New()
+		for d := 0; d < devices; d++ {
+			client := newTestClient(userIDs[i], uuid.NewString(), 256)
+			client.hub = h
+			switch (i*devices + d) % len(benchFormats) {
+			case 1:
+				client.protocolVersion = ProtocolV2
+			case 2:
+				client.protocolVersion = ProtocolV2
+				client.protobuf = true
+			}
+			h.clients.add(client)
+			clients = append(clients, client)
+
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for range client.send {
+				}
+			}()
+		}
+	}
+	return userIDs, func() {
+		for _, client := range clients {
+			h.clients.remove(client, closeNormal)
+		}
+		wg.Wait()
+	}
+}
+
+// BenchmarkGroupBroadcast 本节点收到群消息广播后扇出给所有在线成员，每个成员两台设备
+func BenchmarkGroupBroadcast(b *testing.B) {
+	for _, members := range []int{100, 1000, 5000} {
+		b.Run(fmt.Sprintf("%d-members", members), func(b *testing.B) {
+			h := newTestHub(true)
+			h.clients = newClientRegistry(64)
+			userIDs, disconnect := connectBenchClients(h, members, 2)
+			defer disconnect()
+
+			groupMsg := GroupBroadcastMessage{
+				GroupID:       uuid.New(),
+				Members:       userIDs,
+				SilentMembers: userIDs[:members/10],
+				Message:       sampleMessages()[0],
+			}
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				h.handleGroupBroadcast(groupMsg)
+			}
+			b.StopTimer()
+			b.ReportMetric(float64(h.droppedMessages.Load())/float64(b.N), "drops/op")
+		})
+	}
+}
+
+// BenchmarkSendToUserParallel 多个worker同时向不同用户推送，衡量registry分片锁的竞争
+func BenchmarkSendToUserParallel(b *testing.B) {
+	h := newTestHub(true)
+	h.clients = newClientRegistry(64)
+	userIDs, disconnect := connectBenchClients(h, 10000, 1)
+	defer disconnect()
+
+	message := sampleMessages()[1]
+	var next atomic.Int64
+	b.ReportAllocs()
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		for pb.Next() {
+			h.SendToUser(userIDs[next.Add(1)%int64(len(userIDs))], message)
+		}
+	})
+}
+
+// slowMessageService 只实现EditMessage，每次调用耗时latency；stalled用户的调用一直阻塞到ctx取消
+type slowMessageService struct {
+	MessageServiceClient
+	latency time.Duration
+	stalled uuid.UUID
+	calls   sync.WaitGroup
+}
+
+func (s *slowMessageService) EditMessage(ctx context.Context, req *EditMessageRequest) (*MessageUpdateResponse, error) {
+	defer s.calls.Done()
+	wait := s.latency
+	if req.EditorID == s.stalled {
+		wait = time.Hour
+	}
+	select {
+	case <-time.After(wait):
+		return &MessageUpdateResponse{ID: req.MessageID, Content: req.Content, Edited: true}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// newWorkerTestHub 上行消息经h.workers和dispatch交给service处理，返回的函数停止worker池
+func newWorkerTestHub(service MessageServiceClient, workers int, requestTimeout time.Duration) (*Hub, func()) {
+	h := newTestHub(true)
+	h.clients = newClientRegistry(64)
+	h.opts = newConnOptions(config.GatewayConfig{RequestTimeout: requestTimeout})
+	h.requests = h.requestTypes()
+	h.messageService = service
+	h.workers = newWorkerPool(workers, defaultQueueSize, h.dispatch)
+	ctx, cancel := context.WithCancel(context.Background())
+	h.workers.start(ctx)
+	return h, cancel
+}
+
+// editMessage 一条v2的edit_message请求
+func editMessage(client *Client) UserMessage {
+	payload := fmt.Sprintf(`{"v":2,"id":"bench","type":"edit_message","payload":{"message_id":%q,"chat_type":"p2p","content":"edited"}}`, uuid.New())
+	return UserMessage{UserID: client.userID, DeviceID: client.deviceID, Version: ProtocolV2, Payload: []byte(payload)}
+}
+
+// BenchmarkWorkerPoolSlowService 5万个连接通过h.workers提交edit_message，Message Service每次调用耗时latency。
+// worker一次只处理一个请求，吞吐上限约为worker数/latency；stalled-user里有一个用户的调用一直阻塞到requestTimeout，
+// 分到同一worker的其他用户要等它超时
+func BenchmarkWorkerPoolSlowService(b *testing.B) {
+	cases := []struct {
+		name    string
+		latency time.Duration
+		stall   bool
+	}{
+		{"latency-1ms", time.Millisecond, false},
+		{"latency-10ms", 10 * time.Millisecond, false},
+		{"stalled-user", time.Millisecond, true},
+	}
+	for _, tc := range cases {
+		b.Run(tc.name, func(b *testing.B) {
+			service := &slowMessageService{latency: tc.latency}
+			h, stop := newWorkerTestHub(service, 0, 100*time.Millisecond)
+			defer stop()
+			userIDs, disconnect := connectBenchClients(h, 50000, 1)
+			defer disconnect()
+			clients := make([]*Client, len(userIDs))
+			for i, userID := range userIDs {
+				clients[i] = h.clients.get(userID, "", "")[0]
+			}
+
+			if tc.stall {
+				service.stalled = userIDs[0]
+				service.calls.Add(1)
+				h.workers.submit(editMessage(clients[0]))
+			}
+			messages := make([]UserMessage, len(clients))
+			for i, client := range clients {
+				messages[i] = editMessage(client)
+			}
+
+			var maxDepth int
+			b.ReportAllocs()
+			b.ResetTimer()
+			service.calls.Add(b.N)
+			for i := 0; i < b.N; i++ {
+				h.workers.submit(messages[1+i%(len(messages)-1)])
+				if i%1000 == 0 {
+					if _, depth := h.workers.depth(); depth > maxDepth {
+						maxDepth = depth
+					}
+				}
+			}
+			service.calls.Wait()
+			b.StopTimer()
+			b.ReportMetric(float64(maxDepth), "max_queue_depth")
+		})
+	}
+}
+
+// TestWorkerPoolRequestTimeout 一个用户的调用卡住时，同一worker上其他用户的请求在requestTimeout后得到处理
+func TestWorkerPoolRequestTimeout(t *testing.T) {
+	service := &slowMessageService{}
+	h, stop := newWorkerTestHub(service, 1, 50*time.Millisecond)
+	defer stop()
+	stalled := newTestClient(uuid.New(), "phone", 4)
+	other := newTestClient(uuid.New(), "phone", 4)
+	for _, client := range []*Client{stalled, other} {
+		client.hub = h
+		h.clients.add(client)
+	}
+	service.stalled = stalled.userID
+
+	start := time.Now()
+	service.calls.Add(2)
+	h.workers.submit(editMessage(stalled))
+	h.workers.submit(editMessage(other))
+
+	select {
+	case data := <-other.send:
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Fatalf("request waited %s behind the stalled call", elapsed)
+		}
+		if !strings.Contains(string(data), `"ack"`) {
+			t.Fatalf("expected ack, got %s", data)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("request never handled behind the stalled call")
+	}
+	data := <-stalled.send
+	if !strings.Contains(string(data), `"nack"`) {
+		t.Fatalf("expected nack for the stalled call, got %s", data)
+	}
+	service.calls.Wait()
+}
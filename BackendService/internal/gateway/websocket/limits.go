@@ -0,0 +1,117 @@
+package websocket
+
+import (
+	"compress/flate"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/huangrao121/CommunicationApp/BackendService/config"
+)
+
+const (
+	defaultMaxMessageSize   = 64 << 10
+	defaultMaxFrameSize     = 1 << 20
+	defaultPongWait         = 60 * time.Second
+	defaultWriteWait        = 10 * time.Second
+	defaultHandshakeTimeout = 10 * time.Second
+	defaultRequestTimeout   = 5 * time.Second
+)
+
+var errMessageTooLarge = errors.New("message too large")
+
+// connOptions 连接的超时、大小限制和压缩设置，由GatewayConfig填充默认值得到
+type connOptions struct {
+	maxMessageSize int64
+	maxFrameSize   int64
+	typeLimits     map[string]int64
+
+	pongWait         time.Duration
+	pingPeriod       time.Duration
+	writeWait        time.Duration
+	handshakeTimeout time.Duration
+	requestTimeout   time.Duration
+
+	enableCompression    bool
+	compressionLevel     int
+	compressionThreshold int
+}
+
+func newConnOptions(cfg config.GatewayConfig) connOptions {
+	opts := connOptions{
+		maxMessageSize:       cfg.MaxMessageSize,
+		maxFrameSize:         cfg.MaxFrameSize,
+		typeLimits:           cfg.MessageTypeLimits,
+		pongWait:             cfg.PongWait,
+		pingPeriod:           cfg.PingPeriod,
+		writeWait:            cfg.WriteWait,
+		handshakeTimeout:     cfg.HandshakeTimeout,
+		requestTimeout:       cfg.RequestTimeout,
+		enableCompression:    cfg.EnableCompression,
+		compressionLevel:     cfg.CompressionLevel,
+		compressionThreshold: cfg.CompressionThreshold,
+	}
+	if opts.maxMessageSize <= 0 {
+		opts.maxMessageSize = defaultMaxMessageSize
+	}
+	if opts.maxFrameSize <= 0 {
+		opts.maxFrameSize = defaultMaxFrameSize
+	}
+	if opts.maxFrameSize < opts.maxMessageSize {
+		opts.maxFrameSize = opts.maxMessageSize
+	}
+	if opts.pongWait <= 0 {
+		opts.pongWait = defaultPongWait
+	}
+	// ping必须在对端的读超时之前发出
+	if opts.pingPeriod <= 0 || opts.pingPeriod >= opts.pongWait {
+		opts.pingPeriod = opts.pongWait * 9 / 10
+	}
+	if opts.writeWait <= 0 {
+		opts.writeWait = defaultWriteWait
+	}
+	if opts.handshakeTimeout <= 0 {
+		opts.handshakeTimeout = defaultHandshakeTimeout
+	}
+	if opts.requestTimeout <= 0 {
+		opts.requestTimeout = defaultRequestTimeout
+	}
+	if opts.compressionLevel < flate.BestSpeed || opts.compressionLevel > flate.BestCompression {
+		opts.compressionLevel = flate.BestSpeed
+	}
+	return opts
+}
+
+// typeLimit 返回某种消息类型的大小限制，未单独配置时使用maxMessageSize
+func (o connOptions) typeLimit(messageType string) int64 {
+	if limit, ok := o.typeLimits[messageType]; ok && limit > 0 && limit < o.maxMessageSize {
+		return limit
+	}
+	return o.maxMessageSize
+}
+
+// readLimited 读取一条消息，超过limit时丢弃剩余部分并返回errMessageTooLarge，连接可以继续使用。
+// 超过连接的ReadLimit时gorilla已经发送1009关闭帧，返回websocket.ErrReadLimit
+func readLimited(r io.Reader, limit int64) ([]byte, error) {
+	message, err := io.ReadAll(io.LimitReader(r, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(message)) <= limit {
+		return message, nil
+	}
+	if _, err := io.Copy(io.Discard, r); err != nil {
+		return nil, err
+	}
+	return nil, errMessageTooLarge
+}
+
+// messageTooLarge 超过大小限制时回复给客户端的错误
+func messageTooLarge(limit int64) error {
+	return &ProtocolError{
+		Code:    CodeMessageTooLarge,
+		Message: "Message too large",
+		Err:     fmt.Errorf("%w: limit is %d bytes", errMessageTooLarge, limit),
+	}
+}
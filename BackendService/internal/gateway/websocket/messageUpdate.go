@@ -0,0 +1,109 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+)
+
+// handleEditMessage 只回复ack，message_edited事件由ConsumeMessageUpdates从Kafka推送给会话参与者
+func (h *Hub) handleEditMessage(ctx context.Context, r *clientRequest) (interface{}, error) {
+	var req EditMessageRequest
+	if err := json.Unmarshal(r.Data, &req); err != nil {
+		return nil, invalidPayload(err)
+	}
+	req.EditorID = r.UserID
+
+	resp, err := h.messageService.EditMessage(ctx, &req)
+	if err != nil {
+		return nil, serviceError("Failed to edit message", err)
+	}
+	return resp, nil
+}
+
+// handleRecallMessage 同handleEditMessage，message_recalled事件从Kafka推送
+func (h *Hub) handleRecallMessage(ctx context.Context, r *clientRequest) (interface{}, error) {
+	var req RecallMessageRequest
+	if err := json.Unmarshal(r.Data, &req); err != nil {
+		return nil, invalidPayload(err)
+	}
+	req.OperatorID = r.UserID
+
+	resp, err := h.messageService.RecallMessage(ctx, &req)
+	if err != nil {
+		return nil, serviceError("Failed to recall message", err)
+	}
+	return resp, nil
+}
+
+// handleDeleteMessage 仅对自己删除，只需要同步给操作者自己的所有设备
+func (h *Hub) handleDeleteMessage(ctx context.Context, r *clientRequest) (interface{}, error) {
+	var req DeleteMessageRequest
+	if err := json.Unmarshal(r.Data, &req); err != nil {
+		return nil, invalidPayload(err)
+	}
+	req.UserID = r.UserID
+
+	if err := h.messageService.DeleteMessageForUser(ctx, &req); err != nil {
+		return nil, serviceError("Failed to delete message", err)
+	}
+
+	h.routeToUser(ctx, r.UserID, OutgoingMessage{
+		Type: "message_deleted",
+		Data: map[string]interface{}{
+			"message_id": req.MessageID,
+			"chat_type":  req.ChatType,
+		},
+		Timestamp: time.Now().Unix(),
+	})
+	return nil, nil
+}
+
+// handleReaction 添加或取消表情回应，并把最新的聚合结果推送给会话参与者
+func (h *Hub) handleReaction(ctx context.Context, r *clientRequest, add bool) (interface{}, error) {
+	var req ReactionRequest
+	if err := json.Unmarshal(r.Data, &req); err != nil {
+		return nil, invalidPayload(err)
+	}
+	req.UserID = r.UserID
+
+	var resp *ReactionUpdateResponse
+	var err error
+	if add {
+		resp, err = h.messageService.AddReaction(ctx, &req)
+	} else {
+		resp, err = h.messageService.RemoveReaction(ctx, &req)
+	}
+	if err != nil {
+		return nil, serviceError("Failed to update reaction", err)
+	}
+
+	h.notifyParticipants(ctx, resp.ChatType, resp.SenderID, resp.ReceiverID, resp.GroupID, OutgoingMessage{
+		Type:      "reaction_updated",
+		Data:      resp,
+		Timestamp: time.Now().Unix(),
+	})
+	return resp, nil
+}
+
+// notifyMessageUpdate 把编辑/撤回事件推送给会话的所有参与者，包括发送者自己
+func (h *Hub) notifyMessageUpdate(ctx context.Context, eventType string, resp *MessageUpdateResponse) {
+	h.notifyParticipants(ctx, resp.ChatType, resp.SenderID, resp.ReceiverID, resp.GroupID, OutgoingMessage{
+		Type:      eventType,
+		Data:      resp,
+		Timestamp: time.Now().Unix(),
+	})
+}
+
+// notifyParticipants 单聊推送给收发双方，群聊通过group_broadcast推送给所有群成员
+func (h *Hub) notifyParticipants(ctx context.Context, chatType string, senderID, receiverID, groupID uuid.UUID, message OutgoingMessage) {
+	if chatType == types.ChatTypeGroup {
+		h.PublishToGroup(ctx, groupID, nil, message)
+		return
+	}
+	h.routeToUser(ctx, senderID, message)
+	h.routeToUser(ctx, receiverID, message)
+}
@@ -0,0 +1,75 @@
+package websocket
+
+import (
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	messagesReceived = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "gateway",
+		Name:      "messages_received_total",
+		Help:      "Client requests by type; unsupported types are counted as unknown.",
+	}, []string{"type"})
+	messagesSent = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "gateway",
+		Name:      "messages_sent_total",
+		Help:      "Frames queued to client send buffers by event type.",
+	}, []string{"type"})
+)
+
+// hubCollector 抓取时读取Hub的当前状态，连接数和队列深度不需要在热路径上维护额外的计数
+type hubCollector struct {
+	hub *Hub
+
+	connections             *prometheus.Desc
+	onlineUsers             *prometheus.Desc
+	queueDepth              *prometheus.Desc
+	queueMaxDepth           *prometheus.Desc
+	queueCapacity           *prometheus.Desc
+	droppedMessages         *prometheus.Desc
+	slowConsumerDisconnects *prometheus.Desc
+}
+
+// RegisterMetrics 注册本节点的连接数、worker队列深度和发送缓冲区丢弃统计，指标带node标签
+func (h *Hub) RegisterMetrics(reg prometheus.Registerer) error {
+	labels := prometheus.Labels{"node": h.RedisManager.GetNodeID()}
+	desc := func(name, help string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(metrics.Namespace, "gateway", name), help, nil, labels)
+	}
+	return reg.Register(&hubCollector{
+		hub:                     h,
+		connections:             desc("connected_clients", "WebSocket connections on this node."),
+		onlineUsers:             desc("online_users", "Distinct users connected to this node."),
+		queueDepth:              desc("worker_queue_depth", "Client requests waiting in all worker queues."),
+		queueMaxDepth:           desc("worker_queue_max_depth", "Depth of the fullest worker queue."),
+		queueCapacity:           desc("worker_queue_capacity", "Capacity of each worker queue."),
+		droppedMessages:         desc("send_buffer_drops_total", "Messages dropped because a client send buffer was full."),
+		slowConsumerDisconnects: desc("slow_consumer_disconnects_total", "Connections closed because the send buffer was full."),
+	})
+}
+
+func (c *hubCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.connections
+	ch <- c.onlineUsers
+	ch <- c.queueDepth
+	ch <- c.queueMaxDepth
+	ch <- c.queueCapacity
+	ch <- c.droppedMessages
+	ch <- c.slowConsumerDisconnects
+}
+
+func (c *hubCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.hub.Stats()
+	depth, maxDepth := c.hub.workers.depth()
+	ch <- prometheus.MustNewConstMetric(c.connections, prometheus.GaugeValue, float64(stats.Connections))
+	ch <- prometheus.MustNewConstMetric(c.onlineUsers, prometheus.GaugeValue, float64(stats.OnlineUsers))
+	ch <- prometheus.MustNewConstMetric(c.queueDepth, prometheus.GaugeValue, float64(depth))
+	ch <- prometheus.MustNewConstMetric(c.queueMaxDepth, prometheus.GaugeValue, float64(maxDepth))
+	ch <- prometheus.MustNewConstMetric(c.queueCapacity, prometheus.GaugeValue, float64(c.hub.workers.capacity()))
+	ch <- prometheus.MustNewConstMetric(c.droppedMessages, prometheus.CounterValue, float64(stats.DroppedMessages))
+	ch <- prometheus.MustNewConstMetric(c.slowConsumerDisconnects, prometheus.CounterValue, float64(stats.SlowConsumerDisconnects))
+}
@@ -0,0 +1,216 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/config/logger"
+	"go.opentelemetry.io/otel/codes"
+)
+
+// 协议版本。v1是最初的{type, data}格式，没有请求ID，只有部分请求有确认；
+// v2使用{v, id, type, payload}信封，每个带id的请求都会收到ack或nack
+const (
+	ProtocolV1             = 1
+	ProtocolV2             = 2
+	CurrentProtocolVersion = ProtocolV2
+)
+
+// 错误码，nack和v1的error事件都会携带
+const (
+	CodeInvalidPayload     = "invalid_payload"
+	CodeUnknownType        = "unknown_type"
+	CodeUnsupportedVersion = "unsupported_version"
+	CodeMessageTooLarge    = "message_too_large"
+	CodeInvalidToken       = "invalid_token"
+	CodeServiceError       = "service_error"
+	CodeInternal           = "internal_error"
+	CodeRateLimited        = "rate_limited"
+)
+
+// ProtocolError 请求处理失败的原因，Code是给客户端判断的错误码，Message是可读的描述
+type ProtocolError struct {
+	Code    string
+	Message string
+	Err     error
+	// RetryAfter 被限流时多久之后可以重试
+	RetryAfter time.Duration
+}
+
+func (e *ProtocolError) Error() string {
+	if e.Err == nil {
+		return e.Message
+	}
+	return fmt.Sprintf("%s: %v", e.Message, e.Err)
+}
+
+func (e *ProtocolError) Unwrap() error {
+	return e.Err
+}
+
+func invalidPayload(err error) error {
+	return &ProtocolError{Code: CodeInvalidPayload, Message: "Invalid payload", Err: err}
+}
+
+// serviceError Message Service调用失败，message沿用v1 error事件里的描述
+func serviceError(message string, err error) error {
+	return &ProtocolError{Code: CodeServiceError, Message: message, Err: err}
+}
+
+// ErrorPayload nack和error事件的内容
+type ErrorPayload struct {
+	Code        string `json:"code"`
+	Message     string `json:"message"`
+	Error       string `json:"error,omitempty"`
+	RequestType string `json:"request_type,omitempty"`
+	// RetryAfterMs 只在rate_limited时出现
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+}
+
+func newErrorPayload(requestType string, err error) ErrorPayload {
+	var pe *ProtocolError
+	if !errors.As(err, &pe) {
+		pe = &ProtocolError{Code: CodeInternal, Message: "Internal error", Err: err}
+	}
+	payload := ErrorPayload{Code: pe.Code, Message: pe.Message, RequestType: requestType, RetryAfterMs: pe.RetryAfter.Milliseconds()}
+	if pe.Err != nil {
+		payload.Error = pe.Err.Error()
+	}
+	return payload
+}
+
+// envelope v2协议的JSON下行帧，protobuf编码见gateway.proto的ServerFrame
+type envelope struct {
+	Version   int         `json:"v"`
+	RequestID string      `json:"id,omitempty"`
+	Type      string      `json:"type"`
+	Payload   interface{} `json:"payload,omitempty"`
+	Timestamp int64       `json:"timestamp"`
+	Silent    bool        `json:"silent,omitempty"`
+	TraceID   string      `json:"trace_id,omitempty"`
+}
+
+// clientRequest 一条解析后的客户端请求
+type clientRequest struct {
+	UserID   uuid.UUID
+	DeviceID string
+	Version  int
+	ID       string // 请求ID，v1协议为空
+	Type     string
+	Data     json.RawMessage
+	TraceID  string
+}
+
+// requestHandler 处理一种请求，返回的结果作为ack的内容
+type requestHandler func(ctx context.Context, req *clientRequest) (interface{}, error)
+
+// requestType 一种客户端请求的处理函数和schema描述
+type requestType struct {
+	handle      requestHandler
+	description string
+	payload     interface{} // 请求体结构，用于生成schema
+	serverSet   []string    // 由服务端填充的字段，不出现在schema里
+	result      interface{} // ack携带的结果结构，nil表示ack没有内容
+	// legacyAck v1协议下成功时回复的事件类型，为空时不回复
+	legacyAck string
+	// rateLimit 计入哪个限流桶，为空时计入events，见ratelimit.go
+	rateLimit string
+}
+
+// dispatch 解析并处理一条上行消息，处理结果通过respond回复给发起请求的设备
+func (h *Hub) dispatch(ctx context.Context, userMsg UserMessage) {
+	req := &clientRequest{UserID: userMsg.UserID, DeviceID: userMsg.DeviceID, Version: userMsg.Version}
+	ctx = logger.With(ctx,
+		logger.ConnIDKey, userMsg.ConnID,
+		logger.UserIDKey, userMsg.UserID.String(),
+		logger.DeviceIDKey, userMsg.DeviceID,
+	)
+	incoming, err := decodeIncoming(userMsg)
+	if err != nil {
+		h.respond(req, nil, invalidPayload(err))
+		return
+	}
+	req.ID = incoming.RequestID
+	req.Type = incoming.Type
+	req.Data = incoming.body()
+	if req.ID != "" {
+		ctx = logger.With(ctx, logger.RequestIDKey, req.ID)
+	}
+
+	// 请求的span是Message Service调用、Kafka和下游消费者的根，客户端传了traceparent时挂在客户端的trace下面
+	ctx, span := startRequestSpan(ctx, incoming, h.requests)
+	defer span.End()
+	if spanCtx := span.SpanContext(); spanCtx.HasTraceID() {
+		req.TraceID = spanCtx.TraceID().String()
+	}
+
+	if incoming.Version > CurrentProtocolVersion {
+		h.respond(req, nil, &ProtocolError{
+			Code:    CodeUnsupportedVersion,
+			Message: "Unsupported protocol version",
+			Err:     fmt.Errorf("version %d is not supported", incoming.Version),
+		})
+		return
+	}
+	if limit := h.opts.typeLimit(incoming.Type); int64(len(userMsg.Payload)) > limit {
+		h.respond(req, nil, messageTooLarge(limit))
+		return
+	}
+	rt, ok := h.requests[incoming.Type]
+	if err := h.allowRequest(ctx, req, rt.rateLimit); err != nil {
+		h.respond(req, nil, err)
+		return
+	}
+	if !ok {
+		messagesReceived.WithLabelValues("unknown").Inc()
+		slog.WarnContext(ctx, "Unknown message type", "type", incoming.Type)
+		h.respond(req, nil, &ProtocolError{
+			Code:    CodeUnknownType,
+			Message: "Unknown message type",
+			Err:     fmt.Errorf("unknown message type %q", incoming.Type),
+		})
+		return
+	}
+	messagesReceived.WithLabelValues(incoming.Type).Inc()
+
+	// worker按用户分配，限制处理时间，避免一个慢调用长时间阻塞同一worker上的其他用户
+	handleCtx, cancel := context.WithTimeout(ctx, h.opts.requestTimeout)
+	result, err := rt.handle(handleCtx, req)
+	cancel()
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		slog.WarnContext(ctx, "Failed to handle request", "type", req.Type, "error", err)
+	}
+	h.respond(req, result, err)
+}
+
+// respond 回复请求的处理结果，只发给发起请求的设备。
+// v2协议成功回复ack，失败回复nack，都带上请求ID；
+// v1协议保持原来的行为，成功时只有定义了legacyAck的请求有回复，失败时回复error事件
+func (h *Hub) respond(req *clientRequest, result interface{}, err error) {
+	message := OutgoingMessage{RequestID: req.ID, TraceID: req.TraceID, Timestamp: time.Now().Unix()}
+	switch {
+	case req.Version >= ProtocolV2 && err == nil:
+		message.Type = "ack"
+		message.Data = result
+	case req.Version >= ProtocolV2:
+		message.Type = "nack"
+		message.Data = newErrorPayload(req.Type, err)
+	case err != nil:
+		message.Type = "error"
+		message.Data = newErrorPayload(req.Type, err)
+	default:
+		message.Type = h.requests[req.Type].legacyAck
+		message.Data = result
+		if message.Type == "" {
+			return
+		}
+	}
+	h.sendToDevice(req.UserID, req.DeviceID, message)
+}
@@ -0,0 +1,50 @@
+package websocket
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/huangrao121/CommunicationApp/BackendService/config"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/ratelimit"
+)
+
+// 请求的限流桶。同一用户所有设备、所有节点共享同一个计数
+const (
+	rateLimitMessages = "messages" // 发送、编辑、撤回、删除消息和表情回应
+	rateLimitEvents   = "events"   // typing、已读回执等其它请求，以及未知类型
+)
+
+func (h *Hub) initRateLimits(cfg config.RateLimitConfig) {
+	if cfg.Disabled {
+		return
+	}
+	h.limiter = ratelimit.New(h.RedisManager.Client())
+	h.rateLimits = map[string]ratelimit.Limit{
+		rateLimitMessages: ratelimit.LimitFromConfig(cfg.Messages, ratelimit.DefaultMessages),
+		rateLimitEvents:   ratelimit.LimitFromConfig(cfg.Events, ratelimit.DefaultEvents),
+	}
+}
+
+// allowRequest 超过限制时返回rate_limited错误，Redis出错时放行
+func (h *Hub) allowRequest(ctx context.Context, req *clientRequest, bucket string) error {
+	if h.limiter == nil {
+		return nil
+	}
+	if bucket == "" {
+		bucket = rateLimitEvents
+	}
+	result, err := h.limiter.Allow(ctx, bucket, req.UserID.String(), h.rateLimits[bucket])
+	if err != nil {
+		slog.WarnContext(ctx, "Rate limit check failed, allowing request", "bucket", bucket, "error", err)
+		return nil
+	}
+	if result.Allowed {
+		return nil
+	}
+	return rateLimited(result.RetryAfter)
+}
+
+func rateLimited(retryAfter time.Duration) error {
+	return &ProtocolError{Code: CodeRateLimited, Message: "Rate limit exceeded", RetryAfter: retryAfter}
+}
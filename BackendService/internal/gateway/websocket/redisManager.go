@@ -4,72 +4,231 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"log"
+	"log/slog"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/huangrao121/CommunicationApp/BackendService/config"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/redisclient"
 	"github.com/redis/go-redis/v9"
 )
 
+// 群成员缓存的默认有效期，成员变化后最多这么久才会在推送中生效
+const defaultGroupMemberTTL = time.Minute
+
 type RedisManager struct {
 	redisClusterClient *redis.ClusterClient
 	nodeID             string
+	groupMemberTTL     time.Duration
 }
 
 func NewRedisManager(cfg *config.Config, nodeID string) *RedisManager {
-	redisOpts := &redis.ClusterOptions{
-		Addrs:          cfg.Redis.ClusterAddrs,
-		Password:       cfg.Redis.Password,
-		PoolSize:       cfg.Redis.PoolSize,
-		MinIdleConns:   cfg.Redis.MinIdleConns,
-		MaxIdleConns:   cfg.Redis.MaxIdleConns,
-		MaxActiveConns: cfg.Redis.MaxActiveConns,
-		PoolTimeout:    cfg.Redis.IdleTimeout,
+	client := redisclient.New(cfg.Redis)
+	groupMemberTTL := cfg.Gateway.GroupMemberCacheTTL
+	if groupMemberTTL <= 0 {
+		groupMemberTTL = defaultGroupMemberTTL
 	}
 	return &RedisManager{
-		redisClusterClient: redis.NewClusterClient(redisOpts),
+		redisClusterClient: client,
 		nodeID:             nodeID,
+		groupMemberTTL:     groupMemberTTL,
+	}
+}
+
+// AddUserSession 记录用户的一个在线设备，同一用户的多个设备可能连接在不同节点上。
+// 同时把用户加入节点的用户集合，节点下线后据此清理它的会话
+func (ulm *RedisManager) AddUserSession(ctx context.Context, userID string, session SessionInfo) error {
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+	if err := ulm.redisClusterClient.HSet(ctx, fmt.Sprintf("user_sessions:%s", userID), session.DeviceID, data).Err(); err != nil {
+		return err
+	}
+	return ulm.AddNodeUser(ctx, session.NodeID, userID)
+}
+
+// AddNodeUser 把用户加入节点的用户集合
+func (ulm *RedisManager) AddNodeUser(ctx context.Context, nodeID string, userID string) error {
+	return ulm.redisClusterClient.SAdd(ctx, nodeUsersKey(nodeID), userID).Err()
+}
+
+// RemoveNodeUser 用户在节点上已经没有连接时从节点的用户集合中移除
+func (ulm *RedisManager) RemoveNodeUser(ctx context.Context, nodeID string, userID string) error {
+	return ulm.redisClusterClient.SRem(ctx, nodeUsersKey(nodeID), userID).Err()
+}
+
+func (ulm *RedisManager) RemoveUserSession(ctx context.Context, userID string, deviceID string) error {
+	return ulm.redisClusterClient.HDel(ctx, fmt.Sprintf("user_sessions:%s", userID), deviceID).Err()
+}
+
+// GetUserSessions 返回用户所有在线设备，用户不在线时返回空
+func (ulm *RedisManager) GetUserSessions(ctx context.Context, userID string) ([]SessionInfo, error) {
+	values, err := ulm.redisClusterClient.HGetAll(ctx, fmt.Sprintf("user_sessions:%s", userID)).Result()
+	if err != nil {
+		return nil, err
+	}
+	sessions := make([]SessionInfo, 0, len(values))
+	for _, value := range values {
+		var session SessionInfo
+		if err := json.Unmarshal([]byte(value), &session); err != nil {
+			slog.ErrorContext(ctx, "Failed to unmarshal user session", "error", err)
+			continue
+		}
+		sessions = append(sessions, session)
+	}
+	return sessions, nil
+}
+
+// GetUserNodes 返回用户在线设备所在的节点，去重
+func (ulm *RedisManager) GetUserNodes(ctx context.Context, userID string) ([]string, error) {
+	sessions, err := ulm.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	var nodes []string
+	for _, session := range sessions {
+		if !seen[session.NodeID] {
+			seen[session.NodeID] = true
+			nodes = append(nodes, session.NodeID)
+		}
+	}
+	return nodes, nil
+}
+
+// IsUserOnline 用户至少有一个设备在线时返回true
+func (ulm *RedisManager) IsUserOnline(ctx context.Context, userID string) (bool, error) {
+	n, err := ulm.redisClusterClient.HLen(ctx, fmt.Sprintf("user_sessions:%s", userID)).Result()
+	if err != nil {
+		return false, err
 	}
+	return n > 0, nil
 }
 
-func (ulm *RedisManager) SetUserLocation(ctx context.Context, userID string, location string) error {
-	return ulm.redisClusterClient.Set(context.Background(),
-		fmt.Sprintf("user_location:%s", userID),
-		ulm.nodeID,
-		0).Err()
+// SetTicket 保存连接票据，ttl后自动过期
+func (ulm *RedisManager) SetTicket(ctx context.Context, ticket string, data []byte, ttl time.Duration) error {
+	return ulm.redisClusterClient.Set(ctx, fmt.Sprintf("ws_ticket:%s", ticket), data, ttl).Err()
 }
 
-func (ulm *RedisManager) GetUserLocation(ctx context.Context, userID string) (string, error) {
-	return ulm.redisClusterClient.Get(context.Background(),
-		fmt.Sprintf("user_location:%s", userID)).Result()
+// TakeTicket 读取并删除连接票据，保证票据只能使用一次。票据不存在时返回redis.Nil
+func (ulm *RedisManager) TakeTicket(ctx context.Context, ticket string) ([]byte, error) {
+	return ulm.redisClusterClient.GetDel(ctx, fmt.Sprintf("ws_ticket:%s", ticket)).Bytes()
 }
 
-func (ulm *RedisManager) UnregisterUser(ctx context.Context, userID string) error {
-	return ulm.redisClusterClient.Del(ctx, fmt.Sprintf("user_location:%s", userID)).Err()
+// Client 返回底层的Redis客户端，供需要直接访问Redis的组件使用
+func (ulm *RedisManager) Client() redis.Cmdable {
+	return ulm.redisClusterClient
+}
+
+// Close 关闭Redis连接池
+func (ulm *RedisManager) Close() error {
+	return ulm.redisClusterClient.Close()
 }
 
 func (ulm *RedisManager) GetNodeID() string {
 	return ulm.nodeID
 }
 
+func groupMemberKey(groupID string) string {
+	return fmt.Sprintf("group_member_by_id:%s", groupID)
+}
+
+// SetGroupMemberByID 缓存群成员列表，groupMemberTTL后过期
 func (ulm *RedisManager) SetGroupMemberByID(ctx context.Context, groupID string, members []uuid.UUID) error {
 	jsonData, err := json.Marshal(members)
 	if err != nil {
-		log.Panic("Error marshalling group members: %w", err)
 		return err
 	}
-	return ulm.redisClusterClient.Set(ctx,
-		fmt.Sprintf("group_member_by_id:%s", groupID),
-		jsonData,
-		0).Err()
+	return ulm.redisClusterClient.Set(ctx, groupMemberKey(groupID), jsonData, ulm.groupMemberTTL).Err()
 }
 
+// GetGroupMemberByID 读取缓存的群成员列表，未缓存时返回redis.Nil
 func (ulm *RedisManager) GetGroupMemberByID(ctx context.Context, groupID string) ([]uuid.UUID, error) {
-	var members []uuid.UUID
-	membersData, err := ulm.redisClusterClient.Get(ctx, fmt.Sprintf("group_member_by_id:%s", groupID)).Result()
+	membersData, err := ulm.redisClusterClient.Get(ctx, groupMemberKey(groupID)).Bytes()
 	if err != nil {
+		return nil, err
+	}
+	var members []uuid.UUID
+	if err := json.Unmarshal(membersData, &members); err != nil {
 		return nil, fmt.Errorf("failed to unmarshal group members: %w", err)
 	}
-	json.Unmarshal([]byte(membersData), &members)
 	return members, nil
 }
+
+// DeleteGroupMemberByID 群成员变化时删除缓存，下次推送时重新从Message Service获取
+func (ulm *RedisManager) DeleteGroupMemberByID(ctx context.Context, groupID string) error {
+	return ulm.redisClusterClient.Del(ctx, groupMemberKey(groupID)).Err()
+}
+
+// 节点心跳记录在有序集合里，成员是nodeID，分数是最后一次心跳的毫秒时间戳
+const gatewayNodesKey = "gateway_nodes"
+
+// nodeUsersKey 节点上有连接的用户集合
+func nodeUsersKey(nodeID string) string {
+	return fmt.Sprintf("node_users:%s", nodeID)
+}
+
+// removeNodeSessionsScript 删除用户会话中属于某个节点的设备。检查和删除在同一个脚本里完成，
+// 避免误删刚重连到其它节点的同一设备
+var removeNodeSessionsScript = redis.NewScript(`
+local removed = 0
+local sessions = redis.call('HGETALL', KEYS[1])
+for i = 1, #sessions, 2 do
+	local ok, session = pcall(cjson.decode, sessions[i + 1])
+	if ok and session['node_id'] == ARGV[1] then
+		redis.call('HDEL', KEYS[1], sessions[i])
+		removed = removed + 1
+	end
+end
+return removed
+`)
+
+// HeartbeatNode 更新节点的心跳时间
+func (ulm *RedisManager) HeartbeatNode(ctx context.Context, nodeID string) error {
+	return ulm.redisClusterClient.ZAdd(ctx, gatewayNodesKey, redis.Z{Score: float64(time.Now().UnixMilli()), Member: nodeID}).Err()
+}
+
+// RemoveNode 把节点从集群中移除
+func (ulm *RedisManager) RemoveNode(ctx context.Context, nodeID string) error {
+	return ulm.redisClusterClient.ZRem(ctx, gatewayNodesKey, nodeID).Err()
+}
+
+// GetNodes 返回所有节点及其最后一次心跳时间，包括已经过期但还没有被清理的节点
+func (ulm *RedisManager) GetNodes(ctx context.Context) (map[string]time.Time, error) {
+	members, err := ulm.redisClusterClient.ZRangeWithScores(ctx, gatewayNodesKey, 0, -1).Result()
+	if err != nil {
+		return nil, err
+	}
+	nodes := make(map[string]time.Time, len(members))
+	for _, member := range members {
+		nodeID, ok := member.Member.(string)
+		if !ok {
+			continue
+		}
+		nodes[nodeID] = time.UnixMilli(int64(member.Score))
+	}
+	return nodes, nil
+}
+
+// LockNodeCleanup 多个节点同时发现同一个下线节点时，只有拿到锁的节点负责清理
+func (ulm *RedisManager) LockNodeCleanup(ctx context.Context, nodeID string, ttl time.Duration) (bool, error) {
+	return ulm.redisClusterClient.SetNX(ctx, fmt.Sprintf("gateway_node_cleanup:%s", nodeID), ulm.nodeID, ttl).Result()
+}
+
+// RemoveNodeSessions 删除节点上所有设备的会话记录，返回删除的设备数
+func (ulm *RedisManager) RemoveNodeSessions(ctx context.Context, nodeID string) (int, error) {
+	removed := 0
+	iter := ulm.redisClusterClient.SScan(ctx, nodeUsersKey(nodeID), 0, "", 100).Iterator()
+	for iter.Next(ctx) {
+		n, err := removeNodeSessionsScript.Run(ctx, ulm.redisClusterClient, []string{fmt.Sprintf("user_sessions:%s", iter.Val())}, nodeID).Int()
+		if err != nil {
+			return removed, err
+		}
+		removed += n
+	}
+	if err := iter.Err(); err != nil {
+		return removed, err
+	}
+	return removed, ulm.redisClusterClient.Del(ctx, nodeUsersKey(nodeID)).Err()
+}
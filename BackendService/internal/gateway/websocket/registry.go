@@ -0,0 +1,183 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"sync"
+
+	"github.com/google/uuid"
+)
+
+const defaultShardCount = 64
+
+// clientRegistry 按用户ID分片的连接表，每个分片一把读写锁，
+// 不同用户的注册、注销和投递互不阻塞
+type clientRegistry struct {
+	shards []*registryShard
+}
+
+type registryShard struct {
+	mu      sync.RWMutex
+	clients map[uuid.UUID]map[string]*Client // userID -> deviceID -> 连接
+}
+
+func newClientRegistry(shardCount int) *clientRegistry {
+	if shardCount <= 0 {
+		shardCount = defaultShardCount
+	}
+	shards := make([]*registryShard, shardCount)
+	for i := range shards {
+		shards[i] = &registryShard{clients: make(map[uuid.UUID]map[string]*Client)}
+	}
+	return &clientRegistry{shards: shards}
+}
+
+// userHash uuid v4的后8个字节基本是随机的，直接取模即可均匀分布
+func userHash(userID uuid.UUID) uint64 {
+	return binary.BigEndian.Uint64(userID[8:])
+}
+
+func (r *clientRegistry) shard(userID uuid.UUID) *registryShard {
+	return r.shards[userHash(userID)%uint64(len(r.shards))]
+}
+
+// add 注册连接，同一设备重连时关闭并返回被替换的旧连接
+func (r *clientRegistry) add(client *Client) *Client {
+	s := r.shard(client.userID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	devices, ok := s.clients[client.userID]
+	if !ok {
+		devices = make(map[string]*Client)
+		s.clients[client.userID] = devices
+	}
+	previous := devices[client.deviceID]
+	devices[client.deviceID] = client
+	if previous != nil {
+		previous.closeReason = closeReplaced
+		close(previous.send)
+	}
+	return previous
+}
+
+// remove 移除连接并关闭send通道，reason随关闭帧发给客户端。
+// 返回false表示该连接已经被移除或替换
+func (r *clientRegistry) remove(client *Client, reason closeReason) bool {
+	s := r.shard(client.userID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	devices, ok := s.clients[client.userID]
+	if !ok || devices[client.deviceID] != client {
+		return false
+	}
+	delete(devices, client.deviceID)
+	if len(devices) == 0 {
+		delete(s.clients, client.userID)
+	}
+	// closeReason在close之前写入，writePump读到通道关闭后再读取，不需要额外同步
+	client.closeReason = reason
+	close(client.send)
+	return true
+}
+
+// replaced 该设备是否已经有新的连接
+func (r *clientRegistry) replaced(client *Client) bool {
+	s := r.shard(client.userID)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	current, ok := s.clients[client.userID][client.deviceID]
+	return ok && current != client
+}
+
+// get 返回用户的连接，deviceID不为空时只返回该设备，excludeDeviceID对应的设备会被跳过
+func (r *clientRegistry) get(userID uuid.UUID, deviceID, excludeDeviceID string) []*Client {
+	s := r.shard(userID)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var clients []*Client
+	for id, client := range s.clients[userID] {
+		if (deviceID != "" && id != deviceID) || (excludeDeviceID != "" && id == excludeDeviceID) {
+			continue
+		}
+		clients = append(clients, client)
+	}
+	return clients
+}
+
+// trySend 非阻塞地写入send通道。send通道只在持有写锁时关闭，
+// 持有读锁并确认连接仍在表中时写入是安全的。
+// 缓冲区满时dropOldest为true则丢弃最旧的消息后重试，dropped为丢弃的条数；
+// 否则返回sendBufferFull由调用方断开连接
+func (r *clientRegistry) trySend(client *Client, data []byte, dropOldest bool) (result sendResult, dropped int) {
+	s := r.shard(client.userID)
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.clients[client.userID][client.deviceID] != client {
+		return sendNotRegistered, 0
+	}
+	select {
+	case client.send <- data:
+		return sendDelivered, 0
+	default:
+	}
+	if !dropOldest {
+		return sendBufferFull, 0
+	}
+
+	// 读锁下可能有其它goroutine同时写入，腾出的位置被占用时继续丢弃
+	for {
+		select {
+		case <-client.send:
+			dropped++
+		default:
+		}
+		select {
+		case client.send <- data:
+			return sendDropped, dropped
+		default:
+		}
+	}
+}
+
+// count 返回连接数和在线用户数
+func (r *clientRegistry) count() (connections, users int) {
+	for _, s := range r.shards {
+		s.mu.RLock()
+		users += len(s.clients)
+		for _, devices := range s.clients {
+			connections += len(devices)
+		}
+		s.mu.RUnlock()
+	}
+	return connections, users
+}
+
+// snapshot 返回所有连接的快照，遍历时不持有锁
+func (r *clientRegistry) snapshot() []*Client {
+	var clients []*Client
+	for _, s := range r.shards {
+		s.mu.RLock()
+		for _, devices := range s.clients {
+			for _, client := range devices {
+				clients = append(clients, client)
+			}
+		}
+		s.mu.RUnlock()
+	}
+	return clients
+}
+
+func (r *clientRegistry) userIDs() []uuid.UUID {
+	var ids []uuid.UUID
+	for _, s := range r.shards {
+		s.mu.RLock()
+		for userID := range s.clients {
+			ids = append(ids, userID)
+		}
+		s.mu.RUnlock()
+	}
+	return ids
+}
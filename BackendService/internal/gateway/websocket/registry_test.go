@@ -0,0 +1,177 @@
+package websocket
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func newTestClient(userID uuid.UUID, deviceID string, bufferSize int) *Client {
+	return &Client{
+		logCtx:   context.Background(),
+		send:     make(chan []byte, bufferSize),
+		userID:   userID,
+		deviceID: deviceID,
+	}
+}
+
+// sendClosed send通道已关闭并且缓冲区为空
+func sendClosed(ch chan []byte) bool {
+	for {
+		select {
+		case _, ok := <-ch:
+			if !ok {
+				return true
+			}
+		default:
+			return false
+		}
+	}
+}
+
+func TestRegistryAddReplacesSameDevice(t *testing.T) {
+	r := newClientRegistry(4)
+	userID := uuid.New()
+	first := newTestClient(userID, "phone", 1)
+	second := newTestClient(userID, "phone", 1)
+	other := newTestClient(userID, "laptop", 1)
+
+	if previous := r.add(first); previous != nil {
+		t.Fatalf("add first: previous = %v, want nil", previous)
+	}
+	r.add(other)
+	if previous := r.add(second); previous != first {
+		t.Fatalf("add second: previous = %v, want first connection", previous)
+	}
+
+	if !sendClosed(first.send) {
+		t.Error("replaced connection's send channel is not closed")
+	}
+	if first.closeReason != closeReplaced {
+		t.Errorf("closeReason = %v, want %v", first.closeReason, closeReplaced)
+	}
+	if !r.replaced(first) || r.replaced(second) {
+		t.Error("replaced() should be true only for the old connection")
+	}
+	if r.remove(first, closeNormal) {
+		t.Error("removing a replaced connection should return false")
+	}
+	if got := r.get(userID, "phone", ""); len(got) != 1 || got[0] != second {
+		t.Errorf("get(phone) = %v, want the new connection", got)
+	}
+	if got := r.get(userID, "", "phone"); len(got) != 1 || got[0] != other {
+		t.Errorf("get excluding phone = %v, want only laptop", got)
+	}
+	if connections, users := r.count(); connections != 2 || users != 1 {
+		t.Errorf("count() = %d, %d, want 2, 1", connections, users)
+	}
+}
+
+func TestRegistryRemove(t *testing.T) {
+	r := newClientRegistry(4)
+	client := newTestClient(uuid.New(), "phone", 1)
+	r.add(client)
+
+	if !r.remove(client, closeRevoked) {
+		t.Fatal("remove() = false, want true")
+	}
+	if !sendClosed(client.send) {
+		t.Error("send channel is not closed after remove")
+	}
+	if client.closeReason != closeRevoked {
+		t.Errorf("closeReason = %v, want %v", client.closeReason, closeRevoked)
+	}
+	if r.remove(client, closeNormal) {
+		t.Error("second remove() = true, want false")
+	}
+	if got, _ := r.trySend(client, []byte("x"), false); got != sendNotRegistered {
+		t.Errorf("trySend after remove = %v, want sendNotRegistered", got)
+	}
+	if connections, users := r.count(); connections != 0 || users != 0 {
+		t.Errorf("count() = %d, %d, want 0, 0", connections, users)
+	}
+}
+
+// TestRegistryConcurrentDeliverRemoveReplace 同一分片上并发投递、移除和替换连接，
+// 用-race运行。send通道只在写锁下关闭，投递到已关闭的通道会panic
+func TestRegistryConcurrentDeliverRemoveReplace(t *testing.T) {
+	// 只有一个分片，所有用户都落在同一个分片上
+	r := newClientRegistry(1)
+	users := make([]uuid.UUID, 8)
+	for i := range users {
+		users[i] = uuid.New()
+	}
+	devices := []string{"phone", "laptop"}
+
+	const rounds = 200
+	var wg sync.WaitGroup
+
+	// 替换：不断用新连接替换同一设备
+	for _, userID := range users {
+		for _, deviceID := range devices {
+			wg.Add(1)
+			go func(userID uuid.UUID, deviceID string) {
+				defer wg.Done()
+				for i := 0; i < rounds; i++ {
+					r.add(newTestClient(userID, deviceID, 2))
+				}
+			}(userID, deviceID)
+		}
+	}
+
+	// 移除：移除当前的连接
+	for _, userID := range users {
+		wg.Add(1)
+		go func(userID uuid.UUID) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				for _, client := range r.get(userID, "", "") {
+					r.remove(client, closeNormal)
+				}
+			}
+		}(userID)
+	}
+
+	// 投递：两种策略交替，缓冲区很快就满
+	for w := 0; w < 4; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < rounds; i++ {
+				for _, userID := range users {
+					for _, client := range r.get(userID, "", "") {
+						r.trySend(client, []byte(fmt.Sprintf("%d-%d", w, i)), i%2 == 0)
+					}
+				}
+			}
+		}(w)
+	}
+
+	// 读取：和snapshot、count同时进行
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < rounds; i++ {
+			r.snapshot()
+			r.count()
+			r.userIDs()
+		}
+	}()
+
+	wg.Wait()
+
+	for _, userID := range users {
+		for _, deviceID := range devices {
+			if got := r.get(userID, deviceID, ""); len(got) > 1 {
+				t.Errorf("user %s device %s has %d connections", userID, deviceID, len(got))
+			}
+		}
+	}
+	connections, _ := r.count()
+	if snapshot := r.snapshot(); len(snapshot) != connections {
+		t.Errorf("snapshot has %d connections, count() = %d", len(snapshot), connections)
+	}
+}
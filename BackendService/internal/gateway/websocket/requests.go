@@ -0,0 +1,87 @@
+package websocket
+
+import "context"
+
+// requestTypes 客户端可以发送的所有请求类型。新增请求只需要在这里注册，
+// dispatch负责解析、回复ack/nack，ProtocolSchema据此生成schema
+func (h *Hub) requestTypes() map[string]requestType {
+	return map[string]requestType{
+		"send_p2p_message": {
+			handle:      h.handleP2PMessage,
+			description: "Send a direct message",
+			payload:     SendP2PRequest{},
+			serverSet:   []string{"sender_id"},
+			result:      MessageResponse{},
+			legacyAck:   "message_sent",
+			rateLimit:   rateLimitMessages,
+		},
+		"send_group_message": {
+			handle:      h.handleGroupMessage,
+			description: "Send a group message",
+			payload:     SendGroupRequest{},
+			serverSet:   []string{"sender_id"},
+			result:      MessageResponse{},
+			legacyAck:   "message_sent",
+			rateLimit:   rateLimitMessages,
+		},
+		"typing": {
+			handle:      h.handleTyping,
+			description: "Notify the receiver that the user is typing",
+			payload:     TypingRequest{},
+		},
+		"read_receipt": {
+			handle:      h.handleReadReceipt,
+			description: "Mark messages as read",
+		},
+		"edit_message": {
+			handle:      h.handleEditMessage,
+			description: "Edit a sent message",
+			payload:     EditMessageRequest{},
+			serverSet:   []string{"editor_id"},
+			result:      MessageUpdateResponse{},
+			rateLimit:   rateLimitMessages,
+		},
+		"recall_message": {
+			handle:      h.handleRecallMessage,
+			description: "Recall a sent message for all participants",
+			payload:     RecallMessageRequest{},
+			serverSet:   []string{"operator_id"},
+			result:      MessageUpdateResponse{},
+			rateLimit:   rateLimitMessages,
+		},
+		"delete_message": {
+			handle:      h.handleDeleteMessage,
+			description: "Delete a message for the current user only",
+			payload:     DeleteMessageRequest{},
+			serverSet:   []string{"user_id"},
+			rateLimit:   rateLimitMessages,
+		},
+		"add_reaction": {
+			handle: func(ctx context.Context, r *clientRequest) (interface{}, error) {
+				return h.handleReaction(ctx, r, true)
+			},
+			description: "Add an emoji reaction to a message",
+			payload:     ReactionRequest{},
+			serverSet:   []string{"user_id"},
+			result:      ReactionUpdateResponse{},
+			rateLimit:   rateLimitMessages,
+		},
+		"remove_reaction": {
+			handle: func(ctx context.Context, r *clientRequest) (interface{}, error) {
+				return h.handleReaction(ctx, r, false)
+			},
+			description: "Remove an emoji reaction from a message",
+			payload:     ReactionRequest{},
+			serverSet:   []string{"user_id"},
+			result:      ReactionUpdateResponse{},
+			rateLimit:   rateLimitMessages,
+		},
+		"refresh_token": {
+			handle:      h.handleRefreshToken,
+			description: "Replace the connection's token before it expires",
+			payload:     RefreshTokenRequest{},
+			result:      RefreshTokenResult{},
+			legacyAck:   "token_refreshed",
+		},
+	}
+}
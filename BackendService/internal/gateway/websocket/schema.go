@@ -0,0 +1,210 @@
+package websocket
+
+import (
+	"encoding/json"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ProtocolSchema WebSocket协议的机器可读描述，客户端可以据此生成类型或校验消息
+type ProtocolSchema struct {
+	CurrentVersion int                          `json:"current_version"`
+	Versions       []int                        `json:"versions"`
+	Subprotocols   map[string]SubprotocolSchema `json:"subprotocols"`
+	Requests       []MessageSchema              `json:"requests"`
+	Events         []MessageSchema              `json:"events"`
+	ErrorCodes     map[string]string            `json:"error_codes"`
+}
+
+// MessageSchema 一种请求或事件。Versions为空表示所有版本都支持
+type MessageSchema struct {
+	Type        string        `json:"type"`
+	Description string        `json:"description"`
+	Versions    []int         `json:"versions,omitempty"`
+	Payload     []FieldSchema `json:"payload,omitempty"`
+	Result      []FieldSchema `json:"result,omitempty"`
+	LegacyAck   string        `json:"legacy_ack,omitempty"`
+}
+
+// FieldSchema 一个字段，Fields是对象或数组元素的字段
+type FieldSchema struct {
+	Name     string        `json:"name"`
+	Type     string        `json:"type"`
+	Required bool          `json:"required,omitempty"`
+	Fields   []FieldSchema `json:"fields,omitempty"`
+}
+
+// eventSchema 服务端推送的事件，payload是结构体示例或直接给出的字段
+type eventSchema struct {
+	typ         string
+	description string
+	versions    []int
+	payload     interface{}
+	fields      []FieldSchema
+}
+
+var eventSchemas = []eventSchema{
+	{typ: "connection_established", description: "Sent once after the connection is registered", fields: []FieldSchema{
+		{Name: "user_id", Type: "uuid", Required: true},
+		{Name: "device_id", Type: "string", Required: true},
+		{Name: "protocol_version", Type: "integer", Required: true},
+		{Name: "supported_versions", Type: "array<integer>", Required: true},
+	}},
+	{typ: "ack", description: "Successful response to a request, payload is the request's result", versions: []int{ProtocolV2}},
+	{typ: "nack", description: "Failed response to a request", versions: []int{ProtocolV2}, payload: ErrorPayload{}},
+	{typ: "error", description: "Failed request", versions: []int{ProtocolV1}, payload: ErrorPayload{}},
+	{typ: "message_sent", description: "Message was stored", versions: []int{ProtocolV1}, payload: MessageResponse{}},
+	{typ: "token_refreshed", description: "Token was replaced", versions: []int{ProtocolV1}, payload: RefreshTokenResult{}},
+	{typ: "new_p2p_message", description: "New direct message, also synced to the sender's other devices", payload: P2PMessageEvent{}},
+	{typ: "new_group_message", description: "New group message", payload: GroupMessageEvent{}},
+	{typ: "mention", description: "The user was mentioned in a group message", payload: MentionEvent{}},
+	{typ: "typing_indicator", description: "Another user is typing", fields: []FieldSchema{
+		{Name: "user_id", Type: "uuid", Required: true},
+		{Name: "is_typing", Type: "boolean", Required: true},
+	}},
+	{typ: "message_edited", description: "A message was edited", payload: MessageUpdateResponse{}},
+	{typ: "message_recalled", description: "A message was recalled", payload: MessageUpdateResponse{}},
+	{typ: "message_deleted", description: "The user deleted a message on another device", fields: []FieldSchema{
+		{Name: "message_id", Type: "uuid", Required: true},
+		{Name: "chat_type", Type: "string", Required: true},
+	}},
+	{typ: "reaction_updated", description: "Reactions on a message changed", payload: ReactionUpdateResponse{}},
+	{typ: "conversation_settings_updated", description: "Conversation settings changed on another device", payload: ConversationSettings{}},
+	{typ: "conversation_pins_updated", description: "Pinned conversations were reordered", fields: []FieldSchema{
+		{Name: "pinned", Type: "array<object>", Required: true, Fields: describe(reflect.TypeOf(ConversationSettings{}), nil)},
+	}},
+	{typ: "session_revoked", description: "A device was signed out; the connection is closed if it is this device", fields: []FieldSchema{
+		{Name: "device_id", Type: "string", Required: true},
+		{Name: "reason", Type: "string"},
+	}},
+	{typ: "token_expiring", description: "The token expires soon, send refresh_token", fields: []FieldSchema{
+		{Name: "expires_at", Type: "integer", Required: true},
+	}},
+	{typ: "token_expired", description: "The token expired, the connection will be closed", fields: []FieldSchema{
+		{Name: "expires_at", Type: "integer", Required: true},
+	}},
+	{typ: "reconnect", description: "The node is shutting down; reconnect after the given delay and the load balancer will pick another node", fields: []FieldSchema{
+		{Name: "reason", Type: "string", Required: true},
+		{Name: "reconnect_after_ms", Type: "integer", Required: true},
+	}},
+}
+
+var errorCodeDescriptions = map[string]string{
+	CodeInvalidPayload:     "The request body could not be parsed",
+	CodeUnknownType:        "The request type is not supported",
+	CodeUnsupportedVersion: "The envelope version is newer than the server supports",
+	CodeMessageTooLarge:    "The frame exceeds the size limit for its type",
+	CodeInvalidToken:       "The token is invalid, expired, revoked or belongs to another user",
+	CodeServiceError:       "A backend service rejected or failed the request",
+	CodeInternal:           "Unexpected server error",
+	CodeRateLimited:        "Too many requests of this kind, retry after retry_after_ms",
+}
+
+// ProtocolSchema 返回当前支持的所有请求、事件和错误码
+func (h *Hub) ProtocolSchema() ProtocolSchema {
+	schema := ProtocolSchema{
+		CurrentVersion: CurrentProtocolVersion,
+		Versions:       []int{ProtocolV1, ProtocolV2},
+		Subprotocols:   subprotocolFormats,
+		ErrorCodes:     errorCodeDescriptions,
+	}
+
+	for name, rt := range h.requests {
+		s := MessageSchema{Type: name, Description: rt.description, LegacyAck: rt.legacyAck}
+		if rt.payload != nil {
+			s.Payload = describe(reflect.TypeOf(rt.payload), rt.serverSet)
+		}
+		if rt.result != nil {
+			s.Result = describe(reflect.TypeOf(rt.result), nil)
+		}
+		schema.Requests = append(schema.Requests, s)
+	}
+	sort.Slice(schema.Requests, func(i, j int) bool { return schema.Requests[i].Type < schema.Requests[j].Type })
+
+	for _, e := range eventSchemas {
+		s := MessageSchema{Type: e.typ, Description: e.description, Versions: e.versions, Payload: e.fields}
+		if e.payload != nil {
+			s.Payload = describe(reflect.TypeOf(e.payload), nil)
+		}
+		schema.Events = append(schema.Events, s)
+	}
+	return schema
+}
+
+var (
+	uuidType       = reflect.TypeOf(uuid.UUID{})
+	timeType       = reflect.TypeOf(time.Time{})
+	rawMessageType = reflect.TypeOf(json.RawMessage{})
+)
+
+// describe 按json tag列出结构体的字段，omit里的字段跳过。
+// 指针和omitempty的字段是可选的
+func describe(t reflect.Type, omit []string) []FieldSchema {
+	skip := make(map[string]bool, len(omit))
+	for _, name := range omit {
+		skip[name] = true
+	}
+
+	var fields []FieldSchema
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if !f.IsExported() || tag == "-" {
+			continue
+		}
+		name, opts, _ := strings.Cut(tag, ",")
+		if name == "" {
+			name = f.Name
+		}
+		if skip[name] {
+			continue
+		}
+		typeName, nested := describeType(f.Type)
+		fields = append(fields, FieldSchema{
+			Name:     name,
+			Type:     typeName,
+			Required: f.Type.Kind() != reflect.Ptr && !strings.Contains(opts, "omitempty"),
+			Fields:   nested,
+		})
+	}
+	return fields
+}
+
+func describeType(t reflect.Type) (string, []FieldSchema) {
+	if t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	switch t {
+	case uuidType:
+		return "uuid", nil
+	case timeType:
+		return "datetime", nil
+	case rawMessageType:
+		return "object", nil
+	}
+
+	switch t.Kind() {
+	case reflect.String:
+		return "string", nil
+	case reflect.Bool:
+		return "boolean", nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer", nil
+	case reflect.Float32, reflect.Float64:
+		return "number", nil
+	case reflect.Slice, reflect.Array:
+		elem, nested := describeType(t.Elem())
+		return "array<" + elem + ">", nested
+	case reflect.Struct:
+		return "object", describe(t, nil)
+	case reflect.Map:
+		return "object", nil
+	default:
+		return "any", nil
+	}
+}
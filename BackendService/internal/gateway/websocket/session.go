@@ -0,0 +1,77 @@
+package websocket
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+var ErrSessionNotFound = errors.New("session not found")
+
+// SessionInfo 用户的一个在线设备，保存在Redis的user_sessions:<userID>哈希里
+type SessionInfo struct {
+	DeviceID    string `json:"device_id"`
+	DeviceName  string `json:"device_name,omitempty"`
+	NodeID      string `json:"node_id"`
+	ConnectedAt int64  `json:"connected_at"`
+}
+
+// GetUserSessions 返回用户在所有节点上的在线设备
+func (h *Hub) GetUserSessions(ctx context.Context, userID uuid.UUID) ([]SessionInfo, error) {
+	return h.RedisManager.GetUserSessions(ctx, userID.String())
+}
+
+// DisconnectDevice 远程下线用户的某个设备。用户的所有设备都会收到session_revoked事件，
+// 被下线的设备收到后连接被关闭
+func (h *Hub) DisconnectDevice(ctx context.Context, userID uuid.UUID, deviceID string) error {
+	sessions, err := h.RedisManager.GetUserSessions(ctx, userID.String())
+	if err != nil {
+		return err
+	}
+	var target *SessionInfo
+	for i := range sessions {
+		if sessions[i].DeviceID == deviceID {
+			target = &sessions[i]
+			break
+		}
+	}
+	if target == nil {
+		return ErrSessionNotFound
+	}
+
+	message := OutgoingMessage{
+		Type:      "session_revoked",
+		Data:      map[string]interface{}{"device_id": deviceID},
+		Timestamp: time.Now().Unix(),
+	}
+	h.routeToUserExcept(ctx, userID, deviceID, message)
+
+	if target.NodeID == h.RedisManager.GetNodeID() {
+		h.sendToDevice(userID, deviceID, message)
+		h.disconnectLocal(userID, deviceID)
+		return nil
+	}
+	// 节点已经下线，连接早已断开，直接删除会话记录
+	if !h.cluster.alive(target.NodeID) {
+		return h.RedisManager.RemoveUserSession(ctx, userID.String(), deviceID)
+	}
+	h.PublishToTargetNode(ctx, target.NodeID, CrossNodeMessage{
+		UserID:     userID,
+		DeviceID:   deviceID,
+		Disconnect: true,
+		Message:    message,
+	})
+	return nil
+}
+
+// disconnectLocal 关闭本节点上某个设备的连接，writePump发送完缓冲区里的消息后关闭连接
+func (h *Hub) disconnectLocal(userID uuid.UUID, deviceID string) {
+	for _, client := range h.clients.get(userID, deviceID, "") {
+		if h.clients.remove(client, closeRevoked) {
+			slog.InfoContext(client.logCtx, "Client disconnected", "reason", "remote_disconnect")
+		}
+	}
+}
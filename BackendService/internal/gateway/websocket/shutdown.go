@@ -0,0 +1,79 @@
+package websocket
+
+import (
+	"context"
+	"log/slog"
+	"math/rand"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const defaultReconnectJitter = 10 * time.Second
+
+var closeShutdown = closeReason{websocket.CloseServiceRestart, "server shutting down"}
+
+// acquireConn 节点关闭后拒绝新的连接，返回true时调用方必须在连接结束后调用conns.Done
+func (h *Hub) acquireConn() bool {
+	h.drainMu.RLock()
+	defer h.drainMu.RUnlock()
+	if h.draining {
+		return false
+	}
+	h.conns.Add(1)
+	return true
+}
+
+// Draining 节点是否正在关闭
+func (h *Hub) Draining() bool {
+	h.drainMu.RLock()
+	defer h.drainMu.RUnlock()
+	return h.draining
+}
+
+// Drain 停止接受新连接，通知所有客户端在随机延迟后重连到其它节点，
+// 等待发送缓冲区里的消息写完、连接关闭并删除会话记录。ctx到期时返回ctx的错误
+func (h *Hub) Drain(ctx context.Context) error {
+	// 1. 拒绝新连接，之后不会再有conns.Add
+	h.drainMu.Lock()
+	h.draining = true
+	h.drainMu.Unlock()
+
+	// 2. 通知客户端并关闭send通道，writePump写完缓冲区后发送关闭帧，
+	// readPump随后退出并删除会话记录。在这之后注册的连接由register直接关闭
+	clients := h.clients.snapshot()
+	slog.Info("Draining connections", "connections", len(clients))
+	for _, client := range clients {
+		h.disconnectForShutdown(client)
+	}
+
+	// 3. 等待所有连接结束
+	done := make(chan struct{})
+	go func() {
+		h.conns.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		slog.Info("All connections drained")
+		return nil
+	case <-ctx.Done():
+		connections, _ := h.clients.count()
+		slog.Warn("Drain timed out", "connections_left", connections)
+		return ctx.Err()
+	}
+}
+
+// disconnectForShutdown 发送reconnect事件后关闭连接，客户端在reconnect_after_ms之后重连
+func (h *Hub) disconnectForShutdown(client *Client) {
+	delay := time.Duration(rand.Int63n(int64(h.reconnectJitter)))
+	client.sendMessage(OutgoingMessage{
+		Type: "reconnect",
+		Data: map[string]interface{}{
+			"reason":             "server_shutdown",
+			"reconnect_after_ms": delay.Milliseconds(),
+		},
+		Timestamp: time.Now().Unix(),
+	})
+	h.clients.remove(client, closeShutdown)
+}
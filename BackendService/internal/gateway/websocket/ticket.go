@@ -0,0 +1,85 @@
+package websocket
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/huangrao121/CommunicationApp/BackendService/config/pkg"
+	"github.com/redis/go-redis/v9"
+)
+
+const defaultTicketTTL = 30 * time.Second
+
+var ErrInvalidTicket = errors.New("invalid or expired ticket")
+
+// IssueTicket 为已认证的用户签发连接票据，返回票据和有效期。
+// 浏览器无法在WebSocket握手时设置Authorization头，先用JWT换取一个短期的一次性票据，
+// 再通过/ws?ticket=...建立连接，避免长期有效的JWT出现在URL里。
+// 票据保存原token的claims，连接的过期时间仍然以原token为准
+func (h *Hub) IssueTicket(ctx context.Context, claims *pkg.AppClaims) (string, time.Duration, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", 0, err
+	}
+	ticket := base64.RawURLEncoding.EncodeToString(buf)
+
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return "", 0, err
+	}
+	ttl := h.ticketTTL
+	if claims.ExpiresAt != nil && time.Until(claims.ExpiresAt.Time) < ttl {
+		ttl = time.Until(claims.ExpiresAt.Time)
+	}
+	if ttl <= 0 {
+		return "", 0, ErrTokenExpired
+	}
+	if err := h.RedisManager.SetTicket(ctx, ticket, data, ttl); err != nil {
+		return "", 0, err
+	}
+	return ticket, ttl, nil
+}
+
+// RedeemTicket 校验并作废票据，返回签发票据时的token claims
+func (h *Hub) RedeemTicket(ctx context.Context, ticket string) (*pkg.AppClaims, error) {
+	data, err := h.RedisManager.TakeTicket(ctx, ticket)
+	if errors.Is(err, redis.Nil) {
+		return nil, ErrInvalidTicket
+	}
+	if err != nil {
+		return nil, err
+	}
+	var claims pkg.AppClaims
+	if err := json.Unmarshal(data, &claims); err != nil {
+		return nil, ErrInvalidTicket
+	}
+	return &claims, nil
+}
+
+// newOriginChecker 按允许列表检查Origin。没有Origin头的请求来自原生客户端，直接放行；
+// 列表为空时只允许与Host同源的请求
+func newOriginChecker(allowed []string) func(r *http.Request) bool {
+	origins := make(map[string]bool, len(allowed))
+	for _, origin := range allowed {
+		origins[strings.ToLower(strings.TrimSuffix(origin, "/"))] = true
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || origins["*"] {
+			return true
+		}
+		if len(origins) == 0 {
+			u, err := url.Parse(origin)
+			return err == nil && strings.EqualFold(u.Host, r.Host)
+		}
+		return origins[strings.ToLower(origin)]
+	}
+}
@@ -0,0 +1,174 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/config/pkg"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/revocation"
+)
+
+const (
+	tokenCheckInterval = 10 * time.Second
+	// 过期前多久提醒客户端刷新token
+	tokenExpiryWarning = time.Minute
+)
+
+var (
+	ErrTokenExpired  = errors.New("token expired")
+	ErrTokenRevoked  = errors.New("token revoked")
+	ErrTokenMismatch = errors.New("token belongs to another user")
+)
+
+var closeTokenExpired = closeReason{4001, "token expired"}
+
+type RefreshTokenRequest struct {
+	Token string `json:"token"`
+}
+
+type RefreshTokenResult struct {
+	ExpiresAt int64 `json:"expires_at"`
+}
+
+// CheckToken 建立连接前检查token是否已被撤销
+func (h *Hub) CheckToken(ctx context.Context, claims *pkg.AppClaims) error {
+	if claims.ExpiresAt != nil && !claims.ExpiresAt.After(time.Now()) {
+		return ErrTokenExpired
+	}
+	revoked, err := revocation.IsRevoked(ctx, h.RedisManager.Client(), claims.ID, claims.IssuedAtMillis())
+	if err != nil {
+		return err
+	}
+	if revoked {
+		return ErrTokenRevoked
+	}
+	return nil
+}
+
+// setToken 记录连接当前使用的token的签发和过期时间，没有exp的token永不过期
+func (c *Client) setToken(claims *pkg.AppClaims) {
+	var expiresAt int64
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Unix()
+	}
+	c.tokenIssuedAt.Store(claims.IssuedAtMillis())
+	c.tokenExpiresAt.Store(expiresAt)
+	c.expiryWarned.Store(false)
+}
+
+// handleRefreshToken 客户端在连接内刷新token，新token必须属于同一用户且未被撤销
+func (h *Hub) handleRefreshToken(ctx context.Context, r *clientRequest) (interface{}, error) {
+	var req RefreshTokenRequest
+	if err := json.Unmarshal(r.Data, &req); err != nil {
+		return nil, invalidPayload(err)
+	}
+
+	claims, err := pkg.ParseJWKToken(req.Token, os.Getenv("PK_PATH"))
+	if err == nil && claims.ID != r.UserID {
+		err = ErrTokenMismatch
+	}
+	if err == nil {
+		err = h.CheckToken(ctx, claims)
+	}
+	if err != nil {
+		return nil, &ProtocolError{Code: CodeInvalidToken, Message: "Failed to refresh token", Err: err}
+	}
+
+	var result RefreshTokenResult
+	for _, client := range h.clients.get(r.UserID, r.DeviceID, "") {
+		client.setToken(claims)
+		result.ExpiresAt = client.tokenExpiresAt.Load()
+	}
+	return result, nil
+}
+
+// watchTokenExpiry 定期检查本节点所有连接的token，快过期时提醒客户端刷新，过期后断开连接
+func (h *Hub) watchTokenExpiry(ctx context.Context) {
+	ticker := time.NewTicker(tokenCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			h.checkTokenExpiry(now)
+		}
+	}
+}
+
+func (h *Hub) checkTokenExpiry(now time.Time) {
+	for _, client := range h.clients.snapshot() {
+		expiresAt := client.tokenExpiresAt.Load()
+		if expiresAt == 0 {
+			continue
+		}
+
+		message := OutgoingMessage{
+			Data:      map[string]interface{}{"expires_at": expiresAt},
+			Timestamp: now.Unix(),
+		}
+		switch {
+		case now.Unix() >= expiresAt:
+			// 先发事件再关闭，writePump会把缓冲区里的消息发完再发送关闭帧
+			message.Type = "token_expired"
+			client.sendMessage(message)
+			if h.clients.remove(client, closeTokenExpired) {
+				slog.InfoContext(client.logCtx, "Client disconnected", "reason", "token_expired")
+			}
+		case now.Add(tokenExpiryWarning).Unix() >= expiresAt && client.expiryWarned.CompareAndSwap(false, true):
+			message.Type = "token_expiring"
+			client.sendMessage(message)
+		}
+	}
+}
+
+// listenRevocations 订阅令牌撤销事件，断开本节点上使用被撤销token的连接
+func (h *Hub) listenRevocations(ctx context.Context) {
+	ch := h.RedisManager.redisClusterClient.Subscribe(ctx, revocation.Channel)
+	defer ch.Close()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-ch.Channel():
+			var event revocation.Event
+			if err := json.Unmarshal([]byte(msg.Payload), &event); err != nil {
+				slog.ErrorContext(ctx, "Failed to unmarshal revocation event", "error", err)
+				continue
+			}
+			h.revokeLocal(event)
+		}
+	}
+}
+
+func (h *Hub) revokeLocal(event revocation.Event) {
+	for _, client := range h.clients.get(event.UserID, event.DeviceID, "") {
+		if client.tokenIssuedAt.Load() >= event.IssuedBefore {
+			continue
+		}
+		client.sendMessage(OutgoingMessage{
+			Type: "session_revoked",
+			Data: map[string]interface{}{
+				"device_id": client.deviceID,
+				"reason":    event.Reason,
+			},
+			Timestamp: time.Now().Unix(),
+		})
+		if h.clients.remove(client, closeRevoked) {
+			slog.InfoContext(client.logCtx, "Client disconnected", "reason", "token_revoked", "revoke_reason", event.Reason)
+		}
+	}
+}
+
+// RevokeUserTokens 撤销用户当前所有token并断开所有节点上的连接，用于退出登录
+func (h *Hub) RevokeUserTokens(ctx context.Context, userID uuid.UUID, reason string) error {
+	return revocation.Publish(ctx, h.RedisManager.Client(), revocation.Event{
+		UserID: userID,
+		Reason: reason,
+	})
+}
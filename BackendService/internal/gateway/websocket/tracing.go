@@ -0,0 +1,33 @@
+package websocket
+
+import (
+	"context"
+
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/tracing"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// startRequestSpan 为一条上行请求创建server span。
+// 不支持的类型统一命名为websocket unknown，避免客户端随意构造span名
+func startRequestSpan(ctx context.Context, incoming IncomingMessage, requests map[string]requestType) (context.Context, trace.Span) {
+	if incoming.Traceparent != "" {
+		ctx = otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier{
+			"traceparent": incoming.Traceparent,
+			"tracestate":  incoming.Tracestate,
+		})
+	}
+	name := "unknown"
+	if _, ok := requests[incoming.Type]; ok {
+		name = incoming.Type
+	}
+	return tracing.Tracer().Start(ctx, "websocket "+name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("websocket.request.type", name),
+			attribute.String("websocket.request.id", incoming.RequestID),
+			attribute.Int("websocket.protocol.version", incoming.Version),
+		))
+}
@@ -0,0 +1,51 @@
+package websocket
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"sync"
+
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/kafka"
+)
+
+// ConsumeMessageUpdates 消费消息topic中的编辑/撤回事件并推送给会话参与者，阻塞直到ctx取消。
+// REST接口、审核复核和管理员删除产生的事件只写入Kafka，WebSocket请求产生的事件也统一从这里推送，
+// 避免重复推送。所有网关节点共享同一个消费者组，每个事件只由一个节点处理，再经Hub跨节点路由
+func (h *Hub) ConsumeMessageUpdates(ctx context.Context, brokers []string, topics []string, groupID string) {
+	var wg sync.WaitGroup
+	for _, topic := range topics {
+		consumer := kafka.NewConsumer(brokers, topic, groupID)
+		wg.Add(1)
+		go func(topic string) {
+			defer wg.Done()
+			defer consumer.Close()
+			slog.InfoContext(ctx, "message update consumer started", "topic", topic, "group", groupID)
+			if err := consumer.Start(ctx, h.handleMessageUpdateEvent); err != nil && !errors.Is(err, context.Canceled) {
+				slog.ErrorContext(ctx, "message update consumer stopped", "topic", topic, "error", err)
+			}
+		}(topic)
+	}
+	wg.Wait()
+}
+
+// handleMessageUpdateEvent 新消息由发送时的Hub直接推送，这里只处理编辑和撤回
+func (h *Hub) handleMessageUpdateEvent(ctx context.Context, payload kafka.MessagePayload) error {
+	switch payload.Type {
+	case "message_edited", "message_recalled":
+	default:
+		return nil
+	}
+	// Data反序列化后是map，重新编码成MessageUpdateResponse
+	data, err := json.Marshal(payload.Data)
+	if err != nil {
+		return err
+	}
+	var resp MessageUpdateResponse
+	if err := json.Unmarshal(data, &resp); err != nil {
+		return err
+	}
+	h.notifyMessageUpdate(ctx, payload.Type, &resp)
+	return nil
+}
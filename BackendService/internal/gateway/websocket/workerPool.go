@@ -0,0 +1,103 @@
+package websocket
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sync"
+
+	"github.com/huangrao121/CommunicationApp/BackendService/config/logger"
+)
+
+const defaultQueueSize = 1024
+
+// workerPool 处理客户端上行消息的固定大小的worker池。
+// 同一用户的消息总是进入同一个队列，保证按发送顺序处理；
+// 队列有界，队列满时submit阻塞，反压到对应连接的readPump。
+// 代价是队头阻塞：一个用户的慢调用会让分到同一worker的其他用户一起等待，
+// dispatch用connOptions.requestTimeout限制每个请求的处理时间，worker数也按阻塞调用放大到CPU数的4倍
+type workerPool struct {
+	queues  []chan UserMessage
+	handler func(context.Context, UserMessage)
+	wg      sync.WaitGroup
+	once    sync.Once
+	// done在start的ctx取消后关闭，之后submit不再阻塞
+	done chan struct{}
+}
+
+func newWorkerPool(workers, queueSize int, handler func(context.Context, UserMessage)) *workerPool {
+	if workers <= 0 {
+		workers = runtime.NumCPU() * 4
+	}
+	if queueSize <= 0 {
+		queueSize = defaultQueueSize
+	}
+	queues := make([]chan UserMessage, workers)
+	for i := range queues {
+		queues[i] = make(chan UserMessage, queueSize)
+	}
+	return &workerPool{queues: queues, handler: handler, done: make(chan struct{})}
+}
+
+// start 启动所有worker，ctx取消后worker处理完已取出的消息即退出
+func (p *workerPool) start(ctx context.Context) {
+	p.once.Do(func() {
+		for _, queue := range p.queues {
+			p.wg.Add(1)
+			go p.run(ctx, queue)
+		}
+		go func() {
+			<-ctx.Done()
+			close(p.done)
+		}()
+	})
+}
+
+func (p *workerPool) run(ctx context.Context, queue chan UserMessage) {
+	defer p.wg.Done()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case msg := <-queue:
+			p.handle(ctx, msg)
+		}
+	}
+}
+
+// handle 单条消息处理panic时只丢弃这条消息，不影响同一worker上的其他用户
+func (p *workerPool) handle(ctx context.Context, msg UserMessage) {
+	defer func() {
+		if r := recover(); r != nil {
+			slog.ErrorContext(ctx, "Recovered from panic handling message", logger.ConnIDKey, msg.ConnID, logger.UserIDKey, msg.UserID.String(), "panic", r)
+		}
+	}()
+	p.handler(ctx, msg)
+}
+
+// submit 把消息放入该用户对应的队列，worker池已停止时返回false
+func (p *workerPool) submit(msg UserMessage) bool {
+	queue := p.queues[userHash(msg.UserID)%uint64(len(p.queues))]
+	select {
+	case queue <- msg:
+		return true
+	case <-p.done:
+		return false
+	}
+}
+
+// depth 返回所有队列里等待处理的消息总数和最满的队列的长度
+func (p *workerPool) depth() (total, max int) {
+	for _, queue := range p.queues {
+		n := len(queue)
+		total += n
+		if n > max {
+			max = n
+		}
+	}
+	return total, max
+}
+
+func (p *workerPool) capacity() int {
+	return cap(p.queues[0])
+}
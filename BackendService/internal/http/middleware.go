@@ -1,50 +1,14 @@
 package http
 
 import (
-	"context"
 	"crypto/rand"
 	"encoding/base64"
-	"log/slog"
 	"net/http"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 )
 
-type ctxKey struct{}
-
-func SetLogger() gin.HandlerFunc {
-	return func(c *gin.Context) {
-		start := time.Now()
-		rid := c.GetHeader("X-Request-ID")
-		if rid == "" {
-			rid = uuid.New().String()
-		}
-		c.Writer.Header().Set("request_id", rid)
-
-		l := slog.Default().With(
-			"method", c.Request.Method,
-			"path", c.FullPath(),
-			"remote_ip", c.ClientIP(),
-		)
-		ctx := context.WithValue(c.Request.Context(), ctxKey{}, l)
-		c.Request = c.Request.WithContext(ctx)
-		c.Next()
-
-		l.Info("request completed", "status", c.Writer.Status(), "latency_ms", time.Since(start).Milliseconds(), "bytes", c.Writer.Size())
-	}
-}
-
-func FromCtx(ctx context.Context) *slog.Logger {
-	if v := ctx.Value(ctxKey{}); v != nil {
-		if l, ok := v.(*slog.Logger); ok {
-			return l
-		}
-	}
-	return slog.Default()
-}
-
 // SecureHeaders 添加安全相关的HTTP头
 func SecureHeaders() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -1,17 +1,40 @@
 package http
 
-import "github.com/gin-gonic/gin"
+import (
+	"github.com/gin-gonic/gin"
+	"github.com/huangrao121/CommunicationApp/BackendService/config/logger"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/metrics"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/user"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
+)
 
-func InitRouter() *gin.Engine {
-	router := gin.Default()
+// InitRouter rateLimit按路由限流，登录另外由UserHandler的LoginGuard限制尝试次数；
+// auth校验token，requireAdmin和auth一起保护日志级别接口
+func InitRouter(userHandler *user.UserHandler, rateLimit gin.HandlerFunc, auth gin.HandlerFunc, requireAdmin gin.HandlerFunc) *gin.Engine {
+	// 不使用gin.Default自带的文本日志，请求日志由logger.GinMiddleware记录
+	router := gin.New()
+	router.Use(gin.Recovery())
 
 	router.Use(SecureHeaders())
-	router.Use(SetLogger())
+	// 请求日志从span里取trace ID，otelgin必须在它前面
+	router.Use(otelgin.Middleware("api"))
+	router.Use(logger.GinMiddleware())
+	router.Use(metrics.GinMiddleware("api"))
 
 	if gin.Mode() == gin.ReleaseMode {
 		router.Use(CSRF())
 	}
 
+	router.GET("/metrics", gin.WrapH(metrics.Handler()))
+	logger.RegisterRoutes(router, auth, requireAdmin)
+
+	api := router.Group("/api/v1", rateLimit)
+	{
+		api.POST("/users", userHandler.CreateUser)
+		api.POST("/login", userHandler.Login)
+		api.POST("/mqtt-token", auth, userHandler.RefreshToken)
+	}
+
 	// router.GET("/health", func(c *gin.Context) {
 	// 	c.JSON(http.StatusOK, gin.H{"message": "OK"})
 	// })
@@ -0,0 +1,206 @@
+package handler
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/middleware"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/message/service"
+)
+
+// AdminHandler 管理员接口，路由必须经过AuthMiddleware和RequireAdmin，操作者取自token
+type AdminHandler struct {
+	adminService *service.AdminService
+}
+
+func NewAdminHandler(adminService *service.AdminService) *AdminHandler {
+	return &AdminHandler{adminService: adminService}
+}
+
+func (h *AdminHandler) ListReports(c *gin.Context) {
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	reports, err := h.adminService.ListReports(c.Request.Context(), c.Query("status"), c.Query("target_type"), offset, limit)
+	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"reports": reports})
+}
+
+func (h *AdminHandler) ResolveReport(c *gin.Context) {
+	adminID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	reportID, err := uuid.Parse(c.Param("report_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req service.ResolveReportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ReportID = reportID
+	req.AdminID = adminID
+
+	report, err := h.adminService.ResolveReport(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+func (h *AdminHandler) SuspendUser(c *gin.Context) {
+	h.userAction(c, h.adminService.SuspendUser)
+}
+
+func (h *AdminHandler) BanUser(c *gin.Context) {
+	h.userAction(c, h.adminService.BanUser)
+}
+
+func (h *AdminHandler) ReinstateUser(c *gin.Context) {
+	h.userAction(c, h.adminService.ReinstateUser)
+}
+
+// userAction 禁言、封禁和解封的请求格式相同
+func (h *AdminHandler) userAction(c *gin.Context, action func(ctx context.Context, req *service.UserActionRequest) (*service.UserModerationStatus, error)) {
+	adminID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req service.UserActionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = userID
+	req.AdminID = adminID
+
+	result, err := action(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func (h *AdminHandler) DeleteMessage(c *gin.Context) {
+	adminID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req service.AdminDeleteMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ChatType = c.Param("chat_type")
+	req.MessageID = messageID
+	req.AdminID = adminID
+
+	resp, err := h.adminService.DeleteMessage(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// ListAuditLogs 审计日志，支持按admin_id和target_id过滤
+func (h *AdminHandler) ListAuditLogs(c *gin.Context) {
+	var adminID, targetID uuid.UUID
+	var err error
+	if value := c.Query("admin_id"); value != "" {
+		if adminID, err = uuid.Parse(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	if value := c.Query("target_id"); value != "" {
+		if targetID, err = uuid.Parse(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	logs, err := h.adminService.ListAuditLogs(c.Request.Context(), adminID, targetID, offset, limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"audit_logs": logs})
+}
+
+// ListModerationRecords 审核队列，支持按status(pending/approved/removed)和action(flag/block)过滤
+func (h *AdminHandler) ListModerationRecords(c *gin.Context) {
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	records, err := h.adminService.ListModerationRecords(c.Request.Context(), c.Query("status"), c.Query("action"), offset, limit)
+	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"records": records})
+}
+
+func (h *AdminHandler) ReviewModeration(c *gin.Context) {
+	reviewerID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	recordID, err := uuid.Parse(c.Param("record_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req service.ReviewModerationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.RecordID = recordID
+	req.ReviewerID = reviewerID
+
+	record, err := h.adminService.ReviewModeration(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, record)
+}
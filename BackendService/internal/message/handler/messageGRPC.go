@@ -0,0 +1,489 @@
+package handler
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/gateway/websocket"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/message/service"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/pb/messagepb"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const defaultHistoryLimit = 20
+
+// MessageGRPCServer Message Service的gRPC接口，供Gateway调用，与REST API共用同一个MessageService
+type MessageGRPCServer struct {
+	messagepb.UnimplementedMessageServiceServer
+	messageService *service.MessageService
+}
+
+func NewMessageGRPCServer(messageService *service.MessageService) *MessageGRPCServer {
+	return &MessageGRPCServer{messageService: messageService}
+}
+
+func (s *MessageGRPCServer) SendP2PMessage(ctx context.Context, req *messagepb.SendP2PMessageRequest) (*messagepb.MessageResponse, error) {
+	senderID, err := parseUUID("sender_id", req.SenderId)
+	if err != nil {
+		return nil, err
+	}
+	receiverID, err := parseUUID("receiver_id", req.ReceiverId)
+	if err != nil {
+		return nil, err
+	}
+	replyToID, err := parseOptionalUUID("reply_to_id", req.ReplyToId)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.messageService.SendP2PMessage(ctx, &service.SendP2PMessageRequest{
+		SenderID:    senderID,
+		ReceiverID:  receiverID,
+		Content:     req.Content,
+		ContentType: int(req.ContentType),
+		ReplyToID:   replyToID,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toPBMessageResponse(resp), nil
+}
+
+func (s *MessageGRPCServer) SendGroupMessage(ctx context.Context, req *messagepb.SendGroupMessageRequest) (*messagepb.MessageResponse, error) {
+	senderID, err := parseUUID("sender_id", req.SenderId)
+	if err != nil {
+		return nil, err
+	}
+	groupID, err := parseUUID("group_id", req.GroupId)
+	if err != nil {
+		return nil, err
+	}
+	replyToID, err := parseOptionalUUID("reply_to_id", req.ReplyToId)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.messageService.SendGroupMessage(ctx, &service.SendGroupMessageRequest{
+		SenderID:    senderID,
+		GroupID:     groupID,
+		Content:     req.Content,
+		ContentType: int(req.ContentType),
+		ReplyToID:   replyToID,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toPBMessageResponse(resp), nil
+}
+
+func (s *MessageGRPCServer) EditMessage(ctx context.Context, req *messagepb.EditMessageRequest) (*messagepb.MessageUpdate, error) {
+	messageID, err := parseUUID("message_id", req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+	editorID, err := parseUUID("editor_id", req.EditorId)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.messageService.EditMessage(ctx, &service.EditMessageRequest{
+		MessageID:   messageID,
+		ChatType:    req.ChatType,
+		EditorID:    editorID,
+		Content:     req.Content,
+		ContentType: int(req.ContentType),
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toPBMessageUpdate(resp), nil
+}
+
+func (s *MessageGRPCServer) RecallMessage(ctx context.Context, req *messagepb.RecallMessageRequest) (*messagepb.MessageUpdate, error) {
+	messageID, err := parseUUID("message_id", req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+	operatorID, err := parseUUID("operator_id", req.OperatorId)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.messageService.RecallMessage(ctx, &service.RecallMessageRequest{
+		MessageID:  messageID,
+		ChatType:   req.ChatType,
+		OperatorID: operatorID,
+	})
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toPBMessageUpdate(resp), nil
+}
+
+func (s *MessageGRPCServer) DeleteMessageForUser(ctx context.Context, req *messagepb.DeleteMessageRequest) (*emptypb.Empty, error) {
+	messageID, err := parseUUID("message_id", req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := parseUUID("user_id", req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.messageService.DeleteMessageForUser(ctx, req.ChatType, messageID, userID); err != nil {
+		return nil, grpcError(err)
+	}
+	return &emptypb.Empty{}, nil
+}
+
+func (s *MessageGRPCServer) AddReaction(ctx context.Context, req *messagepb.ReactionRequest) (*messagepb.ReactionUpdate, error) {
+	return s.updateReaction(ctx, req, true)
+}
+
+func (s *MessageGRPCServer) RemoveReaction(ctx context.Context, req *messagepb.ReactionRequest) (*messagepb.ReactionUpdate, error) {
+	return s.updateReaction(ctx, req, false)
+}
+
+func (s *MessageGRPCServer) updateReaction(ctx context.Context, req *messagepb.ReactionRequest, add bool) (*messagepb.ReactionUpdate, error) {
+	messageID, err := parseUUID("message_id", req.MessageId)
+	if err != nil {
+		return nil, err
+	}
+	userID, err := parseUUID("user_id", req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	reactionReq := &service.ReactionRequest{
+		MessageID: messageID,
+		ChatType:  req.ChatType,
+		UserID:    userID,
+		Emoji:     req.Emoji,
+	}
+	var resp *websocket.ReactionUpdateResponse
+	if add {
+		resp, err = s.messageService.AddReaction(ctx, reactionReq)
+	} else {
+		resp, err = s.messageService.RemoveReaction(ctx, reactionReq)
+	}
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &messagepb.ReactionUpdate{
+		MessageId:  resp.MessageID.String(),
+		ChatType:   resp.ChatType,
+		SenderId:   resp.SenderID.String(),
+		ReceiverId: resp.ReceiverID.String(),
+		GroupId:    resp.GroupID.String(),
+		UserId:     resp.UserID.String(),
+		Emoji:      resp.Emoji,
+		Added:      resp.Added,
+		Reactions:  toPBReactionSummaries(resp.Reactions),
+		Timestamp:  resp.Timestamp,
+	}, nil
+}
+
+func (s *MessageGRPCServer) GetP2PMessages(ctx context.Context, req *messagepb.GetP2PMessagesRequest) (*messagepb.GetMessagesResponse, error) {
+	userID, err := parseUUID("user_id", req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	peerID, err := parseUUID("peer_id", req.PeerId)
+	if err != nil {
+		return nil, err
+	}
+
+	views, err := s.messageService.GetP2PMessages(userID, peerID, int(req.Offset), historyLimit(req.Limit))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &messagepb.GetMessagesResponse{Messages: make([]*messagepb.HistoryMessage, 0, len(views))}
+	for _, view := range views {
+		m := view.P2PMessages
+		resp.Messages = append(resp.Messages, &messagepb.HistoryMessage{
+			Id:          m.ID.String(),
+			SenderId:    m.SenderID.String(),
+			ReceiverId:  m.ReceiverID.String(),
+			Content:     m.Content,
+			ContentType: int32(m.ContentType),
+			ReplyToId:   optionalString(m.ReplyToID),
+			Edited:      m.Edited,
+			Recalled:    m.Recalled,
+			CreatedAt:   timestamppb.New(m.CreatedAt),
+			EditedAt:    optionalTimestamp(m.EditedAt),
+			Reactions:   toPBReactionSummaries(view.Reactions),
+			ReplyTo:     toPBReplyPreview(view.ReplyTo),
+		})
+	}
+	return resp, nil
+}
+
+func (s *MessageGRPCServer) GetGroupMessages(ctx context.Context, req *messagepb.GetGroupMessagesRequest) (*messagepb.GetMessagesResponse, error) {
+	userID, err := parseUUID("user_id", req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	groupID, err := parseUUID("group_id", req.GroupId)
+	if err != nil {
+		return nil, err
+	}
+
+	isMember, err := s.messageService.IsGroupMember(userID, groupID)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	if !isMember {
+		return nil, grpcError(service.ErrNotParticipant)
+	}
+
+	views, err := s.messageService.GetGroupMessages(userID, groupID, int(req.Offset), historyLimit(req.Limit))
+	if err != nil {
+		return nil, grpcError(err)
+	}
+
+	resp := &messagepb.GetMessagesResponse{Messages: make([]*messagepb.HistoryMessage, 0, len(views))}
+	for _, view := range views {
+		m := view.GroupMessages
+		resp.Messages = append(resp.Messages, &messagepb.HistoryMessage{
+			Id:               m.ID.String(),
+			SenderId:         m.SenderID.String(),
+			GroupId:          m.GroupID.String(),
+			Content:          m.Content,
+			ContentType:      int32(m.ContentType),
+			ReplyToId:        optionalString(m.ReplyToID),
+			ThreadRootId:     optionalString(m.ThreadRootID),
+			Mentions:         toPBMentions(m.Mentions),
+			MentionAll:       m.MentionAll,
+			Edited:           m.Edited,
+			Recalled:         m.Recalled,
+			CreatedAt:        timestamppb.New(m.CreatedAt),
+			EditedAt:         optionalTimestamp(m.EditedAt),
+			Reactions:        toPBReactionSummaries(view.Reactions),
+			ReplyTo:          toPBReplyPreview(view.ReplyTo),
+			ThreadReplyCount: view.ThreadReplyCount,
+		})
+	}
+	return resp, nil
+}
+
+func (s *MessageGRPCServer) GetGroupMembers(ctx context.Context, req *messagepb.GetGroupMembersRequest) (*messagepb.GetGroupMembersResponse, error) {
+	groupID, err := parseUUID("group_id", req.GroupId)
+	if err != nil {
+		return nil, err
+	}
+
+	members, err := s.messageService.GetGroupMemberIDs(groupID)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return &messagepb.GetGroupMembersResponse{Members: uuidStrings(members)}, nil
+}
+
+func (s *MessageGRPCServer) UpdateConversationSettings(ctx context.Context, req *messagepb.ConversationSettingsRequest) (*messagepb.ConversationSettings, error) {
+	userID, err := parseUUID("user_id", req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	conversationID, err := parseUUID("conversation_id", req.ConversationId)
+	if err != nil {
+		return nil, err
+	}
+
+	settingsReq := &service.ConversationSettingsRequest{
+		UserID:         userID,
+		ConversationID: conversationID,
+		Muted:          req.Muted,
+		Pinned:         req.Pinned,
+		Archived:       req.Archived,
+		MarkedUnread:   req.MarkedUnread,
+	}
+	if req.MutedUntil != nil {
+		mutedUntil := req.MutedUntil.AsTime()
+		settingsReq.MutedUntil = &mutedUntil
+	}
+
+	settings, err := s.messageService.UpdateConversationSettings(ctx, settingsReq)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	return toPBConversationSettings(*settings), nil
+}
+
+func (s *MessageGRPCServer) ReorderPinnedConversations(ctx context.Context, req *messagepb.ReorderPinnedConversationsRequest) (*messagepb.ReorderPinnedConversationsResponse, error) {
+	userID, err := parseUUID("user_id", req.UserId)
+	if err != nil {
+		return nil, err
+	}
+	conversationIDs := make([]uuid.UUID, 0, len(req.ConversationIds))
+	for _, id := range req.ConversationIds {
+		conversationID, err := parseUUID("conversation_ids", id)
+		if err != nil {
+			return nil, err
+		}
+		conversationIDs = append(conversationIDs, conversationID)
+	}
+
+	pinned, err := s.messageService.ReorderPinnedConversations(ctx, userID, conversationIDs)
+	if err != nil {
+		return nil, grpcError(err)
+	}
+	resp := &messagepb.ReorderPinnedConversationsResponse{Pinned: make([]*messagepb.ConversationSettings, 0, len(pinned))}
+	for _, settings := range pinned {
+		resp.Pinned = append(resp.Pinned, toPBConversationSettings(settings))
+	}
+	return resp, nil
+}
+
+// grpcError 将service层的错误映射为gRPC状态码，与REST API的HTTP状态码保持一致
+func grpcError(err error) error {
+	code := codes.Internal
+	switch messageErrorStatus(err) {
+	case http.StatusBadRequest:
+		code = codes.InvalidArgument
+	case http.StatusForbidden:
+		code = codes.PermissionDenied
+	case http.StatusNotFound:
+		code = codes.NotFound
+	case http.StatusConflict:
+		code = codes.FailedPrecondition
+	}
+	return status.Error(code, err.Error())
+}
+
+func parseUUID(field, value string) (uuid.UUID, error) {
+	id, err := uuid.Parse(value)
+	if err != nil {
+		return uuid.Nil, status.Error(codes.InvalidArgument, fmt.Sprintf("invalid %s: %v", field, err))
+	}
+	return id, nil
+}
+
+func parseOptionalUUID(field string, value *string) (*uuid.UUID, error) {
+	if value == nil {
+		return nil, nil
+	}
+	id, err := parseUUID(field, *value)
+	if err != nil {
+		return nil, err
+	}
+	return &id, nil
+}
+
+func historyLimit(limit int32) int {
+	if limit <= 0 {
+		return defaultHistoryLimit
+	}
+	return int(limit)
+}
+
+func toPBMessageResponse(resp *websocket.MessageResponse) *messagepb.MessageResponse {
+	return &messagepb.MessageResponse{
+		Id:           resp.ID.String(),
+		ThreadRootId: optionalString(resp.ThreadRootID),
+		Mentions:     toPBMentions(resp.Mentions),
+		MentionAll:   resp.MentionAll,
+		MutedUserIds: uuidStrings(resp.MutedUserIDs),
+		Timestamp:    resp.Timestamp,
+	}
+}
+
+func toPBMessageUpdate(resp *websocket.MessageUpdateResponse) *messagepb.MessageUpdate {
+	return &messagepb.MessageUpdate{
+		Id:          resp.ID.String(),
+		ChatType:    resp.ChatType,
+		SenderId:    resp.SenderID.String(),
+		ReceiverId:  resp.ReceiverID.String(),
+		GroupId:     resp.GroupID.String(),
+		Content:     resp.Content,
+		ContentType: int32(resp.ContentType),
+		Edited:      resp.Edited,
+		Recalled:    resp.Recalled,
+		Version:     int32(resp.Version),
+		Timestamp:   resp.Timestamp,
+	}
+}
+
+func toPBMentions(mentions []types.MentionEntity) []*messagepb.Mention {
+	if len(mentions) == 0 {
+		return nil
+	}
+	result := make([]*messagepb.Mention, 0, len(mentions))
+	for _, m := range mentions {
+		result = append(result, &messagepb.Mention{
+			UserId:   m.UserID.String(),
+			Username: m.Username,
+			Offset:   int32(m.Offset),
+			Length:   int32(m.Length),
+		})
+	}
+	return result
+}
+
+func toPBReactionSummaries(reactions []websocket.ReactionSummary) []*messagepb.ReactionSummary {
+	result := make([]*messagepb.ReactionSummary, 0, len(reactions))
+	for _, r := range reactions {
+		result = append(result, &messagepb.ReactionSummary{
+			Emoji:   r.Emoji,
+			Count:   int32(r.Count),
+			UserIds: uuidStrings(r.UserIDs),
+		})
+	}
+	return result
+}
+
+func toPBReplyPreview(preview *service.ReplyPreview) *messagepb.ReplyPreview {
+	if preview == nil {
+		return nil
+	}
+	return &messagepb.ReplyPreview{
+		Id:          preview.ID.String(),
+		SenderId:    preview.SenderID.String(),
+		Content:     preview.Content,
+		ContentType: int32(preview.ContentType),
+		Recalled:    preview.Recalled,
+	}
+}
+
+func toPBConversationSettings(settings websocket.ConversationSettings) *messagepb.ConversationSettings {
+	return &messagepb.ConversationSettings{
+		ConversationId: settings.ConversationID.String(),
+		Muted:          settings.Muted,
+		MutedUntil:     optionalTimestamp(settings.MutedUntil),
+		Pinned:         settings.Pinned,
+		PinOrder:       int32(settings.PinOrder),
+		Archived:       settings.Archived,
+		MarkedUnread:   settings.MarkedUnread,
+		UpdatedAt:      timestamppb.New(settings.UpdatedAt),
+	}
+}
+
+func uuidStrings(ids []uuid.UUID) []string {
+	result := make([]string, 0, len(ids))
+	for _, id := range ids {
+		result = append(result, id.String())
+	}
+	return result
+}
+
+func optionalString(id *uuid.UUID) *string {
+	if id == nil {
+		return nil
+	}
+	s := id.String()
+	return &s
+}
+
+func optionalTimestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
@@ -1,12 +1,17 @@
 package handler
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/middleware"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/gateway/websocket"
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/message/service"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
 )
 
 type MessageHandler struct {
@@ -17,49 +22,60 @@ func NewMessageHandler(messageService *service.MessageService) *MessageHandler {
 	return &MessageHandler{messageService: messageService}
 }
 
+// SendP2PMessage 发送者取自token
 func (h *MessageHandler) SendP2PMessage(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
 	var req service.SendP2PMessageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.SenderID = userID
 
 	resp, err := h.messageService.SendP2PMessage(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusCreated, resp)
 }
 
+// SendGroupMessage 发送者取自token
 func (h *MessageHandler) SendGroupMessage(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
 	var req service.SendGroupMessageRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	req.SenderID = userID
 
 	resp, err := h.messageService.SendGroupMessage(c.Request.Context(), &req)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
 		return
 	}
 
 	c.JSON(http.StatusCreated, resp)
 }
 
+// GetP2PMessages 当前用户与peer_id之间的消息，查看者取自token
 func (h *MessageHandler) GetP2PMessages(c *gin.Context) {
-	senderIDStr := c.Param("sender_id")
-	receiverIDStr := c.Param("receiver_id")
-
-	senderID, err := uuid.Parse(senderIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 		return
 	}
-
-	receiverID, err := uuid.Parse(receiverIDStr)
+	peerID, err := uuid.Parse(c.Param("peer_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -68,7 +84,7 @@ func (h *MessageHandler) GetP2PMessages(c *gin.Context) {
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 
-	p2pMessages, err := h.messageService.GetP2PMessages(senderID, receiverID, offset, limit)
+	p2pMessages, err := h.messageService.GetP2PMessages(userID, peerID, offset, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -77,18 +93,33 @@ func (h *MessageHandler) GetP2PMessages(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"p2p_messages": p2pMessages})
 }
 
+// GetGroupMessages 只有群成员可以查看，查看者取自token
 func (h *MessageHandler) GetGroupMessages(c *gin.Context) {
-	groupIDStr := c.Param("group_id")
-	groupID, err := uuid.Parse(groupIDStr)
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	groupID, err := uuid.Parse(c.Param("group_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
+	isMember, err := h.messageService.IsGroupMember(userID, groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !isMember {
+		c.JSON(http.StatusForbidden, gin.H{"error": service.ErrNotParticipant.Error()})
+		return
+	}
+
 	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "20"))
 
-	groupMessages, err := h.messageService.GetGroupMessages(groupID, offset, limit)
+	groupMessages, err := h.messageService.GetGroupMessages(userID, groupID, offset, limit)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -97,15 +128,17 @@ func (h *MessageHandler) GetGroupMessages(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"group_messages": groupMessages})
 }
 
+// GetUserConversations 当前用户的会话列表，用户取自token
 func (h *MessageHandler) GetUserConversations(c *gin.Context) {
-	userIDStr := c.Param("user_id")
-	userID, err := uuid.Parse(userIDStr)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
 		return
 	}
 
-	conversations, err := h.messageService.GetUserConversations(userID)
+	// archived=true时返回已归档的会话
+	archived := c.Query("archived") == "true"
+	conversations, err := h.messageService.GetUserConversations(userID, archived)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
@@ -114,17 +147,67 @@ func (h *MessageHandler) GetUserConversations(c *gin.Context) {
 	c.JSON(http.StatusOK, gin.H{"conversations": conversations})
 }
 
-func (h *MessageHandler) MarkAsRead(c *gin.Context) {
-	userIDStr := c.Param("user_id")
-	conversationIDStr := c.Param("conversation_id")
+// UpdateConversationSettings 修改当前用户在会话中的个人设置，用户取自token
+func (h *MessageHandler) UpdateConversationSettings(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	conversationID, err := uuid.Parse(c.Param("conversation_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req service.ConversationSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.UserID = userID
+	req.ConversationID = conversationID
 
-	userID, err := uuid.Parse(userIDStr)
+	settings, err := h.messageService.UpdateConversationSettings(c.Request.Context(), &req)
 	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, settings)
+}
+
+// ReorderPinnedConversations 调整当前用户置顶会话的顺序，用户取自token
+func (h *MessageHandler) ReorderPinnedConversations(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	var req service.ReorderPinsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
 
-	conversationID, err := uuid.Parse(conversationIDStr)
+	settings, err := h.messageService.ReorderPinnedConversations(c.Request.Context(), userID, req.ConversationIDs)
+	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"pinned": settings})
+}
+
+// MarkAsRead 当前用户把会话标记为已读，用户取自token
+func (h *MessageHandler) MarkAsRead(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	conversationID, err := uuid.Parse(c.Param("conversation_id"))
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
@@ -137,3 +220,368 @@ func (h *MessageHandler) MarkAsRead(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "Messages marked as read"})
 }
+
+// GetGroupMembers 只有群成员可以查看成员列表
+func (h *MessageHandler) GetGroupMembers(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	isMember, err := h.messageService.IsGroupMember(userID, groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !isMember {
+		c.JSON(http.StatusForbidden, gin.H{"error": service.ErrNotParticipant.Error()})
+		return
+	}
+
+	members, err := h.messageService.GetGroupMemberIDs(groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"members": members})
+}
+
+// EditMessage 编辑者取自token
+func (h *MessageHandler) EditMessage(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	var req service.EditMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.MessageID = messageID
+	req.ChatType = c.Param("chat_type")
+	req.EditorID = userID
+
+	resp, err := h.messageService.EditMessage(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// RecallMessage 操作者取自token
+func (h *MessageHandler) RecallMessage(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req := service.RecallMessageRequest{
+		MessageID:  messageID,
+		ChatType:   c.Param("chat_type"),
+		OperatorID: userID,
+	}
+
+	resp, err := h.messageService.RecallMessage(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// DeleteMessage 仅对自己删除，操作者取自token
+func (h *MessageHandler) DeleteMessage(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.messageService.DeleteMessageForUser(c.Request.Context(), c.Param("chat_type"), messageID, userID); err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Message deleted"})
+}
+
+// GetMessageHistory 只有会话参与者可以查看，用户取自token
+func (h *MessageHandler) GetMessageHistory(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	history, err := h.messageService.GetMessageHistory(c.Request.Context(), c.Param("chat_type"), messageID, userID)
+	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// UpdateReaction 添加或取消表情回应，POST为添加，DELETE为取消，用户取自token
+func (h *MessageHandler) UpdateReaction(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	var req service.ReactionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.MessageID = messageID
+	req.ChatType = c.Param("chat_type")
+	req.UserID = userID
+
+	var resp *websocket.ReactionUpdateResponse
+	if c.Request.Method == http.MethodDelete {
+		resp, err = h.messageService.RemoveReaction(c.Request.Context(), &req)
+	} else {
+		resp, err = h.messageService.AddReaction(c.Request.Context(), &req)
+	}
+	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// GetGroupThread 只有群成员可以查看话题，查看者取自token
+func (h *MessageHandler) GetGroupThread(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	groupID, err := uuid.Parse(c.Param("group_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rootID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	isMember, err := h.messageService.IsGroupMember(userID, groupID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if !isMember {
+		c.JSON(http.StatusForbidden, gin.H{"error": service.ErrNotParticipant.Error()})
+		return
+	}
+
+	offset, _ := strconv.Atoi(c.DefaultQuery("offset", "0"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+
+	thread, err := h.messageService.GetGroupThread(userID, groupID, rootID, offset, limit)
+	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, thread)
+}
+
+// SearchMessages 搜索当前用户参与的会话中的消息，用户取自token
+// query: q, chat_type, peer_id, group_id, sender_id, from, to(RFC3339), offset, limit
+func (h *MessageHandler) SearchMessages(c *gin.Context) {
+	userID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+
+	req := service.SearchMessagesRequest{
+		UserID:   userID,
+		Query:    c.Query("q"),
+		ChatType: c.Query("chat_type"),
+	}
+	if req.ChatType != "" && req.ChatType != types.ChatTypeP2P && req.ChatType != types.ChatTypeGroup {
+		c.JSON(http.StatusBadRequest, gin.H{"error": service.ErrInvalidChatType.Error()})
+		return
+	}
+	for key, dst := range map[string]**uuid.UUID{"peer_id": &req.PeerID, "group_id": &req.GroupID, "sender_id": &req.SenderID} {
+		if v := c.Query(key); v != "" {
+			id, err := uuid.Parse(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": key + ": " + err.Error()})
+				return
+			}
+			*dst = &id
+		}
+	}
+	for key, dst := range map[string]**time.Time{"from": &req.From, "to": &req.To} {
+		if v := c.Query(key); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": key + ": " + err.Error()})
+				return
+			}
+			*dst = &t
+		}
+	}
+	req.Offset, _ = strconv.Atoi(c.DefaultQuery("offset", "0"))
+	req.Limit, _ = strconv.Atoi(c.DefaultQuery("limit", "20"))
+
+	results, err := h.messageService.SearchMessages(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"results": results})
+}
+
+// messageErrorStatus 将service层的错误映射为HTTP状态码
+// ReportUser 举报用户，举报者取自token
+func (h *MessageHandler) ReportUser(c *gin.Context) {
+	reporterID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	userID, err := uuid.Parse(c.Param("user_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req service.ReportUserRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ReporterID = reporterID
+	req.UserID = userID
+
+	report, err := h.messageService.ReportUser(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+// ReportMessage 举报消息，举报者取自token
+func (h *MessageHandler) ReportMessage(c *gin.Context) {
+	reporterID, ok := middleware.CurrentUserID(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid token"})
+		return
+	}
+	messageID, err := uuid.Parse(c.Param("message_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var req service.ReportMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.ReporterID = reporterID
+	req.ChatType = c.Param("chat_type")
+	req.MessageID = messageID
+
+	report, err := h.messageService.ReportMessage(c.Request.Context(), &req)
+	if err != nil {
+		c.JSON(messageErrorStatus(err), gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, report)
+}
+
+func messageErrorStatus(err error) int {
+	switch {
+	case errors.Is(err, service.ErrInvalidChatType),
+		errors.Is(err, service.ErrEmptyMessageContent),
+		errors.Is(err, service.ErrMessageUnchanged),
+		errors.Is(err, service.ErrInvalidEmoji),
+		errors.Is(err, service.ErrInvalidReplyTarget),
+		errors.Is(err, service.ErrInvalidSearchQuery),
+		errors.Is(err, service.ErrInvalidMuteExpiry),
+		errors.Is(err, service.ErrConversationNotPinned),
+		errors.Is(err, service.ErrInvalidModerationDecision),
+		errors.Is(err, service.ErrInvalidModerationFilter),
+		errors.Is(err, service.ErrInvalidReportReason),
+		errors.Is(err, service.ErrCannotReportSelf),
+		errors.Is(err, service.ErrInvalidReportResolution),
+		errors.Is(err, service.ErrInvalidReportFilter),
+		errors.Is(err, service.ErrInvalidSuspension):
+		return http.StatusBadRequest
+	case errors.Is(err, service.ErrNotMessageSender),
+		errors.Is(err, service.ErrNotParticipant),
+		errors.Is(err, service.ErrMessageBlocked),
+		errors.Is(err, service.ErrUserRestricted),
+		errors.Is(err, service.ErrCannotModerateAdmin):
+		return http.StatusForbidden
+	case errors.Is(err, service.ErrMessageNotFound),
+		errors.Is(err, service.ErrConversationNotFound),
+		errors.Is(err, service.ErrModerationRecordNotFound),
+		errors.Is(err, service.ErrUserNotFound),
+		errors.Is(err, service.ErrReportNotFound):
+		return http.StatusNotFound
+	case errors.Is(err, service.ErrMessageRecalled),
+		errors.Is(err, service.ErrEditWindowExpired),
+		errors.Is(err, service.ErrRecallWindowExpired),
+		errors.Is(err, service.ErrModerationAlreadyReviewed),
+		errors.Is(err, service.ErrDuplicateReport),
+		errors.Is(err, service.ErrReportAlreadyResolved),
+		errors.Is(err, service.ErrUserNotRestricted):
+		return http.StatusConflict
+	default:
+		return http.StatusInternalServerError
+	}
+}
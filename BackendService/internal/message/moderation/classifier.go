@@ -0,0 +1,86 @@
+package moderation
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+const defaultClassifierTimeout = 2 * time.Second
+
+// HTTPClassifier 调用外部分类服务，请求和响应都是JSON：
+// POST {"content", "content_type", "sender_id", "chat_type"} -> {"action": "allow|flag|block", "reason"}
+type HTTPClassifier struct {
+	url        string
+	httpClient *http.Client
+}
+
+type classifierRequest struct {
+	Content     string `json:"content"`
+	ContentType int    `json:"content_type"`
+	SenderID    string `json:"sender_id"`
+	ChatType    string `json:"chat_type"`
+}
+
+type classifierResponse struct {
+	Action string `json:"action"`
+	Reason string `json:"reason"`
+}
+
+// NewHTTPClassifier timeout<=0时使用默认值，消息发送会等待分类结果，超时不宜过长
+func NewHTTPClassifier(url string, timeout time.Duration) *HTTPClassifier {
+	if timeout <= 0 {
+		timeout = defaultClassifierTimeout
+	}
+	return &HTTPClassifier{
+		url:        url,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+func (c *HTTPClassifier) Name() string {
+	return "classifier"
+}
+
+func (c *HTTPClassifier) Check(ctx context.Context, in *Input) (Verdict, error) {
+	body, err := json.Marshal(classifierRequest{
+		Content:     in.Content,
+		ContentType: in.ContentType,
+		SenderID:    in.SenderID.String(),
+		ChatType:    in.ChatType,
+	})
+	if err != nil {
+		return Verdict{}, err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	if err != nil {
+		return Verdict{}, err
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.httpClient.Do(httpReq)
+	if err != nil {
+		return Verdict{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Verdict{}, fmt.Errorf("classifier returned status %d", resp.StatusCode)
+	}
+	var result classifierResponse
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return Verdict{}, fmt.Errorf("failed to decode classifier response: %w", err)
+	}
+	action := ParseAction(result.Action, "")
+	if action == "" {
+		return Verdict{}, fmt.Errorf("classifier returned unknown action %q", result.Action)
+	}
+	if result.Reason == "" {
+		result.Reason = "classified as " + action
+	}
+	return Verdict{Action: action, Reason: result.Reason}, nil
+}
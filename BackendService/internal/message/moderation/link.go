@@ -0,0 +1,88 @@
+package moderation
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var linkPattern = regexp.MustCompile(`(?i)\b(?:https?://|www\.)[^\s<>"']+`)
+
+// LinkChecker 消息中的链接：BlockedDomains总是拦截，AllowedDomains总是放行，其它链接按policy处理
+type LinkChecker struct {
+	policy         string
+	allowedDomains []string
+	blockedDomains []string
+}
+
+// NewLinkChecker policy为allow且没有配置BlockedDomains时不需要检查，返回nil
+func NewLinkChecker(policy string, allowedDomains, blockedDomains []string) *LinkChecker {
+	l := &LinkChecker{
+		policy:         ParseAction(policy, ActionAllow),
+		allowedDomains: normalizeDomains(allowedDomains),
+		blockedDomains: normalizeDomains(blockedDomains),
+	}
+	if l.policy == ActionAllow && len(l.blockedDomains) == 0 {
+		return nil
+	}
+	return l
+}
+
+func normalizeDomains(domains []string) []string {
+	var normalized []string
+	for _, domain := range domains {
+		domain = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(domain)), ".")
+		if domain != "" {
+			normalized = append(normalized, domain)
+		}
+	}
+	return normalized
+}
+
+func (l *LinkChecker) Name() string {
+	return "link"
+}
+
+func (l *LinkChecker) Check(ctx context.Context, in *Input) (Verdict, error) {
+	verdict := Verdict{Action: ActionAllow}
+	for _, link := range linkPattern.FindAllString(in.Content, -1) {
+		host := linkHost(link)
+		if host == "" {
+			continue
+		}
+		if matchDomain(host, l.blockedDomains) {
+			return Verdict{Action: ActionBlock, Reason: fmt.Sprintf("links to blocked domain %s", host)}, nil
+		}
+		if matchDomain(host, l.allowedDomains) {
+			continue
+		}
+		if severity(l.policy) > severity(verdict.Action) {
+			verdict = Verdict{Action: l.policy, Reason: fmt.Sprintf("contains a link to %s", host)}
+		}
+	}
+	return verdict, nil
+}
+
+// linkHost 返回链接的主机名，www.开头的链接没有协议，补上后再解析
+func linkHost(link string) string {
+	if !strings.Contains(link, "://") {
+		link = "http://" + link
+	}
+	u, err := url.Parse(strings.TrimRight(link, ".,;:!?)]}"))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSuffix(strings.ToLower(u.Hostname()), ".")
+}
+
+// matchDomain 主机名等于某个域名或是它的子域名
+func matchDomain(host string, domains []string) bool {
+	for _, domain := range domains {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
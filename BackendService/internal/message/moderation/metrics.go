@@ -0,0 +1,23 @@
+package moderation
+
+import (
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/metrics"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+var (
+	decisions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "moderation",
+		Name:      "decisions_total",
+		Help:      "Messages moderated before persistence, by final action.",
+	}, []string{"action"})
+
+	checkErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metrics.Namespace,
+		Subsystem: "moderation",
+		Name:      "check_errors_total",
+		Help:      "Moderation checks that failed, by checker.",
+	}, []string{"checker"})
+)
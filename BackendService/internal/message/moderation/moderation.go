@@ -0,0 +1,159 @@
+package moderation
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// 审核结果，按严重程度递增
+const (
+	ActionAllow = "allow"
+	ActionFlag  = "flag"
+	ActionBlock = "block"
+)
+
+// Input 待审核的消息
+type Input struct {
+	SenderID    uuid.UUID
+	ChatType    string
+	ReceiverID  uuid.UUID
+	GroupID     uuid.UUID
+	Content     string
+	ContentType int
+	// 编辑消息时为true，编辑不计入发送频率
+	Edit bool
+}
+
+// Verdict 单个规则的审核结果
+type Verdict struct {
+	Checker string `json:"checker"`
+	Action  string `json:"action"`
+	Reason  string `json:"reason"`
+}
+
+// Decision 所有规则审核后的最终结果，Action取所有Verdict中最严重的一个
+type Decision struct {
+	Action   string
+	Verdicts []Verdict
+}
+
+// Rules 命中的规则名，用逗号分隔
+func (d *Decision) Rules() string {
+	names := make([]string, 0, len(d.Verdicts))
+	for _, v := range d.Verdicts {
+		names = append(names, v.Checker)
+	}
+	return strings.Join(names, ",")
+}
+
+// Reason 命中规则的原因，用分号分隔
+func (d *Decision) Reason() string {
+	reasons := make([]string, 0, len(d.Verdicts))
+	for _, v := range d.Verdicts {
+		reasons = append(reasons, v.Reason)
+	}
+	return strings.Join(reasons, "; ")
+}
+
+// Checker 一条审核规则。没有命中时返回ActionAllow，出错时由Pipeline决定是否拦截
+type Checker interface {
+	Name() string
+	Check(ctx context.Context, in *Input) (Verdict, error)
+}
+
+// Pipeline 按顺序执行审核规则，本地规则在前，需要访问Redis或外部服务的规则在后。
+// 任一规则拦截时不再执行后面的规则
+type Pipeline struct {
+	checkers   []Checker
+	failClosed bool
+}
+
+// NewPipeline 按配置创建审核规则，关闭审核或没有任何规则时返回nil，nil的Pipeline放行所有消息。
+// redisClient为nil时发送频率只在本实例内统计
+func NewPipeline(cfg config.ModerationConfig, redisClient redis.Cmdable) *Pipeline {
+	if cfg.Disabled {
+		return nil
+	}
+	var checkers []Checker
+	if wordList := NewWordList(cfg.BlockedWords, cfg.FlaggedWords); wordList != nil {
+		checkers = append(checkers, wordList)
+	}
+	if link := NewLinkChecker(cfg.LinkPolicy, cfg.AllowedDomains, cfg.BlockedDomains); link != nil {
+		checkers = append(checkers, link)
+	}
+	checkers = append(checkers, NewSpamChecker(cfg, redisClient))
+	if cfg.ClassifierURL != "" {
+		checkers = append(checkers, NewHTTPClassifier(cfg.ClassifierURL, cfg.ClassifierTimeout))
+	}
+	return NewPipelineWithCheckers(cfg.FailClosed, checkers...)
+}
+
+// NewPipelineWithCheckers 使用自定义的审核规则
+func NewPipelineWithCheckers(failClosed bool, checkers ...Checker) *Pipeline {
+	if len(checkers) == 0 {
+		return nil
+	}
+	return &Pipeline{checkers: checkers, failClosed: failClosed}
+}
+
+// Moderate 执行所有审核规则。规则出错时默认跳过，failClosed时拦截消息
+func (p *Pipeline) Moderate(ctx context.Context, in *Input) *Decision {
+	decision := &Decision{Action: ActionAllow}
+	if p == nil {
+		return decision
+	}
+	for _, checker := range p.checkers {
+		verdict, err := checker.Check(ctx, in)
+		if err != nil {
+			slog.ErrorContext(ctx, "Moderation check failed", "checker", checker.Name(), "error", err)
+			checkErrors.WithLabelValues(checker.Name()).Inc()
+			if !p.failClosed {
+				continue
+			}
+			verdict = Verdict{Action: ActionBlock, Reason: "moderation unavailable"}
+		}
+		if verdict.Action == "" || verdict.Action == ActionAllow {
+			continue
+		}
+		verdict.Checker = checker.Name()
+		decision.Verdicts = append(decision.Verdicts, verdict)
+		if severity(verdict.Action) > severity(decision.Action) {
+			decision.Action = verdict.Action
+		}
+		if decision.Action == ActionBlock {
+			break
+		}
+	}
+	decisions.WithLabelValues(decision.Action).Inc()
+	return decision
+}
+
+func severity(action string) int {
+	switch action {
+	case ActionBlock:
+		return 2
+	case ActionFlag:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// ParseAction 解析配置中的处理方式，无法识别时使用defaultAction
+func ParseAction(action, defaultAction string) string {
+	switch strings.ToLower(strings.TrimSpace(action)) {
+	case ActionAllow:
+		return ActionAllow
+	case ActionFlag:
+		return ActionFlag
+	case ActionBlock:
+		return ActionBlock
+	default:
+		return defaultAction
+	}
+}
@@ -0,0 +1,154 @@
+package moderation
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/huangrao121/CommunicationApp/BackendService/config"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRepeatLimit  = 5
+	defaultRepeatWindow = time.Minute
+	defaultBurstLimit   = 20
+	defaultBurstWindow  = 10 * time.Second
+	// 内存计数器超过这个数量时清理过期的窗口
+	memoryCounterSweepSize = 10000
+)
+
+// SpamChecker 垃圾消息检测：同一用户短时间内重复发送相同内容，或发送频率过高
+type SpamChecker struct {
+	counter      counter
+	action       string
+	repeatLimit  int
+	repeatWindow time.Duration
+	burstLimit   int
+	burstWindow  time.Duration
+}
+
+// NewSpamChecker 有Redis时计数在所有实例之间共享，否则只在本实例内统计
+func NewSpamChecker(cfg config.ModerationConfig, redisClient redis.Cmdable) *SpamChecker {
+	s := &SpamChecker{
+		action:       ParseAction(cfg.SpamAction, ActionFlag),
+		repeatLimit:  cfg.RepeatLimit,
+		repeatWindow: cfg.RepeatWindow,
+		burstLimit:   cfg.BurstLimit,
+		burstWindow:  cfg.BurstWindow,
+	}
+	if s.repeatLimit <= 0 {
+		s.repeatLimit = defaultRepeatLimit
+	}
+	if s.repeatWindow <= 0 {
+		s.repeatWindow = defaultRepeatWindow
+	}
+	if s.burstLimit <= 0 {
+		s.burstLimit = defaultBurstLimit
+	}
+	if s.burstWindow <= 0 {
+		s.burstWindow = defaultBurstWindow
+	}
+	if redisClient != nil {
+		s.counter = &redisCounter{client: redisClient}
+	} else {
+		s.counter = newMemoryCounter()
+	}
+	return s
+}
+
+func (s *SpamChecker) Name() string {
+	return "spam"
+}
+
+func (s *SpamChecker) Check(ctx context.Context, in *Input) (Verdict, error) {
+	if in.Edit {
+		return Verdict{Action: ActionAllow}, nil
+	}
+
+	// 1. 发送频率，key带上hash tag，同一用户的计数落在同一个slot
+	sent, err := s.counter.incr(ctx, fmt.Sprintf("moderation:{%s}:burst", in.SenderID), s.burstWindow)
+	if err != nil {
+		return Verdict{}, err
+	}
+	if sent > int64(s.burstLimit) {
+		return Verdict{Action: s.action, Reason: fmt.Sprintf("sent more than %d messages in %s", s.burstLimit, s.burstWindow)}, nil
+	}
+
+	// 2. 重复内容，忽略大小写和首尾空白
+	content := strings.ToLower(strings.TrimSpace(in.Content))
+	if content == "" {
+		return Verdict{Action: ActionAllow}, nil
+	}
+	sum := sha1.Sum([]byte(content))
+	repeated, err := s.counter.incr(ctx, fmt.Sprintf("moderation:{%s}:repeat:%s", in.SenderID, hex.EncodeToString(sum[:])), s.repeatWindow)
+	if err != nil {
+		return Verdict{}, err
+	}
+	if repeated > int64(s.repeatLimit) {
+		return Verdict{Action: s.action, Reason: fmt.Sprintf("sent the same content %d times in %s", repeated, s.repeatWindow)}, nil
+	}
+	return Verdict{Action: ActionAllow}, nil
+}
+
+// counter 固定窗口计数，返回窗口内的计数
+type counter interface {
+	incr(ctx context.Context, key string, window time.Duration) (int64, error)
+}
+
+type redisCounter struct {
+	client redis.Cmdable
+}
+
+// incrScript 计数，key没有过期时间时设置为窗口长度。两步在一个脚本里原子执行，
+// 不会因为设置过期失败或进程退出留下永不过期的计数
+var incrScript = redis.NewScript(`
+local count = redis.call("INCR", KEYS[1])
+if redis.call("PTTL", KEYS[1]) < 0 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[1])
+end
+return count
+`)
+
+func (c *redisCounter) incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	return incrScript.Run(ctx, c.client, []string{key}, window.Milliseconds()).Int64()
+}
+
+type memoryWindow struct {
+	count     int64
+	expiresAt time.Time
+}
+
+type memoryCounter struct {
+	mu      sync.Mutex
+	windows map[string]*memoryWindow
+}
+
+func newMemoryCounter() *memoryCounter {
+	return &memoryCounter{windows: make(map[string]*memoryWindow)}
+}
+
+func (c *memoryCounter) incr(ctx context.Context, key string, window time.Duration) (int64, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if len(c.windows) >= memoryCounterSweepSize {
+		for k, w := range c.windows {
+			if now.After(w.expiresAt) {
+				delete(c.windows, k)
+			}
+		}
+	}
+	w, ok := c.windows[key]
+	if !ok || now.After(w.expiresAt) {
+		w = &memoryWindow{expiresAt: now.Add(window)}
+		c.windows[key] = w
+	}
+	w.count++
+	return w.count, nil
+}
@@ -0,0 +1,52 @@
+package moderation
+
+import (
+	"context"
+	"strings"
+)
+
+// WordList 敏感词过滤，不区分大小写，按子串匹配
+type WordList struct {
+	blocked []string
+	flagged []string
+}
+
+// NewWordList 两个列表都为空时返回nil
+func NewWordList(blocked, flagged []string) *WordList {
+	w := &WordList{blocked: normalizeWords(blocked), flagged: normalizeWords(flagged)}
+	if len(w.blocked) == 0 && len(w.flagged) == 0 {
+		return nil
+	}
+	return w
+}
+
+func normalizeWords(words []string) []string {
+	var normalized []string
+	for _, word := range words {
+		word = strings.ToLower(strings.TrimSpace(word))
+		if word != "" {
+			normalized = append(normalized, word)
+		}
+	}
+	return normalized
+}
+
+func (w *WordList) Name() string {
+	return "word_list"
+}
+
+// Check 原因中不包含命中的词，审核记录会保存原文
+func (w *WordList) Check(ctx context.Context, in *Input) (Verdict, error) {
+	content := strings.ToLower(in.Content)
+	for _, word := range w.blocked {
+		if strings.Contains(content, word) {
+			return Verdict{Action: ActionBlock, Reason: "contains a blocked word"}, nil
+		}
+	}
+	for _, word := range w.flagged {
+		if strings.Contains(content, word) {
+			return Verdict{Action: ActionFlag, Reason: "contains a flagged word"}, nil
+		}
+	}
+	return Verdict{Action: ActionAllow}, nil
+}
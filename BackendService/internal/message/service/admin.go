@@ -0,0 +1,314 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/config/pkg"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/revocation"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/gateway/websocket"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"github.com/redis/go-redis/v9"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 举报的处理方式
+const (
+	ReportResolutionResolve = "resolve"
+	ReportResolutionDismiss = "dismiss"
+)
+
+var (
+	ErrReportNotFound          = errors.New("report not found")
+	ErrReportAlreadyResolved   = errors.New("report has already been resolved")
+	ErrInvalidReportResolution = errors.New("resolution must be resolve or dismiss")
+	ErrInvalidReportFilter     = errors.New("invalid report status or target type")
+	ErrCannotModerateAdmin     = errors.New("cannot suspend or ban an admin")
+	ErrInvalidSuspension       = errors.New("suspension duration must be positive")
+	ErrUserNotRestricted       = errors.New("user is not suspended or banned")
+)
+
+// AdminService 管理员操作：处理举报、封禁用户、删除消息和复核审核记录，每个操作都写入审计日志
+type AdminService struct {
+	DB       *gorm.DB
+	messages *MessageService
+	// redis 发布令牌撤销事件，为nil时封禁后已经建立的连接不会被断开
+	redis redis.Cmdable
+}
+
+func NewAdminService(messageService *MessageService, redisClient redis.Cmdable) *AdminService {
+	return &AdminService{
+		DB:       messageService.DB,
+		messages: messageService,
+		redis:    redisClient,
+	}
+}
+
+// VerifyAdmin 用于middleware.RequireAdmin，按数据库中当前的角色和状态判断，
+// 被取消管理员角色或被封禁的用户在token过期前也不能继续访问管理员接口
+func (s *AdminService) VerifyAdmin(ctx context.Context, claims *pkg.AppClaims) (bool, error) {
+	var user types.Users
+	err := s.DB.WithContext(ctx).Select("id", "role", "status", "suspended_until").Where("id = ?", claims.ID).Take(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return user.IsAdmin() && !user.Restricted(time.Now()), nil
+}
+
+// UserActionRequest 禁言、封禁和解封用户，DurationSeconds只用于禁言
+type UserActionRequest struct {
+	UserID          uuid.UUID `json:"-"`
+	AdminID         uuid.UUID `json:"-"`
+	Reason          string    `json:"reason" binding:"required"`
+	DurationSeconds int64     `json:"duration_seconds"`
+}
+
+// UserModerationStatus 用户当前的状态，SessionsRevoked表示是否已经通知所有网关断开该用户的连接
+type UserModerationStatus struct {
+	UserID          uuid.UUID  `json:"user_id"`
+	Status          string     `json:"status"`
+	SuspendedUntil  *time.Time `json:"suspended_until,omitempty"`
+	SessionsRevoked bool       `json:"sessions_revoked"`
+}
+
+type AdminDeleteMessageRequest struct {
+	ChatType  string    `json:"-"`
+	MessageID uuid.UUID `json:"-"`
+	AdminID   uuid.UUID `json:"-"`
+	Reason    string    `json:"reason" binding:"required"`
+}
+
+type ResolveReportRequest struct {
+	ReportID   uuid.UUID `json:"-"`
+	AdminID    uuid.UUID `json:"-"`
+	Resolution string    `json:"resolution" binding:"required"`
+	Note       string    `json:"note"`
+}
+
+// writeAuditLog 在管理员操作的事务里写入审计日志，操作失败时日志一起回滚
+func writeAuditLog(tx *gorm.DB, adminID uuid.UUID, action, targetType string, targetID uuid.UUID, reason, details string) error {
+	return tx.Create(&types.AdminAuditLogs{
+		AdminID:    adminID,
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		Reason:     reason,
+		Details:    details,
+		CreatedAt:  time.Now(),
+	}).Error
+}
+
+// SuspendUser 禁言用户DurationSeconds秒，到期后自动恢复。已签发的token全部撤销
+func (s *AdminService) SuspendUser(ctx context.Context, req *UserActionRequest) (*UserModerationStatus, error) {
+	if req.DurationSeconds <= 0 {
+		return nil, ErrInvalidSuspension
+	}
+	until := time.Now().Add(time.Duration(req.DurationSeconds) * time.Second)
+	details := fmt.Sprintf("suspended until %s", until.UTC().Format(time.RFC3339))
+	return s.restrictUser(ctx, req, types.UserStatusSuspended, &until, types.AuditActionSuspendUser, details, revocation.ReasonSuspend)
+}
+
+// BanUser 永久封禁用户，需要管理员解封。已签发的token全部撤销
+func (s *AdminService) BanUser(ctx context.Context, req *UserActionRequest) (*UserModerationStatus, error) {
+	return s.restrictUser(ctx, req, types.UserStatusBanned, nil, types.AuditActionBanUser, "", revocation.ReasonBan)
+}
+
+func (s *AdminService) restrictUser(ctx context.Context, req *UserActionRequest, status string, until *time.Time, action, details, revokeReason string) (*UserModerationStatus, error) {
+	// 1. 更新用户状态并写入审计日志
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, req.UserID)
+		if err != nil {
+			return err
+		}
+		// 管理员之间不能互相封禁，也不能封禁自己
+		if user.IsAdmin() {
+			return ErrCannotModerateAdmin
+		}
+		if err := tx.Model(&types.Users{}).Where("id = ?", req.UserID).Updates(map[string]interface{}{
+			"status":          status,
+			"suspended_until": until,
+			"updated_at":      time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return writeAuditLog(tx, req.AdminID, action, types.AuditTargetUser, req.UserID, req.Reason, details)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 撤销token，所有网关节点断开该用户的连接并拒绝旧token重连
+	result := &UserModerationStatus{UserID: req.UserID, Status: status, SuspendedUntil: until}
+	if s.redis == nil {
+		slog.WarnContext(ctx, "Redis is not configured, existing sessions are not revoked", "user_id", req.UserID)
+	} else if err := revocation.Publish(ctx, s.redis, revocation.Event{UserID: req.UserID, Reason: revokeReason}); err != nil {
+		slog.ErrorContext(ctx, "Failed to revoke user sessions", "user_id", req.UserID, "error", err)
+	} else {
+		result.SessionsRevoked = true
+	}
+	slog.InfoContext(ctx, "User restricted by admin", "user_id", req.UserID, "admin_id", req.AdminID, "status", status)
+	return result, nil
+}
+
+// ReinstateUser 解除禁言或封禁。被撤销的token不会恢复，用户需要重新登录
+func (s *AdminService) ReinstateUser(ctx context.Context, req *UserActionRequest) (*UserModerationStatus, error) {
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		user, err := lockUser(tx, req.UserID)
+		if err != nil {
+			return err
+		}
+		if user.Status == types.UserStatusActive {
+			return ErrUserNotRestricted
+		}
+		if err := tx.Model(&types.Users{}).Where("id = ?", req.UserID).Updates(map[string]interface{}{
+			"status":          types.UserStatusActive,
+			"suspended_until": nil,
+			"updated_at":      time.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		return writeAuditLog(tx, req.AdminID, types.AuditActionReinstateUser, types.AuditTargetUser, req.UserID, req.Reason, "previous status "+user.Status)
+	})
+	if err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "User reinstated by admin", "user_id", req.UserID, "admin_id", req.AdminID)
+	return &UserModerationStatus{UserID: req.UserID, Status: types.UserStatusActive}, nil
+}
+
+func lockUser(tx *gorm.DB, userID uuid.UUID) (*types.Users, error) {
+	var user types.Users
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+		Select("id", "role", "status", "suspended_until").
+		Where("id = ?", userID).
+		Take(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// DeleteMessage 管理员删除消息，与撤回相同只保留墓碑，不受撤回时间窗口限制
+func (s *AdminService) DeleteMessage(ctx context.Context, req *AdminDeleteMessageRequest) (*websocket.MessageUpdateResponse, error) {
+	var resp *websocket.MessageUpdateResponse
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		record, err := loadMessage(tx, req.ChatType, req.MessageID, true)
+		if err != nil {
+			return err
+		}
+		if record.Recalled {
+			return ErrMessageRecalled
+		}
+		// 审计日志里记下发送者和原文，消息内容清空后仍然可以追溯
+		details := fmt.Sprintf("chat_type=%s sender_id=%s content=%q", req.ChatType, record.SenderID, record.Content)
+		if err := recallRecord(tx, req.ChatType, record); err != nil {
+			return err
+		}
+		if err := writeAuditLog(tx, req.AdminID, types.AuditActionDeleteMessage, types.AuditTargetMessage, record.ID, req.Reason, details); err != nil {
+			return err
+		}
+		resp = toUpdateResponse(req.ChatType, record, 0)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	s.messages.publishMessageUpdate(ctx, "message_recalled", resp)
+	slog.InfoContext(ctx, "Message deleted by admin", "message_id", req.MessageID, "admin_id", req.AdminID)
+	return resp, nil
+}
+
+// ListReports 举报列表，按时间倒序。status和targetType为空时不过滤
+func (s *AdminService) ListReports(ctx context.Context, status, targetType string, offset, limit int) ([]types.Reports, error) {
+	query := s.DB.WithContext(ctx).Model(&types.Reports{})
+	if status != "" {
+		switch status {
+		case types.ReportStatusOpen, types.ReportStatusResolved, types.ReportStatusDismissed:
+		default:
+			return nil, ErrInvalidReportFilter
+		}
+		query = query.Where("status = ?", status)
+	}
+	if targetType != "" {
+		if targetType != types.ReportTargetUser && targetType != types.ReportTargetMessage {
+			return nil, ErrInvalidReportFilter
+		}
+		query = query.Where("target_type = ?", targetType)
+	}
+
+	var reports []types.Reports
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&reports).Error
+	return reports, err
+}
+
+// ResolveReport 结案或驳回举报。对用户和消息的处理通过单独的接口完成，这里只记录结论
+func (s *AdminService) ResolveReport(ctx context.Context, req *ResolveReportRequest) (*types.Reports, error) {
+	var status, action string
+	switch req.Resolution {
+	case ReportResolutionResolve:
+		status, action = types.ReportStatusResolved, types.AuditActionResolveReport
+	case ReportResolutionDismiss:
+		status, action = types.ReportStatusDismissed, types.AuditActionDismissReport
+	default:
+		return nil, ErrInvalidReportResolution
+	}
+
+	var report types.Reports
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", req.ReportID).Take(&report).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrReportNotFound
+			}
+			return err
+		}
+		if report.Status != types.ReportStatusOpen {
+			return ErrReportAlreadyResolved
+		}
+
+		now := time.Now()
+		report.Status = status
+		report.ResolverID = &req.AdminID
+		report.ResolutionNote = req.Note
+		report.ResolvedAt = &now
+		if err := tx.Model(&types.Reports{}).Where("id = ?", report.ID).Updates(map[string]interface{}{
+			"status":          report.Status,
+			"resolver_id":     report.ResolverID,
+			"resolution_note": report.ResolutionNote,
+			"resolved_at":     report.ResolvedAt,
+		}).Error; err != nil {
+			return err
+		}
+		return writeAuditLog(tx, req.AdminID, action, types.AuditTargetReport, report.ID, req.Note, "")
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// ListAuditLogs 审计日志，按时间倒序。adminID和targetID为uuid.Nil时不过滤
+func (s *AdminService) ListAuditLogs(ctx context.Context, adminID, targetID uuid.UUID, offset, limit int) ([]types.AdminAuditLogs, error) {
+	query := s.DB.WithContext(ctx).Model(&types.AdminAuditLogs{})
+	if adminID != uuid.Nil {
+		query = query.Where("admin_id = ?", adminID)
+	}
+	if targetID != uuid.Nil {
+		query = query.Where("target_id = ?", targetID)
+	}
+
+	var logs []types.AdminAuditLogs
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&logs).Error
+	return logs, err
+}
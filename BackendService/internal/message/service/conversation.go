@@ -0,0 +1,227 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/gateway/websocket"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrConversationNotFound  = errors.New("conversation not found")
+	ErrInvalidMuteExpiry     = errors.New("muted_until must be in the future")
+	ErrConversationNotPinned = errors.New("conversation is not pinned")
+)
+
+type ConversationSettingsRequest struct {
+	UserID         uuid.UUID  `json:"-"`
+	ConversationID uuid.UUID  `json:"-"`
+	Muted          *bool      `json:"muted"`
+	MutedUntil     *time.Time `json:"muted_until"`
+	Pinned         *bool      `json:"pinned"`
+	Archived       *bool      `json:"archived"`
+	MarkedUnread   *bool      `json:"marked_unread"`
+}
+
+type ReorderPinsRequest struct {
+	ConversationIDs []uuid.UUID `json:"conversation_ids" binding:"required"`
+}
+
+// ConversationView 会话以及当前用户在该会话中的未读数和个人设置
+type ConversationView struct {
+	types.Conversations
+	UnreadCount        int                            `json:"unread_count"`
+	MentionUnreadCount int                            `json:"mention_unread_count"`
+	Settings           websocket.ConversationSettings `json:"settings"`
+}
+
+func toConversationSettings(p *types.ConversationParticipants) websocket.ConversationSettings {
+	settings := websocket.ConversationSettings{
+		ConversationID: p.ConversationID,
+		Muted:          p.IsMuted(time.Now()),
+		Pinned:         p.Pinned,
+		PinOrder:       p.PinOrder,
+		Archived:       p.Archived,
+		MarkedUnread:   p.MarkedUnread,
+		UpdatedAt:      p.UpdatedAt,
+	}
+	if settings.Muted {
+		settings.MutedUntil = p.MutedUntil
+	}
+	return settings
+}
+
+// GetUserConversations 返回用户的会话列表：置顶会话按pin_order在前，其余按最近更新时间排序。
+// 默认不返回已归档的会话，includeArchived为true时只返回已归档的会话
+func (s *MessageService) GetUserConversations(userID uuid.UUID, includeArchived bool) ([]ConversationView, error) {
+	var participants []types.ConversationParticipants
+	err := s.DB.Model(&types.ConversationParticipants{}).
+		Joins("JOIN conversations ON conversations.id = conversation_participants.conversation_id").
+		Where("conversation_participants.user_id = ? AND conversation_participants.archived = ?", userID, includeArchived).
+		Order("conversation_participants.pinned DESC").
+		Order("conversation_participants.pin_order ASC").
+		Order("conversations.updated_at DESC").
+		Find(&participants).Error
+	if err != nil {
+		return nil, err
+	}
+	if len(participants) == 0 {
+		return []ConversationView{}, nil
+	}
+
+	ids := make([]uuid.UUID, 0, len(participants))
+	for _, p := range participants {
+		ids = append(ids, p.ConversationID)
+	}
+	var conversations []types.Conversations
+	if err := s.DB.Preload("LastMessage").Where("id IN ?", ids).Find(&conversations).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uuid.UUID]types.Conversations, len(conversations))
+	for _, c := range conversations {
+		byID[c.ID] = c
+	}
+
+	views := make([]ConversationView, 0, len(participants))
+	for i := range participants {
+		p := &participants[i]
+		views = append(views, ConversationView{
+			Conversations:      byID[p.ConversationID],
+			UnreadCount:        p.UnreadCount,
+			MentionUnreadCount: p.MentionUnreadCount,
+			Settings:           toConversationSettings(p),
+		})
+	}
+	return views, nil
+}
+
+// UpdateConversationSettings 只更新请求中非空的字段。取消免打扰时清空过期时间，
+// 新置顶的会话排在已置顶会话的最后
+func (s *MessageService) UpdateConversationSettings(ctx context.Context, req *ConversationSettingsRequest) (*websocket.ConversationSettings, error) {
+	if req.MutedUntil != nil && !req.MutedUntil.After(time.Now()) {
+		return nil, ErrInvalidMuteExpiry
+	}
+
+	var participant types.ConversationParticipants
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).
+			Where("conversation_id = ? AND user_id = ?", req.ConversationID, req.UserID).
+			First(&participant).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrConversationNotFound
+			}
+			return err
+		}
+
+		updates := map[string]interface{}{"updated_at": time.Now()}
+		if req.Muted != nil {
+			updates["muted"] = *req.Muted
+			if *req.Muted {
+				updates["muted_until"] = req.MutedUntil
+			} else {
+				updates["muted_until"] = nil
+			}
+		}
+		if req.Pinned != nil && *req.Pinned != participant.Pinned {
+			updates["pinned"] = *req.Pinned
+			if *req.Pinned {
+				var maxOrder int
+				if err := tx.Model(&types.ConversationParticipants{}).
+					Where("user_id = ? AND pinned = ?", req.UserID, true).
+					Select("COALESCE(MAX(pin_order), 0)").
+					Scan(&maxOrder).Error; err != nil {
+					return err
+				}
+				updates["pin_order"] = maxOrder + 1
+			} else {
+				updates["pin_order"] = 0
+			}
+		}
+		if req.Archived != nil {
+			updates["archived"] = *req.Archived
+		}
+		if req.MarkedUnread != nil {
+			updates["marked_unread"] = *req.MarkedUnread
+		}
+
+		if err := tx.Model(&participant).Updates(updates).Error; err != nil {
+			return err
+		}
+		return tx.First(&participant, "id = ?", participant.ID).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	settings := toConversationSettings(&participant)
+	return &settings, nil
+}
+
+// ReorderPinnedConversations 按给定顺序重排置顶会话，列表中的会话必须都已置顶
+func (s *MessageService) ReorderPinnedConversations(ctx context.Context, userID uuid.UUID, conversationIDs []uuid.UUID) ([]websocket.ConversationSettings, error) {
+	var participants []types.ConversationParticipants
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		for i, conversationID := range conversationIDs {
+			result := tx.Model(&types.ConversationParticipants{}).
+				Where("conversation_id = ? AND user_id = ? AND pinned = ?", conversationID, userID, true).
+				Updates(map[string]interface{}{
+					"pin_order":  i + 1,
+					"updated_at": time.Now(),
+				})
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return ErrConversationNotPinned
+			}
+		}
+		return tx.Where("user_id = ? AND pinned = ?", userID, true).
+			Order("pin_order ASC").
+			Find(&participants).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	settings := make([]websocket.ConversationSettings, 0, len(participants))
+	for i := range participants {
+		settings = append(settings, toConversationSettings(&participants[i]))
+	}
+	return settings, nil
+}
+
+// mutedParticipants 返回userIDs中对该会话开启了免打扰(且未过期)的用户
+func (s *MessageService) mutedParticipants(conversationID uuid.UUID, userIDs []uuid.UUID) ([]uuid.UUID, error) {
+	var participants []types.ConversationParticipants
+	if err := s.DB.Where("conversation_id = ? AND user_id IN ? AND muted = ?", conversationID, userIDs, true).
+		Find(&participants).Error; err != nil {
+		return nil, err
+	}
+	return filterMuted(participants), nil
+}
+
+// mutedGroupMembers 返回对群会话开启了免打扰(且未过期)的成员
+func (s *MessageService) mutedGroupMembers(groupID uuid.UUID) ([]uuid.UUID, error) {
+	var participants []types.ConversationParticipants
+	if err := s.DB.Where("conversation_id IN (SELECT id FROM conversations WHERE group_id = ?) AND muted = ?", groupID, true).
+		Find(&participants).Error; err != nil {
+		return nil, err
+	}
+	return filterMuted(participants), nil
+}
+
+func filterMuted(participants []types.ConversationParticipants) []uuid.UUID {
+	now := time.Now()
+	var muted []uuid.UUID
+	for i := range participants {
+		if participants[i].IsMuted(now) {
+			muted = append(muted, participants[i].UserID)
+		}
+	}
+	return muted
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"gorm.io/gorm"
+)
+
+// @前面必须是开头或空白，避免把邮箱地址当成@
+var mentionPattern = regexp.MustCompile(`(?:^|\s)@([\p{L}\p{N}_.\-]+)`)
+
+const mentionAllKeyword = "all"
+
+// 可以使用@all的群角色
+var mentionAllRoles = map[string]bool{"owner": true, "admin": true}
+
+type parsedMention struct {
+	name   string
+	offset int
+	length int
+}
+
+// parseMentions 从消息内容中解析出所有@，offset/length包含@符号并按rune计算
+func parseMentions(content string) []parsedMention {
+	var mentions []parsedMention
+	for _, loc := range mentionPattern.FindAllStringSubmatchIndex(content, -1) {
+		// loc[2]:loc[3]是用户名，@在它前面一个字节；句末的标点不算在用户名里
+		start := loc[2] - 1
+		name := strings.TrimRight(content[loc[2]:loc[3]], ".-")
+		if name == "" {
+			continue
+		}
+		end := loc[2] + len(name)
+		mentions = append(mentions, parsedMention{
+			name:   name,
+			offset: utf8.RuneCountInString(content[:start]),
+			length: utf8.RuneCountInString(content[start:end]),
+		})
+	}
+	return mentions
+}
+
+// resolveMentions 把解析出的@转换为群成员，非群成员的@当作普通文本忽略。
+// 只有群主和管理员的@all生效
+func resolveMentions(db *gorm.DB, groupID uuid.UUID, sender types.GroupMembers, content string) ([]types.MentionEntity, bool, error) {
+	parsed := parseMentions(content)
+	if len(parsed) == 0 {
+		return nil, false, nil
+	}
+
+	names := make([]string, 0, len(parsed))
+	for _, p := range parsed {
+		if !strings.EqualFold(p.name, mentionAllKeyword) {
+			names = append(names, p.name)
+		}
+	}
+
+	members := make(map[string]uuid.UUID)
+	if len(names) > 0 {
+		var rows []struct {
+			ID       uuid.UUID
+			Username string
+		}
+		if err := db.Model(&types.Users{}).
+			Select("users.id, users.username").
+			Joins("JOIN group_members ON group_members.user_id = users.id").
+			Where("group_members.group_id = ? AND users.username IN ?", groupID, names).
+			Scan(&rows).Error; err != nil {
+			return nil, false, err
+		}
+		for _, row := range rows {
+			members[row.Username] = row.ID
+		}
+	}
+
+	var entities []types.MentionEntity
+	mentionAll := false
+	for _, p := range parsed {
+		if strings.EqualFold(p.name, mentionAllKeyword) {
+			if !mentionAllRoles[sender.Role] {
+				continue
+			}
+			mentionAll = true
+			entities = append(entities, types.MentionEntity{Username: p.name, Offset: p.offset, Length: p.length})
+			continue
+		}
+		userID, ok := members[p.name]
+		if !ok || userID == sender.UserID {
+			continue
+		}
+		entities = append(entities, types.MentionEntity{UserID: userID, Username: p.name, Offset: p.offset, Length: p.length})
+	}
+	return entities, mentionAll, nil
+}
+
+// mentionedUserIDs 返回被单独@的用户，去重
+func mentionedUserIDs(entities []types.MentionEntity) []uuid.UUID {
+	seen := make(map[uuid.UUID]bool)
+	var ids []uuid.UUID
+	for _, e := range entities {
+		if e.UserID == uuid.Nil || seen[e.UserID] {
+			continue
+		}
+		seen[e.UserID] = true
+		ids = append(ids, e.UserID)
+	}
+	return ids
+}
+
+// incrementMentionUnread 被@的成员在群会话中的mention_unread_count+1，@all时除发送者外所有成员+1
+func (m *MessageService) incrementMentionUnread(groupMessage *types.GroupMessages) error {
+	query := m.DB.Model(&types.ConversationParticipants{}).
+		Where("conversation_id IN (SELECT id FROM conversations WHERE group_id = ?)", groupMessage.GroupID).
+		Where("user_id <> ?", groupMessage.SenderID)
+	if !groupMessage.MentionAll {
+		ids := mentionedUserIDs(groupMessage.Mentions)
+		if len(ids) == 0 {
+			return nil
+		}
+		query = query.Where("user_id IN ?", ids)
+	}
+	return query.Updates(map[string]interface{}{
+		"mention_unread_count": gorm.Expr("mention_unread_count + 1"),
+	}).Error
+}
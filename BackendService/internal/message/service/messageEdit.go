@@ -0,0 +1,354 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/kafka"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/gateway/websocket"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/message/moderation"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidChatType     = errors.New("invalid chat type")
+	ErrMessageNotFound     = errors.New("message not found")
+	ErrNotMessageSender    = errors.New("only the sender can modify this message")
+	ErrNotParticipant      = errors.New("user is not a participant of this conversation")
+	ErrMessageRecalled     = errors.New("message has been recalled")
+	ErrEditWindowExpired   = errors.New("edit window has expired")
+	ErrRecallWindowExpired = errors.New("recall window has expired")
+	ErrEmptyMessageContent = errors.New("message content is empty")
+	ErrMessageUnchanged    = errors.New("message content is unchanged")
+)
+
+type EditMessageRequest struct {
+	MessageID   uuid.UUID `json:"-"`
+	ChatType    string    `json:"-"`
+	EditorID    uuid.UUID `json:"-"`
+	Content     string    `json:"content" binding:"required"`
+	ContentType int       `json:"content_type"`
+}
+
+type RecallMessageRequest struct {
+	MessageID  uuid.UUID `json:"-"`
+	ChatType   string    `json:"-"`
+	OperatorID uuid.UUID `json:"-"`
+}
+
+// MessageHistory 消息的当前版本以及之前所有的编辑版本
+type MessageHistory struct {
+	Current  *websocket.MessageUpdateResponse `json:"current"`
+	Versions []types.MessageEdits             `json:"versions"`
+}
+
+// messageRecord P2PMessages和GroupMessages的公共字段，方便统一处理编辑/撤回
+type messageRecord struct {
+	ID           uuid.UUID
+	SenderID     uuid.UUID
+	ReceiverID   uuid.UUID
+	GroupID      uuid.UUID
+	ThreadRootID *uuid.UUID
+	Content      string
+	ContentType  int
+	Edited       bool
+	Recalled     bool
+	CreatedAt    time.Time
+}
+
+func messageModel(chatType string) (interface{}, error) {
+	switch chatType {
+	case types.ChatTypeP2P:
+		return &types.P2PMessages{}, nil
+	case types.ChatTypeGroup:
+		return &types.GroupMessages{}, nil
+	default:
+		return nil, ErrInvalidChatType
+	}
+}
+
+func loadMessage(tx *gorm.DB, chatType string, messageID uuid.UUID, forUpdate bool) (*messageRecord, error) {
+	model, err := messageModel(chatType)
+	if err != nil {
+		return nil, err
+	}
+	query := tx.Model(model).Where("id = ?", messageID)
+	if forUpdate {
+		query = query.Clauses(clause.Locking{Strength: "UPDATE"})
+	}
+	var record messageRecord
+	if err := query.Take(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// isParticipant 判断用户是否能看到这条消息：P2P为收发双方，群消息为群成员
+func isParticipant(tx *gorm.DB, chatType string, record *messageRecord, userID uuid.UUID) (bool, error) {
+	if chatType == types.ChatTypeP2P {
+		return record.SenderID == userID || record.ReceiverID == userID, nil
+	}
+	var count int64
+	err := tx.Model(&types.GroupMembers{}).
+		Where("user_id = ? AND group_id = ?", userID, record.GroupID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func toUpdateResponse(chatType string, record *messageRecord, version int) *websocket.MessageUpdateResponse {
+	return &websocket.MessageUpdateResponse{
+		ID:          record.ID,
+		ChatType:    chatType,
+		SenderID:    record.SenderID,
+		ReceiverID:  record.ReceiverID,
+		GroupID:     record.GroupID,
+		Content:     record.Content,
+		ContentType: record.ContentType,
+		Edited:      record.Edited,
+		Recalled:    record.Recalled,
+		Version:     version,
+		Timestamp:   time.Now().Unix(),
+	}
+}
+
+// EditMessage 在编辑窗口内修改消息内容，旧内容保存到MessageEdits。
+// 审核可能调用外部分类服务，在事务外进行，不在持有行锁和连接时等待HTTP请求
+func (m *MessageService) EditMessage(ctx context.Context, req *EditMessageRequest) (*websocket.MessageUpdateResponse, error) {
+	if req.Content == "" {
+		return nil, ErrEmptyMessageContent
+	}
+
+	// 1. 不加锁读取消息，校验权限和编辑窗口
+	db := m.DB.WithContext(ctx)
+	record, err := loadMessage(db, req.ChatType, req.MessageID, false)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.checkEditable(db, record, req); err != nil {
+		return nil, err
+	}
+
+	// 2. 新内容需要通过审核
+	moderationInput := &moderation.Input{
+		SenderID:    record.SenderID,
+		ChatType:    req.ChatType,
+		ReceiverID:  record.ReceiverID,
+		GroupID:     record.GroupID,
+		Content:     req.Content,
+		ContentType: req.ContentType,
+		Edit:        true,
+	}
+	decision, err := m.moderate(ctx, moderationInput)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp *websocket.MessageUpdateResponse
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// 加锁重新读取消息，防止并发编辑产生相同的版本号；审核期间消息可能已被撤回或编辑，重新校验
+		record, err := loadMessage(tx, req.ChatType, req.MessageID, true)
+		if err != nil {
+			return err
+		}
+		if err := m.checkEditable(tx, record, req); err != nil {
+			return err
+		}
+
+		// 3. 保存旧版本
+		var versions int64
+		if err := tx.Model(&types.MessageEdits{}).Where("message_id = ?", record.ID).Count(&versions).Error; err != nil {
+			return err
+		}
+		prior := types.MessageEdits{
+			MessageID:   record.ID,
+			ChatType:    req.ChatType,
+			Version:     int(versions) + 1,
+			Content:     record.Content,
+			ContentType: record.ContentType,
+			EditorID:    req.EditorID,
+			CreatedAt:   time.Now(),
+		}
+		if err := tx.Create(&prior).Error; err != nil {
+			return err
+		}
+
+		// 4. 更新为最新版本
+		now := time.Now()
+		model, _ := messageModel(req.ChatType)
+		if err := tx.Model(model).Where("id = ?", record.ID).Updates(map[string]interface{}{
+			"content":      req.Content,
+			"content_type": req.ContentType,
+			"edited":       true,
+			"edited_at":    now,
+			"updated_at":   now,
+		}).Error; err != nil {
+			return err
+		}
+
+		if err := m.saveModerationRecord(tx, moderationInput, decision, &record.ID); err != nil {
+			return err
+		}
+
+		record.Content = req.Content
+		record.ContentType = req.ContentType
+		record.Edited = true
+		resp = toUpdateResponse(req.ChatType, record, prior.Version+1)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.publishMessageUpdate(ctx, "message_edited", resp)
+	return resp, nil
+}
+
+// checkEditable 只有发送者可以在编辑窗口内修改未撤回的消息，且内容必须有变化
+func (m *MessageService) checkEditable(db *gorm.DB, record *messageRecord, req *EditMessageRequest) error {
+	if record.SenderID != req.EditorID {
+		return ErrNotMessageSender
+	}
+	if err := ensureUserActive(db, req.EditorID); err != nil {
+		return err
+	}
+	if record.Recalled {
+		return ErrMessageRecalled
+	}
+	if time.Since(record.CreatedAt) > m.editWindow {
+		return ErrEditWindowExpired
+	}
+	if record.Content == req.Content && record.ContentType == req.ContentType {
+		return ErrMessageUnchanged
+	}
+	return nil
+}
+
+// RecallMessage 撤回消息(对所有人删除)，消息内容清空只保留墓碑，历史版本一并删除
+func (m *MessageService) RecallMessage(ctx context.Context, req *RecallMessageRequest) (*websocket.MessageUpdateResponse, error) {
+	var resp *websocket.MessageUpdateResponse
+	err := m.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		record, err := loadMessage(tx, req.ChatType, req.MessageID, true)
+		if err != nil {
+			return err
+		}
+
+		if record.SenderID != req.OperatorID {
+			return ErrNotMessageSender
+		}
+		if record.Recalled {
+			return ErrMessageRecalled
+		}
+		if time.Since(record.CreatedAt) > m.recallWindow {
+			return ErrRecallWindowExpired
+		}
+
+		if err := recallRecord(tx, req.ChatType, record); err != nil {
+			return err
+		}
+		resp = toUpdateResponse(req.ChatType, record, 0)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.publishMessageUpdate(ctx, "message_recalled", resp)
+	return resp, nil
+}
+
+// recallRecord 清空消息内容只保留墓碑，并删除历史版本。调用方负责加锁和权限校验
+func recallRecord(tx *gorm.DB, chatType string, record *messageRecord) error {
+	now := time.Now()
+	model, err := messageModel(chatType)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(model).Where("id = ?", record.ID).Updates(map[string]interface{}{
+		"content":     "",
+		"recalled":    true,
+		"recalled_at": now,
+		"updated_at":  now,
+	}).Error; err != nil {
+		return err
+	}
+	if err := tx.Where("message_id = ?", record.ID).Delete(&types.MessageEdits{}).Error; err != nil {
+		return err
+	}
+
+	record.Content = ""
+	record.Recalled = true
+	return nil
+}
+
+// DeleteMessageForUser 仅对自己删除，不影响其他参与者
+func (m *MessageService) DeleteMessageForUser(ctx context.Context, chatType string, messageID, userID uuid.UUID) error {
+	db := m.DB.WithContext(ctx)
+	record, err := loadMessage(db, chatType, messageID, false)
+	if err != nil {
+		return err
+	}
+	ok, err := isParticipant(db, chatType, record, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrNotParticipant
+	}
+
+	deletion := types.MessageDeletions{
+		MessageID: messageID,
+		UserID:    userID,
+		ChatType:  chatType,
+		CreatedAt: time.Now(),
+	}
+	return db.Clauses(clause.OnConflict{DoNothing: true}).Create(&deletion).Error
+}
+
+// GetMessageHistory 返回消息的最新版本和所有历史版本，只有会话参与者可以查看
+func (m *MessageService) GetMessageHistory(ctx context.Context, chatType string, messageID, userID uuid.UUID) (*MessageHistory, error) {
+	db := m.DB.WithContext(ctx)
+	record, err := loadMessage(db, chatType, messageID, false)
+	if err != nil {
+		return nil, err
+	}
+	ok, err := isParticipant(db, chatType, record, userID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotParticipant
+	}
+
+	var versions []types.MessageEdits
+	if err := db.Where("message_id = ?", messageID).Order("version ASC").Find(&versions).Error; err != nil {
+		return nil, err
+	}
+
+	return &MessageHistory{
+		Current:  toUpdateResponse(chatType, record, len(versions)+1),
+		Versions: versions,
+	}, nil
+}
+
+func (m *MessageService) publishMessageUpdate(ctx context.Context, eventType string, resp *websocket.MessageUpdateResponse) {
+	writerName := "p2p_message"
+	if resp.ChatType == types.ChatTypeGroup {
+		writerName = "group_message"
+	}
+	kafkaPayload := kafka.MessagePayload{
+		Type:      eventType,
+		Data:      resp,
+		Timestamp: time.Now().Unix(),
+	}
+	if err := m.KafkaProducer.SendMessage(ctx, writerName, writerName, kafkaPayload); err != nil {
+		slog.ErrorContext(ctx, "Failed to send message update to Kafka", "error", err, "type", eventType)
+	}
+}
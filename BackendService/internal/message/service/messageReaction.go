@@ -0,0 +1,326 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/gateway/websocket"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 一个表情可能由多个码点组成(肤色、ZWJ序列)，这里只做长度上限的保护
+const maxEmojiRunes = 16
+
+var (
+	ErrInvalidEmoji       = errors.New("invalid emoji")
+	ErrInvalidReplyTarget = errors.New("reply target is not in this conversation")
+)
+
+type ReactionRequest struct {
+	MessageID uuid.UUID `json:"-"`
+	ChatType  string    `json:"-"`
+	UserID    uuid.UUID `json:"-"`
+	Emoji     string    `json:"emoji" binding:"required"`
+}
+
+// ReplyPreview 被回复/引用消息的摘要，被撤回时Content为空
+type ReplyPreview struct {
+	ID          uuid.UUID `json:"id"`
+	SenderID    uuid.UUID `json:"sender_id"`
+	Content     string    `json:"content"`
+	ContentType int       `json:"content_type"`
+	Recalled    bool      `json:"recalled"`
+}
+
+type P2PMessageView struct {
+	types.P2PMessages
+	Reactions []websocket.ReactionSummary `json:"reactions"`
+	ReplyTo   *ReplyPreview               `json:"reply_to,omitempty"`
+}
+
+type GroupMessageView struct {
+	types.GroupMessages
+	Reactions        []websocket.ReactionSummary `json:"reactions"`
+	ReplyTo          *ReplyPreview               `json:"reply_to,omitempty"`
+	ThreadReplyCount int64                       `json:"thread_reply_count"`
+}
+
+// GroupThread 话题视图：根消息以及它下面的回复
+type GroupThread struct {
+	Root    GroupMessageView   `json:"root"`
+	Replies []GroupMessageView `json:"replies"`
+}
+
+func (m *MessageService) AddReaction(ctx context.Context, req *ReactionRequest) (*websocket.ReactionUpdateResponse, error) {
+	return m.updateReaction(ctx, req, true)
+}
+
+func (m *MessageService) RemoveReaction(ctx context.Context, req *ReactionRequest) (*websocket.ReactionUpdateResponse, error) {
+	return m.updateReaction(ctx, req, false)
+}
+
+func (m *MessageService) updateReaction(ctx context.Context, req *ReactionRequest, add bool) (*websocket.ReactionUpdateResponse, error) {
+	emoji := strings.TrimSpace(req.Emoji)
+	if emoji == "" || utf8.RuneCountInString(emoji) > maxEmojiRunes || strings.ContainsAny(emoji, " \t\n") {
+		return nil, ErrInvalidEmoji
+	}
+
+	db := m.DB.WithContext(ctx)
+	// 1. 校验消息存在且用户是会话参与者
+	record, err := loadMessage(db, req.ChatType, req.MessageID, false)
+	if err != nil {
+		return nil, err
+	}
+	if record.Recalled {
+		return nil, ErrMessageRecalled
+	}
+	ok, err := isParticipant(db, req.ChatType, record, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotParticipant
+	}
+
+	// 2. 添加或删除回应，重复添加/删除都是幂等的
+	if add {
+		reaction := types.MessageReactions{
+			MessageID: req.MessageID,
+			ChatType:  req.ChatType,
+			UserID:    req.UserID,
+			Emoji:     emoji,
+			CreatedAt: time.Now(),
+		}
+		err = db.Clauses(clause.OnConflict{DoNothing: true}).Create(&reaction).Error
+	} else {
+		err = db.Where("message_id = ? AND user_id = ? AND emoji = ?", req.MessageID, req.UserID, emoji).
+			Delete(&types.MessageReactions{}).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// 3. 返回最新的聚合结果
+	summaries, err := loadReactionSummaries(db, []uuid.UUID{req.MessageID})
+	if err != nil {
+		return nil, err
+	}
+
+	return &websocket.ReactionUpdateResponse{
+		MessageID:  record.ID,
+		ChatType:   req.ChatType,
+		SenderID:   record.SenderID,
+		ReceiverID: record.ReceiverID,
+		GroupID:    record.GroupID,
+		UserID:     req.UserID,
+		Emoji:      emoji,
+		Added:      add,
+		Reactions:  summaries[req.MessageID],
+		Timestamp:  time.Now().Unix(),
+	}, nil
+}
+
+// loadReactionSummaries 按消息聚合表情回应，表情按第一次出现的顺序排列
+func loadReactionSummaries(db *gorm.DB, messageIDs []uuid.UUID) (map[uuid.UUID][]websocket.ReactionSummary, error) {
+	result := make(map[uuid.UUID][]websocket.ReactionSummary)
+	if len(messageIDs) == 0 {
+		return result, nil
+	}
+
+	var reactions []types.MessageReactions
+	if err := db.Where("message_id IN ?", messageIDs).Order("created_at ASC").Find(&reactions).Error; err != nil {
+		return nil, err
+	}
+
+	for _, reaction := range reactions {
+		summaries := result[reaction.MessageID]
+		found := false
+		for i := range summaries {
+			if summaries[i].Emoji == reaction.Emoji {
+				summaries[i].Count++
+				summaries[i].UserIDs = append(summaries[i].UserIDs, reaction.UserID)
+				found = true
+				break
+			}
+		}
+		if !found {
+			summaries = append(summaries, websocket.ReactionSummary{
+				Emoji:   reaction.Emoji,
+				Count:   1,
+				UserIDs: []uuid.UUID{reaction.UserID},
+			})
+		}
+		result[reaction.MessageID] = summaries
+	}
+	return result, nil
+}
+
+// loadReplyPreviews 批量读取被回复消息的摘要
+func loadReplyPreviews(db *gorm.DB, chatType string, ids []uuid.UUID) (map[uuid.UUID]*ReplyPreview, error) {
+	result := make(map[uuid.UUID]*ReplyPreview)
+	if len(ids) == 0 {
+		return result, nil
+	}
+	model, err := messageModel(chatType)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []messageRecord
+	if err := db.Model(model).Where("id IN ?", ids).Find(&records).Error; err != nil {
+		return nil, err
+	}
+	for _, record := range records {
+		result[record.ID] = &ReplyPreview{
+			ID:          record.ID,
+			SenderID:    record.SenderID,
+			Content:     record.Content,
+			ContentType: record.ContentType,
+			Recalled:    record.Recalled,
+		}
+	}
+	return result, nil
+}
+
+// resolveReply 校验被回复的消息属于同一会话，群消息返回所属话题的根消息ID
+func resolveReply(db *gorm.DB, chatType string, replyToID uuid.UUID, senderID, receiverID, groupID uuid.UUID) (*uuid.UUID, error) {
+	parent, err := loadMessage(db, chatType, replyToID, false)
+	if err != nil {
+		if errors.Is(err, ErrMessageNotFound) {
+			return nil, ErrInvalidReplyTarget
+		}
+		return nil, err
+	}
+
+	if chatType == types.ChatTypeP2P {
+		samePair := (parent.SenderID == senderID && parent.ReceiverID == receiverID) ||
+			(parent.SenderID == receiverID && parent.ReceiverID == senderID)
+		if !samePair {
+			return nil, ErrInvalidReplyTarget
+		}
+		return nil, nil
+	}
+
+	if parent.GroupID != groupID {
+		return nil, ErrInvalidReplyTarget
+	}
+	if parent.ThreadRootID != nil {
+		return parent.ThreadRootID, nil
+	}
+	return &parent.ID, nil
+}
+
+func (s *MessageService) buildP2PViews(messages []types.P2PMessages) ([]P2PMessageView, error) {
+	ids := make([]uuid.UUID, 0, len(messages))
+	var replyIDs []uuid.UUID
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+		if message.ReplyToID != nil {
+			replyIDs = append(replyIDs, *message.ReplyToID)
+		}
+	}
+
+	reactions, err := loadReactionSummaries(s.DB, ids)
+	if err != nil {
+		return nil, err
+	}
+	previews, err := loadReplyPreviews(s.DB, types.ChatTypeP2P, replyIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	views := make([]P2PMessageView, 0, len(messages))
+	for _, message := range messages {
+		view := P2PMessageView{P2PMessages: message, Reactions: reactions[message.ID]}
+		if message.ReplyToID != nil {
+			view.ReplyTo = previews[*message.ReplyToID]
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+func (s *MessageService) buildGroupViews(messages []types.GroupMessages) ([]GroupMessageView, error) {
+	ids := make([]uuid.UUID, 0, len(messages))
+	var replyIDs []uuid.UUID
+	for _, message := range messages {
+		ids = append(ids, message.ID)
+		if message.ReplyToID != nil {
+			replyIDs = append(replyIDs, *message.ReplyToID)
+		}
+	}
+
+	reactions, err := loadReactionSummaries(s.DB, ids)
+	if err != nil {
+		return nil, err
+	}
+	previews, err := loadReplyPreviews(s.DB, types.ChatTypeGroup, replyIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	// 统计每条消息作为话题根时的回复数
+	var counts []struct {
+		ThreadRootID uuid.UUID
+		Count        int64
+	}
+	if len(ids) > 0 {
+		if err := s.DB.Model(&types.GroupMessages{}).
+			Select("thread_root_id, COUNT(*) AS count").
+			Where("thread_root_id IN ?", ids).
+			Group("thread_root_id").
+			Scan(&counts).Error; err != nil {
+			return nil, err
+		}
+	}
+	replyCounts := make(map[uuid.UUID]int64, len(counts))
+	for _, c := range counts {
+		replyCounts[c.ThreadRootID] = c.Count
+	}
+
+	views := make([]GroupMessageView, 0, len(messages))
+	for _, message := range messages {
+		view := GroupMessageView{
+			GroupMessages:    message,
+			Reactions:        reactions[message.ID],
+			ThreadReplyCount: replyCounts[message.ID],
+		}
+		if message.ReplyToID != nil {
+			view.ReplyTo = previews[*message.ReplyToID]
+		}
+		views = append(views, view)
+	}
+	return views, nil
+}
+
+// GetGroupThread 返回话题根消息和按时间正序排列的回复，过滤掉userID"仅对自己删除"的回复
+func (s *MessageService) GetGroupThread(userID, groupID, rootID uuid.UUID, offset, limit int) (*GroupThread, error) {
+	var root types.GroupMessages
+	if err := s.DB.Where("id = ? AND group_id = ?", rootID, groupID).First(&root).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrMessageNotFound
+		}
+		return nil, err
+	}
+
+	var replies []types.GroupMessages
+	if err := s.DB.Where("thread_root_id = ?", rootID).
+		Where("id NOT IN (SELECT message_id FROM message_deletions WHERE user_id = ?)", userID).
+		Order("created_at ASC").
+		Offset(offset).Limit(limit).
+		Find(&replies).Error; err != nil {
+		return nil, err
+	}
+
+	views, err := s.buildGroupViews(append([]types.GroupMessages{root}, replies...))
+	if err != nil {
+		return nil, err
+	}
+	return &GroupThread{Root: views[0], Replies: views[1:]}, nil
+}
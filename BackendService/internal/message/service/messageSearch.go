@@ -0,0 +1,232 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"html"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"gorm.io/gorm"
+)
+
+const (
+	maxSearchQueryRunes = 128
+	maxSearchLimit      = 50
+	// 少于3个字符时三元组索引的选择性很差，CJK词语常见两个字，这里只拒绝单个字符
+	minTrigramQueryRunes = 2
+	snippetRadius        = 30
+	// ts_headline用控制字符标记匹配位置，查询时先从内容中去掉这两个字符，
+	// HTML转义之后再替换成<mark>，避免用户内容里的标签原样返回
+	markStart       = "\x02"
+	markStop        = "\x03"
+	headlineOptions = "StartSel=" + markStart + ", StopSel=" + markStop + ", MaxWords=20, MinWords=5, MaxFragments=2"
+)
+
+var markReplacer = strings.NewReplacer(markStart, "<mark>", markStop, "</mark>")
+
+var ErrInvalidSearchQuery = errors.New("invalid search query")
+
+// SearchMessagesRequest 搜索条件，UserID是查询者，结果只包含其参与的会话
+type SearchMessagesRequest struct {
+	UserID   uuid.UUID
+	Query    string
+	ChatType string // 为空表示同时搜索单聊和群聊
+	PeerID   *uuid.UUID
+	GroupID  *uuid.UUID
+	SenderID *uuid.UUID
+	From     *time.Time
+	To       *time.Time
+	Offset   int
+	Limit    int
+}
+
+type SearchResult struct {
+	ID          uuid.UUID  `json:"id"`
+	ChatType    string     `json:"chat_type"`
+	SenderID    uuid.UUID  `json:"sender_id"`
+	ReceiverID  *uuid.UUID `json:"receiver_id,omitempty"`
+	GroupID     *uuid.UUID `json:"group_id,omitempty"`
+	Content     string     `json:"content"`
+	ContentType int        `json:"content_type"`
+	Snippet     string     `json:"snippet"` // HTML转义后的片段，匹配部分用<mark>包裹
+	Rank        float64    `json:"rank"`
+	Edited      bool       `json:"edited"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// SearchMessages 在查询者参与的单聊和群聊中搜索消息。
+// 非CJK文本使用tsvector全文检索，包含中日韩字符时使用pg_trgm子串匹配
+func (m *MessageService) SearchMessages(ctx context.Context, req *SearchMessagesRequest) ([]SearchResult, error) {
+	query := strings.TrimSpace(req.Query)
+	runes := utf8.RuneCountInString(query)
+	if runes == 0 || runes > maxSearchQueryRunes {
+		return nil, ErrInvalidSearchQuery
+	}
+	cjk := containsCJK(query)
+	if cjk && runes < minTrigramQueryRunes {
+		return nil, ErrInvalidSearchQuery
+	}
+	if req.Limit <= 0 || req.Limit > maxSearchLimit {
+		req.Limit = 20
+	}
+	if req.Offset < 0 {
+		req.Offset = 0
+	}
+
+	db := m.DB.WithContext(ctx)
+	var parts []*gorm.DB
+	// 指定了对端用户只搜单聊，指定了群只搜群聊
+	if (req.ChatType == "" || req.ChatType == types.ChatTypeP2P) && req.GroupID == nil {
+		parts = append(parts, m.p2pSearchQuery(db, req, query, cjk))
+	}
+	if (req.ChatType == "" || req.ChatType == types.ChatTypeGroup) && req.PeerID == nil {
+		parts = append(parts, m.groupSearchQuery(db, req, query, cjk))
+	}
+	if len(parts) == 0 {
+		return []SearchResult{}, nil
+	}
+
+	var results []SearchResult
+	var err error
+	if len(parts) == 1 {
+		err = db.Raw("SELECT * FROM (?) AS r ORDER BY rank DESC, created_at DESC LIMIT ? OFFSET ?",
+			parts[0], req.Limit, req.Offset).Scan(&results).Error
+	} else {
+		err = db.Raw("SELECT * FROM ((?) UNION ALL (?)) AS r ORDER BY rank DESC, created_at DESC LIMIT ? OFFSET ?",
+			parts[0], parts[1], req.Limit, req.Offset).Scan(&results).Error
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range results {
+		if cjk {
+			results[i].Snippet = highlightSnippet(results[i].Content, query)
+		} else {
+			results[i].Snippet = renderHeadline(results[i].Snippet)
+		}
+	}
+	return results, nil
+}
+
+func (m *MessageService) p2pSearchQuery(db *gorm.DB, req *SearchMessagesRequest, query string, cjk bool) *gorm.DB {
+	q := db.Model(&types.P2PMessages{}).
+		Where("(sender_id = ? OR receiver_id = ?)", req.UserID, req.UserID)
+	if req.PeerID != nil {
+		q = q.Where("(sender_id = ? OR receiver_id = ?)", *req.PeerID, *req.PeerID)
+	}
+	columns := "id, 'p2p' AS chat_type, sender_id, receiver_id, NULL::uuid AS group_id, content, content_type, edited, created_at"
+	return applySearchFilters(q, columns, req, query, cjk)
+}
+
+func (m *MessageService) groupSearchQuery(db *gorm.DB, req *SearchMessagesRequest, query string, cjk bool) *gorm.DB {
+	q := db.Model(&types.GroupMessages{}).
+		Where("group_id IN (SELECT group_id FROM group_members WHERE user_id = ?)", req.UserID)
+	if req.GroupID != nil {
+		q = q.Where("group_id = ?", *req.GroupID)
+	}
+	columns := "id, 'group' AS chat_type, sender_id, NULL::uuid AS receiver_id, group_id, content, content_type, edited, created_at"
+	return applySearchFilters(q, columns, req, query, cjk)
+}
+
+// applySearchFilters 追加两张表共有的过滤条件以及匹配、排序、高亮的表达式。
+// 两张表的columns必须一致，才能UNION ALL
+func applySearchFilters(q *gorm.DB, columns string, req *SearchMessagesRequest, query string, cjk bool) *gorm.DB {
+	q = q.Where("recalled = ?", false).
+		Where("id NOT IN (SELECT message_id FROM message_deletions WHERE user_id = ?)", req.UserID)
+	if req.SenderID != nil {
+		q = q.Where("sender_id = ?", *req.SenderID)
+	}
+	if req.From != nil {
+		q = q.Where("created_at >= ?", *req.From)
+	}
+	if req.To != nil {
+		q = q.Where("created_at <= ?", *req.To)
+	}
+
+	if cjk {
+		// 高亮在Go里处理，这里只返回空的snippet占位
+		return q.Select(columns+", '' AS snippet, similarity(content, ?) AS rank", query).
+			Where("content ILIKE ?", "%"+escapeLike(query)+"%")
+	}
+	return q.Select(columns+", ts_headline('simple', translate(content, ?, ''), websearch_to_tsquery('simple', ?), ?) AS snippet, ts_rank(search_vector, websearch_to_tsquery('simple', ?)) AS rank",
+		markStart+markStop, query, headlineOptions, query).
+		Where("search_vector @@ websearch_to_tsquery('simple', ?)", query)
+}
+
+func containsCJK(s string) bool {
+	for _, r := range s {
+		if unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul) {
+			return true
+		}
+	}
+	return false
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// renderHeadline HTML转义ts_headline返回的片段，再把匹配标记替换成<mark>
+func renderHeadline(snippet string) string {
+	return markReplacer.Replace(html.EscapeString(snippet))
+}
+
+// highlightSnippet 截取匹配位置前后的内容，HTML转义后用<mark>包裹匹配部分
+func highlightSnippet(content, query string) string {
+	lowerContent := []rune(strings.ToLower(content))
+	lowerQuery := []rune(strings.ToLower(query))
+	original := []rune(content)
+	if len(lowerContent) != len(original) {
+		// 大小写转换改变了长度时不做截取，直接返回原文
+		return html.EscapeString(content)
+	}
+
+	idx := indexRunes(lowerContent, lowerQuery)
+	if idx < 0 {
+		return html.EscapeString(content)
+	}
+	start := idx - snippetRadius
+	if start < 0 {
+		start = 0
+	}
+	end := idx + len(lowerQuery) + snippetRadius
+	if end > len(original) {
+		end = len(original)
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("...")
+	}
+	b.WriteString(html.EscapeString(string(original[start:idx])))
+	b.WriteString("<mark>")
+	b.WriteString(html.EscapeString(string(original[idx : idx+len(lowerQuery)])))
+	b.WriteString("</mark>")
+	b.WriteString(html.EscapeString(string(original[idx+len(lowerQuery) : end])))
+	if end < len(original) {
+		b.WriteString("...")
+	}
+	return b.String()
+}
+
+func indexRunes(s, sub []rune) int {
+	for i := 0; i+len(sub) <= len(s); i++ {
+		match := true
+		for j := range sub {
+			if s[i+j] != sub[j] {
+				match = false
+				break
+			}
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
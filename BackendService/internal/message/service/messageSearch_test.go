@@ -0,0 +1,29 @@
+package service
+
+import "testing"
+
+func TestHighlightSnippetEscapesContent(t *testing.T) {
+	tests := []struct {
+		name, content, query, want string
+	}{
+		{"match", "<b>会议</b>纪要", "会议", "&lt;b&gt;<mark>会议</mark>&lt;/b&gt;纪要"},
+		{"match contains markup", "<img src=x onerror=alert(1)>", "<img", "<mark>&lt;img</mark> src=x onerror=alert(1)&gt;"},
+		{"no match", "<script>alert(1)</script>", "会议", "&lt;script&gt;alert(1)&lt;/script&gt;"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := highlightSnippet(tt.content, tt.query); got != tt.want {
+				t.Errorf("highlightSnippet() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// TestRenderHeadline ts_headline返回的片段转义后只有标记位置变成<mark>
+func TestRenderHeadline(t *testing.T) {
+	snippet := "say " + markStart + "hello" + markStop + " <script>alert(1)</script>"
+	want := "say <mark>hello</mark> &lt;script&gt;alert(1)&lt;/script&gt;"
+	if got := renderHeadline(snippet); got != want {
+		t.Errorf("snippet = %q, want %q", got, want)
+	}
+}
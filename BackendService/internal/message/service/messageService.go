@@ -2,49 +2,96 @@ package service
 
 import (
 	"context"
+	"errors"
 	"time"
 
 	"log/slog"
 
 	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/config"
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/kafka"
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/gateway/websocket"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/message/moderation"
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
 	"gorm.io/gorm"
 )
 
+// SendP2PMessageRequest 发送者由调用方设置：HTTP取自token，gRPC取自网关已认证的连接
 type SendP2PMessageRequest struct {
-	SenderID    uuid.UUID `json:"sender_id" binding:"required"`
-	ReceiverID  uuid.UUID `json:"receiver_id" binding:"required"`
-	Content     string    `json:"content" binding:"required"`
-	ContentType int       `json:"content_type"`
+	SenderID    uuid.UUID  `json:"-"`
+	ReceiverID  uuid.UUID  `json:"receiver_id" binding:"required"`
+	Content     string     `json:"content" binding:"required"`
+	ContentType int        `json:"content_type"`
+	ReplyToID   *uuid.UUID `json:"reply_to_id"`
 }
 
 type SendGroupMessageRequest struct {
-	SenderID    uuid.UUID `json:"sender_id" binding:"required"`
-	GroupID     uuid.UUID `json:"group_id" binding:"required"`
-	Content     string    `json:"content" binding:"required"`
-	ContentType int       `json:"content_type"`
+	SenderID    uuid.UUID  `json:"-"`
+	GroupID     uuid.UUID  `json:"group_id" binding:"required"`
+	Content     string     `json:"content" binding:"required"`
+	ContentType int        `json:"content_type"`
+	ReplyToID   *uuid.UUID `json:"reply_to_id"`
 }
 
+const (
+	defaultEditWindow   = 15 * time.Minute
+	defaultRecallWindow = 2 * time.Minute
+)
+
 type MessageService struct {
 	DB            *gorm.DB
 	KafkaProducer *kafka.Producer
+	editWindow    time.Duration
+	recallWindow  time.Duration
+	moderator     *moderation.Pipeline
 }
 
-func NewMessageService(db *gorm.DB, kafkaProducer *kafka.Producer) *MessageService {
+// NewMessageService moderator为nil时不审核消息
+func NewMessageService(db *gorm.DB, kafkaProducer *kafka.Producer, cfg config.MessageConfig, moderator *moderation.Pipeline) *MessageService {
+	editWindow := cfg.EditWindow
+	if editWindow <= 0 {
+		editWindow = defaultEditWindow
+	}
+	recallWindow := cfg.RecallWindow
+	if recallWindow <= 0 {
+		recallWindow = defaultRecallWindow
+	}
 	return &MessageService{
 		DB:            db,
 		KafkaProducer: kafkaProducer,
+		editWindow:    editWindow,
+		recallWindow:  recallWindow,
+		moderator:     moderator,
 	}
 }
 
 func (m *MessageService) SendP2PMessage(ctx context.Context, req *SendP2PMessageRequest) (*websocket.MessageResponse, error) {
-	// 1. 检查接收者是否是发送者的朋友
+	// 1. 检查发送者没有被封禁，接收者是发送者的朋友
+	if err := ensureUserActive(m.DB.WithContext(ctx), req.SenderID); err != nil {
+		return nil, err
+	}
 	var friendship types.Friends
 	if err := m.DB.Where("user_id = ? AND friend_id = ?", req.SenderID, req.ReceiverID).First(&friendship).Error; err != nil {
 		return nil, err
 	}
+	// 回复的消息必须属于同一会话
+	if req.ReplyToID != nil {
+		if _, err := resolveReply(m.DB, types.ChatTypeP2P, *req.ReplyToID, req.SenderID, req.ReceiverID, uuid.Nil); err != nil {
+			return nil, err
+		}
+	}
+	// 落库前审核，被拦截时直接返回
+	moderationInput := &moderation.Input{
+		SenderID:    req.SenderID,
+		ChatType:    types.ChatTypeP2P,
+		ReceiverID:  req.ReceiverID,
+		Content:     req.Content,
+		ContentType: req.ContentType,
+	}
+	decision, err := m.moderate(ctx, moderationInput)
+	if err != nil {
+		return nil, err
+	}
 
 	// 2. 创建消息struct
 	message := types.P2PMessages{
@@ -54,107 +101,172 @@ func (m *MessageService) SendP2PMessage(ctx context.Context, req *SendP2PMessage
 		Receiver:    types.Users{ID: req.ReceiverID},
 		Content:     req.Content,
 		ContentType: req.ContentType,
+		ReplyToID:   req.ReplyToID,
 		CreatedAt:   time.Now(),
 	}
-	// 3. 将消息发送到Kafka
+	// 3. 将消息存储到db.
+	result := m.DB.Create(&message)
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to save message to database", "error", result.Error)
+		return nil, result.Error
+	}
+	// 被标记的消息照常发送，审核记录进入复核队列
+	if err := m.saveModerationRecord(m.DB.WithContext(ctx), moderationInput, decision, &message.ID); err != nil {
+		slog.ErrorContext(ctx, "Failed to save moderation record", "error", err)
+	}
+	// 4. 落库后再发送到Kafka，保证下游拿到的消息带有ID
 	kafkaPayload := kafka.MessagePayload{
 		Type:      "p2p_message",
 		Data:      message,
 		Timestamp: time.Now().Unix(),
 	}
 	if err := m.KafkaProducer.SendMessage(ctx, "p2p_message", "p2p_message", kafkaPayload); err != nil {
-		slog.Error("Failed to send message to Kafka", "error", err)
+		slog.ErrorContext(ctx, "Failed to send message to Kafka", "error", err)
 	}
-	// 4. 将消息存储到db.
-	result := m.DB.Create(&message)
-	if result.Error != nil {
-		slog.Error("Failed to save message to database", "error", result.Error)
-		return nil, result.Error
+	// 5. 更新会话和未读数，并查询接收者是否开启了免打扰
+	var mutedUserIDs []uuid.UUID
+	conversationID, err := m.updateP2PConversation(message)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to update conversation", "error", err)
+	} else {
+		mutedUserIDs, err = m.mutedParticipants(conversationID, []uuid.UUID{req.ReceiverID})
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to load mute settings", "error", err)
+		}
 	}
-	// 5. 返回消息结构
+	// 6. 返回消息结构
 	return &websocket.MessageResponse{
-		ID:        message.ID,
-		Success:   true,
-		Error:     "",
-		Timestamp: time.Now().Unix(),
+		ID:           message.ID,
+		Success:      true,
+		Error:        "",
+		MutedUserIDs: mutedUserIDs,
+		Timestamp:    time.Now().Unix(),
 	}, nil
 }
 
 func (m *MessageService) SendGroupMessage(ctx context.Context, req *SendGroupMessageRequest) (*websocket.MessageResponse, error) {
-	// 1. 检查发送者是否是群成员
+	// 1. 检查发送者没有被封禁，并且是群成员
+	if err := ensureUserActive(m.DB.WithContext(ctx), req.SenderID); err != nil {
+		return nil, err
+	}
 	var groupMember types.GroupMembers
 	if err := m.DB.Where("user_id = ? AND group_id = ?", req.SenderID, req.GroupID).First(&groupMember).Error; err != nil {
 		return nil, err
 	}
-
-	// 2. 创建消息struct
-	groupMessage := types.GroupMessages{
+	// 回复群消息时归入被回复消息所在的话题
+	var threadRootID *uuid.UUID
+	if req.ReplyToID != nil {
+		rootID, err := resolveReply(m.DB, types.ChatTypeGroup, *req.ReplyToID, req.SenderID, uuid.Nil, req.GroupID)
+		if err != nil {
+			return nil, err
+		}
+		threadRootID = rootID
+	}
+	// 解析@，只保留群成员
+	mentions, mentionAll, err := resolveMentions(m.DB, req.GroupID, groupMember, req.Content)
+	if err != nil {
+		return nil, err
+	}
+	// 落库前审核，被拦截时直接返回
+	moderationInput := &moderation.Input{
 		SenderID:    req.SenderID,
-		Sender:      types.Users{ID: req.SenderID},
+		ChatType:    types.ChatTypeGroup,
 		GroupID:     req.GroupID,
-		Group:       types.Groups{ID: req.GroupID},
 		Content:     req.Content,
 		ContentType: req.ContentType,
-		CreatedAt:   time.Now(),
 	}
-	// 3. 将消息发送到Kafka
+	decision, err := m.moderate(ctx, moderationInput)
+	if err != nil {
+		return nil, err
+	}
+
+	// 2. 创建消息struct
+	groupMessage := types.GroupMessages{
+		SenderID:     req.SenderID,
+		Sender:       types.Users{ID: req.SenderID},
+		GroupID:      req.GroupID,
+		Group:        types.Groups{ID: req.GroupID},
+		Content:      req.Content,
+		ContentType:  req.ContentType,
+		ReplyToID:    req.ReplyToID,
+		ThreadRootID: threadRootID,
+		Mentions:     mentions,
+		MentionAll:   mentionAll,
+		CreatedAt:    time.Now(),
+	}
+	// 3. 将消息存储到db.
+	result := m.DB.Create(&groupMessage)
+	if result.Error != nil {
+		slog.ErrorContext(ctx, "Failed to save message to database", "error", result.Error)
+		return nil, result.Error
+	}
+	// 被标记的消息照常发送，审核记录进入复核队列
+	if err := m.saveModerationRecord(m.DB.WithContext(ctx), moderationInput, decision, &groupMessage.ID); err != nil {
+		slog.ErrorContext(ctx, "Failed to save moderation record", "error", err)
+	}
+	// 4. 落库后再发送到Kafka，保证下游拿到的消息带有ID
 	kafkaPayload := kafka.MessagePayload{
 		Type:      "group_message",
 		Data:      groupMessage,
 		Timestamp: time.Now().Unix(),
 	}
 	if err := m.KafkaProducer.SendMessage(ctx, "group_message", "group_message", kafkaPayload); err != nil {
-		slog.Error("Failed to send message to Kafka", "error", err)
+		slog.ErrorContext(ctx, "Failed to send message to Kafka", "error", err)
 	}
-	// 4. 将消息存储到db.
-	result := m.DB.Create(&groupMessage)
-	if result.Error != nil {
-		slog.Error("Failed to save message to database", "error", result.Error)
-		return nil, result.Error
+	if err := m.incrementMentionUnread(&groupMessage); err != nil {
+		slog.ErrorContext(ctx, "Failed to update mention unread count", "error", err)
+	}
+	mutedUserIDs, err := m.mutedGroupMembers(req.GroupID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load mute settings", "error", err)
 	}
 	// 5. 返回消息结构
 	return &websocket.MessageResponse{
-		ID:        groupMessage.ID,
-		Success:   true,
-		Error:     "",
-		Timestamp: time.Now().Unix(),
+		ID:           groupMessage.ID,
+		Success:      true,
+		Error:        "",
+		ThreadRootID: threadRootID,
+		Mentions:     mentions,
+		MentionAll:   mentionAll,
+		MutedUserIDs: mutedUserIDs,
+		Timestamp:    time.Now().Unix(),
 	}, nil
 }
 
-func (s *MessageService) GetP2PMessages(senderID, receiverID uuid.UUID, offset, limit int) ([]types.P2PMessages, error) {
+// GetP2PMessages 返回两人之间的消息，senderID为查询者，其"仅对自己删除"的消息会被过滤。
+// 被编辑的消息返回最新版本(Edited=true)，被撤回的消息以墓碑形式返回(Recalled=true，内容为空)，
+// 每条消息附带表情回应的聚合结果和被回复消息的摘要
+func (s *MessageService) GetP2PMessages(senderID, receiverID uuid.UUID, offset, limit int) ([]P2PMessageView, error) {
 	var messages []types.P2PMessages
-	err := s.DB.Where("sender_id = ? AND receiver_id = ?", senderID, receiverID).
-		Or("sender_id = ? AND receiver_id = ?", receiverID, senderID).
+	err := s.DB.Where("(sender_id = ? AND receiver_id = ?) OR (sender_id = ? AND receiver_id = ?)", senderID, receiverID, receiverID, senderID).
+		Where("id NOT IN (SELECT message_id FROM message_deletions WHERE user_id = ?)", senderID).
 		Order("created_at DESC").
 		Offset(offset).Limit(limit).
 		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
 
-	return messages, err
+	return s.buildP2PViews(messages)
 }
 
-func (s *MessageService) GetGroupMessages(groupID uuid.UUID, offset, limit int) ([]types.GroupMessages, error) {
+// GetGroupMessages 返回群消息，过滤掉userID"仅对自己删除"的消息
+func (s *MessageService) GetGroupMessages(userID, groupID uuid.UUID, offset, limit int) ([]GroupMessageView, error) {
 	var messages []types.GroupMessages
 	err := s.DB.Where("group_id = ?", groupID).
+		Where("id NOT IN (SELECT message_id FROM message_deletions WHERE user_id = ?)", userID).
 		Order("created_at DESC").
 		Offset(offset).Limit(limit).
 		Find(&messages).Error
+	if err != nil {
+		return nil, err
+	}
 
-	return messages, err
-}
-
-func (s *MessageService) GetUserConversations(userID uuid.UUID) ([]types.Conversations, error) {
-	var conversations []types.Conversations
-	err := s.DB.Preload("LastMessage").Preload("Participants").
-		Where("p2p_user1 = ? OR p2p_user2 = ?", userID, userID).
-		Or("id IN (SELECT conversation_id FROM conversation_participants WHERE user_id = ?)", userID).
-		Order("updated_at DESC").
-		Find(&conversations).Error
-
-	return conversations, err
+	return s.buildGroupViews(messages)
 }
 
-// 更新P2P会话
-func (s *MessageService) updateP2PConversation(message types.P2PMessages) {
+// 更新P2P会话，返回会话ID
+func (s *MessageService) updateP2PConversation(message types.P2PMessages) (uuid.UUID, error) {
 	var conversation types.Conversations
 
 	// 查找现有会话（确保user1 < user2的顺序）
@@ -163,58 +275,90 @@ func (s *MessageService) updateP2PConversation(message types.P2PMessages) {
 		user1, user2 = user2, user1
 	}
 
-	err := s.DB.Where("p2p_user1 = ? AND p2p_user2 = ?", user1, user2).First(&conversation).Error
+	err := s.DB.Where("p2p_user1_id = ? AND p2p_user2_id = ?", user1, user2).First(&conversation).Error
 
-	if err == gorm.ErrRecordNotFound {
-		// 创建新会话
-		conversation = types.Conversations{
-			P2PUser1:      user1,
-			P2PUser2:      user2,
-			LastMessageID: message.ID,
-			CreatedAt:     time.Now(),
-			UpdatedAt:     time.Now(),
-		}
-		s.DB.Create(&conversation)
-
-		// 创建参与者记录
-		participants := []types.ConversationParticipants{
-			{
-				ConversationID: conversation.ID,
-				UserID:         user1,
-				UnreadCount:    0,
-				CreatedAt:      time.Now(),
-				UpdatedAt:      time.Now(),
-			},
-			{
-				ConversationID: conversation.ID,
-				UserID:         user2,
-				UnreadCount:    1, // 接收者的未读数+1
-				CreatedAt:      time.Now(),
-				UpdatedAt:      time.Now(),
-			},
-		}
-		s.DB.Create(&participants)
-	} else {
-		// 更新现有会话
-		conversation.LastMessageID = message.ID
-		conversation.UpdatedAt = time.Now()
-		s.DB.Save(&conversation)
-
-		// 更新接收者的未读计数
-		s.DB.Model(&types.ConversationParticipants{}).
-			Where("conversation_id = ? AND user_id = ?", conversation.ID, message.ReceiverID).
-			Updates(map[string]interface{}{
-				"unread_count": gorm.Expr("unread_count + 1"),
-				"updated_at":   time.Now(),
-			})
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		err := s.DB.Transaction(func(tx *gorm.DB) error {
+			// 创建新会话
+			conversation = types.Conversations{
+				P2PUser1:      user1,
+				P2PUser2:      user2,
+				LastMessageID: message.ID,
+				CreatedAt:     time.Now(),
+				UpdatedAt:     time.Now(),
+			}
+			if err := tx.Omit("LastMessage", "Participants").Create(&conversation).Error; err != nil {
+				return err
+			}
+
+			// 创建参与者记录，接收者的未读数为1
+			participants := []types.ConversationParticipants{
+				{
+					ConversationID: conversation.ID,
+					UserID:         message.SenderID,
+					UnreadCount:    0,
+					CreatedAt:      time.Now(),
+					UpdatedAt:      time.Now(),
+				},
+				{
+					ConversationID: conversation.ID,
+					UserID:         message.ReceiverID,
+					UnreadCount:    1,
+					CreatedAt:      time.Now(),
+					UpdatedAt:      time.Now(),
+				},
+			}
+			return tx.Create(&participants).Error
+		})
+		return conversation.ID, err
+	}
+	if err != nil {
+		return uuid.Nil, err
+	}
+
+	// 更新现有会话
+	if err := s.DB.Model(&types.Conversations{}).
+		Where("id = ?", conversation.ID).
+		Updates(map[string]interface{}{
+			"last_message_id": message.ID,
+			"updated_at":      time.Now(),
+		}).Error; err != nil {
+		return conversation.ID, err
 	}
+
+	// 更新接收者的未读计数
+	err = s.DB.Model(&types.ConversationParticipants{}).
+		Where("conversation_id = ? AND user_id = ?", conversation.ID, message.ReceiverID).
+		Updates(map[string]interface{}{
+			"unread_count": gorm.Expr("unread_count + 1"),
+			"updated_at":   time.Now(),
+		}).Error
+	return conversation.ID, err
 }
 
 func (s *MessageService) MarkMessagesAsRead(userID, conversationID uuid.UUID) error {
 	return s.DB.Model(&types.ConversationParticipants{}).
 		Where("conversation_id = ? AND user_id = ?", conversationID, userID).
 		Updates(map[string]interface{}{
-			"unread_count": 0,
-			"updated_at":   time.Now(),
+			"unread_count":         0,
+			"mention_unread_count": 0,
+			"marked_unread":        false,
+			"updated_at":           time.Now(),
 		}).Error
 }
+
+func (s *MessageService) IsGroupMember(userID, groupID uuid.UUID) (bool, error) {
+	var count int64
+	err := s.DB.Model(&types.GroupMembers{}).
+		Where("user_id = ? AND group_id = ?", userID, groupID).
+		Count(&count).Error
+	return count > 0, err
+}
+
+func (s *MessageService) GetGroupMemberIDs(groupID uuid.UUID) ([]uuid.UUID, error) {
+	var members []uuid.UUID
+	err := s.DB.Model(&types.GroupMembers{}).
+		Where("group_id = ?", groupID).
+		Pluck("user_id", &members).Error
+	return members, err
+}
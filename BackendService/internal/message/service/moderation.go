@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/gateway/websocket"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/message/moderation"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 审核复核的处理方式
+const (
+	ModerationDecisionApprove = "approve"
+	ModerationDecisionRemove  = "remove"
+)
+
+var (
+	ErrMessageBlocked            = errors.New("message blocked by moderation")
+	ErrModerationRecordNotFound  = errors.New("moderation record not found")
+	ErrModerationAlreadyReviewed = errors.New("moderation record has already been reviewed")
+	ErrInvalidModerationDecision = errors.New("decision must be approve or remove")
+	ErrInvalidModerationFilter   = errors.New("invalid moderation status or action")
+)
+
+type ReviewModerationRequest struct {
+	RecordID   uuid.UUID `json:"-"`
+	ReviewerID uuid.UUID `json:"-"`
+	Decision   string    `json:"decision" binding:"required"`
+	Note       string    `json:"note"`
+}
+
+// moderate 消息落库前审核。被拦截时保存审核记录并返回ErrMessageBlocked，
+// 被标记时由调用方在消息落库后调用saveModerationRecord
+func (m *MessageService) moderate(ctx context.Context, in *moderation.Input) (*moderation.Decision, error) {
+	decision := m.moderator.Moderate(ctx, in)
+	if decision.Action != moderation.ActionBlock {
+		return decision, nil
+	}
+	// 拦截记录不能放在调用方的事务里，事务回滚时记录也会丢失
+	if err := m.saveModerationRecord(m.DB.WithContext(ctx), in, decision, nil); err != nil {
+		slog.ErrorContext(ctx, "Failed to save moderation record", "error", err)
+	}
+	slog.InfoContext(ctx, "Message blocked by moderation", "rules", decision.Rules())
+	return nil, fmt.Errorf("%w: %s", ErrMessageBlocked, decision.Reason())
+}
+
+// saveModerationRecord 保存被拦截或标记的消息，放行的消息不记录
+func (m *MessageService) saveModerationRecord(tx *gorm.DB, in *moderation.Input, decision *moderation.Decision, messageID *uuid.UUID) error {
+	if decision == nil || decision.Action == moderation.ActionAllow {
+		return nil
+	}
+	record := types.ModerationRecords{
+		MessageID:   messageID,
+		ChatType:    in.ChatType,
+		SenderID:    in.SenderID,
+		Content:     in.Content,
+		ContentType: in.ContentType,
+		Action:      decision.Action,
+		Rules:       decision.Rules(),
+		Reason:      decision.Reason(),
+		Status:      types.ModerationStatusPending,
+		CreatedAt:   time.Now(),
+	}
+	if in.ReceiverID != uuid.Nil {
+		record.ReceiverID = &in.ReceiverID
+	}
+	if in.GroupID != uuid.Nil {
+		record.GroupID = &in.GroupID
+	}
+	return tx.Create(&record).Error
+}
+
+// ListModerationRecords 审核队列，按时间倒序。status和action为空时不过滤
+func (s *AdminService) ListModerationRecords(ctx context.Context, status, action string, offset, limit int) ([]types.ModerationRecords, error) {
+	query := s.DB.WithContext(ctx).Model(&types.ModerationRecords{})
+	if status != "" {
+		switch status {
+		case types.ModerationStatusPending, types.ModerationStatusApproved, types.ModerationStatusRemoved:
+		default:
+			return nil, ErrInvalidModerationFilter
+		}
+		query = query.Where("status = ?", status)
+	}
+	if action != "" {
+		if action != moderation.ActionFlag && action != moderation.ActionBlock {
+			return nil, ErrInvalidModerationFilter
+		}
+		query = query.Where("action = ?", action)
+	}
+
+	var records []types.ModerationRecords
+	err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&records).Error
+	return records, err
+}
+
+// ReviewModeration 复核一条审核记录：approve保留消息，remove撤回已经发出的消息。
+// 被拦截的消息没有落库，remove只更新记录状态
+func (s *AdminService) ReviewModeration(ctx context.Context, req *ReviewModerationRequest) (*types.ModerationRecords, error) {
+	var status string
+	switch req.Decision {
+	case ModerationDecisionApprove:
+		status = types.ModerationStatusApproved
+	case ModerationDecisionRemove:
+		status = types.ModerationStatusRemoved
+	default:
+		return nil, ErrInvalidModerationDecision
+	}
+
+	var record types.ModerationRecords
+	var recalled *websocket.MessageUpdateResponse
+	err := s.DB.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// 1. 加锁读取记录，防止两个管理员同时复核
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", req.RecordID).Take(&record).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrModerationRecordNotFound
+			}
+			return err
+		}
+		if record.Status != types.ModerationStatusPending {
+			return ErrModerationAlreadyReviewed
+		}
+
+		// 2. 撤回消息，已经被发送者撤回的消息不需要再处理
+		if status == types.ModerationStatusRemoved && record.MessageID != nil {
+			message, err := loadMessage(tx, record.ChatType, *record.MessageID, true)
+			if err != nil && !errors.Is(err, ErrMessageNotFound) {
+				return err
+			}
+			if message != nil && !message.Recalled {
+				if err := recallRecord(tx, record.ChatType, message); err != nil {
+					return err
+				}
+				recalled = toUpdateResponse(record.ChatType, message, 0)
+			}
+		}
+
+		// 3. 更新记录状态并写入审计日志
+		now := time.Now()
+		record.Status = status
+		record.ReviewerID = &req.ReviewerID
+		record.ReviewNote = req.Note
+		record.ReviewedAt = &now
+		if err := tx.Model(&types.ModerationRecords{}).Where("id = ?", record.ID).Updates(map[string]interface{}{
+			"status":      record.Status,
+			"reviewer_id": record.ReviewerID,
+			"review_note": record.ReviewNote,
+			"reviewed_at": record.ReviewedAt,
+		}).Error; err != nil {
+			return err
+		}
+		return writeAuditLog(tx, req.ReviewerID, types.AuditActionReviewModeration, types.AuditTargetModeration, record.ID, req.Note, "decision "+req.Decision)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if recalled != nil {
+		s.messages.publishMessageUpdate(ctx, "message_recalled", recalled)
+	}
+	slog.InfoContext(ctx, "Moderation record reviewed", "record_id", record.ID, "reviewer_id", req.ReviewerID, "decision", req.Decision)
+	return &record, nil
+}
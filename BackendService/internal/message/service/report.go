@@ -0,0 +1,171 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"gorm.io/gorm"
+)
+
+// 举报原因
+const (
+	ReportReasonSpam       = "spam"
+	ReportReasonHarassment = "harassment"
+	ReportReasonHate       = "hate"
+	ReportReasonSexual     = "sexual"
+	ReportReasonViolence   = "violence"
+	ReportReasonOther      = "other"
+)
+
+var (
+	ErrInvalidReportReason = errors.New("invalid report reason")
+	ErrCannotReportSelf    = errors.New("cannot report yourself")
+	ErrDuplicateReport     = errors.New("you have already reported this and it is still open")
+	ErrUserNotFound        = errors.New("user not found")
+	ErrUserRestricted      = errors.New("user is suspended or banned")
+)
+
+type ReportUserRequest struct {
+	ReporterID uuid.UUID `json:"-"`
+	UserID     uuid.UUID `json:"-"`
+	Reason     string    `json:"reason" binding:"required"`
+	Details    string    `json:"details"`
+}
+
+type ReportMessageRequest struct {
+	ReporterID uuid.UUID `json:"-"`
+	ChatType   string    `json:"-"`
+	MessageID  uuid.UUID `json:"-"`
+	Reason     string    `json:"reason" binding:"required"`
+	Details    string    `json:"details"`
+}
+
+func validReportReason(reason string) bool {
+	switch reason {
+	case ReportReasonSpam, ReportReasonHarassment, ReportReasonHate,
+		ReportReasonSexual, ReportReasonViolence, ReportReasonOther:
+		return true
+	default:
+		return false
+	}
+}
+
+// ReportUser 举报用户，同一举报者对同一用户只能有一个未处理的举报
+func (m *MessageService) ReportUser(ctx context.Context, req *ReportUserRequest) (*types.Reports, error) {
+	if !validReportReason(req.Reason) {
+		return nil, ErrInvalidReportReason
+	}
+	if req.ReporterID == req.UserID {
+		return nil, ErrCannotReportSelf
+	}
+	db := m.DB.WithContext(ctx)
+
+	var count int64
+	if err := db.Model(&types.Users{}).Where("id = ?", req.UserID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrUserNotFound
+	}
+	duplicate, err := hasOpenReport(db.Where("target_type = ? AND target_user_id = ?", types.ReportTargetUser, req.UserID), req.ReporterID)
+	if err != nil {
+		return nil, err
+	}
+	if duplicate {
+		return nil, ErrDuplicateReport
+	}
+
+	report := types.Reports{
+		ReporterID:   req.ReporterID,
+		TargetType:   types.ReportTargetUser,
+		TargetUserID: req.UserID,
+		Reason:       req.Reason,
+		Details:      req.Details,
+		Status:       types.ReportStatusOpen,
+		CreatedAt:    time.Now(),
+	}
+	if err := db.Create(&report).Error; err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "User reported", "report_id", report.ID, "target_user_id", req.UserID, "reason", req.Reason)
+	return &report, nil
+}
+
+// ReportMessage 举报消息，只有能看到这条消息的会话参与者可以举报
+func (m *MessageService) ReportMessage(ctx context.Context, req *ReportMessageRequest) (*types.Reports, error) {
+	if !validReportReason(req.Reason) {
+		return nil, ErrInvalidReportReason
+	}
+	db := m.DB.WithContext(ctx)
+
+	// 1. 校验消息和举报者
+	record, err := loadMessage(db, req.ChatType, req.MessageID, false)
+	if err != nil {
+		return nil, err
+	}
+	if record.SenderID == req.ReporterID {
+		return nil, ErrCannotReportSelf
+	}
+	ok, err := isParticipant(db, req.ChatType, record, req.ReporterID)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, ErrNotParticipant
+	}
+	duplicate, err := hasOpenReport(db.Where("message_id = ?", req.MessageID), req.ReporterID)
+	if err != nil {
+		return nil, err
+	}
+	if duplicate {
+		return nil, ErrDuplicateReport
+	}
+
+	// 2. 保存举报时的消息内容
+	report := types.Reports{
+		ReporterID:   req.ReporterID,
+		TargetType:   types.ReportTargetMessage,
+		TargetUserID: record.SenderID,
+		MessageID:    &record.ID,
+		ChatType:     req.ChatType,
+		Content:      record.Content,
+		Reason:       req.Reason,
+		Details:      req.Details,
+		Status:       types.ReportStatusOpen,
+		CreatedAt:    time.Now(),
+	}
+	if err := db.Create(&report).Error; err != nil {
+		return nil, err
+	}
+	slog.InfoContext(ctx, "Message reported", "report_id", report.ID, "message_id", req.MessageID, "reason", req.Reason)
+	return &report, nil
+}
+
+// hasOpenReport query为举报对象的过滤条件
+func hasOpenReport(query *gorm.DB, reporterID uuid.UUID) (bool, error) {
+	var count int64
+	err := query.Model(&types.Reports{}).
+		Where("reporter_id = ? AND status = ?", reporterID, types.ReportStatusOpen).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// ensureUserActive 被封禁或禁言中的用户不能发送和编辑消息
+func ensureUserActive(tx *gorm.DB, userID uuid.UUID) error {
+	var user types.Users
+	err := tx.Select("id", "status", "suspended_until").Where("id = ?", userID).Take(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+	if user.Restricted(time.Now()) {
+		return ErrUserRestricted
+	}
+	return nil
+}
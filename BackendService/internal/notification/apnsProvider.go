@@ -0,0 +1,150 @@
+package notification
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/huangrao121/CommunicationApp/BackendService/config"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+)
+
+const (
+	apnsProductionHost  = "https://api.push.apple.com"
+	apnsDevelopmentHost = "https://api.sandbox.push.apple.com"
+	// Apple要求认证token在20~60分钟之间刷新
+	apnsTokenTTL = 50 * time.Minute
+	// apns-collapse-id最长64字节
+	apnsMaxCollapseID = 64
+)
+
+// APNsProvider 通过HTTP/2和token认证(.p8私钥)向Apple推送服务发送通知
+type APNsProvider struct {
+	host       string
+	topic      string
+	keyID      string
+	teamID     string
+	key        *ecdsa.PrivateKey
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenIssued time.Time
+}
+
+func NewAPNsProvider(cfg config.APNsConfig) (*APNsProvider, error) {
+	keyData, err := os.ReadFile(cfg.KeyPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read apns key: %w", err)
+	}
+	key, err := jwt.ParseECPrivateKeyFromPEM(keyData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse apns key: %w", err)
+	}
+
+	host := apnsDevelopmentHost
+	if cfg.Production {
+		host = apnsProductionHost
+	}
+	return &APNsProvider{
+		host:   host,
+		topic:  cfg.Topic,
+		keyID:  cfg.KeyID,
+		teamID: cfg.TeamID,
+		key:    key,
+		// 标准库对https自动协商HTTP/2
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (p *APNsProvider) Platform() string {
+	return types.PlatformAPNs
+}
+
+func (p *APNsProvider) Send(ctx context.Context, device *types.DeviceTokens, n *Notification) error {
+	token, err := p.authToken()
+	if err != nil {
+		return err
+	}
+
+	payload := map[string]interface{}{
+		"aps": map[string]interface{}{
+			"alert": map[string]string{
+				"title": n.Title,
+				"body":  truncateBody(n.Body),
+			},
+			"sound":     "default",
+			"thread-id": n.CollapseKey,
+		},
+	}
+	for k, v := range n.Data {
+		payload[k] = v
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/3/device/%s", p.host, device.Token), bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	httpReq.Header.Set("authorization", "bearer "+token)
+	httpReq.Header.Set("apns-topic", p.topic)
+	httpReq.Header.Set("apns-push-type", "alert")
+	httpReq.Header.Set("apns-priority", "10")
+	if n.CollapseKey != "" && len(n.CollapseKey) <= apnsMaxCollapseID {
+		httpReq.Header.Set("apns-collapse-id", n.CollapseKey)
+	}
+
+	resp, err := p.httpClient.Do(httpReq)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusOK {
+		return nil
+	}
+	var errResp struct {
+		Reason string `json:"reason"`
+	}
+	json.NewDecoder(resp.Body).Decode(&errResp)
+	// 410表示设备已注销，BadDeviceToken表示token和环境不匹配或格式错误
+	if resp.StatusCode == http.StatusGone || errResp.Reason == "BadDeviceToken" || errResp.Reason == "Unregistered" {
+		return ErrInvalidToken
+	}
+	return fmt.Errorf("apns returned status %d: %s", resp.StatusCode, errResp.Reason)
+}
+
+// authToken 返回缓存的provider token，过期前重新签发
+func (p *APNsProvider) authToken() (string, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.token != "" && time.Since(p.tokenIssued) < apnsTokenTTL {
+		return p.token, nil
+	}
+
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": p.teamID,
+		"iat": now.Unix(),
+	}
+	t := jwt.NewWithClaims(jwt.SigningMethodES256, claims)
+	t.Header["kid"] = p.keyID
+	signed, err := t.SignedString(p.key)
+	if err != nil {
+		return "", fmt.Errorf("failed to sign apns token: %w", err)
+	}
+	p.token = signed
+	p.tokenIssued = now
+	return signed, nil
+}
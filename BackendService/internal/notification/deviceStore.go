@@ -0,0 +1,93 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	ErrInvalidPlatform    = errors.New("invalid platform")
+	ErrMissingWebPushKeys = errors.New("web push subscription requires p256dh and auth keys")
+	ErrDeviceNotFound     = errors.New("device not found")
+)
+
+type RegisterDeviceRequest struct {
+	Platform   string `json:"platform" binding:"required"`
+	Token      string `json:"token" binding:"required"`
+	P256dh     string `json:"p256dh"`
+	Auth       string `json:"auth"`
+	AppVersion string `json:"app_version"`
+}
+
+type DeviceStore struct {
+	db *gorm.DB
+}
+
+func NewDeviceStore(db *gorm.DB) *DeviceStore {
+	return &DeviceStore{db: db}
+}
+
+// Register 注册或刷新设备凭证。同一个token换了登录账号时归属到新用户
+func (s *DeviceStore) Register(ctx context.Context, userID uuid.UUID, req *RegisterDeviceRequest) (*types.DeviceTokens, error) {
+	switch req.Platform {
+	case types.PlatformAPNs, types.PlatformFCM:
+	case types.PlatformWebPush:
+		if req.P256dh == "" || req.Auth == "" {
+			return nil, ErrMissingWebPushKeys
+		}
+	default:
+		return nil, ErrInvalidPlatform
+	}
+
+	device := types.DeviceTokens{
+		UserID:     userID,
+		Platform:   req.Platform,
+		Token:      req.Token,
+		P256dh:     req.P256dh,
+		Auth:       req.Auth,
+		AppVersion: req.AppVersion,
+		CreatedAt:  time.Now(),
+		UpdatedAt:  time.Now(),
+	}
+	err := s.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "platform"}, {Name: "token"}},
+		DoUpdates: clause.AssignmentColumns([]string{"user_id", "p256dh", "auth", "app_version", "updated_at"}),
+	}).Create(&device).Error
+	if err != nil {
+		return nil, err
+	}
+
+	// 冲突更新时device.ID不会被回填，重新查询一次
+	if err := s.db.WithContext(ctx).Where("platform = ? AND token = ?", req.Platform, req.Token).First(&device).Error; err != nil {
+		return nil, err
+	}
+	return &device, nil
+}
+
+func (s *DeviceStore) Unregister(ctx context.Context, userID, deviceID uuid.UUID) error {
+	result := s.db.WithContext(ctx).Where("id = ? AND user_id = ?", deviceID, userID).Delete(&types.DeviceTokens{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrDeviceNotFound
+	}
+	return nil
+}
+
+func (s *DeviceStore) ListByUser(ctx context.Context, userID uuid.UUID) ([]types.DeviceTokens, error) {
+	var devices []types.DeviceTokens
+	err := s.db.WithContext(ctx).Where("user_id = ?", userID).Order("updated_at DESC").Find(&devices).Error
+	return devices, err
+}
+
+// Delete 推送平台报告token失效时删除设备
+func (s *DeviceStore) Delete(ctx context.Context, deviceID uuid.UUID) error {
+	return s.db.WithContext(ctx).Where("id = ?", deviceID).Delete(&types.DeviceTokens{}).Error
+}
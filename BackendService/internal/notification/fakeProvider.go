@@ -0,0 +1,72 @@
+package notification
+
+import (
+	"context"
+	"sync"
+
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+)
+
+// FakeDelivery FakeProvider记录的一次推送
+type FakeDelivery struct {
+	UserID       string
+	Token        string
+	Notification Notification
+}
+
+// FakeProvider 不访问任何外部服务，只记录推送内容，用于测试和本地开发。
+// InvalidTokens中的token发送时返回ErrInvalidToken，用来模拟设备注销
+type FakeProvider struct {
+	platform string
+
+	mu            sync.Mutex
+	deliveries    []FakeDelivery
+	invalidTokens map[string]bool
+}
+
+func NewFakeProvider(platform string) *FakeProvider {
+	return &FakeProvider{
+		platform:      platform,
+		invalidTokens: make(map[string]bool),
+	}
+}
+
+func (p *FakeProvider) Platform() string {
+	return p.platform
+}
+
+func (p *FakeProvider) Send(ctx context.Context, device *types.DeviceTokens, n *Notification) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.invalidTokens[device.Token] {
+		return ErrInvalidToken
+	}
+	p.deliveries = append(p.deliveries, FakeDelivery{
+		UserID:       device.UserID.String(),
+		Token:        device.Token,
+		Notification: *n,
+	})
+	return nil
+}
+
+// InvalidateToken 之后发往该token的推送都会返回ErrInvalidToken
+func (p *FakeProvider) InvalidateToken(token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.invalidTokens[token] = true
+}
+
+// Deliveries 返回目前为止记录的推送的副本
+func (p *FakeProvider) Deliveries() []FakeDelivery {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]FakeDelivery(nil), p.deliveries...)
+}
+
+func (p *FakeProvider) Reset() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.deliveries = nil
+	p.invalidTokens = make(map[string]bool)
+}
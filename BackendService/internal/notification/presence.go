@@ -0,0 +1,25 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// redisPresence 读取网关在user_sessions:<userID>哈希里记录的在线设备，有任意设备在线即视为在线
+type redisPresence struct {
+	client redis.Cmdable
+}
+
+func NewRedisPresence(client redis.Cmdable) PresenceChecker {
+	return &redisPresence{client: client}
+}
+
+func (p *redisPresence) IsUserOnline(ctx context.Context, userID string) (bool, error) {
+	n, err := p.client.HLen(ctx, fmt.Sprintf("user_sessions:%s", userID)).Result()
+	if err != nil {
+		return false, err
+	}
+	return n > 0, nil
+}
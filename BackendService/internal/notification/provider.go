@@ -0,0 +1,39 @@
+package notification
+
+import (
+	"context"
+	"errors"
+	"unicode/utf8"
+
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+)
+
+// ErrInvalidToken 推送平台确认设备凭证已失效(卸载、注销、过期)，调用方应删除该设备
+var ErrInvalidToken = errors.New("device token is no longer valid")
+
+// 推送内容的最大长度，超出部分截断，避免超过各平台4KB的payload上限
+const maxBodyRunes = 120
+
+// Notification 与平台无关的推送内容
+type Notification struct {
+	Title string
+	Body  string
+	// 同一个CollapseKey的通知在设备上只保留最新的一条，通常是会话维度
+	CollapseKey string
+	// 客户端点击通知后用来跳转的自定义数据
+	Data map[string]string
+}
+
+// Provider 推送平台的抽象，每个平台一个实现
+type Provider interface {
+	Platform() string
+	Send(ctx context.Context, device *types.DeviceTokens, n *Notification) error
+}
+
+func truncateBody(body string) string {
+	if utf8.RuneCountInString(body) <= maxBodyRunes {
+		return body
+	}
+	runes := []rune(body)
+	return string(runes[:maxBodyRunes]) + "…"
+}
@@ -0,0 +1,50 @@
+package notification
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	defaultRateLimit  = 20
+	defaultRateWindow = time.Minute
+)
+
+// RateLimiter 限制单个用户的推送频率，避免群聊刷屏时设备被通知轰炸
+type RateLimiter interface {
+	Allow(ctx context.Context, userID uuid.UUID) (bool, error)
+}
+
+// redisRateLimiter 基于Redis的固定窗口计数，多个worker实例共享同一个计数
+type redisRateLimiter struct {
+	client redis.Cmdable
+	limit  int
+	window time.Duration
+}
+
+func NewRedisRateLimiter(client redis.Cmdable, limit int, window time.Duration) RateLimiter {
+	if limit <= 0 {
+		limit = defaultRateLimit
+	}
+	if window <= 0 {
+		window = defaultRateWindow
+	}
+	return &redisRateLimiter{client: client, limit: limit, window: window}
+}
+
+func (l *redisRateLimiter) Allow(ctx context.Context, userID uuid.UUID) (bool, error) {
+	windowStart := time.Now().UnixNano() / int64(l.window)
+	key := fmt.Sprintf("notification_rate:%s:%d", userID, windowStart)
+
+	pipe := l.client.TxPipeline()
+	incr := pipe.Incr(ctx, key)
+	pipe.Expire(ctx, key, l.window)
+	if _, err := pipe.Exec(ctx); err != nil {
+		return false, err
+	}
+	return incr.Val() <= int64(l.limit), nil
+}
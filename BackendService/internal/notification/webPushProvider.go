@@ -0,0 +1,94 @@
+package notification
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/SherClockHolmes/webpush-go"
+	"github.com/huangrao121/CommunicationApp/BackendService/config"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+)
+
+// 推送服务在用户离线时保留通知的时间(秒)
+const webPushTTL = 24 * 60 * 60
+
+// WebPushProvider 使用VAPID认证向浏览器推送服务发送加密通知
+type WebPushProvider struct {
+	publicKey  string
+	privateKey string
+	subject    string
+	httpClient *http.Client
+}
+
+func NewWebPushProvider(cfg config.WebPushConfig) (*WebPushProvider, error) {
+	if cfg.VAPIDPublicKey == "" || cfg.VAPIDPrivateKey == "" {
+		return nil, errors.New("vapid keys are required")
+	}
+	return &WebPushProvider{
+		publicKey:  cfg.VAPIDPublicKey,
+		privateKey: cfg.VAPIDPrivateKey,
+		subject:    cfg.Subject,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+func (p *WebPushProvider) Platform() string {
+	return types.PlatformWebPush
+}
+
+func (p *WebPushProvider) Send(ctx context.Context, device *types.DeviceTokens, n *Notification) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"title": n.Title,
+		"body":  truncateBody(n.Body),
+		"tag":   n.CollapseKey,
+		"data":  n.Data,
+	})
+	if err != nil {
+		return err
+	}
+
+	resp, err := webpush.SendNotificationWithContext(ctx, payload, &webpush.Subscription{
+		Endpoint: device.Token,
+		Keys: webpush.Keys{
+			Auth:   device.Auth,
+			P256dh: device.P256dh,
+		},
+	}, &webpush.Options{
+		HTTPClient:      p.httpClient,
+		Subscriber:      p.subject,
+		VAPIDPublicKey:  p.publicKey,
+		VAPIDPrivateKey: p.privateKey,
+		Topic:           webPushTopic(n.CollapseKey),
+		TTL:             webPushTTL,
+		Urgency:         webpush.UrgencyHigh,
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode >= 200 && resp.StatusCode < 300:
+		return nil
+	case resp.StatusCode == http.StatusNotFound || resp.StatusCode == http.StatusGone:
+		// 订阅已过期或被用户取消
+		return ErrInvalidToken
+	default:
+		return fmt.Errorf("web push returned status %d", resp.StatusCode)
+	}
+}
+
+// webPushTopic Topic头最长32个URL安全的base64字符，collapse key取哈希后截断
+func webPushTopic(collapseKey string) string {
+	if collapseKey == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(collapseKey))
+	return base64.RawURLEncoding.EncodeToString(sum[:24])
+}
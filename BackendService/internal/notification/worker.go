@@ -0,0 +1,264 @@
+package notification
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/kafka"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"gorm.io/gorm"
+)
+
+// PresenceChecker 查询用户是否在线，见NewRedisPresence
+type PresenceChecker interface {
+	IsUserOnline(ctx context.Context, userID string) (bool, error)
+}
+
+// Worker 消费消息topic，给离线的接收者推送通知
+type Worker struct {
+	db        *gorm.DB
+	presence  PresenceChecker
+	limiter   RateLimiter
+	devices   *DeviceStore
+	providers map[string]Provider
+}
+
+func NewWorker(db *gorm.DB, presence PresenceChecker, limiter RateLimiter, devices *DeviceStore, providers ...Provider) *Worker {
+	w := &Worker{
+		db:        db,
+		presence:  presence,
+		limiter:   limiter,
+		devices:   devices,
+		providers: make(map[string]Provider),
+	}
+	for _, p := range providers {
+		w.providers[p.Platform()] = p
+	}
+	return w
+}
+
+// recipient 一个需要推送的接收者，mentioned为true时忽略免打扰
+type recipient struct {
+	userID    uuid.UUID
+	mentioned bool
+}
+
+// Run 为每个topic启动一个消费者，阻塞直到ctx取消
+func (w *Worker) Run(ctx context.Context, brokers []string, topics []string, groupID string) {
+	var wg sync.WaitGroup
+	for _, topic := range topics {
+		consumer := kafka.NewConsumer(brokers, topic, groupID)
+		wg.Add(1)
+		go func(topic string) {
+			defer wg.Done()
+			defer consumer.Close()
+			slog.InfoContext(ctx, "notification consumer started", "topic", topic, "group", groupID)
+			if err := consumer.Start(ctx, w.HandleMessage); err != nil && !errors.Is(err, context.Canceled) {
+				slog.ErrorContext(ctx, "notification consumer stopped", "topic", topic, "error", err)
+			}
+		}(topic)
+	}
+	wg.Wait()
+}
+
+// HandleMessage 处理一条Kafka消息，编辑、撤回等事件不推送
+func (w *Worker) HandleMessage(ctx context.Context, payload kafka.MessagePayload) error {
+	switch payload.Type {
+	case "p2p_message":
+		var message types.P2PMessages
+		if err := decodePayload(payload.Data, &message); err != nil {
+			return err
+		}
+		return w.handleP2PMessage(ctx, &message)
+	case "group_message":
+		var message types.GroupMessages
+		if err := decodePayload(payload.Data, &message); err != nil {
+			return err
+		}
+		return w.handleGroupMessage(ctx, &message)
+	default:
+		return nil
+	}
+}
+
+func (w *Worker) handleP2PMessage(ctx context.Context, message *types.P2PMessages) error {
+	senderName, err := w.displayName(ctx, message.SenderID)
+	if err != nil {
+		return err
+	}
+
+	n := &Notification{
+		Title:       senderName,
+		Body:        message.Content,
+		CollapseKey: fmt.Sprintf("p2p:%s", message.SenderID),
+		Data: map[string]string{
+			"chat_type":  types.ChatTypeP2P,
+			"message_id": message.ID.String(),
+			"sender_id":  message.SenderID.String(),
+		},
+	}
+	conversations := w.db.Model(&types.Conversations{}).Select("id").
+		Where("(p2p_user1_id = ? AND p2p_user2_id = ?) OR (p2p_user1_id = ? AND p2p_user2_id = ?)",
+			message.SenderID, message.ReceiverID, message.ReceiverID, message.SenderID)
+
+	return w.dispatch(ctx, []recipient{{userID: message.ReceiverID}}, conversations, n)
+}
+
+func (w *Worker) handleGroupMessage(ctx context.Context, message *types.GroupMessages) error {
+	senderName, err := w.displayName(ctx, message.SenderID)
+	if err != nil {
+		return err
+	}
+	var group types.Groups
+	if err := w.db.WithContext(ctx).Select("id", "name").First(&group, "id = ?", message.GroupID).Error; err != nil {
+		return err
+	}
+
+	var memberIDs []uuid.UUID
+	if err := w.db.WithContext(ctx).Model(&types.GroupMembers{}).
+		Where("group_id = ? AND user_id <> ?", message.GroupID, message.SenderID).
+		Pluck("user_id", &memberIDs).Error; err != nil {
+		return err
+	}
+
+	mentioned := make(map[uuid.UUID]bool)
+	for _, mention := range message.Mentions {
+		mentioned[mention.UserID] = true
+	}
+	recipients := make([]recipient, 0, len(memberIDs))
+	for _, id := range memberIDs {
+		recipients = append(recipients, recipient{userID: id, mentioned: message.MentionAll || mentioned[id]})
+	}
+
+	n := &Notification{
+		Title:       group.Name,
+		Body:        fmt.Sprintf("%s: %s", senderName, message.Content),
+		CollapseKey: fmt.Sprintf("group:%s", message.GroupID),
+		Data: map[string]string{
+			"chat_type":  types.ChatTypeGroup,
+			"message_id": message.ID.String(),
+			"sender_id":  message.SenderID.String(),
+			"group_id":   message.GroupID.String(),
+		},
+	}
+	conversations := w.db.Model(&types.Conversations{}).Select("id").Where("group_id = ?", message.GroupID)
+
+	return w.dispatch(ctx, recipients, conversations, n)
+}
+
+// dispatch 过滤掉在线、免打扰和超过频率限制的接收者，把通知发到其余接收者的所有设备
+func (w *Worker) dispatch(ctx context.Context, recipients []recipient, conversations *gorm.DB, n *Notification) error {
+	if len(recipients) == 0 {
+		return nil
+	}
+	muted, err := w.mutedUsers(ctx, recipients, conversations)
+	if err != nil {
+		return err
+	}
+
+	for _, r := range recipients {
+		// 1. 任意设备在线的用户已经通过WebSocket收到消息
+		online, err := w.presence.IsUserOnline(ctx, r.userID.String())
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to check user presence", "user_id", r.userID, "error", err)
+			continue
+		}
+		if online {
+			continue
+		}
+		// 2. 免打扰的会话只有被@时才推送
+		if muted[r.userID] && !r.mentioned {
+			continue
+		}
+		// 3. 频率限制
+		allowed, err := w.limiter.Allow(ctx, r.userID)
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to check notification rate limit", "user_id", r.userID, "error", err)
+			continue
+		}
+		if !allowed {
+			slog.DebugContext(ctx, "Notification rate limited", "user_id", r.userID)
+			continue
+		}
+		// 4. 推送到用户的所有设备
+		w.sendToUser(ctx, r.userID, n)
+	}
+	return nil
+}
+
+func (w *Worker) sendToUser(ctx context.Context, userID uuid.UUID, n *Notification) {
+	devices, err := w.devices.ListByUser(ctx, userID)
+	if err != nil {
+		slog.ErrorContext(ctx, "Failed to load devices", "user_id", userID, "error", err)
+		return
+	}
+
+	for i := range devices {
+		device := &devices[i]
+		provider, ok := w.providers[device.Platform]
+		if !ok {
+			continue
+		}
+		err := provider.Send(ctx, device, n)
+		if errors.Is(err, ErrInvalidToken) {
+			slog.InfoContext(ctx, "Removing invalid device token", "user_id", userID, "platform", device.Platform)
+			if err := w.devices.Delete(ctx, device.ID); err != nil {
+				slog.ErrorContext(ctx, "Failed to remove device", "device_id", device.ID, "error", err)
+			}
+			continue
+		}
+		if err != nil {
+			slog.ErrorContext(ctx, "Failed to send notification", "user_id", userID, "platform", device.Platform, "error", err)
+		}
+	}
+}
+
+// mutedUsers 返回在会话中开启了免打扰(且未过期)的接收者
+func (w *Worker) mutedUsers(ctx context.Context, recipients []recipient, conversations *gorm.DB) (map[uuid.UUID]bool, error) {
+	ids := make([]uuid.UUID, 0, len(recipients))
+	for _, r := range recipients {
+		ids = append(ids, r.userID)
+	}
+
+	var participants []types.ConversationParticipants
+	if err := w.db.WithContext(ctx).
+		Where("conversation_id IN (?) AND user_id IN ? AND muted = ?", conversations, ids, true).
+		Find(&participants).Error; err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	muted := make(map[uuid.UUID]bool, len(participants))
+	for i := range participants {
+		if participants[i].IsMuted(now) {
+			muted[participants[i].UserID] = true
+		}
+	}
+	return muted, nil
+}
+
+func (w *Worker) displayName(ctx context.Context, userID uuid.UUID) (string, error) {
+	var user types.Users
+	if err := w.db.WithContext(ctx).Select("id", "username", "nickname").First(&user, "id = ?", userID).Error; err != nil {
+		return "", err
+	}
+	if user.Nickname != "" {
+		return user.Nickname, nil
+	}
+	return user.Username, nil
+}
+
+// decodePayload MessagePayload.Data反序列化后是map，重新编码成具体的消息结构
+func decodePayload(data any, out interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, out)
+}
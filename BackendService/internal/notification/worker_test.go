@@ -0,0 +1,386 @@
+package notification
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/kafka"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// fakeDB 内存中的用户、群、免打扰和设备数据，通过database/sql驱动提供给gorm。
+// 只实现Worker用到的查询，按表名区分
+type fakeDB struct {
+	mu      sync.Mutex
+	queries int
+	users   map[string]string   // userID -> username
+	groups  map[string]string   // groupID -> name
+	members map[string][]string // groupID -> userIDs
+	muted   map[string]bool     // 开启了免打扰的userID
+	devices []types.DeviceTokens
+}
+
+var fakeDBs sync.Map // dsn -> *fakeDB
+
+type fakeDriver struct{}
+
+func init() {
+	sql.Register("notificationtest", fakeDriver{})
+}
+
+func (fakeDriver) Open(dsn string) (driver.Conn, error) {
+	db, ok := fakeDBs.Load(dsn)
+	if !ok {
+		return nil, fmt.Errorf("unknown fake db %q", dsn)
+	}
+	return &fakeConn{db: db.(*fakeDB)}, nil
+}
+
+type fakeConn struct {
+	db *fakeDB
+}
+
+func (c *fakeConn) Prepare(string) (driver.Stmt, error) {
+	return nil, errors.New("prepare not supported")
+}
+func (c *fakeConn) Close() error              { return nil }
+func (c *fakeConn) Begin() (driver.Tx, error) { return fakeTx{}, nil }
+
+type fakeTx struct{}
+
+func (fakeTx) Commit() error   { return nil }
+func (fakeTx) Rollback() error { return nil }
+
+func (c *fakeConn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.queries++
+
+	arg := func(i int) string {
+		if i >= len(args) {
+			return ""
+		}
+		return fmt.Sprint(args[i].Value)
+	}
+	switch {
+	case strings.Contains(query, `FROM "users"`):
+		rows := &fakeRows{columns: []string{"id", "username", "nickname"}}
+		if name, ok := db.users[arg(0)]; ok {
+			rows.values = [][]driver.Value{{arg(0), name, ""}}
+		}
+		return rows, nil
+	case strings.Contains(query, `FROM "groups"`):
+		rows := &fakeRows{columns: []string{"id", "name"}}
+		if name, ok := db.groups[arg(0)]; ok {
+			rows.values = [][]driver.Value{{arg(0), name}}
+		}
+		return rows, nil
+	case strings.Contains(query, `FROM "group_members"`):
+		rows := &fakeRows{columns: []string{"user_id"}}
+		for _, id := range db.members[arg(0)] {
+			if id != arg(1) {
+				rows.values = append(rows.values, []driver.Value{id})
+			}
+		}
+		return rows, nil
+	case strings.Contains(query, `FROM "conversation_participants"`):
+		// 参数里包含接收者ID，返回其中开启了免打扰的
+		rows := &fakeRows{columns: []string{"user_id", "muted"}}
+		for i := range args {
+			if db.muted[arg(i)] {
+				rows.values = append(rows.values, []driver.Value{arg(i), true})
+			}
+		}
+		return rows, nil
+	case strings.Contains(query, `FROM "device_tokens"`):
+		rows := &fakeRows{columns: []string{"id", "user_id", "platform", "token"}}
+		for _, d := range db.devices {
+			if d.UserID.String() == arg(0) {
+				rows.values = append(rows.values, []driver.Value{d.ID.String(), d.UserID.String(), d.Platform, d.Token})
+			}
+		}
+		return rows, nil
+	}
+	return nil, fmt.Errorf("unexpected query: %s", query)
+}
+
+func (c *fakeConn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	db := c.db
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.queries++
+
+	if !strings.Contains(query, `DELETE FROM "device_tokens"`) || len(args) == 0 {
+		return nil, fmt.Errorf("unexpected exec: %s", query)
+	}
+	kept := db.devices[:0]
+	for _, d := range db.devices {
+		if d.ID.String() != fmt.Sprint(args[0].Value) {
+			kept = append(kept, d)
+		}
+	}
+	affected := int64(len(db.devices) - len(kept))
+	db.devices = kept
+	return driver.RowsAffected(affected), nil
+}
+
+type fakeRows struct {
+	columns []string
+	values  [][]driver.Value
+}
+
+func (r *fakeRows) Columns() []string { return r.columns }
+func (r *fakeRows) Close() error      { return nil }
+
+func (r *fakeRows) Next(dest []driver.Value) error {
+	if len(r.values) == 0 {
+		return io.EOF
+	}
+	copy(dest, r.values[0])
+	r.values = r.values[1:]
+	return nil
+}
+
+func (db *fakeDB) addDevice(userID uuid.UUID, platform, token string) {
+	db.devices = append(db.devices, types.DeviceTokens{ID: uuid.New(), UserID: userID, Platform: platform, Token: token})
+}
+
+func (db *fakeDB) deviceTokens() []string {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	var tokens []string
+	for _, d := range db.devices {
+		tokens = append(tokens, d.Token)
+	}
+	return tokens
+}
+
+type fakePresence map[uuid.UUID]bool
+
+func (p fakePresence) IsUserOnline(ctx context.Context, userID string) (bool, error) {
+	return p[uuid.MustParse(userID)], nil
+}
+
+// countLimiter 每个用户最多允许limit次推送
+type countLimiter struct {
+	mu     sync.Mutex
+	limit  int
+	counts map[uuid.UUID]int
+}
+
+func (l *countLimiter) Allow(ctx context.Context, userID uuid.UUID) (bool, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.counts[userID]++
+	return l.counts[userID] <= l.limit, nil
+}
+
+type workerFixture struct {
+	db       *fakeDB
+	presence fakePresence
+	limiter  *countLimiter
+	provider *FakeProvider
+	worker   *Worker
+
+	sender, alice, bob, carol uuid.UUID
+	group                     uuid.UUID
+}
+
+// newWorkerFixture sender给alice、bob、carol发消息，三人和sender在同一个群里，每人一台FCM设备
+func newWorkerFixture(t *testing.T) *workerFixture {
+	t.Helper()
+	f := &workerFixture{
+		presence: fakePresence{},
+		limiter:  &countLimiter{limit: 100, counts: make(map[uuid.UUID]int)},
+		provider: NewFakeProvider(types.PlatformFCM),
+		sender:   uuid.New(),
+		alice:    uuid.New(),
+		bob:      uuid.New(),
+		carol:    uuid.New(),
+		group:    uuid.New(),
+	}
+	f.db = &fakeDB{
+		users: map[string]string{
+			f.sender.String(): "sender",
+			f.alice.String():  "alice",
+			f.bob.String():    "bob",
+			f.carol.String():  "carol",
+		},
+		groups:  map[string]string{f.group.String(): "team"},
+		members: map[string][]string{f.group.String(): {f.sender.String(), f.alice.String(), f.bob.String(), f.carol.String()}},
+		muted:   map[string]bool{},
+	}
+	for _, id := range []uuid.UUID{f.alice, f.bob, f.carol} {
+		f.db.addDevice(id, types.PlatformFCM, "token-"+f.db.users[id.String()])
+	}
+
+	dsn := t.Name()
+	fakeDBs.Store(dsn, f.db)
+	t.Cleanup(func() { fakeDBs.Delete(dsn) })
+	gdb, err := gorm.Open(postgres.New(postgres.Config{DriverName: "notificationtest", DSN: dsn}), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		t.Fatalf("open db: %v", err)
+	}
+	f.worker = NewWorker(gdb, f.presence, f.limiter, NewDeviceStore(gdb), f.provider)
+	return f
+}
+
+func (f *workerFixture) p2p(receiver uuid.UUID) kafka.MessagePayload {
+	return kafka.MessagePayload{Type: "p2p_message", Data: types.P2PMessages{
+		ID:         uuid.New(),
+		SenderID:   f.sender,
+		ReceiverID: receiver,
+		Content:    "hello",
+	}}
+}
+
+func (f *workerFixture) groupMessage(mentions ...uuid.UUID) kafka.MessagePayload {
+	message := types.GroupMessages{
+		ID:       uuid.New(),
+		SenderID: f.sender,
+		GroupID:  f.group,
+		Content:  "hello team",
+	}
+	for _, id := range mentions {
+		message.Mentions = append(message.Mentions, types.MentionEntity{UserID: id})
+	}
+	return kafka.MessagePayload{Type: "group_message", Data: message}
+}
+
+func (f *workerFixture) handle(t *testing.T, payload kafka.MessagePayload) {
+	t.Helper()
+	if err := f.worker.HandleMessage(context.Background(), payload); err != nil {
+		t.Fatalf("HandleMessage(%s): %v", payload.Type, err)
+	}
+}
+
+// deliveredTo 收到推送的用户名，按推送顺序
+func (f *workerFixture) deliveredTo() []string {
+	var names []string
+	for _, d := range f.provider.Deliveries() {
+		names = append(names, f.db.users[d.UserID])
+	}
+	return names
+}
+
+func assertDelivered(t *testing.T, f *workerFixture, want ...string) {
+	t.Helper()
+	got := f.deliveredTo()
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("delivered to %v, want %v", got, want)
+	}
+}
+
+func TestHandleMessageP2P(t *testing.T) {
+	f := newWorkerFixture(t)
+	f.handle(t, f.p2p(f.alice))
+
+	assertDelivered(t, f, "alice")
+	delivery := f.provider.Deliveries()[0]
+	if delivery.Notification.Title != "sender" || delivery.Notification.Body != "hello" {
+		t.Errorf("notification = %+v, want title sender and body hello", delivery.Notification)
+	}
+}
+
+func TestHandleMessageSkipsOnlineUsers(t *testing.T) {
+	f := newWorkerFixture(t)
+	f.presence[f.alice] = true
+
+	f.handle(t, f.p2p(f.alice))
+	assertDelivered(t, f)
+
+	f.handle(t, f.groupMessage())
+	assertDelivered(t, f, "bob", "carol")
+}
+
+func TestHandleMessageMutedConversation(t *testing.T) {
+	f := newWorkerFixture(t)
+	f.db.muted[f.alice.String()] = true
+	f.db.muted[f.bob.String()] = true
+
+	// 免打扰的成员不推送，被@的成员仍然推送
+	f.handle(t, f.groupMessage(f.bob))
+	assertDelivered(t, f, "bob", "carol")
+
+	f.provider.Reset()
+	f.handle(t, f.p2p(f.alice))
+	assertDelivered(t, f)
+}
+
+func TestHandleMessageMentionAllOverridesMute(t *testing.T) {
+	f := newWorkerFixture(t)
+	f.db.muted[f.alice.String()] = true
+
+	payload := f.groupMessage()
+	message := payload.Data.(types.GroupMessages)
+	message.MentionAll = true
+	payload.Data = message
+	f.handle(t, payload)
+
+	assertDelivered(t, f, "alice", "bob", "carol")
+}
+
+func TestHandleMessageRateLimit(t *testing.T) {
+	f := newWorkerFixture(t)
+	f.limiter.limit = 2
+
+	for i := 0; i < 3; i++ {
+		f.handle(t, f.p2p(f.alice))
+	}
+	f.handle(t, f.p2p(f.bob))
+
+	assertDelivered(t, f, "alice", "alice", "bob")
+}
+
+func TestHandleMessageRemovesInvalidTokens(t *testing.T) {
+	f := newWorkerFixture(t)
+	f.db.addDevice(f.alice, types.PlatformFCM, "token-alice-tablet")
+	f.provider.InvalidateToken("token-alice")
+
+	f.handle(t, f.p2p(f.alice))
+
+	deliveries := f.provider.Deliveries()
+	if len(deliveries) != 1 || deliveries[0].Token != "token-alice-tablet" {
+		t.Errorf("deliveries = %+v, want only token-alice-tablet", deliveries)
+	}
+	for _, token := range f.db.deviceTokens() {
+		if token == "token-alice" {
+			t.Error("invalid token was not removed")
+		}
+	}
+	if got := len(f.db.deviceTokens()); got != 3 {
+		t.Errorf("%d devices left, want 3", got)
+	}
+}
+
+func TestHandleMessageSkipsEditAndRecall(t *testing.T) {
+	f := newWorkerFixture(t)
+
+	for _, typ := range []string{"message_edited", "message_recalled"} {
+		f.handle(t, kafka.MessagePayload{Type: typ, Data: map[string]any{
+			"id":        uuid.New(),
+			"chat_type": types.ChatTypeP2P,
+			"sender_id": f.sender,
+			"content":   "edited",
+		}})
+	}
+
+	assertDelivered(t, f)
+	if f.db.queries != 0 {
+		t.Errorf("ran %d queries for edit/recall events, want 0", f.db.queries)
+	}
+}
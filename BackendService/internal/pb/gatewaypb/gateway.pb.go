@@ -0,0 +1,2793 @@
+// WebSocket网关的protobuf帧格式，通过子协议chat.v2+proto协商，语义与v2 JSON信封一致。
+// 字段名与JSON字段一致，uuid使用字符串表示。
+//
+// 修改后重新生成：
+//
+//	protoc -I proto --go_out=. --go_opt=module=github.com/huangrao121/CommunicationApp/BackendService proto/gateway.proto
+
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.7
+// 	protoc        (unknown)
+// source: gateway.proto
+
+package gatewaypb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// ClientFrame 客户端上行帧。payload字段名与请求类型一致，type为空时由payload推断
+type ClientFrame struct {
+	state protoimpl.MessageState `protogen:"open.v1"`
+	V     uint32                 `protobuf:"varint,1,opt,name=v,proto3" json:"v,omitempty"`
+	Id    string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Type  string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	// W3C trace context，客户端有自己的trace时传入，服务端的span挂在它下面
+	Traceparent string `protobuf:"bytes,4,opt,name=traceparent,proto3" json:"traceparent,omitempty"`
+	Tracestate  string `protobuf:"bytes,5,opt,name=tracestate,proto3" json:"tracestate,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*ClientFrame_SendP2PMessage
+	//	*ClientFrame_SendGroupMessage
+	//	*ClientFrame_Typing
+	//	*ClientFrame_EditMessage
+	//	*ClientFrame_RecallMessage
+	//	*ClientFrame_DeleteMessage
+	//	*ClientFrame_AddReaction
+	//	*ClientFrame_RemoveReaction
+	//	*ClientFrame_RefreshToken
+	Payload       isClientFrame_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ClientFrame) Reset() {
+	*x = ClientFrame{}
+	mi := &file_gateway_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ClientFrame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ClientFrame) ProtoMessage() {}
+
+func (x *ClientFrame) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ClientFrame.ProtoReflect.Descriptor instead.
+func (*ClientFrame) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *ClientFrame) GetV() uint32 {
+	if x != nil {
+		return x.V
+	}
+	return 0
+}
+
+func (x *ClientFrame) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ClientFrame) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ClientFrame) GetTraceparent() string {
+	if x != nil {
+		return x.Traceparent
+	}
+	return ""
+}
+
+func (x *ClientFrame) GetTracestate() string {
+	if x != nil {
+		return x.Tracestate
+	}
+	return ""
+}
+
+func (x *ClientFrame) GetPayload() isClientFrame_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *ClientFrame) GetSendP2PMessage() *SendP2PRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientFrame_SendP2PMessage); ok {
+			return x.SendP2PMessage
+		}
+	}
+	return nil
+}
+
+func (x *ClientFrame) GetSendGroupMessage() *SendGroupRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientFrame_SendGroupMessage); ok {
+			return x.SendGroupMessage
+		}
+	}
+	return nil
+}
+
+func (x *ClientFrame) GetTyping() *TypingRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientFrame_Typing); ok {
+			return x.Typing
+		}
+	}
+	return nil
+}
+
+func (x *ClientFrame) GetEditMessage() *EditMessageRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientFrame_EditMessage); ok {
+			return x.EditMessage
+		}
+	}
+	return nil
+}
+
+func (x *ClientFrame) GetRecallMessage() *RecallMessageRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientFrame_RecallMessage); ok {
+			return x.RecallMessage
+		}
+	}
+	return nil
+}
+
+func (x *ClientFrame) GetDeleteMessage() *DeleteMessageRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientFrame_DeleteMessage); ok {
+			return x.DeleteMessage
+		}
+	}
+	return nil
+}
+
+func (x *ClientFrame) GetAddReaction() *ReactionRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientFrame_AddReaction); ok {
+			return x.AddReaction
+		}
+	}
+	return nil
+}
+
+func (x *ClientFrame) GetRemoveReaction() *ReactionRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientFrame_RemoveReaction); ok {
+			return x.RemoveReaction
+		}
+	}
+	return nil
+}
+
+func (x *ClientFrame) GetRefreshToken() *RefreshTokenRequest {
+	if x != nil {
+		if x, ok := x.Payload.(*ClientFrame_RefreshToken); ok {
+			return x.RefreshToken
+		}
+	}
+	return nil
+}
+
+type isClientFrame_Payload interface {
+	isClientFrame_Payload()
+}
+
+type ClientFrame_SendP2PMessage struct {
+	SendP2PMessage *SendP2PRequest `protobuf:"bytes,10,opt,name=send_p2p_message,json=sendP2pMessage,proto3,oneof"`
+}
+
+type ClientFrame_SendGroupMessage struct {
+	SendGroupMessage *SendGroupRequest `protobuf:"bytes,11,opt,name=send_group_message,json=sendGroupMessage,proto3,oneof"`
+}
+
+type ClientFrame_Typing struct {
+	Typing *TypingRequest `protobuf:"bytes,12,opt,name=typing,proto3,oneof"`
+}
+
+type ClientFrame_EditMessage struct {
+	EditMessage *EditMessageRequest `protobuf:"bytes,13,opt,name=edit_message,json=editMessage,proto3,oneof"`
+}
+
+type ClientFrame_RecallMessage struct {
+	RecallMessage *RecallMessageRequest `protobuf:"bytes,14,opt,name=recall_message,json=recallMessage,proto3,oneof"`
+}
+
+type ClientFrame_DeleteMessage struct {
+	DeleteMessage *DeleteMessageRequest `protobuf:"bytes,15,opt,name=delete_message,json=deleteMessage,proto3,oneof"`
+}
+
+type ClientFrame_AddReaction struct {
+	AddReaction *ReactionRequest `protobuf:"bytes,16,opt,name=add_reaction,json=addReaction,proto3,oneof"`
+}
+
+type ClientFrame_RemoveReaction struct {
+	RemoveReaction *ReactionRequest `protobuf:"bytes,17,opt,name=remove_reaction,json=removeReaction,proto3,oneof"`
+}
+
+type ClientFrame_RefreshToken struct {
+	RefreshToken *RefreshTokenRequest `protobuf:"bytes,18,opt,name=refresh_token,json=refreshToken,proto3,oneof"`
+}
+
+func (*ClientFrame_SendP2PMessage) isClientFrame_Payload() {}
+
+func (*ClientFrame_SendGroupMessage) isClientFrame_Payload() {}
+
+func (*ClientFrame_Typing) isClientFrame_Payload() {}
+
+func (*ClientFrame_EditMessage) isClientFrame_Payload() {}
+
+func (*ClientFrame_RecallMessage) isClientFrame_Payload() {}
+
+func (*ClientFrame_DeleteMessage) isClientFrame_Payload() {}
+
+func (*ClientFrame_AddReaction) isClientFrame_Payload() {}
+
+func (*ClientFrame_RemoveReaction) isClientFrame_Payload() {}
+
+func (*ClientFrame_RefreshToken) isClientFrame_Payload() {}
+
+// ServerFrame 服务端下行帧。同一种payload可能用于多种事件，以type为准
+type ServerFrame struct {
+	state     protoimpl.MessageState `protogen:"open.v1"`
+	V         uint32                 `protobuf:"varint,1,opt,name=v,proto3" json:"v,omitempty"`
+	Id        string                 `protobuf:"bytes,2,opt,name=id,proto3" json:"id,omitempty"`
+	Type      string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	Timestamp int64                  `protobuf:"varint,4,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	Silent    bool                   `protobuf:"varint,5,opt,name=silent,proto3" json:"silent,omitempty"`
+	// ack/nack对应请求的trace ID，用于排查问题
+	TraceId string `protobuf:"bytes,6,opt,name=trace_id,json=traceId,proto3" json:"trace_id,omitempty"`
+	// Types that are valid to be assigned to Payload:
+	//
+	//	*ServerFrame_ConnectionEstablished
+	//	*ServerFrame_Error
+	//	*ServerFrame_MessageResponse
+	//	*ServerFrame_MessageUpdate
+	//	*ServerFrame_ReactionUpdate
+	//	*ServerFrame_RefreshTokenResult
+	//	*ServerFrame_NewP2PMessage
+	//	*ServerFrame_NewGroupMessage
+	//	*ServerFrame_Mention
+	//	*ServerFrame_TypingIndicator
+	//	*ServerFrame_MessageDeleted
+	//	*ServerFrame_ConversationSettings
+	//	*ServerFrame_ConversationPins
+	//	*ServerFrame_SessionRevoked
+	//	*ServerFrame_TokenExpiry
+	//	*ServerFrame_Reconnect
+	Payload       isServerFrame_Payload `protobuf_oneof:"payload"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ServerFrame) Reset() {
+	*x = ServerFrame{}
+	mi := &file_gateway_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ServerFrame) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ServerFrame) ProtoMessage() {}
+
+func (x *ServerFrame) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ServerFrame.ProtoReflect.Descriptor instead.
+func (*ServerFrame) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *ServerFrame) GetV() uint32 {
+	if x != nil {
+		return x.V
+	}
+	return 0
+}
+
+func (x *ServerFrame) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *ServerFrame) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *ServerFrame) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+func (x *ServerFrame) GetSilent() bool {
+	if x != nil {
+		return x.Silent
+	}
+	return false
+}
+
+func (x *ServerFrame) GetTraceId() string {
+	if x != nil {
+		return x.TraceId
+	}
+	return ""
+}
+
+func (x *ServerFrame) GetPayload() isServerFrame_Payload {
+	if x != nil {
+		return x.Payload
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetConnectionEstablished() *ConnectionEstablished {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_ConnectionEstablished); ok {
+			return x.ConnectionEstablished
+		}
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetError() *ErrorPayload {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_Error); ok {
+			return x.Error
+		}
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetMessageResponse() *MessageResponse {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_MessageResponse); ok {
+			return x.MessageResponse
+		}
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetMessageUpdate() *MessageUpdate {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_MessageUpdate); ok {
+			return x.MessageUpdate
+		}
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetReactionUpdate() *ReactionUpdate {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_ReactionUpdate); ok {
+			return x.ReactionUpdate
+		}
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetRefreshTokenResult() *RefreshTokenResult {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_RefreshTokenResult); ok {
+			return x.RefreshTokenResult
+		}
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetNewP2PMessage() *P2PMessageEvent {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_NewP2PMessage); ok {
+			return x.NewP2PMessage
+		}
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetNewGroupMessage() *GroupMessageEvent {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_NewGroupMessage); ok {
+			return x.NewGroupMessage
+		}
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetMention() *MentionEvent {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_Mention); ok {
+			return x.Mention
+		}
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetTypingIndicator() *TypingIndicator {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_TypingIndicator); ok {
+			return x.TypingIndicator
+		}
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetMessageDeleted() *MessageDeleted {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_MessageDeleted); ok {
+			return x.MessageDeleted
+		}
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetConversationSettings() *ConversationSettings {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_ConversationSettings); ok {
+			return x.ConversationSettings
+		}
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetConversationPins() *ConversationPins {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_ConversationPins); ok {
+			return x.ConversationPins
+		}
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetSessionRevoked() *SessionRevoked {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_SessionRevoked); ok {
+			return x.SessionRevoked
+		}
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetTokenExpiry() *TokenExpiry {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_TokenExpiry); ok {
+			return x.TokenExpiry
+		}
+	}
+	return nil
+}
+
+func (x *ServerFrame) GetReconnect() *Reconnect {
+	if x != nil {
+		if x, ok := x.Payload.(*ServerFrame_Reconnect); ok {
+			return x.Reconnect
+		}
+	}
+	return nil
+}
+
+type isServerFrame_Payload interface {
+	isServerFrame_Payload()
+}
+
+type ServerFrame_ConnectionEstablished struct {
+	ConnectionEstablished *ConnectionEstablished `protobuf:"bytes,10,opt,name=connection_established,json=connectionEstablished,proto3,oneof"`
+}
+
+type ServerFrame_Error struct {
+	// nack和v1的error事件
+	Error *ErrorPayload `protobuf:"bytes,11,opt,name=error,proto3,oneof"`
+}
+
+type ServerFrame_MessageResponse struct {
+	// send_p2p_message/send_group_message的ack
+	MessageResponse *MessageResponse `protobuf:"bytes,12,opt,name=message_response,json=messageResponse,proto3,oneof"`
+}
+
+type ServerFrame_MessageUpdate struct {
+	// message_edited/message_recalled事件，以及edit_message/recall_message的ack
+	MessageUpdate *MessageUpdate `protobuf:"bytes,13,opt,name=message_update,json=messageUpdate,proto3,oneof"`
+}
+
+type ServerFrame_ReactionUpdate struct {
+	// reaction_updated事件，以及add_reaction/remove_reaction的ack
+	ReactionUpdate *ReactionUpdate `protobuf:"bytes,14,opt,name=reaction_update,json=reactionUpdate,proto3,oneof"`
+}
+
+type ServerFrame_RefreshTokenResult struct {
+	RefreshTokenResult *RefreshTokenResult `protobuf:"bytes,15,opt,name=refresh_token_result,json=refreshTokenResult,proto3,oneof"`
+}
+
+type ServerFrame_NewP2PMessage struct {
+	NewP2PMessage *P2PMessageEvent `protobuf:"bytes,16,opt,name=new_p2p_message,json=newP2pMessage,proto3,oneof"`
+}
+
+type ServerFrame_NewGroupMessage struct {
+	NewGroupMessage *GroupMessageEvent `protobuf:"bytes,17,opt,name=new_group_message,json=newGroupMessage,proto3,oneof"`
+}
+
+type ServerFrame_Mention struct {
+	Mention *MentionEvent `protobuf:"bytes,18,opt,name=mention,proto3,oneof"`
+}
+
+type ServerFrame_TypingIndicator struct {
+	TypingIndicator *TypingIndicator `protobuf:"bytes,19,opt,name=typing_indicator,json=typingIndicator,proto3,oneof"`
+}
+
+type ServerFrame_MessageDeleted struct {
+	MessageDeleted *MessageDeleted `protobuf:"bytes,20,opt,name=message_deleted,json=messageDeleted,proto3,oneof"`
+}
+
+type ServerFrame_ConversationSettings struct {
+	ConversationSettings *ConversationSettings `protobuf:"bytes,21,opt,name=conversation_settings,json=conversationSettings,proto3,oneof"`
+}
+
+type ServerFrame_ConversationPins struct {
+	ConversationPins *ConversationPins `protobuf:"bytes,22,opt,name=conversation_pins,json=conversationPins,proto3,oneof"`
+}
+
+type ServerFrame_SessionRevoked struct {
+	SessionRevoked *SessionRevoked `protobuf:"bytes,23,opt,name=session_revoked,json=sessionRevoked,proto3,oneof"`
+}
+
+type ServerFrame_TokenExpiry struct {
+	// token_expiring/token_expired事件
+	TokenExpiry *TokenExpiry `protobuf:"bytes,24,opt,name=token_expiry,json=tokenExpiry,proto3,oneof"`
+}
+
+type ServerFrame_Reconnect struct {
+	// 节点关闭前通知客户端重连
+	Reconnect *Reconnect `protobuf:"bytes,25,opt,name=reconnect,proto3,oneof"`
+}
+
+func (*ServerFrame_ConnectionEstablished) isServerFrame_Payload() {}
+
+func (*ServerFrame_Error) isServerFrame_Payload() {}
+
+func (*ServerFrame_MessageResponse) isServerFrame_Payload() {}
+
+func (*ServerFrame_MessageUpdate) isServerFrame_Payload() {}
+
+func (*ServerFrame_ReactionUpdate) isServerFrame_Payload() {}
+
+func (*ServerFrame_RefreshTokenResult) isServerFrame_Payload() {}
+
+func (*ServerFrame_NewP2PMessage) isServerFrame_Payload() {}
+
+func (*ServerFrame_NewGroupMessage) isServerFrame_Payload() {}
+
+func (*ServerFrame_Mention) isServerFrame_Payload() {}
+
+func (*ServerFrame_TypingIndicator) isServerFrame_Payload() {}
+
+func (*ServerFrame_MessageDeleted) isServerFrame_Payload() {}
+
+func (*ServerFrame_ConversationSettings) isServerFrame_Payload() {}
+
+func (*ServerFrame_ConversationPins) isServerFrame_Payload() {}
+
+func (*ServerFrame_SessionRevoked) isServerFrame_Payload() {}
+
+func (*ServerFrame_TokenExpiry) isServerFrame_Payload() {}
+
+func (*ServerFrame_Reconnect) isServerFrame_Payload() {}
+
+type SendP2PRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ReceiverId    string                 `protobuf:"bytes,1,opt,name=receiver_id,json=receiverId,proto3" json:"receiver_id,omitempty"`
+	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	ContentType   int32                  `protobuf:"varint,3,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	ReplyToId     *string                `protobuf:"bytes,4,opt,name=reply_to_id,json=replyToId,proto3,oneof" json:"reply_to_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendP2PRequest) Reset() {
+	*x = SendP2PRequest{}
+	mi := &file_gateway_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendP2PRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendP2PRequest) ProtoMessage() {}
+
+func (x *SendP2PRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendP2PRequest.ProtoReflect.Descriptor instead.
+func (*SendP2PRequest) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *SendP2PRequest) GetReceiverId() string {
+	if x != nil {
+		return x.ReceiverId
+	}
+	return ""
+}
+
+func (x *SendP2PRequest) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *SendP2PRequest) GetContentType() int32 {
+	if x != nil {
+		return x.ContentType
+	}
+	return 0
+}
+
+func (x *SendP2PRequest) GetReplyToId() string {
+	if x != nil && x.ReplyToId != nil {
+		return *x.ReplyToId
+	}
+	return ""
+}
+
+type SendGroupRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	GroupId       string                 `protobuf:"bytes,1,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	Content       string                 `protobuf:"bytes,2,opt,name=content,proto3" json:"content,omitempty"`
+	ContentType   int32                  `protobuf:"varint,3,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	ReplyToId     *string                `protobuf:"bytes,4,opt,name=reply_to_id,json=replyToId,proto3,oneof" json:"reply_to_id,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SendGroupRequest) Reset() {
+	*x = SendGroupRequest{}
+	mi := &file_gateway_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SendGroupRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SendGroupRequest) ProtoMessage() {}
+
+func (x *SendGroupRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SendGroupRequest.ProtoReflect.Descriptor instead.
+func (*SendGroupRequest) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *SendGroupRequest) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *SendGroupRequest) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *SendGroupRequest) GetContentType() int32 {
+	if x != nil {
+		return x.ContentType
+	}
+	return 0
+}
+
+func (x *SendGroupRequest) GetReplyToId() string {
+	if x != nil && x.ReplyToId != nil {
+		return *x.ReplyToId
+	}
+	return ""
+}
+
+type TypingRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ReceiverId    string                 `protobuf:"bytes,1,opt,name=receiver_id,json=receiverId,proto3" json:"receiver_id,omitempty"`
+	IsTyping      bool                   `protobuf:"varint,2,opt,name=is_typing,json=isTyping,proto3" json:"is_typing,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TypingRequest) Reset() {
+	*x = TypingRequest{}
+	mi := &file_gateway_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TypingRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TypingRequest) ProtoMessage() {}
+
+func (x *TypingRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TypingRequest.ProtoReflect.Descriptor instead.
+func (*TypingRequest) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *TypingRequest) GetReceiverId() string {
+	if x != nil {
+		return x.ReceiverId
+	}
+	return ""
+}
+
+func (x *TypingRequest) GetIsTyping() bool {
+	if x != nil {
+		return x.IsTyping
+	}
+	return false
+}
+
+type EditMessageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	ChatType      string                 `protobuf:"bytes,2,opt,name=chat_type,json=chatType,proto3" json:"chat_type,omitempty"`
+	Content       string                 `protobuf:"bytes,3,opt,name=content,proto3" json:"content,omitempty"`
+	ContentType   int32                  `protobuf:"varint,4,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *EditMessageRequest) Reset() {
+	*x = EditMessageRequest{}
+	mi := &file_gateway_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *EditMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*EditMessageRequest) ProtoMessage() {}
+
+func (x *EditMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use EditMessageRequest.ProtoReflect.Descriptor instead.
+func (*EditMessageRequest) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *EditMessageRequest) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *EditMessageRequest) GetChatType() string {
+	if x != nil {
+		return x.ChatType
+	}
+	return ""
+}
+
+func (x *EditMessageRequest) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *EditMessageRequest) GetContentType() int32 {
+	if x != nil {
+		return x.ContentType
+	}
+	return 0
+}
+
+type RecallMessageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	ChatType      string                 `protobuf:"bytes,2,opt,name=chat_type,json=chatType,proto3" json:"chat_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RecallMessageRequest) Reset() {
+	*x = RecallMessageRequest{}
+	mi := &file_gateway_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RecallMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RecallMessageRequest) ProtoMessage() {}
+
+func (x *RecallMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RecallMessageRequest.ProtoReflect.Descriptor instead.
+func (*RecallMessageRequest) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *RecallMessageRequest) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *RecallMessageRequest) GetChatType() string {
+	if x != nil {
+		return x.ChatType
+	}
+	return ""
+}
+
+type DeleteMessageRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	ChatType      string                 `protobuf:"bytes,2,opt,name=chat_type,json=chatType,proto3" json:"chat_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *DeleteMessageRequest) Reset() {
+	*x = DeleteMessageRequest{}
+	mi := &file_gateway_proto_msgTypes[7]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *DeleteMessageRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*DeleteMessageRequest) ProtoMessage() {}
+
+func (x *DeleteMessageRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[7]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use DeleteMessageRequest.ProtoReflect.Descriptor instead.
+func (*DeleteMessageRequest) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{7}
+}
+
+func (x *DeleteMessageRequest) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *DeleteMessageRequest) GetChatType() string {
+	if x != nil {
+		return x.ChatType
+	}
+	return ""
+}
+
+type ReactionRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	ChatType      string                 `protobuf:"bytes,2,opt,name=chat_type,json=chatType,proto3" json:"chat_type,omitempty"`
+	Emoji         string                 `protobuf:"bytes,3,opt,name=emoji,proto3" json:"emoji,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReactionRequest) Reset() {
+	*x = ReactionRequest{}
+	mi := &file_gateway_proto_msgTypes[8]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReactionRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReactionRequest) ProtoMessage() {}
+
+func (x *ReactionRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[8]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReactionRequest.ProtoReflect.Descriptor instead.
+func (*ReactionRequest) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{8}
+}
+
+func (x *ReactionRequest) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *ReactionRequest) GetChatType() string {
+	if x != nil {
+		return x.ChatType
+	}
+	return ""
+}
+
+func (x *ReactionRequest) GetEmoji() string {
+	if x != nil {
+		return x.Emoji
+	}
+	return ""
+}
+
+type RefreshTokenRequest struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Token         string                 `protobuf:"bytes,1,opt,name=token,proto3" json:"token,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefreshTokenRequest) Reset() {
+	*x = RefreshTokenRequest{}
+	mi := &file_gateway_proto_msgTypes[9]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefreshTokenRequest) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefreshTokenRequest) ProtoMessage() {}
+
+func (x *RefreshTokenRequest) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[9]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefreshTokenRequest.ProtoReflect.Descriptor instead.
+func (*RefreshTokenRequest) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{9}
+}
+
+func (x *RefreshTokenRequest) GetToken() string {
+	if x != nil {
+		return x.Token
+	}
+	return ""
+}
+
+type ConnectionEstablished struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	UserId            string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	DeviceId          string                 `protobuf:"bytes,2,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	ProtocolVersion   uint32                 `protobuf:"varint,3,opt,name=protocol_version,json=protocolVersion,proto3" json:"protocol_version,omitempty"`
+	SupportedVersions []uint32               `protobuf:"varint,4,rep,packed,name=supported_versions,json=supportedVersions,proto3" json:"supported_versions,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *ConnectionEstablished) Reset() {
+	*x = ConnectionEstablished{}
+	mi := &file_gateway_proto_msgTypes[10]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConnectionEstablished) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConnectionEstablished) ProtoMessage() {}
+
+func (x *ConnectionEstablished) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[10]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConnectionEstablished.ProtoReflect.Descriptor instead.
+func (*ConnectionEstablished) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{10}
+}
+
+func (x *ConnectionEstablished) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ConnectionEstablished) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *ConnectionEstablished) GetProtocolVersion() uint32 {
+	if x != nil {
+		return x.ProtocolVersion
+	}
+	return 0
+}
+
+func (x *ConnectionEstablished) GetSupportedVersions() []uint32 {
+	if x != nil {
+		return x.SupportedVersions
+	}
+	return nil
+}
+
+type ErrorPayload struct {
+	state       protoimpl.MessageState `protogen:"open.v1"`
+	Code        string                 `protobuf:"bytes,1,opt,name=code,proto3" json:"code,omitempty"`
+	Message     string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`
+	Error       string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	RequestType string                 `protobuf:"bytes,4,opt,name=request_type,json=requestType,proto3" json:"request_type,omitempty"`
+	// rate_limited时多久之后可以重试
+	RetryAfterMs  int64 `protobuf:"varint,5,opt,name=retry_after_ms,json=retryAfterMs,proto3" json:"retry_after_ms,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ErrorPayload) Reset() {
+	*x = ErrorPayload{}
+	mi := &file_gateway_proto_msgTypes[11]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ErrorPayload) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ErrorPayload) ProtoMessage() {}
+
+func (x *ErrorPayload) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[11]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ErrorPayload.ProtoReflect.Descriptor instead.
+func (*ErrorPayload) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{11}
+}
+
+func (x *ErrorPayload) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
+func (x *ErrorPayload) GetMessage() string {
+	if x != nil {
+		return x.Message
+	}
+	return ""
+}
+
+func (x *ErrorPayload) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *ErrorPayload) GetRequestType() string {
+	if x != nil {
+		return x.RequestType
+	}
+	return ""
+}
+
+func (x *ErrorPayload) GetRetryAfterMs() int64 {
+	if x != nil {
+		return x.RetryAfterMs
+	}
+	return 0
+}
+
+type Mention struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Username      string                 `protobuf:"bytes,2,opt,name=username,proto3" json:"username,omitempty"`
+	Offset        int32                  `protobuf:"varint,3,opt,name=offset,proto3" json:"offset,omitempty"`
+	Length        int32                  `protobuf:"varint,4,opt,name=length,proto3" json:"length,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Mention) Reset() {
+	*x = Mention{}
+	mi := &file_gateway_proto_msgTypes[12]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Mention) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Mention) ProtoMessage() {}
+
+func (x *Mention) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[12]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Mention.ProtoReflect.Descriptor instead.
+func (*Mention) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{12}
+}
+
+func (x *Mention) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *Mention) GetUsername() string {
+	if x != nil {
+		return x.Username
+	}
+	return ""
+}
+
+func (x *Mention) GetOffset() int32 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
+func (x *Mention) GetLength() int32 {
+	if x != nil {
+		return x.Length
+	}
+	return 0
+}
+
+type MessageResponse struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	Success       bool                   `protobuf:"varint,2,opt,name=success,proto3" json:"success,omitempty"`
+	Error         string                 `protobuf:"bytes,3,opt,name=error,proto3" json:"error,omitempty"`
+	ThreadRootId  *string                `protobuf:"bytes,4,opt,name=thread_root_id,json=threadRootId,proto3,oneof" json:"thread_root_id,omitempty"`
+	Mentions      []*Mention             `protobuf:"bytes,5,rep,name=mentions,proto3" json:"mentions,omitempty"`
+	MentionAll    bool                   `protobuf:"varint,6,opt,name=mention_all,json=mentionAll,proto3" json:"mention_all,omitempty"`
+	MutedUserIds  []string               `protobuf:"bytes,7,rep,name=muted_user_ids,json=mutedUserIds,proto3" json:"muted_user_ids,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,8,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageResponse) Reset() {
+	*x = MessageResponse{}
+	mi := &file_gateway_proto_msgTypes[13]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageResponse) ProtoMessage() {}
+
+func (x *MessageResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[13]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageResponse.ProtoReflect.Descriptor instead.
+func (*MessageResponse) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{13}
+}
+
+func (x *MessageResponse) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *MessageResponse) GetSuccess() bool {
+	if x != nil {
+		return x.Success
+	}
+	return false
+}
+
+func (x *MessageResponse) GetError() string {
+	if x != nil {
+		return x.Error
+	}
+	return ""
+}
+
+func (x *MessageResponse) GetThreadRootId() string {
+	if x != nil && x.ThreadRootId != nil {
+		return *x.ThreadRootId
+	}
+	return ""
+}
+
+func (x *MessageResponse) GetMentions() []*Mention {
+	if x != nil {
+		return x.Mentions
+	}
+	return nil
+}
+
+func (x *MessageResponse) GetMentionAll() bool {
+	if x != nil {
+		return x.MentionAll
+	}
+	return false
+}
+
+func (x *MessageResponse) GetMutedUserIds() []string {
+	if x != nil {
+		return x.MutedUserIds
+	}
+	return nil
+}
+
+func (x *MessageResponse) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type MessageUpdate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	ChatType      string                 `protobuf:"bytes,2,opt,name=chat_type,json=chatType,proto3" json:"chat_type,omitempty"`
+	SenderId      string                 `protobuf:"bytes,3,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
+	ReceiverId    string                 `protobuf:"bytes,4,opt,name=receiver_id,json=receiverId,proto3" json:"receiver_id,omitempty"`
+	GroupId       string                 `protobuf:"bytes,5,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	Content       string                 `protobuf:"bytes,6,opt,name=content,proto3" json:"content,omitempty"`
+	ContentType   int32                  `protobuf:"varint,7,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	Edited        bool                   `protobuf:"varint,8,opt,name=edited,proto3" json:"edited,omitempty"`
+	Recalled      bool                   `protobuf:"varint,9,opt,name=recalled,proto3" json:"recalled,omitempty"`
+	Version       int32                  `protobuf:"varint,10,opt,name=version,proto3" json:"version,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,11,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageUpdate) Reset() {
+	*x = MessageUpdate{}
+	mi := &file_gateway_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageUpdate) ProtoMessage() {}
+
+func (x *MessageUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageUpdate.ProtoReflect.Descriptor instead.
+func (*MessageUpdate) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *MessageUpdate) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *MessageUpdate) GetChatType() string {
+	if x != nil {
+		return x.ChatType
+	}
+	return ""
+}
+
+func (x *MessageUpdate) GetSenderId() string {
+	if x != nil {
+		return x.SenderId
+	}
+	return ""
+}
+
+func (x *MessageUpdate) GetReceiverId() string {
+	if x != nil {
+		return x.ReceiverId
+	}
+	return ""
+}
+
+func (x *MessageUpdate) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *MessageUpdate) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *MessageUpdate) GetContentType() int32 {
+	if x != nil {
+		return x.ContentType
+	}
+	return 0
+}
+
+func (x *MessageUpdate) GetEdited() bool {
+	if x != nil {
+		return x.Edited
+	}
+	return false
+}
+
+func (x *MessageUpdate) GetRecalled() bool {
+	if x != nil {
+		return x.Recalled
+	}
+	return false
+}
+
+func (x *MessageUpdate) GetVersion() int32 {
+	if x != nil {
+		return x.Version
+	}
+	return 0
+}
+
+func (x *MessageUpdate) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type ReactionSummary struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Emoji         string                 `protobuf:"bytes,1,opt,name=emoji,proto3" json:"emoji,omitempty"`
+	Count         int32                  `protobuf:"varint,2,opt,name=count,proto3" json:"count,omitempty"`
+	UserIds       []string               `protobuf:"bytes,3,rep,name=user_ids,json=userIds,proto3" json:"user_ids,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReactionSummary) Reset() {
+	*x = ReactionSummary{}
+	mi := &file_gateway_proto_msgTypes[15]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReactionSummary) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReactionSummary) ProtoMessage() {}
+
+func (x *ReactionSummary) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[15]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReactionSummary.ProtoReflect.Descriptor instead.
+func (*ReactionSummary) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{15}
+}
+
+func (x *ReactionSummary) GetEmoji() string {
+	if x != nil {
+		return x.Emoji
+	}
+	return ""
+}
+
+func (x *ReactionSummary) GetCount() int32 {
+	if x != nil {
+		return x.Count
+	}
+	return 0
+}
+
+func (x *ReactionSummary) GetUserIds() []string {
+	if x != nil {
+		return x.UserIds
+	}
+	return nil
+}
+
+type ReactionUpdate struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	ChatType      string                 `protobuf:"bytes,2,opt,name=chat_type,json=chatType,proto3" json:"chat_type,omitempty"`
+	SenderId      string                 `protobuf:"bytes,3,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
+	ReceiverId    string                 `protobuf:"bytes,4,opt,name=receiver_id,json=receiverId,proto3" json:"receiver_id,omitempty"`
+	GroupId       string                 `protobuf:"bytes,5,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	UserId        string                 `protobuf:"bytes,6,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	Emoji         string                 `protobuf:"bytes,7,opt,name=emoji,proto3" json:"emoji,omitempty"`
+	Added         bool                   `protobuf:"varint,8,opt,name=added,proto3" json:"added,omitempty"`
+	Reactions     []*ReactionSummary     `protobuf:"bytes,9,rep,name=reactions,proto3" json:"reactions,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,10,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ReactionUpdate) Reset() {
+	*x = ReactionUpdate{}
+	mi := &file_gateway_proto_msgTypes[16]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ReactionUpdate) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ReactionUpdate) ProtoMessage() {}
+
+func (x *ReactionUpdate) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[16]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ReactionUpdate.ProtoReflect.Descriptor instead.
+func (*ReactionUpdate) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{16}
+}
+
+func (x *ReactionUpdate) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *ReactionUpdate) GetChatType() string {
+	if x != nil {
+		return x.ChatType
+	}
+	return ""
+}
+
+func (x *ReactionUpdate) GetSenderId() string {
+	if x != nil {
+		return x.SenderId
+	}
+	return ""
+}
+
+func (x *ReactionUpdate) GetReceiverId() string {
+	if x != nil {
+		return x.ReceiverId
+	}
+	return ""
+}
+
+func (x *ReactionUpdate) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *ReactionUpdate) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *ReactionUpdate) GetEmoji() string {
+	if x != nil {
+		return x.Emoji
+	}
+	return ""
+}
+
+func (x *ReactionUpdate) GetAdded() bool {
+	if x != nil {
+		return x.Added
+	}
+	return false
+}
+
+func (x *ReactionUpdate) GetReactions() []*ReactionSummary {
+	if x != nil {
+		return x.Reactions
+	}
+	return nil
+}
+
+func (x *ReactionUpdate) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type RefreshTokenResult struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ExpiresAt     int64                  `protobuf:"varint,1,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *RefreshTokenResult) Reset() {
+	*x = RefreshTokenResult{}
+	mi := &file_gateway_proto_msgTypes[17]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *RefreshTokenResult) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*RefreshTokenResult) ProtoMessage() {}
+
+func (x *RefreshTokenResult) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[17]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use RefreshTokenResult.ProtoReflect.Descriptor instead.
+func (*RefreshTokenResult) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{17}
+}
+
+func (x *RefreshTokenResult) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+type P2PMessageEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	SenderId      string                 `protobuf:"bytes,2,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
+	ReceiverId    string                 `protobuf:"bytes,3,opt,name=receiver_id,json=receiverId,proto3" json:"receiver_id,omitempty"`
+	Content       string                 `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+	ContentType   int32                  `protobuf:"varint,5,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	ReplyToId     *string                `protobuf:"bytes,6,opt,name=reply_to_id,json=replyToId,proto3,oneof" json:"reply_to_id,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,7,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *P2PMessageEvent) Reset() {
+	*x = P2PMessageEvent{}
+	mi := &file_gateway_proto_msgTypes[18]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *P2PMessageEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*P2PMessageEvent) ProtoMessage() {}
+
+func (x *P2PMessageEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[18]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use P2PMessageEvent.ProtoReflect.Descriptor instead.
+func (*P2PMessageEvent) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{18}
+}
+
+func (x *P2PMessageEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *P2PMessageEvent) GetSenderId() string {
+	if x != nil {
+		return x.SenderId
+	}
+	return ""
+}
+
+func (x *P2PMessageEvent) GetReceiverId() string {
+	if x != nil {
+		return x.ReceiverId
+	}
+	return ""
+}
+
+func (x *P2PMessageEvent) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *P2PMessageEvent) GetContentType() int32 {
+	if x != nil {
+		return x.ContentType
+	}
+	return 0
+}
+
+func (x *P2PMessageEvent) GetReplyToId() string {
+	if x != nil && x.ReplyToId != nil {
+		return *x.ReplyToId
+	}
+	return ""
+}
+
+func (x *P2PMessageEvent) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type GroupMessageEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            string                 `protobuf:"bytes,1,opt,name=id,proto3" json:"id,omitempty"`
+	SenderId      string                 `protobuf:"bytes,2,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
+	GroupId       string                 `protobuf:"bytes,3,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	Content       string                 `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+	ContentType   int32                  `protobuf:"varint,5,opt,name=content_type,json=contentType,proto3" json:"content_type,omitempty"`
+	ReplyToId     *string                `protobuf:"bytes,6,opt,name=reply_to_id,json=replyToId,proto3,oneof" json:"reply_to_id,omitempty"`
+	ThreadRootId  *string                `protobuf:"bytes,7,opt,name=thread_root_id,json=threadRootId,proto3,oneof" json:"thread_root_id,omitempty"`
+	Mentions      []*Mention             `protobuf:"bytes,8,rep,name=mentions,proto3" json:"mentions,omitempty"`
+	MentionAll    bool                   `protobuf:"varint,9,opt,name=mention_all,json=mentionAll,proto3" json:"mention_all,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,10,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *GroupMessageEvent) Reset() {
+	*x = GroupMessageEvent{}
+	mi := &file_gateway_proto_msgTypes[19]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *GroupMessageEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*GroupMessageEvent) ProtoMessage() {}
+
+func (x *GroupMessageEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[19]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use GroupMessageEvent.ProtoReflect.Descriptor instead.
+func (*GroupMessageEvent) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{19}
+}
+
+func (x *GroupMessageEvent) GetId() string {
+	if x != nil {
+		return x.Id
+	}
+	return ""
+}
+
+func (x *GroupMessageEvent) GetSenderId() string {
+	if x != nil {
+		return x.SenderId
+	}
+	return ""
+}
+
+func (x *GroupMessageEvent) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *GroupMessageEvent) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *GroupMessageEvent) GetContentType() int32 {
+	if x != nil {
+		return x.ContentType
+	}
+	return 0
+}
+
+func (x *GroupMessageEvent) GetReplyToId() string {
+	if x != nil && x.ReplyToId != nil {
+		return *x.ReplyToId
+	}
+	return ""
+}
+
+func (x *GroupMessageEvent) GetThreadRootId() string {
+	if x != nil && x.ThreadRootId != nil {
+		return *x.ThreadRootId
+	}
+	return ""
+}
+
+func (x *GroupMessageEvent) GetMentions() []*Mention {
+	if x != nil {
+		return x.Mentions
+	}
+	return nil
+}
+
+func (x *GroupMessageEvent) GetMentionAll() bool {
+	if x != nil {
+		return x.MentionAll
+	}
+	return false
+}
+
+func (x *GroupMessageEvent) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type MentionEvent struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	GroupId       string                 `protobuf:"bytes,2,opt,name=group_id,json=groupId,proto3" json:"group_id,omitempty"`
+	SenderId      string                 `protobuf:"bytes,3,opt,name=sender_id,json=senderId,proto3" json:"sender_id,omitempty"`
+	Content       string                 `protobuf:"bytes,4,opt,name=content,proto3" json:"content,omitempty"`
+	MentionAll    bool                   `protobuf:"varint,5,opt,name=mention_all,json=mentionAll,proto3" json:"mention_all,omitempty"`
+	Timestamp     int64                  `protobuf:"varint,6,opt,name=timestamp,proto3" json:"timestamp,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MentionEvent) Reset() {
+	*x = MentionEvent{}
+	mi := &file_gateway_proto_msgTypes[20]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MentionEvent) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MentionEvent) ProtoMessage() {}
+
+func (x *MentionEvent) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[20]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MentionEvent.ProtoReflect.Descriptor instead.
+func (*MentionEvent) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{20}
+}
+
+func (x *MentionEvent) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *MentionEvent) GetGroupId() string {
+	if x != nil {
+		return x.GroupId
+	}
+	return ""
+}
+
+func (x *MentionEvent) GetSenderId() string {
+	if x != nil {
+		return x.SenderId
+	}
+	return ""
+}
+
+func (x *MentionEvent) GetContent() string {
+	if x != nil {
+		return x.Content
+	}
+	return ""
+}
+
+func (x *MentionEvent) GetMentionAll() bool {
+	if x != nil {
+		return x.MentionAll
+	}
+	return false
+}
+
+func (x *MentionEvent) GetTimestamp() int64 {
+	if x != nil {
+		return x.Timestamp
+	}
+	return 0
+}
+
+type TypingIndicator struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	UserId        string                 `protobuf:"bytes,1,opt,name=user_id,json=userId,proto3" json:"user_id,omitempty"`
+	IsTyping      bool                   `protobuf:"varint,2,opt,name=is_typing,json=isTyping,proto3" json:"is_typing,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TypingIndicator) Reset() {
+	*x = TypingIndicator{}
+	mi := &file_gateway_proto_msgTypes[21]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TypingIndicator) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TypingIndicator) ProtoMessage() {}
+
+func (x *TypingIndicator) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[21]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TypingIndicator.ProtoReflect.Descriptor instead.
+func (*TypingIndicator) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{21}
+}
+
+func (x *TypingIndicator) GetUserId() string {
+	if x != nil {
+		return x.UserId
+	}
+	return ""
+}
+
+func (x *TypingIndicator) GetIsTyping() bool {
+	if x != nil {
+		return x.IsTyping
+	}
+	return false
+}
+
+type MessageDeleted struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	MessageId     string                 `protobuf:"bytes,1,opt,name=message_id,json=messageId,proto3" json:"message_id,omitempty"`
+	ChatType      string                 `protobuf:"bytes,2,opt,name=chat_type,json=chatType,proto3" json:"chat_type,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *MessageDeleted) Reset() {
+	*x = MessageDeleted{}
+	mi := &file_gateway_proto_msgTypes[22]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *MessageDeleted) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*MessageDeleted) ProtoMessage() {}
+
+func (x *MessageDeleted) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[22]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use MessageDeleted.ProtoReflect.Descriptor instead.
+func (*MessageDeleted) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{22}
+}
+
+func (x *MessageDeleted) GetMessageId() string {
+	if x != nil {
+		return x.MessageId
+	}
+	return ""
+}
+
+func (x *MessageDeleted) GetChatType() string {
+	if x != nil {
+		return x.ChatType
+	}
+	return ""
+}
+
+type ConversationSettings struct {
+	state          protoimpl.MessageState `protogen:"open.v1"`
+	ConversationId string                 `protobuf:"bytes,1,opt,name=conversation_id,json=conversationId,proto3" json:"conversation_id,omitempty"`
+	Muted          bool                   `protobuf:"varint,2,opt,name=muted,proto3" json:"muted,omitempty"`
+	MutedUntil     *timestamppb.Timestamp `protobuf:"bytes,3,opt,name=muted_until,json=mutedUntil,proto3" json:"muted_until,omitempty"`
+	Pinned         bool                   `protobuf:"varint,4,opt,name=pinned,proto3" json:"pinned,omitempty"`
+	PinOrder       int32                  `protobuf:"varint,5,opt,name=pin_order,json=pinOrder,proto3" json:"pin_order,omitempty"`
+	Archived       bool                   `protobuf:"varint,6,opt,name=archived,proto3" json:"archived,omitempty"`
+	MarkedUnread   bool                   `protobuf:"varint,7,opt,name=marked_unread,json=markedUnread,proto3" json:"marked_unread,omitempty"`
+	UpdatedAt      *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields  protoimpl.UnknownFields
+	sizeCache      protoimpl.SizeCache
+}
+
+func (x *ConversationSettings) Reset() {
+	*x = ConversationSettings{}
+	mi := &file_gateway_proto_msgTypes[23]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConversationSettings) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConversationSettings) ProtoMessage() {}
+
+func (x *ConversationSettings) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[23]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConversationSettings.ProtoReflect.Descriptor instead.
+func (*ConversationSettings) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{23}
+}
+
+func (x *ConversationSettings) GetConversationId() string {
+	if x != nil {
+		return x.ConversationId
+	}
+	return ""
+}
+
+func (x *ConversationSettings) GetMuted() bool {
+	if x != nil {
+		return x.Muted
+	}
+	return false
+}
+
+func (x *ConversationSettings) GetMutedUntil() *timestamppb.Timestamp {
+	if x != nil {
+		return x.MutedUntil
+	}
+	return nil
+}
+
+func (x *ConversationSettings) GetPinned() bool {
+	if x != nil {
+		return x.Pinned
+	}
+	return false
+}
+
+func (x *ConversationSettings) GetPinOrder() int32 {
+	if x != nil {
+		return x.PinOrder
+	}
+	return 0
+}
+
+func (x *ConversationSettings) GetArchived() bool {
+	if x != nil {
+		return x.Archived
+	}
+	return false
+}
+
+func (x *ConversationSettings) GetMarkedUnread() bool {
+	if x != nil {
+		return x.MarkedUnread
+	}
+	return false
+}
+
+func (x *ConversationSettings) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+type ConversationPins struct {
+	state         protoimpl.MessageState  `protogen:"open.v1"`
+	Pinned        []*ConversationSettings `protobuf:"bytes,1,rep,name=pinned,proto3" json:"pinned,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ConversationPins) Reset() {
+	*x = ConversationPins{}
+	mi := &file_gateway_proto_msgTypes[24]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ConversationPins) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ConversationPins) ProtoMessage() {}
+
+func (x *ConversationPins) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[24]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ConversationPins.ProtoReflect.Descriptor instead.
+func (*ConversationPins) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{24}
+}
+
+func (x *ConversationPins) GetPinned() []*ConversationSettings {
+	if x != nil {
+		return x.Pinned
+	}
+	return nil
+}
+
+type SessionRevoked struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	DeviceId      string                 `protobuf:"bytes,1,opt,name=device_id,json=deviceId,proto3" json:"device_id,omitempty"`
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *SessionRevoked) Reset() {
+	*x = SessionRevoked{}
+	mi := &file_gateway_proto_msgTypes[25]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SessionRevoked) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SessionRevoked) ProtoMessage() {}
+
+func (x *SessionRevoked) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[25]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SessionRevoked.ProtoReflect.Descriptor instead.
+func (*SessionRevoked) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{25}
+}
+
+func (x *SessionRevoked) GetDeviceId() string {
+	if x != nil {
+		return x.DeviceId
+	}
+	return ""
+}
+
+func (x *SessionRevoked) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+type TokenExpiry struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	ExpiresAt     int64                  `protobuf:"varint,1,opt,name=expires_at,json=expiresAt,proto3" json:"expires_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *TokenExpiry) Reset() {
+	*x = TokenExpiry{}
+	mi := &file_gateway_proto_msgTypes[26]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *TokenExpiry) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*TokenExpiry) ProtoMessage() {}
+
+func (x *TokenExpiry) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[26]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use TokenExpiry.ProtoReflect.Descriptor instead.
+func (*TokenExpiry) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{26}
+}
+
+func (x *TokenExpiry) GetExpiresAt() int64 {
+	if x != nil {
+		return x.ExpiresAt
+	}
+	return 0
+}
+
+type Reconnect struct {
+	state            protoimpl.MessageState `protogen:"open.v1"`
+	Reason           string                 `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"`
+	ReconnectAfterMs int64                  `protobuf:"varint,2,opt,name=reconnect_after_ms,json=reconnectAfterMs,proto3" json:"reconnect_after_ms,omitempty"`
+	unknownFields    protoimpl.UnknownFields
+	sizeCache        protoimpl.SizeCache
+}
+
+func (x *Reconnect) Reset() {
+	*x = Reconnect{}
+	mi := &file_gateway_proto_msgTypes[27]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Reconnect) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Reconnect) ProtoMessage() {}
+
+func (x *Reconnect) ProtoReflect() protoreflect.Message {
+	mi := &file_gateway_proto_msgTypes[27]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Reconnect.ProtoReflect.Descriptor instead.
+func (*Reconnect) Descriptor() ([]byte, []int) {
+	return file_gateway_proto_rawDescGZIP(), []int{27}
+}
+
+func (x *Reconnect) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
+func (x *Reconnect) GetReconnectAfterMs() int64 {
+	if x != nil {
+		return x.ReconnectAfterMs
+	}
+	return 0
+}
+
+var File_gateway_proto protoreflect.FileDescriptor
+
+const file_gateway_proto_rawDesc = "" +
+	"\n" +
+	"\rgateway.proto\x12\n" +
+	"gateway.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\x84\x06\n" +
+	"\vClientFrame\x12\f\n" +
+	"\x01v\x18\x01 \x01(\rR\x01v\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12 \n" +
+	"\vtraceparent\x18\x04 \x01(\tR\vtraceparent\x12\x1e\n" +
+	"\n" +
+	"tracestate\x18\x05 \x01(\tR\n" +
+	"tracestate\x12F\n" +
+	"\x10send_p2p_message\x18\n" +
+	" \x01(\v2\x1a.gateway.v1.SendP2PRequestH\x00R\x0esendP2pMessage\x12L\n" +
+	"\x12send_group_message\x18\v \x01(\v2\x1c.gateway.v1.SendGroupRequestH\x00R\x10sendGroupMessage\x123\n" +
+	"\x06typing\x18\f \x01(\v2\x19.gateway.v1.TypingRequestH\x00R\x06typing\x12C\n" +
+	"\fedit_message\x18\r \x01(\v2\x1e.gateway.v1.EditMessageRequestH\x00R\veditMessage\x12I\n" +
+	"\x0erecall_message\x18\x0e \x01(\v2 .gateway.v1.RecallMessageRequestH\x00R\rrecallMessage\x12I\n" +
+	"\x0edelete_message\x18\x0f \x01(\v2 .gateway.v1.DeleteMessageRequestH\x00R\rdeleteMessage\x12@\n" +
+	"\fadd_reaction\x18\x10 \x01(\v2\x1b.gateway.v1.ReactionRequestH\x00R\vaddReaction\x12F\n" +
+	"\x0fremove_reaction\x18\x11 \x01(\v2\x1b.gateway.v1.ReactionRequestH\x00R\x0eremoveReaction\x12F\n" +
+	"\rrefresh_token\x18\x12 \x01(\v2\x1f.gateway.v1.RefreshTokenRequestH\x00R\frefreshTokenB\t\n" +
+	"\apayload\"\x8f\n" +
+	"\n" +
+	"\vServerFrame\x12\f\n" +
+	"\x01v\x18\x01 \x01(\rR\x01v\x12\x0e\n" +
+	"\x02id\x18\x02 \x01(\tR\x02id\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x1c\n" +
+	"\ttimestamp\x18\x04 \x01(\x03R\ttimestamp\x12\x16\n" +
+	"\x06silent\x18\x05 \x01(\bR\x06silent\x12\x19\n" +
+	"\btrace_id\x18\x06 \x01(\tR\atraceId\x12Z\n" +
+	"\x16connection_established\x18\n" +
+	" \x01(\v2!.gateway.v1.ConnectionEstablishedH\x00R\x15connectionEstablished\x120\n" +
+	"\x05error\x18\v \x01(\v2\x18.gateway.v1.ErrorPayloadH\x00R\x05error\x12H\n" +
+	"\x10message_response\x18\f \x01(\v2\x1b.gateway.v1.MessageResponseH\x00R\x0fmessageResponse\x12B\n" +
+	"\x0emessage_update\x18\r \x01(\v2\x19.gateway.v1.MessageUpdateH\x00R\rmessageUpdate\x12E\n" +
+	"\x0freaction_update\x18\x0e \x01(\v2\x1a.gateway.v1.ReactionUpdateH\x00R\x0ereactionUpdate\x12R\n" +
+	"\x14refresh_token_result\x18\x0f \x01(\v2\x1e.gateway.v1.RefreshTokenResultH\x00R\x12refreshTokenResult\x12E\n" +
+	"\x0fnew_p2p_message\x18\x10 \x01(\v2\x1b.gateway.v1.P2PMessageEventH\x00R\rnewP2pMessage\x12K\n" +
+	"\x11new_group_message\x18\x11 \x01(\v2\x1d.gateway.v1.GroupMessageEventH\x00R\x0fnewGroupMessage\x124\n" +
+	"\amention\x18\x12 \x01(\v2\x18.gateway.v1.MentionEventH\x00R\amention\x12H\n" +
+	"\x10typing_indicator\x18\x13 \x01(\v2\x1b.gateway.v1.TypingIndicatorH\x00R\x0ftypingIndicator\x12E\n" +
+	"\x0fmessage_deleted\x18\x14 \x01(\v2\x1a.gateway.v1.MessageDeletedH\x00R\x0emessageDeleted\x12W\n" +
+	"\x15conversation_settings\x18\x15 \x01(\v2 .gateway.v1.ConversationSettingsH\x00R\x14conversationSettings\x12K\n" +
+	"\x11conversation_pins\x18\x16 \x01(\v2\x1c.gateway.v1.ConversationPinsH\x00R\x10conversationPins\x12E\n" +
+	"\x0fsession_revoked\x18\x17 \x01(\v2\x1a.gateway.v1.SessionRevokedH\x00R\x0esessionRevoked\x12<\n" +
+	"\ftoken_expiry\x18\x18 \x01(\v2\x17.gateway.v1.TokenExpiryH\x00R\vtokenExpiry\x125\n" +
+	"\treconnect\x18\x19 \x01(\v2\x15.gateway.v1.ReconnectH\x00R\treconnectB\t\n" +
+	"\apayload\"\xa3\x01\n" +
+	"\x0eSendP2PRequest\x12\x1f\n" +
+	"\vreceiver_id\x18\x01 \x01(\tR\n" +
+	"receiverId\x12\x18\n" +
+	"\acontent\x18\x02 \x01(\tR\acontent\x12!\n" +
+	"\fcontent_type\x18\x03 \x01(\x05R\vcontentType\x12#\n" +
+	"\vreply_to_id\x18\x04 \x01(\tH\x00R\treplyToId\x88\x01\x01B\x0e\n" +
+	"\f_reply_to_id\"\x9f\x01\n" +
+	"\x10SendGroupRequest\x12\x19\n" +
+	"\bgroup_id\x18\x01 \x01(\tR\agroupId\x12\x18\n" +
+	"\acontent\x18\x02 \x01(\tR\acontent\x12!\n" +
+	"\fcontent_type\x18\x03 \x01(\x05R\vcontentType\x12#\n" +
+	"\vreply_to_id\x18\x04 \x01(\tH\x00R\treplyToId\x88\x01\x01B\x0e\n" +
+	"\f_reply_to_id\"M\n" +
+	"\rTypingRequest\x12\x1f\n" +
+	"\vreceiver_id\x18\x01 \x01(\tR\n" +
+	"receiverId\x12\x1b\n" +
+	"\tis_typing\x18\x02 \x01(\bR\bisTyping\"\x8d\x01\n" +
+	"\x12EditMessageRequest\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x1b\n" +
+	"\tchat_type\x18\x02 \x01(\tR\bchatType\x12\x18\n" +
+	"\acontent\x18\x03 \x01(\tR\acontent\x12!\n" +
+	"\fcontent_type\x18\x04 \x01(\x05R\vcontentType\"R\n" +
+	"\x14RecallMessageRequest\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x1b\n" +
+	"\tchat_type\x18\x02 \x01(\tR\bchatType\"R\n" +
+	"\x14DeleteMessageRequest\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x1b\n" +
+	"\tchat_type\x18\x02 \x01(\tR\bchatType\"c\n" +
+	"\x0fReactionRequest\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x1b\n" +
+	"\tchat_type\x18\x02 \x01(\tR\bchatType\x12\x14\n" +
+	"\x05emoji\x18\x03 \x01(\tR\x05emoji\"+\n" +
+	"\x13RefreshTokenRequest\x12\x14\n" +
+	"\x05token\x18\x01 \x01(\tR\x05token\"\xa7\x01\n" +
+	"\x15ConnectionEstablished\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tdevice_id\x18\x02 \x01(\tR\bdeviceId\x12)\n" +
+	"\x10protocol_version\x18\x03 \x01(\rR\x0fprotocolVersion\x12-\n" +
+	"\x12supported_versions\x18\x04 \x03(\rR\x11supportedVersions\"\x9b\x01\n" +
+	"\fErrorPayload\x12\x12\n" +
+	"\x04code\x18\x01 \x01(\tR\x04code\x12\x18\n" +
+	"\amessage\x18\x02 \x01(\tR\amessage\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x12!\n" +
+	"\frequest_type\x18\x04 \x01(\tR\vrequestType\x12$\n" +
+	"\x0eretry_after_ms\x18\x05 \x01(\x03R\fretryAfterMs\"n\n" +
+	"\aMention\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1a\n" +
+	"\busername\x18\x02 \x01(\tR\busername\x12\x16\n" +
+	"\x06offset\x18\x03 \x01(\x05R\x06offset\x12\x16\n" +
+	"\x06length\x18\x04 \x01(\x05R\x06length\"\xa5\x02\n" +
+	"\x0fMessageResponse\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x18\n" +
+	"\asuccess\x18\x02 \x01(\bR\asuccess\x12\x14\n" +
+	"\x05error\x18\x03 \x01(\tR\x05error\x12)\n" +
+	"\x0ethread_root_id\x18\x04 \x01(\tH\x00R\fthreadRootId\x88\x01\x01\x12/\n" +
+	"\bmentions\x18\x05 \x03(\v2\x13.gateway.v1.MentionR\bmentions\x12\x1f\n" +
+	"\vmention_all\x18\x06 \x01(\bR\n" +
+	"mentionAll\x12$\n" +
+	"\x0emuted_user_ids\x18\a \x03(\tR\fmutedUserIds\x12\x1c\n" +
+	"\ttimestamp\x18\b \x01(\x03R\ttimestampB\x11\n" +
+	"\x0f_thread_root_id\"\xbe\x02\n" +
+	"\rMessageUpdate\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1b\n" +
+	"\tchat_type\x18\x02 \x01(\tR\bchatType\x12\x1b\n" +
+	"\tsender_id\x18\x03 \x01(\tR\bsenderId\x12\x1f\n" +
+	"\vreceiver_id\x18\x04 \x01(\tR\n" +
+	"receiverId\x12\x19\n" +
+	"\bgroup_id\x18\x05 \x01(\tR\agroupId\x12\x18\n" +
+	"\acontent\x18\x06 \x01(\tR\acontent\x12!\n" +
+	"\fcontent_type\x18\a \x01(\x05R\vcontentType\x12\x16\n" +
+	"\x06edited\x18\b \x01(\bR\x06edited\x12\x1a\n" +
+	"\brecalled\x18\t \x01(\bR\brecalled\x12\x18\n" +
+	"\aversion\x18\n" +
+	" \x01(\x05R\aversion\x12\x1c\n" +
+	"\ttimestamp\x18\v \x01(\x03R\ttimestamp\"X\n" +
+	"\x0fReactionSummary\x12\x14\n" +
+	"\x05emoji\x18\x01 \x01(\tR\x05emoji\x12\x14\n" +
+	"\x05count\x18\x02 \x01(\x05R\x05count\x12\x19\n" +
+	"\buser_ids\x18\x03 \x03(\tR\auserIds\"\xc3\x02\n" +
+	"\x0eReactionUpdate\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x1b\n" +
+	"\tchat_type\x18\x02 \x01(\tR\bchatType\x12\x1b\n" +
+	"\tsender_id\x18\x03 \x01(\tR\bsenderId\x12\x1f\n" +
+	"\vreceiver_id\x18\x04 \x01(\tR\n" +
+	"receiverId\x12\x19\n" +
+	"\bgroup_id\x18\x05 \x01(\tR\agroupId\x12\x17\n" +
+	"\auser_id\x18\x06 \x01(\tR\x06userId\x12\x14\n" +
+	"\x05emoji\x18\a \x01(\tR\x05emoji\x12\x14\n" +
+	"\x05added\x18\b \x01(\bR\x05added\x129\n" +
+	"\treactions\x18\t \x03(\v2\x1b.gateway.v1.ReactionSummaryR\treactions\x12\x1c\n" +
+	"\ttimestamp\x18\n" +
+	" \x01(\x03R\ttimestamp\"3\n" +
+	"\x12RefreshTokenResult\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x01 \x01(\x03R\texpiresAt\"\xef\x01\n" +
+	"\x0fP2PMessageEvent\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1b\n" +
+	"\tsender_id\x18\x02 \x01(\tR\bsenderId\x12\x1f\n" +
+	"\vreceiver_id\x18\x03 \x01(\tR\n" +
+	"receiverId\x12\x18\n" +
+	"\acontent\x18\x04 \x01(\tR\acontent\x12!\n" +
+	"\fcontent_type\x18\x05 \x01(\x05R\vcontentType\x12#\n" +
+	"\vreply_to_id\x18\x06 \x01(\tH\x00R\treplyToId\x88\x01\x01\x12\x1c\n" +
+	"\ttimestamp\x18\a \x01(\x03R\ttimestampB\x0e\n" +
+	"\f_reply_to_id\"\xfb\x02\n" +
+	"\x11GroupMessageEvent\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\tR\x02id\x12\x1b\n" +
+	"\tsender_id\x18\x02 \x01(\tR\bsenderId\x12\x19\n" +
+	"\bgroup_id\x18\x03 \x01(\tR\agroupId\x12\x18\n" +
+	"\acontent\x18\x04 \x01(\tR\acontent\x12!\n" +
+	"\fcontent_type\x18\x05 \x01(\x05R\vcontentType\x12#\n" +
+	"\vreply_to_id\x18\x06 \x01(\tH\x00R\treplyToId\x88\x01\x01\x12)\n" +
+	"\x0ethread_root_id\x18\a \x01(\tH\x01R\fthreadRootId\x88\x01\x01\x12/\n" +
+	"\bmentions\x18\b \x03(\v2\x13.gateway.v1.MentionR\bmentions\x12\x1f\n" +
+	"\vmention_all\x18\t \x01(\bR\n" +
+	"mentionAll\x12\x1c\n" +
+	"\ttimestamp\x18\n" +
+	" \x01(\x03R\ttimestampB\x0e\n" +
+	"\f_reply_to_idB\x11\n" +
+	"\x0f_thread_root_id\"\xbe\x01\n" +
+	"\fMentionEvent\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x19\n" +
+	"\bgroup_id\x18\x02 \x01(\tR\agroupId\x12\x1b\n" +
+	"\tsender_id\x18\x03 \x01(\tR\bsenderId\x12\x18\n" +
+	"\acontent\x18\x04 \x01(\tR\acontent\x12\x1f\n" +
+	"\vmention_all\x18\x05 \x01(\bR\n" +
+	"mentionAll\x12\x1c\n" +
+	"\ttimestamp\x18\x06 \x01(\x03R\ttimestamp\"G\n" +
+	"\x0fTypingIndicator\x12\x17\n" +
+	"\auser_id\x18\x01 \x01(\tR\x06userId\x12\x1b\n" +
+	"\tis_typing\x18\x02 \x01(\bR\bisTyping\"L\n" +
+	"\x0eMessageDeleted\x12\x1d\n" +
+	"\n" +
+	"message_id\x18\x01 \x01(\tR\tmessageId\x12\x1b\n" +
+	"\tchat_type\x18\x02 \x01(\tR\bchatType\"\xc3\x02\n" +
+	"\x14ConversationSettings\x12'\n" +
+	"\x0fconversation_id\x18\x01 \x01(\tR\x0econversationId\x12\x14\n" +
+	"\x05muted\x18\x02 \x01(\bR\x05muted\x12;\n" +
+	"\vmuted_until\x18\x03 \x01(\v2\x1a.google.protobuf.TimestampR\n" +
+	"mutedUntil\x12\x16\n" +
+	"\x06pinned\x18\x04 \x01(\bR\x06pinned\x12\x1b\n" +
+	"\tpin_order\x18\x05 \x01(\x05R\bpinOrder\x12\x1a\n" +
+	"\barchived\x18\x06 \x01(\bR\barchived\x12#\n" +
+	"\rmarked_unread\x18\a \x01(\bR\fmarkedUnread\x129\n" +
+	"\n" +
+	"updated_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAt\"L\n" +
+	"\x10ConversationPins\x128\n" +
+	"\x06pinned\x18\x01 \x03(\v2 .gateway.v1.ConversationSettingsR\x06pinned\"E\n" +
+	"\x0eSessionRevoked\x12\x1b\n" +
+	"\tdevice_id\x18\x01 \x01(\tR\bdeviceId\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\",\n" +
+	"\vTokenExpiry\x12\x1d\n" +
+	"\n" +
+	"expires_at\x18\x01 \x01(\x03R\texpiresAt\"Q\n" +
+	"\tReconnect\x12\x16\n" +
+	"\x06reason\x18\x01 \x01(\tR\x06reason\x12,\n" +
+	"\x12reconnect_after_ms\x18\x02 \x01(\x03R\x10reconnectAfterMsBNZLgithub.com/huangrao121/CommunicationApp/BackendService/internal/pb/gatewaypbb\x06proto3"
+
+var (
+	file_gateway_proto_rawDescOnce sync.Once
+	file_gateway_proto_rawDescData []byte
+)
+
+func file_gateway_proto_rawDescGZIP() []byte {
+	file_gateway_proto_rawDescOnce.Do(func() {
+		file_gateway_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_gateway_proto_rawDesc), len(file_gateway_proto_rawDesc)))
+	})
+	return file_gateway_proto_rawDescData
+}
+
+var file_gateway_proto_msgTypes = make([]protoimpl.MessageInfo, 28)
+var file_gateway_proto_goTypes = []any{
+	(*ClientFrame)(nil),           // 0: gateway.v1.ClientFrame
+	(*ServerFrame)(nil),           // 1: gateway.v1.ServerFrame
+	(*SendP2PRequest)(nil),        // 2: gateway.v1.SendP2PRequest
+	(*SendGroupRequest)(nil),      // 3: gateway.v1.SendGroupRequest
+	(*TypingRequest)(nil),         // 4: gateway.v1.TypingRequest
+	(*EditMessageRequest)(nil),    // 5: gateway.v1.EditMessageRequest
+	(*RecallMessageRequest)(nil),  // 6: gateway.v1.RecallMessageRequest
+	(*DeleteMessageRequest)(nil),  // 7: gateway.v1.DeleteMessageRequest
+	(*ReactionRequest)(nil),       // 8: gateway.v1.ReactionRequest
+	(*RefreshTokenRequest)(nil),   // 9: gateway.v1.RefreshTokenRequest
+	(*ConnectionEstablished)(nil), // 10: gateway.v1.ConnectionEstablished
+	(*ErrorPayload)(nil),          // 11: gateway.v1.ErrorPayload
+	(*Mention)(nil),               // 12: gateway.v1.Mention
+	(*MessageResponse)(nil),       // 13: gateway.v1.MessageResponse
+	(*MessageUpdate)(nil),         // 14: gateway.v1.MessageUpdate
+	(*ReactionSummary)(nil),       // 15: gateway.v1.ReactionSummary
+	(*ReactionUpdate)(nil),        // 16: gateway.v1.ReactionUpdate
+	(*RefreshTokenResult)(nil),    // 17: gateway.v1.RefreshTokenResult
+	(*P2PMessageEvent)(nil),       // 18: gateway.v1.P2PMessageEvent
+	(*GroupMessageEvent)(nil),     // 19: gateway.v1.GroupMessageEvent
+	(*MentionEvent)(nil),          // 20: gateway.v1.MentionEvent
+	(*TypingIndicator)(nil),       // 21: gateway.v1.TypingIndicator
+	(*MessageDeleted)(nil),        // 22: gateway.v1.MessageDeleted
+	(*ConversationSettings)(nil),  // 23: gateway.v1.ConversationSettings
+	(*ConversationPins)(nil),      // 24: gateway.v1.ConversationPins
+	(*SessionRevoked)(nil),        // 25: gateway.v1.SessionRevoked
+	(*TokenExpiry)(nil),           // 26: gateway.v1.TokenExpiry
+	(*Reconnect)(nil),             // 27: gateway.v1.Reconnect
+	(*timestamppb.Timestamp)(nil), // 28: google.protobuf.Timestamp
+}
+var file_gateway_proto_depIdxs = []int32{
+	2,  // 0: gateway.v1.ClientFrame.send_p2p_message:type_name -> gateway.v1.SendP2PRequest
+	3,  // 1: gateway.v1.ClientFrame.send_group_message:type_name -> gateway.v1.SendGroupRequest
+	4,  // 2: gateway.v1.ClientFrame.typing:type_name -> gateway.v1.TypingRequest
+	5,  // 3: gateway.v1.ClientFrame.edit_message:type_name -> gateway.v1.EditMessageRequest
+	6,  // 4: gateway.v1.ClientFrame.recall_message:type_name -> gateway.v1.RecallMessageRequest
+	7,  // 5: gateway.v1.ClientFrame.delete_message:type_name -> gateway.v1.DeleteMessageRequest
+	8,  // 6: gateway.v1.ClientFrame.add_reaction:type_name -> gateway.v1.ReactionRequest
+	8,  // 7: gateway.v1.ClientFrame.remove_reaction:type_name -> gateway.v1.ReactionRequest
+	9,  // 8: gateway.v1.ClientFrame.refresh_token:type_name -> gateway.v1.RefreshTokenRequest
+	10, // 9: gateway.v1.ServerFrame.connection_established:type_name -> gateway.v1.ConnectionEstablished
+	11, // 10: gateway.v1.ServerFrame.error:type_name -> gateway.v1.ErrorPayload
+	13, // 11: gateway.v1.ServerFrame.message_response:type_name -> gateway.v1.MessageResponse
+	14, // 12: gateway.v1.ServerFrame.message_update:type_name -> gateway.v1.MessageUpdate
+	16, // 13: gateway.v1.ServerFrame.reaction_update:type_name -> gateway.v1.ReactionUpdate
+	17, // 14: gateway.v1.ServerFrame.refresh_token_result:type_name -> gateway.v1.RefreshTokenResult
+	18, // 15: gateway.v1.ServerFrame.new_p2p_message:type_name -> gateway.v1.P2PMessageEvent
+	19, // 16: gateway.v1.ServerFrame.new_group_message:type_name -> gateway.v1.GroupMessageEvent
+	20, // 17: gateway.v1.ServerFrame.mention:type_name -> gateway.v1.MentionEvent
+	21, // 18: gateway.v1.ServerFrame.typing_indicator:type_name -> gateway.v1.TypingIndicator
+	22, // 19: gateway.v1.ServerFrame.message_deleted:type_name -> gateway.v1.MessageDeleted
+	23, // 20: gateway.v1.ServerFrame.conversation_settings:type_name -> gateway.v1.ConversationSettings
+	24, // 21: gateway.v1.ServerFrame.conversation_pins:type_name -> gateway.v1.ConversationPins
+	25, // 22: gateway.v1.ServerFrame.session_revoked:type_name -> gateway.v1.SessionRevoked
+	26, // 23: gateway.v1.ServerFrame.token_expiry:type_name -> gateway.v1.TokenExpiry
+	27, // 24: gateway.v1.ServerFrame.reconnect:type_name -> gateway.v1.Reconnect
+	12, // 25: gateway.v1.MessageResponse.mentions:type_name -> gateway.v1.Mention
+	15, // 26: gateway.v1.ReactionUpdate.reactions:type_name -> gateway.v1.ReactionSummary
+	12, // 27: gateway.v1.GroupMessageEvent.mentions:type_name -> gateway.v1.Mention
+	28, // 28: gateway.v1.ConversationSettings.muted_until:type_name -> google.protobuf.Timestamp
+	28, // 29: gateway.v1.ConversationSettings.updated_at:type_name -> google.protobuf.Timestamp
+	23, // 30: gateway.v1.ConversationPins.pinned:type_name -> gateway.v1.ConversationSettings
+	31, // [31:31] is the sub-list for method output_type
+	31, // [31:31] is the sub-list for method input_type
+	31, // [31:31] is the sub-list for extension type_name
+	31, // [31:31] is the sub-list for extension extendee
+	0,  // [0:31] is the sub-list for field type_name
+}
+
+func init() { file_gateway_proto_init() }
+func file_gateway_proto_init() {
+	if File_gateway_proto != nil {
+		return
+	}
+	file_gateway_proto_msgTypes[0].OneofWrappers = []any{
+		(*ClientFrame_SendP2PMessage)(nil),
+		(*ClientFrame_SendGroupMessage)(nil),
+		(*ClientFrame_Typing)(nil),
+		(*ClientFrame_EditMessage)(nil),
+		(*ClientFrame_RecallMessage)(nil),
+		(*ClientFrame_DeleteMessage)(nil),
+		(*ClientFrame_AddReaction)(nil),
+		(*ClientFrame_RemoveReaction)(nil),
+		(*ClientFrame_RefreshToken)(nil),
+	}
+	file_gateway_proto_msgTypes[1].OneofWrappers = []any{
+		(*ServerFrame_ConnectionEstablished)(nil),
+		(*ServerFrame_Error)(nil),
+		(*ServerFrame_MessageResponse)(nil),
+		(*ServerFrame_MessageUpdate)(nil),
+		(*ServerFrame_ReactionUpdate)(nil),
+		(*ServerFrame_RefreshTokenResult)(nil),
+		(*ServerFrame_NewP2PMessage)(nil),
+		(*ServerFrame_NewGroupMessage)(nil),
+		(*ServerFrame_Mention)(nil),
+		(*ServerFrame_TypingIndicator)(nil),
+		(*ServerFrame_MessageDeleted)(nil),
+		(*ServerFrame_ConversationSettings)(nil),
+		(*ServerFrame_ConversationPins)(nil),
+		(*ServerFrame_SessionRevoked)(nil),
+		(*ServerFrame_TokenExpiry)(nil),
+		(*ServerFrame_Reconnect)(nil),
+	}
+	file_gateway_proto_msgTypes[2].OneofWrappers = []any{}
+	file_gateway_proto_msgTypes[3].OneofWrappers = []any{}
+	file_gateway_proto_msgTypes[13].OneofWrappers = []any{}
+	file_gateway_proto_msgTypes[18].OneofWrappers = []any{}
+	file_gateway_proto_msgTypes[19].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_gateway_proto_rawDesc), len(file_gateway_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   28,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_gateway_proto_goTypes,
+		DependencyIndexes: file_gateway_proto_depIdxs,
+		MessageInfos:      file_gateway_proto_msgTypes,
+	}.Build()
+	File_gateway_proto = out.File
+	file_gateway_proto_goTypes = nil
+	file_gateway_proto_depIdxs = nil
+}
@@ -0,0 +1,38 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 管理员操作
+const (
+	AuditActionSuspendUser      = "suspend_user"
+	AuditActionBanUser          = "ban_user"
+	AuditActionReinstateUser    = "reinstate_user"
+	AuditActionDeleteMessage    = "delete_message"
+	AuditActionResolveReport    = "resolve_report"
+	AuditActionDismissReport    = "dismiss_report"
+	AuditActionReviewModeration = "review_moderation"
+)
+
+// 操作对象
+const (
+	AuditTargetUser       = "user"
+	AuditTargetMessage    = "message"
+	AuditTargetReport     = "report"
+	AuditTargetModeration = "moderation_record"
+)
+
+// AdminAuditLogs 管理员操作记录，只能追加。数据库触发器拒绝对这张表的UPDATE、DELETE和TRUNCATE
+type AdminAuditLogs struct {
+	ID         uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4();column:id" json:"id"`
+	AdminID    uuid.UUID `gorm:"not null;column:admin_id;index" json:"admin_id"`
+	Action     string    `gorm:"not null;column:action" json:"action"`
+	TargetType string    `gorm:"not null;column:target_type" json:"target_type"`
+	TargetID   uuid.UUID `gorm:"not null;column:target_id;index" json:"target_id"`
+	Reason     string    `gorm:"column:reason" json:"reason,omitempty"`
+	Details    string    `gorm:"column:details" json:"details,omitempty"`
+	CreatedAt  time.Time `gorm:"index" json:"created_at"`
+}
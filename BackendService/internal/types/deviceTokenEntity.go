@@ -0,0 +1,27 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	PlatformAPNs    = "apns"
+	PlatformFCM     = "fcm"
+	PlatformWebPush = "webpush"
+)
+
+// DeviceTokens 用户设备的推送凭证。Web Push的Token是订阅的endpoint，
+// P256dh和Auth是浏览器订阅时返回的加密密钥，其它平台为空
+type DeviceTokens struct {
+	ID         uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4();column:id" json:"id"`
+	UserID     uuid.UUID `gorm:"not null;column:user_id;index" json:"user_id"`
+	Platform   string    `gorm:"not null;column:platform;uniqueIndex:idx_device_platform_token" json:"platform"`
+	Token      string    `gorm:"not null;column:token;uniqueIndex:idx_device_platform_token" json:"token"`
+	P256dh     string    `gorm:"column:p256dh" json:"-"`
+	Auth       string    `gorm:"column:auth" json:"-"`
+	AppVersion string    `gorm:"column:app_version" json:"app_version"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
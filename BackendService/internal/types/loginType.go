@@ -11,3 +11,16 @@ type MqttClaims struct {
 	Email    string        `json:"email"`
 	ACL      []interface{} `json:"acl"`
 }
+
+// SignupReq 注册请求，Users的Password不参与JSON序列化，注册和登录使用单独的请求结构
+type SignupReq struct {
+	Username string `json:"username" binding:"required"`
+	Nickname string `json:"nickname"`
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=8"`
+}
+
+type LoginReq struct {
+	Email    string `json:"email" binding:"required"`
+	Password string `json:"password" binding:"required"`
+}
@@ -0,0 +1,33 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+const (
+	ChatTypeP2P   = "p2p"
+	ChatTypeGroup = "group"
+)
+
+// MessageEdits 保存消息每次被编辑前的版本，按Version递增
+type MessageEdits struct {
+	ID          uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4();column:id" json:"id"`
+	MessageID   uuid.UUID `gorm:"not null;column:message_id;uniqueIndex:idx_message_version" json:"message_id"`
+	ChatType    string    `gorm:"not null;column:chat_type" json:"chat_type"`
+	Version     int       `gorm:"not null;column:version;uniqueIndex:idx_message_version" json:"version"`
+	Content     string    `gorm:"not null;column:content" json:"content"`
+	ContentType int       `gorm:"not null;column:content_type" json:"content_type"`
+	EditorID    uuid.UUID `gorm:"not null;column:editor_id" json:"editor_id"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// MessageDeletions 记录用户"仅对自己删除"的消息，查询历史时过滤掉
+type MessageDeletions struct {
+	ID        uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4();column:id" json:"id"`
+	MessageID uuid.UUID `gorm:"not null;column:message_id;uniqueIndex:idx_deletion_message_user" json:"message_id"`
+	UserID    uuid.UUID `gorm:"not null;column:user_id;uniqueIndex:idx_deletion_message_user" json:"user_id"`
+	ChatType  string    `gorm:"not null;column:chat_type" json:"chat_type"`
+	CreatedAt time.Time `json:"created_at"`
+}
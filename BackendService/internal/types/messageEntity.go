@@ -12,27 +12,50 @@ type P2PMessages struct {
 	Sender      Users
 	ReceiverID  uuid.UUID `gorm:"not null;column:receiver_id;index"`
 	Receiver    Users
-	Content     string `gorm:"not null;column:content"`
-	ContentType int    `gorm:"not null;column:content_type"`
+	Content     string     `gorm:"not null;column:content"`
+	ContentType int        `gorm:"not null;column:content_type"`
+	ReplyToID   *uuid.UUID `gorm:"type:uuid;column:reply_to_id;index"`
+	Edited      bool       `gorm:"column:edited;default:false"`
+	EditedAt    *time.Time `gorm:"column:edited_at"`
+	Recalled    bool       `gorm:"column:recalled;default:false"`
+	RecalledAt  *time.Time `gorm:"column:recalled_at"`
 	CreatedAt   time.Time
+	UpdatedAt   time.Time
 }
 
 type GroupMessages struct {
-	ID          uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4();column:id"`
-	SenderID    uuid.UUID `gorm:"not null;column:sender_id;index"`
-	Sender      Users
-	Content     string    `gorm:"not null;column:content"`
-	ContentType int       `gorm:"not null;column:content_type"`
-	GroupID     uuid.UUID `gorm:"not null;column:group_id;index"`
-	Group       Groups
-	CreatedAt   time.Time
+	ID           uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4();column:id"`
+	SenderID     uuid.UUID `gorm:"not null;column:sender_id;index"`
+	Sender       Users
+	Content      string    `gorm:"not null;column:content"`
+	ContentType  int       `gorm:"not null;column:content_type"`
+	GroupID      uuid.UUID `gorm:"not null;column:group_id;index"`
+	Group        Groups
+	ReplyToID    *uuid.UUID      `gorm:"type:uuid;column:reply_to_id;index"`
+	ThreadRootID *uuid.UUID      `gorm:"type:uuid;column:thread_root_id;index"`
+	Mentions     []MentionEntity `gorm:"type:jsonb;serializer:json;column:mentions"`
+	MentionAll   bool            `gorm:"column:mention_all;default:false"`
+	Edited       bool            `gorm:"column:edited;default:false"`
+	EditedAt     *time.Time      `gorm:"column:edited_at"`
+	Recalled     bool            `gorm:"column:recalled;default:false"`
+	RecalledAt   *time.Time      `gorm:"column:recalled_at"`
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// MentionEntity 群消息中的一个@，Offset和Length按字符(rune)计算，@all时UserID为空
+type MentionEntity struct {
+	UserID   uuid.UUID `json:"user_id"`
+	Username string    `json:"username"`
+	Offset   int       `json:"offset"`
+	Length   int       `json:"length"`
 }
 
 type Conversations struct {
 	ID            uuid.UUID                  `gorm:"primaryKey;type:uuid;default:uuid_generate_v4();column:id"`
 	P2PUser1      uuid.UUID                  `gorm:"not null;column:p2p_user1_id;uniqueIndex:idx_p2p_user1_user2"`
 	P2PUser2      uuid.UUID                  `gorm:"not null;column:p2p_user2_id;uniqueIndex:idx_p2p_user1_user2"`
-	GroupID       uuid.UUID                  `gorm:"column:group_id;uniqueIndex:idx_group_id"`
+	GroupID       *uuid.UUID                 `gorm:"column:group_id;uniqueIndex:idx_group_id"`
 	LastMessageID uuid.UUID                  `gorm:"column:last_message_id;index"`
 	LastMessage   P2PMessages                `gorm:"foreignKey:LastMessageID;references:ID"`
 	Participants  []ConversationParticipants `gorm:"many2many:group_members;joinForeignKey:ConversationID;joinReferences:UserID"`
@@ -41,10 +64,25 @@ type Conversations struct {
 }
 
 type ConversationParticipants struct {
-	ID             uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4();column:id"`
-	ConversationID uuid.UUID `gorm:"not null;column:conversation_id;uniqueIndex:idx_conversation_user"`
-	UserID         uuid.UUID `gorm:"not null;column:user_id;uniqueIndex:idx_conversation_user"`
-	UnreadCount    int       `gorm:"column:unread_count;default:0"`
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
+	ID                 uuid.UUID  `gorm:"primaryKey;type:uuid;default:uuid_generate_v4();column:id"`
+	ConversationID     uuid.UUID  `gorm:"not null;column:conversation_id;uniqueIndex:idx_conversation_user"`
+	UserID             uuid.UUID  `gorm:"not null;column:user_id;uniqueIndex:idx_conversation_user"`
+	UnreadCount        int        `gorm:"column:unread_count;default:0"`
+	MentionUnreadCount int        `gorm:"column:mention_unread_count;default:0"`
+	Muted              bool       `gorm:"column:muted;default:false"`
+	MutedUntil         *time.Time `gorm:"column:muted_until"`
+	Pinned             bool       `gorm:"column:pinned;default:false"`
+	PinOrder           int        `gorm:"column:pin_order;default:0"`
+	Archived           bool       `gorm:"column:archived;default:false"`
+	MarkedUnread       bool       `gorm:"column:marked_unread;default:false"`
+	CreatedAt          time.Time
+	UpdatedAt          time.Time
+}
+
+// IsMuted 免打扰是否生效，MutedUntil为空表示永久免打扰
+func (p *ConversationParticipants) IsMuted(now time.Time) bool {
+	if !p.Muted {
+		return false
+	}
+	return p.MutedUntil == nil || now.Before(*p.MutedUntil)
 }
@@ -0,0 +1,17 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// MessageReactions 用户对消息的表情回应，同一用户对同一消息的同一表情只记录一次
+type MessageReactions struct {
+	ID        uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4();column:id" json:"id"`
+	MessageID uuid.UUID `gorm:"not null;column:message_id;uniqueIndex:idx_reaction_message_user_emoji" json:"message_id"`
+	ChatType  string    `gorm:"not null;column:chat_type" json:"chat_type"`
+	UserID    uuid.UUID `gorm:"not null;column:user_id;uniqueIndex:idx_reaction_message_user_emoji" json:"user_id"`
+	Emoji     string    `gorm:"not null;column:emoji;uniqueIndex:idx_reaction_message_user_emoji" json:"emoji"`
+	CreatedAt time.Time `json:"created_at"`
+}
@@ -0,0 +1,35 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 审核记录的状态
+const (
+	ModerationStatusPending  = "pending"
+	ModerationStatusApproved = "approved"
+	ModerationStatusRemoved  = "removed"
+)
+
+// ModerationRecords 被审核规则拦截(block)或标记(flag)的消息，保存原文供管理员复核。
+// 被拦截的消息没有落库，MessageID为空
+type ModerationRecords struct {
+	ID          uuid.UUID  `gorm:"primaryKey;type:uuid;default:uuid_generate_v4();column:id" json:"id"`
+	MessageID   *uuid.UUID `gorm:"type:uuid;column:message_id;index" json:"message_id,omitempty"`
+	ChatType    string     `gorm:"not null;column:chat_type" json:"chat_type"`
+	SenderID    uuid.UUID  `gorm:"not null;column:sender_id;index" json:"sender_id"`
+	ReceiverID  *uuid.UUID `gorm:"type:uuid;column:receiver_id" json:"receiver_id,omitempty"`
+	GroupID     *uuid.UUID `gorm:"type:uuid;column:group_id" json:"group_id,omitempty"`
+	Content     string     `gorm:"not null;column:content" json:"content"`
+	ContentType int        `gorm:"not null;column:content_type" json:"content_type"`
+	Action      string     `gorm:"not null;column:action;index:idx_moderation_status_action" json:"action"`
+	Rules       string     `gorm:"not null;column:rules" json:"rules"`
+	Reason      string     `gorm:"not null;column:reason" json:"reason"`
+	Status      string     `gorm:"not null;column:status;index:idx_moderation_status_action" json:"status"`
+	ReviewerID  *uuid.UUID `gorm:"type:uuid;column:reviewer_id" json:"reviewer_id,omitempty"`
+	ReviewNote  string     `gorm:"column:review_note" json:"review_note,omitempty"`
+	ReviewedAt  *time.Time `gorm:"column:reviewed_at" json:"reviewed_at,omitempty"`
+	CreatedAt   time.Time  `gorm:"index" json:"created_at"`
+}
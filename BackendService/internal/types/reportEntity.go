@@ -0,0 +1,39 @@
+package types
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// 举报对象
+const (
+	ReportTargetUser    = "user"
+	ReportTargetMessage = "message"
+)
+
+// 举报状态
+const (
+	ReportStatusOpen      = "open"
+	ReportStatusResolved  = "resolved"
+	ReportStatusDismissed = "dismissed"
+)
+
+// Reports 用户对其他用户或消息的举报。举报消息时TargetUserID为消息发送者，
+// Content保存举报时的消息内容，消息之后被编辑或撤回也能看到原文
+type Reports struct {
+	ID             uuid.UUID  `gorm:"primaryKey;type:uuid;default:uuid_generate_v4();column:id" json:"id"`
+	ReporterID     uuid.UUID  `gorm:"not null;column:reporter_id;index" json:"reporter_id"`
+	TargetType     string     `gorm:"not null;column:target_type" json:"target_type"`
+	TargetUserID   uuid.UUID  `gorm:"not null;column:target_user_id;index" json:"target_user_id"`
+	MessageID      *uuid.UUID `gorm:"type:uuid;column:message_id;index" json:"message_id,omitempty"`
+	ChatType       string     `gorm:"column:chat_type" json:"chat_type,omitempty"`
+	Content        string     `gorm:"column:content" json:"content,omitempty"`
+	Reason         string     `gorm:"not null;column:reason" json:"reason"`
+	Details        string     `gorm:"column:details" json:"details,omitempty"`
+	Status         string     `gorm:"not null;column:status;index" json:"status"`
+	ResolverID     *uuid.UUID `gorm:"type:uuid;column:resolver_id" json:"resolver_id,omitempty"`
+	ResolutionNote string     `gorm:"column:resolution_note" json:"resolution_note,omitempty"`
+	ResolvedAt     *time.Time `gorm:"column:resolved_at" json:"resolved_at,omitempty"`
+	CreatedAt      time.Time  `gorm:"index" json:"created_at"`
+}
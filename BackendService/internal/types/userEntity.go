@@ -6,13 +6,29 @@ import (
 	"github.com/google/uuid"
 )
 
+// 用户角色，admin可以处理举报、封禁用户和删除消息
+const (
+	UserRoleUser  = "user"
+	UserRoleAdmin = "admin"
+)
+
+// 用户状态，suspended在SuspendedUntil之后自动恢复，banned需要管理员解除
+const (
+	UserStatusActive    = "active"
+	UserStatusSuspended = "suspended"
+	UserStatusBanned    = "banned"
+)
+
 type Users struct {
-	ID            uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4();column:id" json:"id"`
-	Username      string    `gorm:"unique;column:username" json:"username"`
-	Nickname      string    `gorm:"column:nickname" json:"nickname"`
-	ProfileAvatar string    `gorm:"column:profile_avatar" json:"profile_avatar"`
-	Email         string    `gorm:"unique;column:email" json:"email"`
-	Password      string    `gorm:"column:password" json:"-"`
+	ID             uuid.UUID  `gorm:"primaryKey;type:uuid;default:uuid_generate_v4();column:id" json:"id"`
+	Username       string     `gorm:"unique;column:username" json:"username"`
+	Nickname       string     `gorm:"column:nickname" json:"nickname"`
+	ProfileAvatar  string     `gorm:"column:profile_avatar" json:"profile_avatar"`
+	Email          string     `gorm:"unique;column:email" json:"email"`
+	Password       string     `gorm:"column:password" json:"-"`
+	Role           string     `gorm:"not null;default:user;column:role" json:"role"`
+	Status         string     `gorm:"not null;default:active;column:status" json:"status"`
+	SuspendedUntil *time.Time `gorm:"column:suspended_until" json:"suspended_until,omitempty"`
 	//OauthIdentities []OauthIdentity `gorm:"foreignKey:UserID;references:ID;constraint:OnDelete:CASCADE"`
 	Friends                  []Users                    `gorm:"many2many:friends;joinForeignKey:UserID;joinReferences:FriendID"`
 	Groups                   []Groups                   `gorm:"foreignKey:OwnerID;references:ID;constraint:OnDelete:CASCADE"`
@@ -22,6 +38,23 @@ type Users struct {
 	UpdatedAt                time.Time                  `gorm:"column:updated_at" json:"updated_at"`
 }
 
+// IsAdmin 是否是管理员
+func (u *Users) IsAdmin() bool {
+	return u.Role == UserRoleAdmin
+}
+
+// Restricted 用户在now时是否被封禁或处于禁言期，被限制的用户不能登录和发送消息
+func (u *Users) Restricted(now time.Time) bool {
+	switch u.Status {
+	case UserStatusBanned:
+		return true
+	case UserStatusSuspended:
+		return u.SuspendedUntil == nil || u.SuspendedUntil.After(now)
+	default:
+		return false
+	}
+}
+
 type OauthIdentities struct {
 	ID         uuid.UUID `gorm:"primaryKey;type:uuid;default:uuid_generate_v4();column:id"`
 	UserID     uuid.UUID `gorm:"not null;column:user_id;index"`
@@ -1,31 +1,51 @@
 package user
 
 import (
+	"errors"
 	"log/slog"
 	"net/http"
 	"os"
 	"time"
 
 	"github.com/gin-gonic/gin"
-	"github.com/google/uuid"
 	"github.com/huangrao121/CommunicationApp/BackendService/config/pkg"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/middleware"
+	"github.com/huangrao121/CommunicationApp/BackendService/internal/common/ratelimit"
 	"github.com/huangrao121/CommunicationApp/BackendService/internal/types"
+	"golang.org/x/crypto/bcrypt"
+	"gorm.io/gorm"
 )
 
 type UserHandler struct {
 	userStore *UserStore
+	// loginGuard 登录限流和失败锁定，为nil时不限制
+	loginGuard *ratelimit.LoginGuard
 }
 
-func NewUserHandler(userStore *UserStore) *UserHandler {
-	return &UserHandler{userStore: userStore}
+func NewUserHandler(userStore *UserStore, loginGuard *ratelimit.LoginGuard) *UserHandler {
+	return &UserHandler{userStore: userStore, loginGuard: loginGuard}
 }
 
 func (h *UserHandler) CreateUser(c *gin.Context) {
-	var user types.Users
-	if err := c.ShouldBindJSON(&user); err != nil {
+	var req types.SignupReq
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	hashed, err := bcrypt.GenerateFromPassword([]byte(req.Password), bcrypt.DefaultCost)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	// 角色和状态只能由管理员修改，注册的用户总是普通用户
+	user := types.Users{
+		Username: req.Username,
+		Nickname: req.Nickname,
+		Email:    req.Email,
+		Password: string(hashed),
+		Role:     types.UserRoleUser,
+		Status:   types.UserStatusActive,
+	}
 
 	r := h.userStore.db.Create(&user)
 	if r.Error != nil {
@@ -33,7 +53,7 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 		return
 	}
 
-	slog.Info("User created", "user", user)
+	slog.InfoContext(c.Request.Context(), "User created", "user_id", user.ID)
 
 	token, err := pkg.GenerateJWKToken(&user, nil, os.Getenv("PK_PATH"), time.Hour*24)
 	if err != nil {
@@ -59,21 +79,56 @@ func (h *UserHandler) CreateUser(c *gin.Context) {
 }
 
 func (h *UserHandler) Login(c *gin.Context) {
-	var user types.Users
-	if err := c.ShouldBindJSON(&user); err != nil {
+	var req types.LoginReq
+	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	ctx := c.Request.Context()
 
-	r := h.userStore.db.First(&user, "email = ?", user.Email, "password = ?", user.Password)
-	if r.Error != nil {
+	// 1. IP超过尝试速率或账号在这个IP上已被锁定时直接拒绝，不校验密码
+	ip := c.ClientIP()
+	result, err := h.loginGuard.Check(ctx, ip, req.Email)
+	if err != nil {
+		slog.WarnContext(ctx, "Login rate limit check failed", "error", err)
+	}
+	if !result.Allowed {
+		ratelimit.Abort(c, result.RetryAfter)
+		return
+	}
+
+	// 2. 校验账号密码，失败时记录，同一IP连续失败达到上限后锁定
+	var user types.Users
+	r := h.userStore.db.Where("email = ?", req.Email).First(&user)
+	if r.Error != nil && !errors.Is(r.Error, gorm.ErrRecordNotFound) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": r.Error.Error()})
 		return
 	}
-	if r.RowsAffected == 0 {
+	// 账号不存在和密码错误返回相同的结果，不暴露邮箱是否注册过
+	if r.Error != nil || bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(req.Password)) != nil {
+		lockout, err := h.loginGuard.Failed(ctx, ip, req.Email)
+		if err != nil {
+			slog.WarnContext(ctx, "Failed to record login failure", "error", err)
+		}
+		if lockout > 0 {
+			slog.WarnContext(ctx, "Account locked for client IP after repeated login failures", "email", req.Email, "ip", ip, "lockout", lockout)
+			ratelimit.Abort(c, lockout)
+			return
+		}
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid email or password"})
 		return
 	}
+	if err := h.loginGuard.Succeeded(ctx, ip, req.Email); err != nil {
+		slog.WarnContext(ctx, "Failed to reset login failures", "error", err)
+	}
+	// 3. 被封禁或禁言中的用户不签发token
+	if user.Restricted(time.Now()) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"error":           "Account " + user.Status,
+			"suspended_until": user.SuspendedUntil,
+		})
+		return
+	}
 
 	token, err := pkg.GenerateJWKToken(&user, nil, os.Getenv("PK_PATH"), time.Hour*24)
 	if err != nil {
@@ -97,17 +152,15 @@ func (h *UserHandler) Login(c *gin.Context) {
 	c.JSON(http.StatusOK, loginResp)
 }
 
-// 刷新短时间的mqtt token，用于mqtt连接
+// 刷新短时间的mqtt token，用于mqtt连接，必须放在AuthMiddleware之后
 func (h *UserHandler) RefreshToken(c *gin.Context) {
-	userID, okId := c.Get("user_id")
-	userName, okName := c.Get("user_name")
-	email, okEmail := c.Get("user_email")
-	if !okId || !okName || !okEmail {
+	claims, ok := middleware.CurrentClaims(c)
+	if !ok {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
 		return
 	}
-	acl := getACL(userID.(string))
-	refreshToken, err := pkg.GenerateJWKToken(&types.Users{ID: userID.(uuid.UUID), Username: userName.(string), Email: email.(string)}, acl, os.Getenv("PK_PATH"), time.Minute*15)
+	acl := getACL(claims.ID.String())
+	refreshToken, err := pkg.GenerateJWKToken(&types.Users{ID: claims.ID, Username: claims.Username, Email: claims.Email, Role: claims.Role}, acl, os.Getenv("MQTT_PK_PATH"), time.Minute*15)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return